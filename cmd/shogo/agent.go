@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/cassette"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/tools"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/usi"
+)
+
+// registerAgents registers the providers of the game: the Claude and OpenAI agents given the board
+// tools, the USI engine and the replay of a cassette.
+func registerAgents() {
+	agent.Register("claude", agent.ClaudeProvider(agent.WithClaudeTools(tools.New)))
+	agent.Register("openai", agent.OpenAIProvider(agent.WithOpenAITools(tools.New)))
+	agent.Register("engine", engineProvider)
+	agent.Register("replay", func(env agent.Env) (agent.Agent, error) {
+		path := env("AGENT_REPLAY")
+		if path == "" {
+			return nil, fmt.Errorf("%w: no AGENT_REPLAY found in env", agent.ErrNoCredentials)
+		}
+		return cassette.Open(path)
+	})
+}
+
+// engineProvider returns the agent searching with the USI engine AGENT_ENGINE, a binary or the
+// built-in engine by default.
+func engineProvider(env agent.Env) (agent.Agent, error) {
+	path := env("AGENT_ENGINE")
+	if path == "" {
+		path = builtinEngine
+	}
+	p, closeEngine, err := newMatchPlayer(context.Background(), path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return usi.New(p.Engine, usi.WithShutdown(closeEngine)), nil
+}
+
+// defaultProvider is the provider of the agent unless -agent names one: replay when AGENT_REPLAY
+// names a cassette, else AGENT, else claude.
+func defaultProvider() string {
+	if os.Getenv("AGENT_REPLAY") != "" {
+		return "replay"
+	}
+	if name := os.Getenv("AGENT"); name != "" {
+		return name
+	}
+	return "claude"
+}
+
+// openAgent returns the agent of the provider name, nil for none. Its requests are recorded to the
+// cassette AGENT_RECORD, if set, and cancelled after AGENT_TIMEOUT.
+func openAgent(name string) (agent.Agent, error) {
+	timeout := agent.DefaultTimeout
+	if s := os.Getenv("AGENT_TIMEOUT"); s != "" {
+		var err error
+		if timeout, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid AGENT_TIMEOUT: %w", err)
+		}
+	}
+	a, err := agent.New(name, os.Getenv)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	if path := os.Getenv("AGENT_RECORD"); path != "" {
+		r, err := cassette.NewRecorder(a, path)
+		if err != nil {
+			// The agent may run an engine, it is shut down with the error.
+			if c, ok := a.(io.Closer); ok {
+				_ = c.Close()
+			}
+			return nil, err
+		}
+		a = r
+	}
+	return agent.NewTimeoutAgent(a, timeout), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// analysisUpdate is posted to the event loop when the lines of the analysis change.
+type analysisUpdate struct{}
+
+// analysisStartTimeout bounds the handshake with the engine when the analysis starts.
+const analysisStartTimeout = 10 * time.Second
+
+// analyzer runs the analyze command of the TUI: an engine analysing the board in a panel next
+// to it, restarted whenever the board changes.
+type analyzer struct {
+	gui     *gui.GUI
+	path    string
+	multiPV int
+
+	analysis    *engine.Analysis
+	closeEngine func()
+	// posted is set while an update waits on the event loop, so a chatty engine doesn't flood it.
+	posted atomic.Bool
+}
+
+// newAnalyzer returns an analyzer of the engine at path, or the built-in engine, showing multiPV lines.
+func newAnalyzer(gui *gui.GUI, path string, multiPV int) *analyzer {
+	return &analyzer{gui: gui, path: path, multiPV: multiPV}
+}
+
+// start starts the engine and shows the panel, the analysis begins on the next follow.
+func (a *analyzer) start(multiPV int) error {
+	a.stop()
+	p, closeEngine, err := newMatchPlayer(context.Background(), a.path, "", nil)
+	if err != nil {
+		return err
+	}
+	analysis := engine.NewAnalysis(p.Engine, multiPV)
+	analysis.OnUpdate = a.post
+	ctx, cancel := context.WithTimeout(context.Background(), analysisStartTimeout)
+	defer cancel()
+	if err := analysis.Start(ctx); err != nil {
+		closeEngine()
+		return err
+	}
+	a.analysis, a.closeEngine = analysis, closeEngine
+	a.gui.Analysis = analysis
+	return nil
+}
+
+// stop stops the analysis, shuts the engine down and hides the panel.
+func (a *analyzer) stop() {
+	if a == nil || a.analysis == nil {
+		return
+	}
+	a.analysis.Stop()
+	a.closeEngine()
+	a.analysis, a.closeEngine = nil, nil
+	a.gui.Analysis = nil
+}
+
+// follow restarts the analysis when the board of g changed since it started.
+func (a *analyzer) follow(g *shogi.Game) {
+	if a == nil || a.analysis == nil {
+		return
+	}
+	if a.analysis.Position() != g.Board().String() {
+		a.analysis.Analyze(g)
+	}
+}
+
+func (a *analyzer) post() {
+	if a.posted.Swap(true) {
+		return
+	}
+	_ = (*a.gui.Screen).PostEvent(tcell.NewEventInterrupt(analysisUpdate{}))
+}
+
+// handleAnalysisEvent lets the next update be posted once the panel is rendered.
+func handleAnalysisEvent(a *analyzer, data interface{}) {
+	if _, ok := data.(analysisUpdate); ok && a != nil {
+		a.posted.Store(false)
+	}
+}
+
+// processAnalyzeCmd runs the analyze command:
+//
+//	analyze [lines]    start, or stop, analysing the board with the given number of lines
+//	analyze off        stop analysing
+//
+// It reports false for any other command.
+func processAnalyzeCmd(a *analyzer, cmd string) (string, bool) {
+	verb, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+	if a == nil || verb != "analyze" {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg)
+	multiPV := a.multiPV
+	switch {
+	case arg == "off" || arg == "" && a.analysis != nil:
+		a.stop()
+		return "Analysis stopped.", true
+	case arg != "":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return "⚠ Usage: analyze [lines] | analyze off", true
+		}
+		multiPV = n
+	}
+	if err := a.start(multiPV); err != nil {
+		return fmt.Sprintf("⚠ analyze: %v", err), true
+	}
+	return fmt.Sprintf("Analysing with %s, type analyze off to stop.", a.path), true
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/book"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// runBook builds and inspects opening books in the YaneuraOu format:
+//
+//	shogo book build -o book.db [-maxply 32] [-min 1] <file|dir>...  build a book from CSA records
+//	shogo book show -book book.db [sfen]                             the book moves of a position
+//
+// The built-in engine plays from a book set with -option BookFile=book.db.
+func runBook(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: shogo book build -o file [-maxply n] [-min n] <file|dir>... | show -book file [sfen]")
+	}
+	switch args[0] {
+	case "build":
+		return buildBook(args[1:])
+	case "show":
+		return showBook(args[1:])
+	}
+	return fmt.Errorf("unknown book command %q", args[0])
+}
+
+// buildBook writes the book of the moves played in CSA records.
+func buildBook(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	out := fs.String("o", "book.db", "book file to write")
+	maxPly := fs.Int("maxply", 32, "moves of every game added to the book, 0 for all")
+	minCount := fs.Int("min", 1, "games a move must be played in to be added")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b := book.NewBuilder(book.WithMaxPly(*maxPly), book.WithMinCount(*minCount))
+	added, failed, err := addRecords(b, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	bk := b.Book()
+	if err := bk.WriteFile(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d games, %d errors, %d positions written to %s.\n", added, failed, bk.Len(), *out)
+	return nil
+}
+
+// addRecords adds the .csa files in paths to b, directories recursively, and returns how many
+// were added and how many couldn't be read.
+func addRecords(b *book.Builder, paths []string) (added, failed int, err error) {
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if e.IsDir() || !strings.EqualFold(filepath.Ext(path), ".csa") {
+				return nil
+			}
+			if err := addRecord(b, path); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				failed++
+				return nil
+			}
+			added++
+			return nil
+		})
+		if err != nil {
+			return added, failed, err
+		}
+	}
+	return added, failed, nil
+}
+
+func addRecord(b *book.Builder, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := kifu.ReadCSA(f)
+	if err != nil {
+		return err
+	}
+	return b.Add(r)
+}
+
+// showBook prints the book moves of a position, the starting one by default.
+func showBook(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	path := fs.String("book", "book.db", "book file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	bk, err := book.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	sfen := strings.Join(fs.Args(), " ")
+	if sfen == "" {
+		sfen = shogi.StartingPosition
+	}
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(sfen); err != nil {
+		return err
+	}
+
+	moves := bk.Moves(b)
+	if len(moves) == 0 {
+		fmt.Printf("%s: not in the book\n", b.Position())
+		return nil
+	}
+	fmt.Printf("%s:\n", b.Position())
+	for _, m := range moves {
+		fmt.Printf("  %-6s ponder %-6s %5d cp, depth %d, played %d times\n", m.Move, m.Ponder, m.Value, m.Depth, m.Count)
+	}
+	return nil
+}
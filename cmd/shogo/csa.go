@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/csa"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// runCSA plays games of an engine on a CSA server such as Floodgate:
+//
+//	shogo csa -user name -password pw [-host h] [-p port] [-engine path|builtin] [-games n] [-out dir]
+//
+// To play them yourself, start the TUI with -csa instead.
+func runCSA(args []string) error {
+	fs := flag.NewFlagSet("csa", flag.ExitOnError)
+	host := fs.String("host", "127.0.0.1", "CSA server address")
+	port := fs.Int("p", 4081, "CSA server port")
+	user := fs.String("user", "", "login name")
+	password := fs.String("password", "", "login password")
+	path := fs.String("engine", builtinEngine, "engine binary, or builtin")
+	options := optionFlags{}
+	fs.Var(options, "option", "engine option as name=value, can be repeated")
+	games := fs.Int("games", 1, "number of games to play, 0 to play until interrupted")
+	out := fs.String("out", "", "directory to write the games as CSA files")
+	verbose := fs.Bool("v", false, "log the lines exchanged with the server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *user == "" {
+		return fmt.Errorf("missing -user")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p, closeEngine, err := newMatchPlayer(ctx, *path, "", options)
+	if err != nil {
+		return err
+	}
+	defer closeEngine()
+	player := &csa.EnginePlayer{Engine: p.Engine, Options: p.Options}
+
+	clientOptions := []func(*csa.Client){}
+	if *verbose {
+		clientOptions = append(clientOptions, csa.WithLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+	address := net.JoinHostPort(*host, strconv.Itoa(*port))
+	c, err := csa.Dial(ctx, address, *user, *password, player, clientOptions...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for n := 0; *games == 0 || n < *games; n++ {
+		r, err := c.Play(ctx)
+		if err != nil {
+			return err
+		}
+		rec := r.Record
+		fmt.Printf("Game %s (%s vs %s): %s %s, %d moves\n", rec.Event, rec.Sente, rec.Gote, rec.Result, rec.Termination, len(rec.Moves))
+		if *out != "" {
+			if err := writeCSARecord(filepath.Join(*out, strings.ReplaceAll(rec.Event, "/", "_")+".csa"), rec); err != nil {
+				return err
+			}
+		}
+	}
+	return c.Logout(ctx)
+}
+
+func writeCSARecord(path string, rec kifu.Record) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := kifu.WriteCSA(f, rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Events posted to the event loop of the TUI while playing on a CSA server.
+type (
+	csaStart    struct{ summary csa.Summary }
+	csaTurn     struct{ position csa.Position }
+	csaGameOver struct{ result csa.Result }
+)
+
+// csaSession lets the human in the TUI play on a CSA server. Its events are applied to the game
+// on the event loop; the moves entered there are handed to the client through the move handler.
+type csaSession struct {
+	*csa.HumanPlayer
+	gui     *gui.GUI
+	game    *shogi.Game
+	summary csa.Summary
+	// turn is set while the server waits for a move of the human.
+	turn bool
+}
+
+// NewGame posts the summary to the event loop before accepting the game.
+func (s *csaSession) NewGame(ctx context.Context, summary csa.Summary) error {
+	s.post(csaStart{summary})
+	return s.HumanPlayer.NewGame(ctx, summary)
+}
+
+func (s *csaSession) post(data interface{}) {
+	_ = (*s.gui.Screen).PostEvent(tcell.NewEventInterrupt(data))
+}
+
+// connectCSA logs in to the CSA server at address and plays its games in game, until the
+// connection is lost.
+func connectCSA(gui *gui.GUI, address, user, password string, game *shogi.Game) (*csaSession, error) {
+	s := &csaSession{HumanPlayer: csa.NewHumanPlayer(), gui: gui, game: game}
+	s.OnTurn = func(p csa.Position) { s.post(csaTurn{p}) }
+	s.OnGameOver = func(r csa.Result) { s.post(csaGameOver{r}) }
+
+	ctx := context.Background()
+	c, err := csa.Dial(ctx, address, user, password, s)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer c.Close()
+		for {
+			if _, err := c.Play(ctx); err != nil {
+				s.post(disconnected{err})
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// handleCSAEvent applies an event posted by connectCSA and logs it.
+func handleCSAEvent(gui *gui.GUI, s *csaSession, data interface{}) {
+	var err error
+	switch data := data.(type) {
+	case csaStart:
+		s.summary = data.summary
+		s.turn = false
+		err = s.setPosition(csa.Position{SFEN: data.summary.Position})
+		gui.AppendLog(fmt.Sprintf("Game %s started: %s vs %s", data.summary.GameID, data.summary.Sente, data.summary.Gote))
+	case csaTurn:
+		err = s.setPosition(data.position)
+		s.turn = err == nil
+		if n := len(data.position.Moves); n > 0 {
+			gui.AppendLog(fmt.Sprintf("Opponent played %s", data.position.Moves[n-1]))
+		}
+	case csaGameOver:
+		s.turn = false
+		s.game.End(data.result.Record.Result)
+		gui.AppendLog(fmt.Sprintf("Game over %s (%s)", data.result.Outcome, data.result.Reason))
+	}
+	if err != nil {
+		gui.AppendLog(fmt.Sprintf("shogo error: %v", err))
+	}
+}
+
+// setPosition replaces the game with the one of p, keeping the AI client.
+func (s *csaSession) setPosition(p csa.Position) error {
+	options := []func(*shogi.Game){}
+	if tc := s.summary.TimeControl(); tc.Main > 0 || tc.Byoyomi > 0 || tc.Increment > 0 {
+		options = append(options, shogi.WithClock(tc))
+	}
+	g, err := p.Game(s.summary.Sente, s.summary.Gote, options...)
+	if err != nil {
+		return err
+	}
+	if clock := g.Clock(); clock != nil && !p.Time.Infinite {
+		clock.Set(shogi.Black, p.Time.BTime)
+		clock.Set(shogi.White, p.Time.WTime)
+	}
+	g.SetAIClient(s.game.GetAIClient())
+	g.SetMoveHandler(s.play)
+	*s.game = *g
+	return nil
+}
+
+// play hands a move entered in the TUI to the client.
+func (s *csaSession) play(m shogi.Move) error {
+	if !s.turn {
+		return fmt.Errorf("csa: not your turn")
+	}
+	if !s.game.Board().IsLegal(m) {
+		return fmt.Errorf("csa: illegal move %s", m.USI())
+	}
+	if err := s.Play(m.USI()); err != nil {
+		return err
+	}
+	s.turn = false
+	return nil
+}
+
+// processCSACmd runs the commands only available on a CSA server:
+//
+//	resign, win (entering king declaration)
+//
+// It reports false for any other command.
+func processCSACmd(s *csaSession, cmd string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	var move string
+	switch strings.TrimSpace(cmd) {
+	case "resign":
+		move = csa.MoveResign
+	case "win":
+		move = csa.MoveWin
+	default:
+		return "", false
+	}
+	if !s.turn {
+		return "⚠ csa: not your turn", true
+	}
+	if err := s.Play(move); err != nil {
+		return fmt.Sprintf("⚠ %v", err), true
+	}
+	s.turn = false
+	return strings.Repeat(" ", 80), true
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/db"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// runDB manages the game database:
+//
+//	shogo db [-db path] import <file|dir>...   import CSA records, directories recursively
+//	shogo db [-db path] search [-n 10] [sfen]  games that reached a position and what was played next
+func runDB(args []string) error {
+	fs := flag.NewFlagSet("db", flag.ExitOnError)
+	path := fs.String("db", db.DefaultPath(), "database file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: shogo db [-db path] import <file|dir>... | search [-n 10] [sfen]")
+	}
+
+	d, err := db.Open(*path)
+	if err != nil {
+		return err
+	}
+	switch fs.Arg(0) {
+	case "import":
+		return importRecords(d, fs.Args()[1:])
+	case "search":
+		return searchPosition(d, fs.Args()[1:])
+	}
+	return fmt.Errorf("unknown db command %q", fs.Arg(0))
+}
+
+// importRecords imports the .csa files in paths and saves the database.
+func importRecords(d *db.DB, paths []string) error {
+	imported, skipped, failed := 0, 0, 0
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if e.IsDir() || !strings.EqualFold(filepath.Ext(path), ".csa") {
+				return nil
+			}
+			ok, err := d.ImportFile(path)
+			switch {
+			case err != nil:
+				fmt.Fprintln(os.Stderr, err)
+				failed++
+			case ok:
+				imported++
+			default:
+				skipped++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := d.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d games, %d duplicates, %d errors, %d games in the database.\n", imported, skipped, failed, d.Len())
+	return nil
+}
+
+// searchPosition prints what the database knows about a position, the starting one by default.
+func searchPosition(d *db.DB, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	n := fs.Int("n", 10, "games to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sfen := strings.Join(fs.Args(), " ")
+	if sfen == "" {
+		sfen = shogi.StartingPosition
+	}
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(sfen); err != nil {
+		return err
+	}
+
+	r := d.Search(b)
+	fmt.Printf("%s: %s\n", b.Position(), r.Stats)
+	for _, c := range r.Next {
+		fmt.Printf("  %s\n", c)
+	}
+	for i, o := range r.Occurrences {
+		if i == *n {
+			fmt.Printf("  ... %d more\n", len(r.Occurrences)-*n)
+			break
+		}
+		g, _ := d.Game(o.Game)
+		fmt.Printf("  #%d %s vs %s %s, ply %d, %s\n", g.ID, g.Record.Sente, g.Record.Gote, g.Record.Result, o.Ply, g.Source)
+	}
+	return nil
+}
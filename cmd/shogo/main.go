@@ -1,95 +1,109 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/joho/godotenv"
 	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/book"
+	"github.com/juanpablocruz/shogo/clientr/internal/client"
 	"github.com/juanpablocruz/shogo/clientr/internal/cmd"
 	"github.com/juanpablocruz/shogo/clientr/internal/config"
 	"github.com/juanpablocruz/shogo/clientr/internal/gui"
 	"github.com/juanpablocruz/shogo/clientr/internal/input"
+	"github.com/juanpablocruz/shogo/clientr/internal/player"
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
 	"github.com/juanpablocruz/shogo/clientr/internal/theme"
 )
 
-type Message struct {
-	Action string                 `json:"action"`
-	Data   map[string]interface{} `json:"data"`
-}
-
-func ConnectClient(address string) {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		fmt.Println("Error establishing connection:", err)
-		os.Exit(1)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "match":
+			if err := runMatch(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "stats":
+			if err := runStats(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "db":
+			if err := runDB(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "watch":
+			if err := runWatch(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "csa":
+			if err := runCSA(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "book":
+			if err := runBook(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "review":
+			if err := runReview(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
-	defer conn.Close()
 
-	joinMsg := Message{
-		Action: "join",
-		Data:   make(map[string]interface{}),
+	// notices are logged once the screen is up.
+	notices := []string{}
+	if err := godotenv.Load(); errors.Is(err, fs.ErrNotExist) {
+		notices = append(notices, "No .env file found, the settings are read from the environment.")
+	} else if err != nil {
+		notices = append(notices, fmt.Sprintf("shogo error: .env: %v", err))
 	}
-	data, err := json.Marshal(joinMsg)
-	if err != nil {
-		fmt.Println("Error sending join: ", err)
-		os.Exit(1)
-	}
-
-	conn.Write(data)
-
-	decoder := json.NewDecoder(conn)
 
-	for {
-		var msg Message
-		err := decoder.Decode(&msg)
-		if err != nil {
-			fmt.Println("Error decoding message:", err)
-			os.Exit(1)
-		}
+	config := config.Init()
 
-		fmt.Printf("Received: %s: %v", msg.Action, msg.Data)
+	registerAgents()
+	provider := config.Agent
+	if provider == "" {
+		provider = defaultProvider()
 	}
-}
-
-func main() {
-	err := godotenv.Load()
+	aiClient, err := openAgent(provider)
 	if err != nil {
-		log.Fatal(err)
+		notices = append(notices, fmt.Sprintf("AI features disabled: %v. Type agent <name> to choose an agent.", err))
+		provider = agent.None
 	}
-	var aiClient agent.Agent
-	agentType := os.Getenv("AGENT")
-	if agentType == "openai" {
-		openai_key := os.Getenv("OPENAI_API_KEY")
-
-		if openai_key == "" {
-			log.Fatal("No OPENAI_API_KEY found in env")
-		}
-		aiClient = agent.NewOpenAIAgent(openai_key)
-	} else {
 
-		claude_key := os.Getenv("CLAUDE_API_KEY")
-
-		if claude_key == "" {
-			log.Fatal("No CLAUDE_API_KEY found in env")
-		}
-		aiClient = agent.NewClaudeAgent(claude_key)
-	}
-
-	config := config.Init()
-
-	address := fmt.Sprintf("127.0.0.1:%d", config.Port)
-	fmt.Println("Connecting to ", address)
-
-	gs := *shogi.NewGame(config.SentePlayer, config.GotePlayer,
+	options := []func(*shogi.Game){
 		func(g *shogi.Game) {
 			g.SetAIClient(aiClient)
-		})
+		},
+	}
+	if config.Clock != "" {
+		tc, err := shogi.ParseTimeControl(config.Clock)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, shogi.WithClock(tc))
+	}
+	gs := *shogi.NewGame(config.SentePlayer, config.GotePlayer, options...)
 
 	gui := gui.NewGUI()
 	gui.Theme = theme.ThemeBasic
@@ -103,18 +117,110 @@ func main() {
 	gui.Render(&gs, in)
 
 	gui.AppendLog("Initialized.")
+	for _, n := range notices {
+		gui.AppendLog(n)
+	}
+
+	cmdOptions := []func(*cmd.Commands){cmd.WithAgents(provider, openAgent)}
+	if config.SaveDir != "" {
+		cmdOptions = append(cmdOptions, cmd.WithSaveDir(config.SaveDir))
+	}
+	if config.DB != "" {
+		cmdOptions = append(cmdOptions, cmd.WithDatabase(config.DB))
+	}
+	commands := cmd.New(cmdOptions...)
+
+	if config.Book != "" {
+		b, err := book.ReadFile(config.Book)
+		if err != nil {
+			gui.AppendLog(fmt.Sprintf("shogo error: %v", err))
+		} else {
+			gui.Book = b
+		}
+	}
+	if config.Resume && !config.Online && !config.CSA {
+		if ok, err := commands.ResumeLast(&gs, gui); err != nil {
+			gui.AppendLog(fmt.Sprintf("shogo error: %v", err))
+		} else if !ok {
+			gui.AppendLog("No unfinished game to resume.")
+		}
+		gui.Render(&gs, in)
+	}
+
+	var online *client.Client
+	if config.Online {
+		address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+		gui.AppendLog(fmt.Sprintf("Connecting to %s", address))
+		online, err = connectOnline(gui, address, config.Name, &gs)
+		if err != nil {
+			gui.Quit()
+			log.Fatal(err)
+		}
+		defer online.Close()
+	}
 
+	var remote *csaSession
+	if config.CSA {
+		address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+		gui.AppendLog(fmt.Sprintf("Logging in to %s", address))
+		remote, err = connectCSA(gui, address, config.Name, config.Password, &gs)
+		if err != nil {
+			gui.Quit()
+			log.Fatal(err)
+		}
+	}
+
+	if gs.Clock() != nil || online != nil || remote != nil {
+		go tickClocks(gui)
+	}
+
+	var players *player.Controller
+	if online == nil && remote == nil {
+		var closePlayers func()
+		players, closePlayers, err = newController(gui, config.SentePlayer, config.GotePlayer)
+		if err != nil {
+			gui.Quit()
+			log.Fatal(err)
+		}
+		defer closePlayers()
+		commands.Players = players
+	}
+
+	an := newAnalyzer(gui, config.AnalysisEngine, config.MultiPV)
+	rv := newReviewer(gui, config.AnalysisEngine)
 	for {
-		_ = Interact(gui, in, &gs)
+		if players != nil {
+			players.Follow(&gs)
+		}
+		_ = Interact(gui, in, &gs, commands, online, remote, an, rv, players)
+		an.follow(&gs)
 
 		gui.Render(&gs, in)
 	}
 }
 
-func Interact(gui *gui.GUI, in *input.Input, gs *shogi.Game) bool {
+// explanationScroll is the number of lines PgUp and PgDn scroll the explanation panel.
+const explanationScroll = 6
+
+// tickClocks wakes up the event loop every second so the clocks are rendered while nobody types.
+func tickClocks(gui *gui.GUI) {
+	for range time.Tick(time.Second) {
+		_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(nil))
+	}
+}
+
+func Interact(gui *gui.GUI, in *input.Input, gs *shogi.Game, commands *cmd.Commands, online *client.Client, remote *csaSession, an *analyzer, rv *reviewer, players *player.Controller) bool {
 	rescore := true
 	ev := (*gui.Screen).PollEvent()
 	quit := func() {
+		if online == nil && remote == nil {
+			commands.Autosave(gs, gui)
+		}
+		an.stop()
+		rv.stop()
+		if players != nil {
+			players.Stop()
+		}
 		gui.Quit()
 		os.Exit(0)
 	}
@@ -125,14 +231,36 @@ func Interact(gui *gui.GUI, in *input.Input, gs *shogi.Game) bool {
 	switch ev := ev.(type) {
 	case *tcell.EventKey:
 		switch ev.Key() {
-		case tcell.KeyEscape, tcell.KeyCtrlC:
+		case tcell.KeyEscape:
+			if msg, ok := cancelRequest(commands, gs, rv, players); ok {
+				gui.DrawMsgLabel(fmt.Sprintf("%-80s", msg), gui.Theme)
+			} else {
+				quit()
+			}
+		case tcell.KeyCtrlC:
 			quit()
 		case tcell.KeyEnter:
-			msg, gs = cmd.ProcessCmd(in.Current(), gs, gui, in)
+			var ok bool
+			if msg, ok = processOnlineCmd(online, in.Current()); !ok {
+				msg, ok = processCSACmd(remote, in.Current())
+			}
+			if !ok {
+				msg, ok = processAnalyzeCmd(an, in.Current())
+			}
+			if !ok {
+				msg, ok = processReviewCmd(rv, in.Current(), gs)
+			}
+			if !ok {
+				msg, gs = commands.ProcessCmd(in.Current(), gs, gui, in)
+			}
 			gui.DrawMsgLabel(msg, gui.Theme)
 			in.Clear()
 			gui.Render(gs, in)
 
+		case tcell.KeyPgUp:
+			gui.ScrollExplanation(-explanationScroll)
+		case tcell.KeyPgDn:
+			gui.ScrollExplanation(explanationScroll)
 		case tcell.KeyBackspace, tcell.KeyBackspace2:
 			rescore = false
 			in.Backspace()
@@ -142,6 +270,40 @@ func Interact(gui *gui.GUI, in *input.Input, gs *shogi.Game) bool {
 
 	case *tcell.EventResize:
 		(*gui.Screen).Sync()
+	case *tcell.EventInterrupt:
+		handleOnlineEvent(gui, online, ev.Data())
+		handleCSAEvent(gui, remote, ev.Data())
+		handleAnalysisEvent(an, ev.Data())
+		handleReviewEvent(gui, ev.Data())
+		handlePlayerEvent(gui, players, gs, ev.Data())
+		handleReplyEvent(gui, ev.Data())
 	}
 	return rescore
 }
+
+// cancelRequest cancels the agent request of a command, or else the review of the game, or else
+// the search of the computer player on turn. It returns the message to show and reports whether
+// any was running.
+func cancelRequest(commands *cmd.Commands, gs *shogi.Game, rv *reviewer, players *player.Controller) (string, bool) {
+	if commands.Cancel() {
+		return "Request cancelled.", true
+	}
+	if rv.stop() {
+		return "Review cancelled.", true
+	}
+	if players != nil && players.Cancel() {
+		side := "Sente"
+		if gs.Board().Turn == shogi.White {
+			side = "Gote"
+		}
+		return fmt.Sprintf("⚠ %s's move cancelled, type retry to ask again or play its move.", side), true
+	}
+	return "", false
+}
+
+// handleReplyEvent shows the reply of an agent request started by a command.
+func handleReplyEvent(gui *gui.GUI, data interface{}) {
+	if r, ok := data.(cmd.Reply); ok {
+		gui.DrawMsgLabel(fmt.Sprintf("%-80s", r.Apply()), gui.Theme)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/match"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// builtinEngine is the engine path that selects the built-in engine.
+const builtinEngine = "builtin"
+
+// optionFlags collects repeated name=value flags.
+type optionFlags map[string]string
+
+func (o optionFlags) String() string {
+	parts := []string{}
+	for k, v := range o {
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (o optionFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expecting name=value, got %s", s)
+	}
+	o[name] = value
+	return nil
+}
+
+// runMatch plays a match between two engines:
+//
+//	shogo match -engine1 <path|builtin> -engine2 <path|builtin> [-games n] [-time d] [-byoyomi d] ...
+func runMatch(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ExitOnError)
+	engine1 := fs.String("engine1", builtinEngine, "first engine binary, or builtin")
+	engine2 := fs.String("engine2", builtinEngine, "second engine binary, or builtin")
+	name1 := fs.String("name1", "", "first engine name, defaults to its id")
+	name2 := fs.String("name2", "", "second engine name, defaults to its id")
+	options1, options2 := optionFlags{}, optionFlags{}
+	fs.Var(options1, "option1", "option of the first engine as name=value, can be repeated")
+	fs.Var(options2, "option2", "option of the second engine as name=value, can be repeated")
+	games := fs.Int("games", 2, "number of games, colours alternate every game")
+	mainTime := fs.Duration("time", 0, "main time of each player")
+	byoyomi := fs.Duration("byoyomi", time.Second, "time per move once the main time is used")
+	increment := fs.Duration("inc", 0, "time added after every move")
+	margin := fs.Duration("margin", 100*time.Millisecond, "time an engine can overstep its clock")
+	openings := fs.String("openings", "", "file with one start position SFEN per line")
+	maxPlies := fs.Int("maxplies", 320, "plies after which the game is a draw, 0 for no limit")
+	resignScore := fs.Int("resign-score", 3000, "score in centipawns to adjudicate a loss")
+	resignMoves := fs.Int("resign-moves", 0, "moves both engines must agree on the resign score, 0 disables it")
+	drawScore := fs.Int("draw-score", 10, "score in centipawns to adjudicate a draw")
+	drawMoves := fs.Int("draw-moves", 0, "moves both engines must agree on the draw score, 0 disables it")
+	drawMinPly := fs.Int("draw-minply", 80, "plies played before a draw can be adjudicated")
+	event := fs.String("event", "shogo match", "event name written in the records")
+	out := fs.String("out", "", "directory to write the games as CSA files")
+	sprt := sprtFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	test, err := sprt()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	first, closeFirst, err := newMatchPlayer(ctx, *engine1, *name1, options1)
+	if err != nil {
+		return err
+	}
+	defer closeFirst()
+	second, closeSecond, err := newMatchPlayer(ctx, *engine2, *name2, options2)
+	if err != nil {
+		return err
+	}
+	defer closeSecond()
+
+	var positions []string
+	if *openings != "" {
+		f, err := os.Open(*openings)
+		if err != nil {
+			return err
+		}
+		positions, err = match.ReadOpenings(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	options := []func(*match.Match){
+		match.WithGames(*games),
+		match.WithOpenings(positions),
+		match.WithTimeControl(match.TimeControl{Time: *mainTime, Byoyomi: *byoyomi, Increment: *increment, Margin: *margin}),
+		match.WithAdjudication(match.Adjudication{
+			MaxPlies:    *maxPlies,
+			ResignScore: *resignScore,
+			ResignMoves: *resignMoves,
+			DrawScore:   *drawScore,
+			DrawMoves:   *drawMoves,
+			DrawMinPly:  *drawMinPly,
+		}),
+		match.WithEvent(*event),
+		match.WithOutDir(*out),
+	}
+	if test != nil {
+		options = append(options, match.WithSPRT(*test))
+	}
+	m := match.New(first, second, options...)
+	m.OnGame = func(g match.GameResult) {
+		fmt.Printf("Game %d (%s vs %s): %s %s, %d moves\n", g.Number, g.Record.Sente, g.Record.Gote, g.Record.Result, g.Record.Termination, len(g.Record.Moves))
+	}
+
+	summary, err := m.Run(ctx)
+	fmt.Println(summary)
+	printStats(os.Stdout, summary.WLD(), summary.Pentanomial, test)
+	return err
+}
+
+// newMatchPlayer starts the engine at path, or the built-in engine, and returns a function to shut it down.
+func newMatchPlayer(ctx context.Context, path, name string, options map[string]string) (*match.Player, func(), error) {
+	if path == builtinEngine {
+		le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+		ctx, cancel := context.WithCancel(ctx)
+		go le.Run(ctx)
+		if name == "" {
+			name = builtinEngine
+		}
+		return &match.Player{Name: name, Engine: engine.NewGUIEngine(le), Options: options}, cancel, nil
+	}
+
+	pe, err := engine.NewProcessEngine(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &match.Player{Name: name, Engine: engine.NewGUIEngine(pe), Options: options}, func() { _ = pe.Close() }, nil
+}
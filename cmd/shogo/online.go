@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/client"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// disconnected is posted to the event loop once the connection to the server is lost.
+type disconnected struct {
+	err error
+}
+
+// reconnect is posted to the event loop to try to resume the game after the connection was lost.
+type reconnect struct {
+	attempt int
+}
+
+// Reconnection attempts, every reconnectDelay, before giving up on the game.
+const (
+	reconnectAttempts = 30
+	reconnectDelay    = 2 * time.Second
+)
+
+// connectOnline joins the server at address and forwards its messages to the event loop of
+// gui, where they are applied to game.
+func connectOnline(gui *gui.GUI, address, name string, game *shogi.Game) (*client.Client, error) {
+	ctx := context.Background()
+	c, err := client.Dial(ctx, address, game, client.WithName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	forwardEvents(ctx, gui, c)
+	return c, nil
+}
+
+// forwardEvents runs c and posts the messages it receives to the event loop of gui.
+func forwardEvents(ctx context.Context, gui *gui.GUI, c *client.Client) {
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	go func() {
+		for p := range c.Events() {
+			_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(p))
+		}
+		_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(disconnected{<-done}))
+	}()
+}
+
+// handleOnlineEvent applies a message posted by connectOnline and logs it.
+func handleOnlineEvent(gui *gui.GUI, c *client.Client, data interface{}) {
+	switch data := data.(type) {
+	case disconnected:
+		if c != nil && c.Playing() && c.Token() != "" {
+			gui.AppendLog("Connection lost, reconnecting...")
+			scheduleReconnect(gui, 1)
+			return
+		}
+		gui.AppendLog(fmt.Sprintf("Disconnected from server: %v", data.err))
+	case reconnect:
+		ctx, cancel := context.WithTimeout(context.Background(), reconnectDelay)
+		defer cancel()
+		if err := c.Reconnect(ctx); err != nil {
+			if data.attempt >= reconnectAttempts {
+				gui.AppendLog(fmt.Sprintf("Couldn't reconnect: %v", err))
+				return
+			}
+			scheduleReconnect(gui, data.attempt+1)
+			return
+		}
+		forwardEvents(context.Background(), gui, c)
+	case protocol.Payload:
+		if err := c.Handle(data); err != nil {
+			gui.AppendLog(fmt.Sprintf("shogo error: %v", err))
+			return
+		}
+		switch p := data.(type) {
+		case protocol.Start:
+			gui.AppendLog(fmt.Sprintf("Game %s started: %s vs %s", p.ID, p.Sente, p.Gote))
+		case protocol.History:
+			if c.Watching() {
+				gui.AppendLog(fmt.Sprintf("Watching game %s: %s vs %s", p.ID, p.Sente, p.Gote))
+			} else {
+				gui.AppendLog(fmt.Sprintf("Resumed game %s", p.ID))
+			}
+		case protocol.Presence:
+			if p.Connected {
+				gui.AppendLog(fmt.Sprintf("%s is back", p.Name))
+			} else {
+				grace := time.Duration(p.Grace) * time.Millisecond
+				gui.AppendLog(fmt.Sprintf("%s lost the connection, clocks stopped for up to %s", p.Name, grace))
+			}
+		case protocol.Move:
+			if c.Watching() {
+				gui.AppendLog(fmt.Sprintf("%d. %s", p.Ply, p.Move))
+			} else {
+				gui.AppendLog(fmt.Sprintf("Opponent played %s", p.Move))
+			}
+		case protocol.DrawOffer:
+			gui.AppendLog("Draw offered, type accept to take it")
+		case protocol.Chat:
+			gui.AppendLog(fmt.Sprintf("%s: %s", p.From, p.Text))
+		case protocol.GameOver:
+			gui.AppendLog(fmt.Sprintf("Game over %s (%s)", p.Result, p.Reason))
+		case protocol.Error:
+			gui.AppendLog(fmt.Sprintf("Server error: %s", p.Message))
+		}
+	}
+}
+
+// scheduleReconnect posts a reconnection attempt to the event loop after reconnectDelay.
+func scheduleReconnect(gui *gui.GUI, attempt int) {
+	time.AfterFunc(reconnectDelay, func() {
+		_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(reconnect{attempt}))
+	})
+}
+
+// processOnlineCmd runs the commands only available online:
+//
+//	resign, draw, accept, say <text>
+//
+// It reports false for any other command.
+func processOnlineCmd(c *client.Client, cmd string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	cmd = strings.TrimSpace(cmd)
+	verb, text, _ := strings.Cut(cmd, " ")
+
+	var err error
+	switch verb {
+	case "resign":
+		err = c.Resign()
+	case "draw":
+		err = c.OfferDraw()
+	case "accept":
+		err = c.AcceptDraw()
+	case "say":
+		err = c.Chat(text)
+	default:
+		return "", false
+	}
+	if err != nil {
+		return fmt.Sprintf("⚠ %v", err), true
+	}
+	return strings.Repeat(" ", 80), true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/player"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// computerTurn is posted to the event loop when a computer player chose its move.
+type computerTurn struct {
+	turn player.Turn
+}
+
+// agentLevels are the levels a cpu player can be given, as in -gote cpu:pro.
+var agentLevels = map[string]agent.AgentLevel{
+	"beginner": agent.Begginer,
+	"medium":   agent.Medium,
+	"pro":      agent.Pro,
+}
+
+// newMover returns the computer player described by spec, nil for a human:
+//
+//	human            typed on the keyboard
+//	cpu[:level]      the AI agent of the game, at beginner, medium or pro level, medium by default
+//	engine[:path]    the USI engine binary at path, or the built-in engine
+//
+// The returned function shuts the player down.
+func newMover(spec string) (player.Mover, func(), error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "human":
+		return nil, func() {}, nil
+	case "cpu":
+		level := agent.Medium
+		if arg != "" {
+			l, ok := agentLevels[arg]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown cpu level %s, expecting beginner, medium or pro", arg)
+			}
+			level = l
+		}
+		return player.NewAgent(level), func() {}, nil
+	case "engine":
+		if arg == "" {
+			arg = builtinEngine
+		}
+		p, closeEngine, err := newMatchPlayer(context.Background(), arg, "", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return player.NewEngine(p.Engine), closeEngine, nil
+	}
+	return nil, nil, fmt.Errorf("unknown player %s, expecting human, cpu[:level] or engine[:path]", spec)
+}
+
+// newController returns the controller of the computer players among sente and gote, posting
+// their moves to the event loop, and a function to shut them down.
+func newController(gui *gui.GUI, sente, gote string) (*player.Controller, func(), error) {
+	movers := map[shogi.Color]player.Mover{}
+	closers := []func(){}
+	closeAll := func() {
+		for _, f := range closers {
+			f()
+		}
+	}
+	for color, spec := range map[shogi.Color]string{shogi.Black: sente, shogi.White: gote} {
+		m, closeMover, err := newMover(spec)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, closeMover)
+		if m != nil {
+			movers[color] = m
+		}
+	}
+	c := player.NewController(movers)
+	c.OnTurn = func(t player.Turn) {
+		_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(computerTurn{t}))
+	}
+	return c, func() {
+		c.Stop()
+		closeAll()
+	}, nil
+}
+
+// handlePlayerEvent plays the move of a computer player on g.
+func handlePlayerEvent(gui *gui.GUI, c *player.Controller, g *shogi.Game, data interface{}) {
+	ct, ok := data.(computerTurn)
+	if !ok || c == nil {
+		return
+	}
+	t := ct.turn
+	side := "Sente"
+	if t.Color == shogi.White {
+		side = "Gote"
+	}
+	notation := shogi.Notation{Board: g.Board().Clone()}
+	played, err := c.Play(g, t)
+	switch {
+	case errors.Is(err, player.ErrNoAgent):
+		gui.DrawMsgLabel(fmt.Sprintf("⚠ %s has no AI agent, type agent <name> to choose one or play its move.", side), gui.Theme)
+	case err != nil:
+		gui.AppendLog(fmt.Sprintf("shogo error: %s player: %v", side, err))
+		gui.DrawMsgLabel(fmt.Sprintf("⚠ %s didn't move, type retry to ask again or play its move.", side), gui.Theme)
+	case !played:
+	case errors.Is(t.Err, player.ErrResign):
+		gui.AppendLog(fmt.Sprintf("%s resigns, game over %s.", side, g.Outcome()))
+	case errors.Is(t.Err, player.ErrWin):
+		gui.AppendLog(fmt.Sprintf("%s declares an entering king win, game over %s.", side, g.Outcome()))
+	default:
+		gui.AppendLog(fmt.Sprintf("%s plays %s -> %s", side, notation.EncodeMovement(t.Move), g.Board().String()))
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/review"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// runReview reviews a game with an engine and writes it annotated:
+//
+//	shogo review [-engine path|builtin] [-movetime 1s] [-depth n] [-o annotated.csa] <kifu.csa>
+func runReview(args []string) error {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	path := fs.String("engine", builtinEngine, "engine binary, or builtin")
+	options := optionFlags{}
+	fs.Var(options, "option", "engine option as name=value, can be repeated")
+	moveTime := fs.Duration("movetime", time.Second, "time the engine searches every position")
+	depth := fs.Int("depth", 0, "depth the engine searches every position, instead of -movetime")
+	out := fs.String("o", "", "file to write the annotated game to in CSA format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: shogo review [-engine path|builtin] [-movetime d] [-depth n] [-o file] <kifu.csa>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rec, err := kifu.ReadCSA(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	p, closeEngine, err := newMatchPlayer(ctx, *path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer closeEngine()
+
+	params := engine.GoParams{MoveTime: *moveTime}
+	if *depth > 0 {
+		params = engine.GoParams{Depth: *depth}
+	}
+	r := review.New(p.Engine, review.WithSearch(params), review.WithOptions(options))
+	r.OnProgress = func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rReviewing %d/%d", done, total)
+	}
+	rv, err := r.Review(ctx, rec)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range rv.Flagged() {
+		fmt.Println(line)
+	}
+	fmt.Println(rv.Sente)
+	fmt.Println(rv.Gote)
+	if *out == "" {
+		return nil
+	}
+	annotated, err := rv.Annotate(rec)
+	if err != nil {
+		return err
+	}
+	return writeCSARecord(*out, annotated)
+}
+
+// reviewDone is posted to the event loop when the review started by the review command ends.
+type reviewDone struct {
+	review review.Review
+	err    error
+}
+
+// reviewer runs the review command of the TUI: a review of the game in the background, cancelled
+// by Escape or when quitting so its engine is always shut down.
+type reviewer struct {
+	gui  *gui.GUI
+	path string
+	// cancel cancels the review running and done is closed once it returned, nil when none runs.
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newReviewer returns a reviewer with the engine at path, or the built-in engine.
+func newReviewer(gui *gui.GUI, path string) *reviewer {
+	return &reviewer{gui: gui, path: path}
+}
+
+// processReviewCmd runs the review command, reviewing the game with the engine of r. It reports
+// false for any other command.
+func processReviewCmd(r *reviewer, cmd string, g *shogi.Game) (string, bool) {
+	if r == nil || strings.TrimSpace(cmd) != "review" {
+		return "", false
+	}
+	return r.start(g), true
+}
+
+// start reviews the game in the background, replacing the review running, the result is logged
+// once posted back to the event loop.
+func (r *reviewer) start(g *shogi.Game) string {
+	rec := kifu.GameRecord(g)
+	if len(rec.Moves) == 0 {
+		return "⚠ No moves to review."
+	}
+	r.stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	go func() {
+		defer close(done)
+		var rv review.Review
+		p, closeEngine, err := newMatchPlayer(ctx, r.path, "", nil)
+		if err == nil {
+			defer closeEngine()
+			rv, err = review.New(p.Engine, review.WithSearch(engine.GoParams{MoveTime: 500 * time.Millisecond})).Review(ctx, rec)
+		}
+		// A review cancelled posts nothing.
+		if ctx.Err() == nil {
+			_ = (*r.gui.Screen).PostEvent(tcell.NewEventInterrupt(reviewDone{rv, err}))
+		}
+	}()
+	return fmt.Sprintf("Reviewing %d moves with %s, press Escape to cancel...", len(rec.Moves), r.path)
+}
+
+// stop cancels the review running and waits for its engine to shut down. It reports whether a
+// review was running.
+func (r *reviewer) stop() bool {
+	if r == nil || r.done == nil {
+		return false
+	}
+	r.cancel()
+	running := true
+	select {
+	case <-r.done:
+		running = false
+	default:
+	}
+	<-r.done
+	r.cancel, r.done = nil, nil
+	return running
+}
+
+// handleReviewEvent logs the moves flagged by a review and the summary of both players.
+func handleReviewEvent(gui *gui.GUI, data interface{}) {
+	done, ok := data.(reviewDone)
+	if !ok {
+		return
+	}
+	if done.err != nil {
+		gui.AppendLog(fmt.Sprintf("shogo error: review: %v", done.err))
+		return
+	}
+	for _, line := range done.review.Flagged() {
+		gui.AppendLog(line)
+	}
+	gui.AppendLog(done.review.Sente.String())
+	gui.AppendLog(done.review.Gote.String())
+}
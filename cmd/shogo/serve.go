@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/server"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// runServe hosts games for the clients started with -online:
+//
+//	shogo serve [-p port] [-clock tc] [-position sfen] [-grace d]
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.Int("p", 8080, "port to listen on")
+	clock := fs.String("clock", "", "time control of the games, e.g. 10m+30s, empty for no clock")
+	position := fs.String("position", shogi.StartingPosition, "SFEN the games start from")
+	grace := fs.Duration("grace", 30*time.Second, "time a disconnected player has to resume its game, 0 loses it at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	options := []func(*server.Server){
+		server.WithPosition(*position),
+		server.WithReconnectGrace(*grace),
+		server.WithLogger(log.New(os.Stderr, "shogo: ", log.LstdFlags)),
+	}
+	if *clock != "" {
+		tc, err := shogi.ParseTimeControl(*clock)
+		if err != nil {
+			return err
+		}
+		options = append(options, server.WithTimeControl(tc))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return server.New(options...).ListenAndServe(ctx, fmt.Sprintf(":%d", *port))
+}
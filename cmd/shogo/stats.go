@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+	"github.com/juanpablocruz/shogo/clientr/internal/stats"
+)
+
+// sprtFlags registers the SPRT flags on fs, the returned function gives the test once the flags
+// are parsed, nil if it wasn't enabled with -elo1.
+func sprtFlags(fs *flag.FlagSet) func() (*stats.SPRT, error) {
+	elo0 := fs.Float64("elo0", 0, "SPRT Elo difference of H0")
+	elo1 := fs.Float64("elo1", 0, "SPRT Elo difference of H1, the SPRT runs when it is set")
+	alpha := fs.Float64("alpha", 0.05, "SPRT probability of accepting H1 when H0 holds")
+	beta := fs.Float64("beta", 0.05, "SPRT probability of accepting H0 when H1 holds")
+	return func() (*stats.SPRT, error) {
+		if *elo1 == 0 {
+			return nil, nil
+		}
+		sprt := stats.SPRT{Elo0: *elo0, Elo1: *elo1, Alpha: *alpha, Beta: *beta}
+		if err := sprt.Validate(); err != nil {
+			return nil, err
+		}
+		return &sprt, nil
+	}
+}
+
+// printStats writes the Elo estimate of the results, from the pairs when there are any, and the SPRT state.
+func printStats(w io.Writer, wld stats.WLD, penta stats.Pentanomial, sprt *stats.SPRT) {
+	fmt.Fprintf(w, "Games: %d, W/L/D: %s, %s\n", wld.Games(), wld, stats.NewEstimate(wld))
+	if penta.Pairs() == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Pairs: %d, pentanomial: %s, %s\n", penta.Pairs(), penta, stats.NewEstimate(penta))
+	if sprt != nil {
+		llr, result := sprt.Test(penta)
+		fmt.Fprintf(w, "%s: LLR %.2f, %s\n", sprt, llr, result)
+	}
+}
+
+// runStats reports the statistics of finished games written by `shogo match`:
+//
+//	shogo stats [-player name] [-elo0 x -elo1 y] <file.csa|dir>...
+//
+// Consecutive games from the same start position with swapped colours are counted as pairs.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	player := fs.String("player", "", "player the results are counted for, defaults to sente of the first game")
+	sprt := sprtFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	test, err := sprt()
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("stats: expecting result files or directories")
+	}
+
+	records, err := readRecords(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("stats: no games found")
+	}
+	if *player == "" {
+		*player = records[0].Sente
+	}
+
+	var wld stats.WLD
+	var penta stats.Pentanomial
+	scores := make([]float64, len(records))
+	for i, r := range records {
+		score, err := playerScore(r, *player)
+		if err != nil {
+			return err
+		}
+		scores[i] = score
+		wld.Add(score)
+	}
+	for i := 0; i+1 < len(records); i++ {
+		a, b := records[i], records[i+1]
+		if a.StartPosition == b.StartPosition && a.Sente == b.Gote && a.Gote == b.Sente {
+			penta.Add(scores[i], scores[i+1])
+			i++
+		}
+	}
+
+	fmt.Printf("Results of %s\n", *player)
+	printStats(os.Stdout, wld, penta, test)
+	return nil
+}
+
+// readRecords reads the CSA files at paths, directories are read in name order.
+func readRecords(paths []string) ([]kifu.Record, error) {
+	files := []string{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*.csa"))
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(matches)
+		files = append(files, matches...)
+	}
+
+	records := make([]kifu.Record, 0, len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		r, err := kifu.ReadCSA(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if r.Result == shogi.NoOutcome {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// playerScore returns the points player got in the game.
+func playerScore(r kifu.Record, player string) (float64, error) {
+	var win shogi.Outcome
+	switch {
+	case strings.EqualFold(r.Sente, player):
+		win = shogi.BlackWon
+	case strings.EqualFold(r.Gote, player):
+		win = shogi.WhiteWon
+	default:
+		return 0, fmt.Errorf("stats: %s didn't play %s vs %s", player, r.Sente, r.Gote)
+	}
+
+	switch r.Result {
+	case shogi.Draw:
+		return 0.5, nil
+	case win:
+		return 1, nil
+	}
+	return 0, nil
+}
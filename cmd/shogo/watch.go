@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/client"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+	"github.com/juanpablocruz/shogo/clientr/internal/theme"
+)
+
+// runWatch follows a game of a shogo server as a spectator:
+//
+//	shogo watch [-host h] [-p port] [game-id]
+//
+// Without a game id it lists the games in progress.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	host := fs.String("host", "127.0.0.1", "server address")
+	port := fs.Int("p", 8080, "server port")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	game := shogi.NewGame("sente", "gote")
+	c, err := client.Connect(ctx, net.JoinHostPort(*host, strconv.Itoa(*port)), game)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if fs.NArg() == 0 {
+		return listGames(ctx, c)
+	}
+	if err := c.Watch(fs.Arg(0)); err != nil {
+		return err
+	}
+
+	gui := gui.NewGUI()
+	gui.Theme = theme.ThemeBasic
+	defer gui.Quit()
+	gui.AppendLog("Press q or Esc to quit.")
+	forwardEvents(ctx, gui, c)
+	go tickClocks(gui)
+
+	for {
+		gui.Render(game, nil)
+		switch ev := (*gui.Screen).PollEvent().(type) {
+		case *tcell.EventKey:
+			if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
+				return nil
+			}
+		case *tcell.EventResize:
+			(*gui.Screen).Sync()
+		case *tcell.EventInterrupt:
+			handleOnlineEvent(gui, c, ev.Data())
+		}
+	}
+}
+
+// listGames prints the games in progress on the server of c.
+func listGames(ctx context.Context, c *client.Client) error {
+	if err := c.Watch(""); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	for p := range c.Events() {
+		switch p := p.(type) {
+		case protocol.Games:
+			if len(p.Games) == 0 {
+				fmt.Println("No games in progress.")
+			}
+			for _, g := range p.Games {
+				fmt.Printf("%-6s %s vs %s, %d moves\n", g.ID, g.Sente, g.Gote, g.Ply)
+			}
+			return nil
+		case protocol.Error:
+			return fmt.Errorf("server error: %s", p.Message)
+		}
+	}
+	return <-done
+}
@@ -1,5 +1,11 @@
 package agent
 
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
 type AgentLevel int8
 
 const (
@@ -8,7 +14,106 @@ const (
 	Pro
 )
 
+// Side is a player of the game.
+type Side string
+
+const (
+	Sente Side = "sente"
+	Gote  Side = "gote"
+)
+
+// Request is the position an agent is asked a move for.
+type Request struct {
+	SFEN string
+	// Moves are the moves played to reach the position in USI, oldest first.
+	Moves []string
+	// Side is the player to move.
+	Side Side
+	// Level is the strength of the player asked by AskMovement.
+	Level AgentLevel
+	// Feedback is added to the prompt, e.g. why the previous reply was rejected.
+	Feedback string
+}
+
+// usiNotation ends the system prompts asking for a move: the moves are written in USI, as the
+// moves played are sent and the replies are validated.
+const usiNotation = " Write the move in USI notation, the notation of the moves played: the origin and destination " +
+	"squares, files numbered 1 to 9 from right to left as seen by sente and ranks a to i from top to bottom, such as " +
+	"7g7f, followed by + when the piece promotes, such as 8h2b+, or the piece dropped from the hand, an asterisk and " +
+	"the square, such as P*5e."
+
+// String returns how the system prompts describe a player of the level, e.g. professional.
+func (l AgentLevel) String() string {
+	switch l {
+	case Pro:
+		return "professional"
+	case Begginer:
+		return "beginner"
+	}
+	return "medium level"
+}
+
+// hintSystem is the system prompt of AskHint, sent by every provider.
+const hintSystem = "You are a shogi tutor. You will receive a SFEN string representing a shogi game. Respond ONLY " +
+	"with a valid JSON object that strictly adheres to the following schema: { \"next_move\": string } where the " +
+	"value is the suggested movement for the current player. Do not include any extra text or explanation." + usiNotation
+
+// movementSystem returns the system prompt of AskMovement for a player of level, sent by every
+// provider.
+func movementSystem(level AgentLevel) string {
+	return fmt.Sprintf("You are a %s shogi player. You will receive a SFEN string representing a shogi game. Respond "+
+		"ONLY with a valid JSON object that strictly adheres to the following schema: { \"next_move\": string } where "+
+		"the value is the next movement for the current player according to your level. Do not include any extra text "+
+		"or explanation.", level) + usiNotation
+}
+
+// Prompt is the message sent to the model: the SFEN first, then the side to move, the moves played
+// and the feedback.
+func (r Request) Prompt() string {
+	lines := []string{r.SFEN}
+	if r.Side != "" {
+		lines = append(lines, fmt.Sprintf("Side to move: %s", r.Side))
+	}
+	if len(r.Moves) > 0 {
+		lines = append(lines, fmt.Sprintf("Moves played (USI): %s", strings.Join(r.Moves, " ")))
+	}
+	if r.Feedback != "" {
+		lines = append(lines, "", r.Feedback)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Agent answers requests with a model. Every call returns when ctx is done, with its error.
 type Agent interface {
-	AskHint(string) (string, error)
-	AskMovement(string, AgentLevel) (string, error)
+	AskHint(ctx context.Context, r Request) (string, error)
+	AskMovement(ctx context.Context, r Request) (string, error)
+	// Chat returns the plain text answer to the last of messages, a conversation following the
+	// system prompt.
+	Chat(ctx context.Context, system string, messages []Message) (string, error)
+}
+
+// Unwrap returns the agent asked by a, through the agents wrapping another one such as a
+// TimeoutAgent.
+func Unwrap(a Agent) Agent {
+	for {
+		w, ok := a.(interface{ Agent() Agent })
+		if !ok {
+			return a
+		}
+		a = w.Agent()
+	}
+}
+
+// Role is who wrote a message of a conversation.
+type Role string
+
+const (
+	UserRole      Role = "user"
+	AssistantRole Role = "assistant"
+)
+
+// Message is a message of a conversation with an agent.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
 }
@@ -0,0 +1,232 @@
+// Package cassette records the requests sent to an agent and its responses to a file, and replays
+// them without the agent, for offline tests and reproducible bug reports.
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+)
+
+// ErrNotRecorded is returned by a Replayer asked a request that isn't in its cassette.
+var ErrNotRecorded = errors.New("cassette: request not recorded")
+
+// Kind is the method of the agent called.
+type Kind string
+
+const (
+	Hint     Kind = "hint"
+	Movement Kind = "movement"
+	Chat     Kind = "chat"
+)
+
+// Request is a call to an agent, the fields of the agent.Request of a hint or a movement or the
+// conversation of a chat.
+type Request struct {
+	Kind  Kind       `json:"kind"`
+	SFEN  string     `json:"sfen,omitempty"`
+	Moves []string   `json:"moves,omitempty"`
+	Side  agent.Side `json:"side,omitempty"`
+	// Level is the level of a movement: beginner, medium or pro.
+	Level    string          `json:"level,omitempty"`
+	Feedback string          `json:"feedback,omitempty"`
+	System   string          `json:"system,omitempty"`
+	Messages []agent.Message `json:"messages,omitempty"`
+}
+
+// Interaction is a request and the response of the agent.
+type Interaction struct {
+	Request  Request `json:"request"`
+	Response string  `json:"response"`
+	// Error is the error returned instead of a response.
+	Error string `json:"error,omitempty"`
+}
+
+// Cassette is the interactions with an agent, in the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads the cassette at path.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cassette: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	return nil
+}
+
+// levels name the agent levels in cassettes.
+var levels = map[agent.AgentLevel]string{agent.Begginer: "beginner", agent.Medium: "medium", agent.Pro: "pro"}
+
+func hintRequest(r agent.Request) Request {
+	return Request{Kind: Hint, SFEN: r.SFEN, Moves: slices.Clone(r.Moves), Side: r.Side, Feedback: r.Feedback}
+}
+
+func movementRequest(r agent.Request) Request {
+	req := hintRequest(r)
+	req.Kind, req.Level = Movement, levels[r.Level]
+	return req
+}
+
+func chatRequest(system string, messages []agent.Message) Request {
+	return Request{Kind: Chat, System: system, Messages: slices.Clone(messages)}
+}
+
+func (r Request) equal(o Request) bool {
+	return r.Kind == o.Kind && r.SFEN == o.SFEN && slices.Equal(r.Moves, o.Moves) && r.Side == o.Side &&
+		r.Level == o.Level && r.Feedback == o.Feedback && r.System == o.System && slices.Equal(r.Messages, o.Messages)
+}
+
+// Recorder is an agent asking another one and saving every interaction to its cassette file as
+// soon as it happens, so a session that crashes is recorded up to the crash. The calls cancelled
+// or timed out are not saved.
+type Recorder struct {
+	agent agent.Agent
+	path  string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns an agent asking a and recording to the cassette at path. The interactions
+// already recorded there are kept.
+func NewRecorder(a agent.Agent, path string) (*Recorder, error) {
+	r := &Recorder{agent: a, path: path}
+	if c, err := Load(path); err == nil {
+		r.cassette = *c
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Agent returns the agent recorded.
+func (r *Recorder) Agent() agent.Agent {
+	return r.agent
+}
+
+func (r *Recorder) AskHint(ctx context.Context, req agent.Request) (string, error) {
+	response, err := r.agent.AskHint(ctx, req)
+	return response, r.record(ctx, hintRequest(req), response, err)
+}
+
+func (r *Recorder) AskMovement(ctx context.Context, req agent.Request) (string, error) {
+	response, err := r.agent.AskMovement(ctx, req)
+	return response, r.record(ctx, movementRequest(req), response, err)
+}
+
+func (r *Recorder) Chat(ctx context.Context, system string, messages []agent.Message) (string, error) {
+	response, err := r.agent.Chat(ctx, system, messages)
+	return response, r.record(ctx, chatRequest(system, messages), response, err)
+}
+
+// record saves the interaction and returns the error of the agent, or else of saving it. A call
+// whose ctx ended, cancelled or past its deadline, is not saved: it is no response of the agent.
+func (r *Recorder) record(ctx context.Context, request Request, response string, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	i := Interaction{Request: request, Response: response}
+	if err != nil {
+		i.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	if saveErr := r.cassette.Save(r.path); err == nil {
+		return saveErr
+	}
+	return err
+}
+
+// Replayer is an agent answering the requests recorded in a cassette with their responses. A
+// request recorded several times gets its responses in the order they were recorded, the last one
+// once they were all served. A call whose context is done returns its error.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	served   []bool
+}
+
+// NewReplayer returns an agent replaying c.
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{cassette: c, served: make([]bool, len(c.Interactions))}
+}
+
+// Open returns an agent replaying the cassette at path.
+func Open(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(c), nil
+}
+
+func (r *Replayer) AskHint(ctx context.Context, req agent.Request) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return r.replay(hintRequest(req))
+}
+
+func (r *Replayer) AskMovement(ctx context.Context, req agent.Request) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return r.replay(movementRequest(req))
+}
+
+func (r *Replayer) Chat(ctx context.Context, system string, messages []agent.Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return r.replay(chatRequest(system, messages))
+}
+
+// replay returns the recorded response to request.
+func (r *Replayer) replay(request Request) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !interaction.Request.equal(request) {
+			continue
+		}
+		last = i
+		if !r.served[i] {
+			break
+		}
+	}
+	if last < 0 {
+		return "", fmt.Errorf("%w: %s %q", ErrNotRecorded, request.Kind, request.SFEN)
+	}
+	r.served[last] = true
+	interaction := r.cassette.Interactions[last]
+	if interaction.Error != "" {
+		return interaction.Response, errors.New(interaction.Error)
+	}
+	return interaction.Response, nil
+}
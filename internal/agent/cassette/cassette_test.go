@@ -0,0 +1,172 @@
+package cassette_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/cassette"
+)
+
+const sfen = "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1"
+
+// countingAgent answers every request with its number, failing the requests in fail.
+type countingAgent struct {
+	asked int
+	fail  map[int]bool
+}
+
+func (c *countingAgent) answer() (string, error) {
+	c.asked++
+	if c.fail[c.asked] {
+		return "", errors.New("rate limited")
+	}
+	return "answer " + string(rune('0'+c.asked)), nil
+}
+
+func (c *countingAgent) AskHint(context.Context, agent.Request) (string, error) {
+	return c.answer()
+}
+
+func (c *countingAgent) AskMovement(context.Context, agent.Request) (string, error) {
+	return c.answer()
+}
+
+func (c *countingAgent) Chat(context.Context, string, []agent.Message) (string, error) {
+	return c.answer()
+}
+
+func TestRecorder_replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	r, err := cassette.NewRecorder(&countingAgent{fail: map[int]bool{3: true}}, path)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	ctx := context.Background()
+	position := agent.Request{SFEN: sfen, Moves: []string{"7g7f"}, Side: agent.Gote}
+	retry := position
+	retry.Feedback = "Your previous reply was rejected."
+	pro := position
+	pro.Level = agent.Pro
+	conversation := []agent.Message{{Role: agent.UserRole, Content: "Why P-3f?"}}
+	requests := []struct {
+		name string // description of this test case
+		ask  func(agent.Agent) (string, error)
+	}{
+		{name: "hint", ask: func(a agent.Agent) (string, error) { return a.AskHint(ctx, position) }},
+		{name: "movement", ask: func(a agent.Agent) (string, error) { return a.AskMovement(ctx, pro) }},
+		{name: "failed hint", ask: func(a agent.Agent) (string, error) { return a.AskHint(ctx, retry) }},
+		{name: "chat", ask: func(a agent.Agent) (string, error) { return a.Chat(ctx, "You are a tutor.", conversation) }},
+		{name: "same hint again", ask: func(a agent.Agent) (string, error) { return a.AskHint(ctx, position) }},
+	}
+	type result struct {
+		answer string
+		err    error
+	}
+	recorded := []result{}
+	for _, req := range requests {
+		answer, err := req.ask(r)
+		recorded = append(recorded, result{answer, err})
+	}
+	if recorded[2].err == nil {
+		t.Fatalf("recorder hid the error of the agent")
+	}
+
+	// The requests are replayed in another order, the hint asked twice getting both answers in turn.
+	replayer, err := cassette.Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	for _, i := range []int{3, 0, 2, 1, 4} {
+		answer, err := requests[i].ask(replayer)
+		want := recorded[i]
+		if answer != want.answer || (err == nil) != (want.err == nil) || (err != nil && err.Error() != want.err.Error()) {
+			t.Errorf("%s replayed %q, %v, want %q, %v", requests[i].name, answer, err, want.answer, want.err)
+		}
+	}
+	if answer, _ := replayer.AskHint(ctx, position); answer != recorded[4].answer {
+		t.Errorf("hint replayed once all served = %q, want the last answer %q", answer, recorded[4].answer)
+	}
+
+	beginner := pro
+	beginner.Level = agent.Begginer
+	if _, err := replayer.AskMovement(ctx, beginner); !errors.Is(err, cassette.ErrNotRecorded) {
+		t.Errorf("movement of another level replayed %v, want ErrNotRecorded", err)
+	}
+	if _, err := replayer.AskHint(ctx, agent.Request{SFEN: sfen, Side: agent.Gote}); !errors.Is(err, cassette.ErrNotRecorded) {
+		t.Errorf("hint without the moves replayed %v, want ErrNotRecorded", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := replayer.AskHint(cancelled, position); !errors.Is(err, context.Canceled) {
+		t.Errorf("hint with a cancelled context replayed %v, want context.Canceled", err)
+	}
+	if _, err := replayer.Chat(ctx, "You are a tutor.", append(conversation, agent.Message{Role: agent.AssistantRole, Content: "answer 4"})); !errors.Is(err, cassette.ErrNotRecorded) {
+		t.Errorf("another conversation replayed %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestNewRecorder_appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	for range 2 {
+		r, err := cassette.NewRecorder(&countingAgent{}, path)
+		if err != nil {
+			t.Fatalf("NewRecorder() failed: %v", err)
+		}
+		if _, err := r.AskHint(context.Background(), agent.Request{SFEN: sfen}); err != nil {
+			t.Fatalf("AskHint() failed: %v", err)
+		}
+	}
+	c, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Errorf("cassette has %d interactions, want both sessions", len(c.Interactions))
+	}
+	if _, err := cassette.Open(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Open() of a missing cassette succeeded")
+	}
+}
+
+func TestRecorder_skips_ended_calls(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	tests := []struct {
+		name string // description of this test case
+		ctx  context.Context
+		want int
+	}{
+		{name: "cancelled", ctx: cancelled},
+		{name: "past its deadline", ctx: expired},
+		{name: "answered", ctx: context.Background(), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.json")
+			r, err := cassette.NewRecorder(&countingAgent{}, path)
+			if err != nil {
+				t.Fatalf("NewRecorder() failed: %v", err)
+			}
+			if _, err := r.AskHint(tt.ctx, agent.Request{SFEN: sfen}); err != nil {
+				t.Fatalf("AskHint() failed: %v", err)
+			}
+			c, err := cassette.Load(path)
+			if errors.Is(err, os.ErrNotExist) {
+				c, err = &cassette.Cassette{}, nil
+			}
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if len(c.Interactions) != tt.want {
+				t.Errorf("cassette has %d interactions, want %d", len(c.Interactions), tt.want)
+			}
+		})
+	}
+}
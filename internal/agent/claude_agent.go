@@ -9,94 +9,95 @@ import (
 	"github.com/liushuangls/go-anthropic/v2"
 )
 
+// ClaudeClient sends requests to the Anthropic messages API, an *anthropic.Client or a fake in tests.
+type ClaudeClient interface {
+	CreateMessages(ctx context.Context, request anthropic.MessagesRequest) (anthropic.MessagesResponse, error)
+}
+
 type ClaudeAgent struct {
-	client *anthropic.Client
+	client ClaudeClient
+	tools  Tools
 }
 
-func NewClaudeAgent(key string) *ClaudeAgent {
-	c := anthropic.NewClient(key)
-	return &ClaudeAgent{
-		client: c,
+// WithClaudeClient sends the requests with client instead of the Anthropic API.
+func WithClaudeClient(client ClaudeClient) func(*ClaudeAgent) {
+	return func(c *ClaudeAgent) {
+		c.client = client
 	}
 }
 
-func (c *ClaudeAgent) AskHint(sfen string) (string, error) {
-	return c.Ask(sfen,
-		"You are a shogi tutor. You will receive a SFEN string representing a shogi game. Respond ONLY with a valid JSON object that strictly adheres to the following schema: { \"next_move\": string } where the value is the suggested movement in Hodges notation. Do not include any extra text or explanation.")
+// WithClaudeTools gives the model the tools returned for the position asked about.
+func WithClaudeTools(tools Tools) func(*ClaudeAgent) {
+	return func(c *ClaudeAgent) {
+		c.tools = tools
+	}
 }
 
-func (c *ClaudeAgent) AskMovement(sfen string, difficulty AgentLevel) (string, error) {
-	level := "begginer"
-	switch difficulty {
-	case Pro:
-		level = "profesional"
-	case Medium:
-		level = "medium level"
-	case Begginer:
-		level = "begginer"
-	default:
-		level = "medium level"
+func NewClaudeAgent(key string, options ...func(*ClaudeAgent)) *ClaudeAgent {
+	c := &ClaudeAgent{
+		client: anthropic.NewClient(key),
+	}
+	for _, f := range options {
+		f(c)
 	}
+	return c
+}
+
+func (c *ClaudeAgent) AskHint(ctx context.Context, r Request) (string, error) {
+	return c.Ask(ctx, r, hintSystem)
+}
 
-	system := fmt.Sprintf("You are a %s shogi player. You are going to receive a sfen string representing a shogi game and are going to respond with the next movement for the current player according to your level using Hodges notation", level)
-	return c.Ask(sfen, system)
+func (c *ClaudeAgent) AskMovement(ctx context.Context, r Request) (string, error) {
+	return c.Ask(ctx, r, movementSystem(r.Level))
 }
 
-func (c *ClaudeAgent) Ask(message string, system string) (string, error) {
+// Ask sends the prompt of r with the system prompt and returns the move of the reply.
+func (c *ClaudeAgent) Ask(ctx context.Context, r Request, system string) (string, error) {
 	request := anthropic.MessagesRequest{
 		Model:  anthropic.ModelClaude3Dot5HaikuLatest,
 		System: system,
 		Messages: []anthropic.Message{
-			anthropic.NewUserTextMessage(message),
+			anthropic.NewUserTextMessage(r.Prompt()),
 		},
 		MaxTokens: 1000,
-		/*
-			Tools: []anthropic.ToolDefinition{
-				{
-					Name:        "get_shogi_movement",
-					Description: "Get the next movement in a given shogi game",
-					InputSchema: jsonschema.Definition{
-						Type: jsonschema.Object,
-						Properties: map[string]jsonschema.Definition{
-							"next_move": {
-								Type:        jsonschema.String,
-								Description: "The suggested next shogi movement in Hodges notation",
-							},
-						},
-						Required: []string{"next_move"},
-					},
-				},
-			},*/
-	}
-	resp, err := c.client.CreateMessages(context.Background(), request)
+	}
+	var tools []Tool
+	if c.tools != nil {
+		tools = c.tools(r)
+		request.System += toolsPrompt
+		for _, t := range tools {
+			request.Tools = append(request.Tools, anthropic.ToolDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				InputSchema: t.Parameters,
+			})
+		}
+	}
+	resp, err := c.client.CreateMessages(ctx, request)
 	if err != nil {
 		return "", err
 	}
-	/*
-		request.Messages = append(request.Messages, anthropic.Message{
-			Role:    anthropic.RoleAssistant,
-			Content: resp.Content,
-		})
-
-		var toolUse *anthropic.MessageContentToolUse
-
-		for _, c := range resp.Content {
-			if c.Type == anthropic.MessagesContentTypeToolUse {
-				toolUse = c.MessageContentToolUse
-			}
+	// The tools called are run and their results sent back until the model answers.
+	for round := 1; resp.StopReason == anthropic.MessagesStopReasonToolUse; round++ {
+		if round > MaxToolRounds {
+			return "", ErrTooManyToolCalls
 		}
-
-		if toolUse == nil {
-			panic("tool use not found")
+		results := []anthropic.MessageContent{}
+		for _, content := range resp.Content {
+			if content.Type != anthropic.MessagesContentTypeToolUse || content.MessageContentToolUse == nil {
+				continue
+			}
+			result, isErr := callTool(tools, content.Name, content.Input)
+			results = append(results, anthropic.NewToolResultMessageContent(content.ID, result, isErr))
 		}
-
-		request.Messages = append(request.Messages, anthropic.NewToolResultsMessage(toolUse.ID, "7f7g", false))
-
-		finalResp, err := c.client.CreateMessages(context.Background(), request)
-		if err != nil {
-			panic(err)
+		request.Messages = append(request.Messages,
+			anthropic.Message{Role: anthropic.RoleAssistant, Content: resp.Content},
+			anthropic.Message{Role: anthropic.RoleUser, Content: results},
+		)
+		if resp, err = c.client.CreateMessages(ctx, request); err != nil {
+			return "", err
 		}
-	*/
+	}
 	var finalText string
 	for _, msg := range resp.Content {
 		if msg.Type == anthropic.MessagesContentTypeText && msg.Text != nil {
@@ -117,3 +118,28 @@ func (c *ClaudeAgent) Ask(message string, system string) (string, error) {
 	}
 	return result.NextMove, nil
 }
+
+func (c *ClaudeAgent) Chat(ctx context.Context, system string, messages []Message) (string, error) {
+	request := anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Dot5HaikuLatest,
+		System:    system,
+		MaxTokens: 1000,
+	}
+	for _, m := range messages {
+		if m.Role == AssistantRole {
+			request.Messages = append(request.Messages, anthropic.NewAssistantTextMessage(m.Content))
+		} else {
+			request.Messages = append(request.Messages, anthropic.NewUserTextMessage(m.Content))
+		}
+	}
+	resp, err := c.client.CreateMessages(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range resp.Content {
+		if msg.Type == anthropic.MessagesContentTypeText && msg.Text != nil {
+			return *msg.Text, nil
+		}
+	}
+	return "", errors.New("final answer not found")
+}
@@ -0,0 +1,28 @@
+package agent
+
+import "context"
+
+// Conversation is a chat with an agent: every question is sent along with the previous ones and
+// their answers, so follow-up questions keep their context.
+type Conversation struct {
+	agent  Agent
+	system string
+	// Messages are the questions asked and the answers, in order.
+	Messages []Message
+}
+
+// NewConversation returns a conversation with a following the system prompt.
+func NewConversation(a Agent, system string) *Conversation {
+	return &Conversation{agent: a, system: system}
+}
+
+// Ask sends question and returns the answer. A question that fails isn't kept in the conversation.
+func (c *Conversation) Ask(ctx context.Context, question string) (string, error) {
+	messages := append(c.Messages, Message{Role: UserRole, Content: question})
+	answer, err := c.agent.Chat(ctx, c.system, messages)
+	if err != nil {
+		return "", err
+	}
+	c.Messages = append(messages, Message{Role: AssistantRole, Content: answer})
+	return answer, nil
+}
@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LocalAPI is the API spoken by the server of a LocalAgent.
+type LocalAPI string
+
+const (
+	// OpenAIAPI is the chat completions API, served by llama.cpp, vLLM, LM Studio and others under
+	// a base URL such as http://localhost:8080/v1.
+	OpenAIAPI LocalAPI = "openai"
+	// OllamaAPI is the chat API of Ollama, under a base URL such as http://localhost:11434.
+	OllamaAPI LocalAPI = "ollama"
+)
+
+// ParseLocalAPI returns the API named s.
+func ParseLocalAPI(s string) (LocalAPI, error) {
+	switch api := LocalAPI(strings.ToLower(s)); api {
+	case OpenAIAPI, OllamaAPI:
+		return api, nil
+	}
+	return "", fmt.Errorf("agent: unknown local api %q, expecting openai or ollama", s)
+}
+
+// LocalAgent asks a model served over HTTP, e.g. on the local network, so positions never leave it.
+type LocalAgent struct {
+	baseURL     string
+	api         LocalAPI
+	model       string
+	temperature float64
+	key         string
+	client      *http.Client
+}
+
+// WithAPI sets the API of the server, OpenAIAPI by default.
+func WithAPI(api LocalAPI) func(*LocalAgent) {
+	return func(a *LocalAgent) {
+		a.api = api
+	}
+}
+
+// WithModel sets the name of the model asked.
+func WithModel(model string) func(*LocalAgent) {
+	return func(a *LocalAgent) {
+		a.model = model
+	}
+}
+
+// WithTemperature sets the sampling temperature, 0.2 by default.
+func WithTemperature(t float64) func(*LocalAgent) {
+	return func(a *LocalAgent) {
+		a.temperature = t
+	}
+}
+
+// WithTimeout bounds every request, 60 seconds by default.
+func WithTimeout(d time.Duration) func(*LocalAgent) {
+	return func(a *LocalAgent) {
+		a.client.Timeout = d
+	}
+}
+
+// WithAPIKey sends key as a bearer token, for servers behind an authenticating proxy.
+func WithAPIKey(key string) func(*LocalAgent) {
+	return func(a *LocalAgent) {
+		a.key = key
+	}
+}
+
+// NewLocalAgent returns an agent asking the server at baseURL.
+func NewLocalAgent(baseURL string, options ...func(*LocalAgent)) *LocalAgent {
+	a := &LocalAgent{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		api:         OpenAIAPI,
+		temperature: 0.2,
+		client:      &http.Client{Timeout: 60 * time.Second},
+	}
+	for _, f := range options {
+		f(a)
+	}
+	return a
+}
+
+func (a *LocalAgent) AskHint(ctx context.Context, r Request) (string, error) {
+	return a.Ask(ctx, r, hintSystem)
+}
+
+func (a *LocalAgent) AskMovement(ctx context.Context, r Request) (string, error) {
+	return a.Ask(ctx, r, movementSystem(r.Level))
+}
+
+// localMessage is a message of the chat, in both APIs.
+type localMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string         `json:"model,omitempty"`
+	Messages    []localMessage `json:"messages"`
+	Temperature float64        `json:"temperature"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message localMessage `json:"message"`
+	} `json:"choices"`
+}
+
+type ollamaRequest struct {
+	Model    string         `json:"model"`
+	Messages []localMessage `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Format   string         `json:"format,omitempty"`
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+	} `json:"options"`
+}
+
+type ollamaResponse struct {
+	Message localMessage `json:"message"`
+}
+
+// errorResponse is the body of a failed request, in both APIs.
+type errorResponse struct {
+	Error json.RawMessage `json:"error"`
+}
+
+// Ask sends the prompt of r with the system prompt and returns the move of the reply. A reply that
+// isn't the JSON object asked for is returned as it is.
+func (a *LocalAgent) Ask(ctx context.Context, r Request, system string) (string, error) {
+	content, err := a.complete(ctx, system, []Message{{Role: UserRole, Content: r.Prompt()}}, true)
+	if err != nil {
+		return "", err
+	}
+	var result Movement
+	if err := json.Unmarshal([]byte(content), &result); err == nil && result.NextMove != "" {
+		return result.NextMove, nil
+	}
+	return content, nil
+}
+
+func (a *LocalAgent) Chat(ctx context.Context, system string, messages []Message) (string, error) {
+	return a.complete(ctx, system, messages, false)
+}
+
+// complete sends the conversation and returns the answer, asking Ollama for JSON when asJSON is set.
+func (a *LocalAgent) complete(ctx context.Context, system string, conversation []Message, asJSON bool) (string, error) {
+	messages := []localMessage{}
+	if system != "" {
+		messages = append(messages, localMessage{Role: "system", Content: system})
+	}
+	for _, m := range conversation {
+		messages = append(messages, localMessage{Role: string(m.Role), Content: m.Content})
+	}
+
+	var content string
+	switch a.api {
+	case OllamaAPI:
+		req := ollamaRequest{Model: a.model, Messages: messages}
+		if asJSON {
+			req.Format = "json"
+		}
+		req.Options.Temperature = a.temperature
+		var resp ollamaResponse
+		if err := a.post(ctx, "/api/chat", req, &resp); err != nil {
+			return "", err
+		}
+		content = resp.Message.Content
+	default:
+		var resp openAIResponse
+		if err := a.post(ctx, "/chat/completions", openAIRequest{Model: a.model, Messages: messages, Temperature: a.temperature}, &resp); err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("agent: local model returned no choices")
+		}
+		content = resp.Choices[0].Message.Content
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errors.New("agent: local model returned an empty answer")
+	}
+	return content, nil
+}
+
+// post sends req as JSON to path and decodes the reply into resp.
+func (a *LocalAgent) post(ctx context.Context, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if a.key != "" {
+		r.Header.Set("Authorization", "Bearer "+a.key)
+	}
+	res, err := a.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("agent: local model: %w", err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("agent: local model: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(data, &e) == nil && len(e.Error) > 0 {
+			return fmt.Errorf("agent: local model: %s: %s", res.Status, e.Error)
+		}
+		return fmt.Errorf("agent: local model: %s", res.Status)
+	}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("agent: local model: invalid reply: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+)
+
+// chatRequest is the part of the requests of both APIs checked by the tests.
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Temperature *float64 `json:"temperature"`
+	Stream      *bool    `json:"stream"`
+	Options     struct {
+		Temperature *float64 `json:"temperature"`
+	} `json:"options"`
+}
+
+func TestLocalAgent(t *testing.T) {
+	const sfen = "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1"
+	tests := []struct {
+		name   string // description of this test case
+		api    agent.LocalAPI
+		path   string
+		reply  string
+		want   string
+		status int
+		errMsg string
+	}{
+		{
+			name:  "openai json answer",
+			api:   agent.OpenAIAPI,
+			path:  "/v1/chat/completions",
+			reply: `{"choices":[{"message":{"role":"assistant","content":"{\"next_move\": \"3g3f\"}"}}]}`,
+			want:  "3g3f",
+		},
+		{
+			name:  "openai plain answer",
+			api:   agent.OpenAIAPI,
+			path:  "/v1/chat/completions",
+			reply: `{"choices":[{"message":{"role":"assistant","content":" 7g7f\n"}}]}`,
+			want:  "7g7f",
+		},
+		{
+			name:  "ollama",
+			api:   agent.OllamaAPI,
+			path:  "/api/chat",
+			reply: `{"message":{"role":"assistant","content":"{\"next_move\":\"2g2f\"}"},"done":true}`,
+			want:  "2g2f",
+		},
+		{
+			name:   "server error",
+			api:    agent.OllamaAPI,
+			path:   "/api/chat",
+			reply:  `{"error":"model \"shogi\" not found"}`,
+			status: http.StatusNotFound,
+			errMsg: "not found",
+		},
+		{
+			name:   "no choices",
+			api:    agent.OpenAIAPI,
+			path:   "/v1/chat/completions",
+			reply:  `{"choices":[]}`,
+			errMsg: "no choices",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got chatRequest
+			var auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != tt.path {
+					t.Errorf("request %s %s, want POST %s", r.Method, r.URL.Path, tt.path)
+				}
+				auth = r.Header.Get("Authorization")
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("invalid request body: %v", err)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = w.Write([]byte(tt.reply))
+			}))
+			defer srv.Close()
+
+			base := srv.URL
+			if tt.api == agent.OpenAIAPI {
+				base += "/v1/"
+			}
+			a := agent.NewLocalAgent(base, agent.WithAPI(tt.api), agent.WithModel("shogi"),
+				agent.WithTemperature(0.7), agent.WithAPIKey("secret"))
+			move, err := a.AskMovement(context.Background(), agent.Request{SFEN: sfen, Side: agent.Sente, Moves: []string{"7g7f", "3c3d"}, Level: agent.Pro})
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("AskMovement() error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AskMovement() failed: %v", err)
+			}
+			if move != tt.want {
+				t.Errorf("AskMovement() = %q, want %q", move, tt.want)
+			}
+
+			if got.Model != "shogi" || len(got.Messages) != 2 || got.Messages[0].Role != "system" {
+				t.Fatalf("request = %+v, want the model, the system prompt and the position", got)
+			}
+			if want := sfen + "\nSide to move: sente\nMoves played (USI): 7g7f 3c3d"; got.Messages[1].Content != want {
+				t.Errorf("prompt = %q, want %q", got.Messages[1].Content, want)
+			}
+			if system := got.Messages[0].Content; !strings.Contains(system, "professional") || !strings.Contains(system, "USI") || strings.Contains(system, "Hodges") {
+				t.Errorf("system prompt = %q, want the level and the USI notation", system)
+			}
+			temperature := got.Temperature
+			if tt.api == agent.OllamaAPI {
+				temperature = got.Options.Temperature
+				if got.Stream == nil || *got.Stream {
+					t.Errorf("ollama request streams, want a single reply")
+				}
+			}
+			if temperature == nil || *temperature != 0.7 {
+				t.Errorf("temperature = %v, want 0.7", temperature)
+			}
+			if auth != "Bearer secret" {
+				t.Errorf("Authorization = %q, want the api key", auth)
+			}
+		})
+	}
+}
+
+func TestLocalAgent_timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	a := agent.NewLocalAgent(srv.URL, agent.WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	if _, err := a.AskHint(context.Background(), agent.Request{SFEN: "9/9/9/9/9/9/9/9/9 b - 1"}); err == nil {
+		t.Errorf("AskHint() succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("AskHint() took %s, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestLocalAgent_cancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	a := agent.NewLocalAgent(srv.URL)
+	start := time.Now()
+	if _, err := a.AskHint(ctx, agent.Request{SFEN: "9/9/9/9/9/9/9/9/9 b - 1"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("AskHint() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("AskHint() took %s, want it to return when cancelled", elapsed)
+	}
+}
+
+func TestParseLocalAPI(t *testing.T) {
+	if api, err := agent.ParseLocalAPI("Ollama"); err != nil || api != agent.OllamaAPI {
+		t.Errorf("ParseLocalAPI(Ollama) = %q, %v", api, err)
+	}
+	if _, err := agent.ParseLocalAPI("grpc"); err == nil {
+		t.Errorf("ParseLocalAPI(grpc) succeeded, want an error")
+	}
+}
+
+func TestLocalAgent_Chat(t *testing.T) {
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		requests = append(requests, req)
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"Because it opens the bishop."}}`))
+	}))
+	defer srv.Close()
+
+	c := agent.NewConversation(agent.NewLocalAgent(srv.URL, agent.WithAPI(agent.OllamaAPI)), "You are a tutor.")
+	for _, q := range []string{"Why P-3f?", "And P-2f?"} {
+		answer, err := c.Ask(context.Background(), q)
+		if err != nil || answer != "Because it opens the bishop." {
+			t.Fatalf("Ask(%q) = %q, %v", q, answer, err)
+		}
+	}
+	if len(c.Messages) != 4 || c.Messages[3].Role != agent.AssistantRole {
+		t.Errorf("Messages = %+v, want both questions and answers", c.Messages)
+	}
+	last := requests[1]
+	if _, ok := last["format"]; ok {
+		t.Errorf("chat request asks for %v, want plain text", last["format"])
+	}
+	messages, _ := last["messages"].([]any)
+	if len(messages) != 4 {
+		t.Fatalf("chat request has %d messages, want the system prompt and the conversation", len(messages))
+	}
+	if m, _ := messages[2].(map[string]any); m["role"] != "assistant" {
+		t.Errorf("messages[2] = %v, want the first answer", m)
+	}
+}
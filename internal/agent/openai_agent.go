@@ -2,20 +2,43 @@ package agent
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
 
 	"github.com/invopop/jsonschema"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
 )
 
+// OpenAIClient sends requests to the OpenAI chat completions API, the completions of an
+// *openai.Client or a fake in tests.
+type OpenAIClient interface {
+	New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error)
+}
+
 type OpenAIAgent struct {
-	client         *openai.Client
+	client         OpenAIClient
+	tools          Tools
 	schemaResponse openai.ResponseFormatJSONSchemaJSONSchemaParam
 }
 
+// WithOpenAIClient sends the requests with client instead of the OpenAI API.
+func WithOpenAIClient(client OpenAIClient) func(*OpenAIAgent) {
+	return func(c *OpenAIAgent) {
+		c.client = client
+	}
+}
+
+// WithOpenAITools gives the model the tools returned for the position asked about.
+func WithOpenAITools(tools Tools) func(*OpenAIAgent) {
+	return func(c *OpenAIAgent) {
+		c.tools = tools
+	}
+}
+
 type Movement struct {
-	NextMove string `json:"next_move" jsonschema_description:"The suggested next shogi movement"`
+	NextMove string `json:"next_move" jsonschema_description:"The suggested next shogi movement in USI, such as 7g7f, 8h2b+ or P*5e"`
 }
 
 func GenerateSchema[T any]() interface{} {
@@ -32,9 +55,9 @@ func GenerateSchema[T any]() interface{} {
 
 var MovementResponseSchema = GenerateSchema[Movement]()
 
-func NewOpenAIAgent(key string) *OpenAIAgent {
-	return &OpenAIAgent{
-		client: openai.NewClient(option.WithAPIKey(key)),
+func NewOpenAIAgent(key string, options ...func(*OpenAIAgent)) *OpenAIAgent {
+	c := &OpenAIAgent{
+		client: openai.NewClient(option.WithAPIKey(key)).Chat.Completions,
 		schemaResponse: openai.ResponseFormatJSONSchemaJSONSchemaParam{
 			Name:        openai.F("movement"),
 			Description: openai.F("Next movement in a shogi game"),
@@ -42,36 +65,33 @@ func NewOpenAIAgent(key string) *OpenAIAgent {
 			Strict:      openai.Bool(true),
 		},
 	}
+	for _, f := range options {
+		f(c)
+	}
+	return c
 }
 
-func (c *OpenAIAgent) AskHint(sfen string) (string, error) {
-	system := "You are a shogi tutor. You are going to receive a sfen string representing a shogi game and are meant to respond with a movement suggestion for the current player"
-	return c.Ask(sfen, system)
+func (c *OpenAIAgent) AskHint(ctx context.Context, r Request) (string, error) {
+	return c.Ask(ctx, r, hintSystem)
 }
 
-func (c *OpenAIAgent) AskMovement(sfen string, difficulty AgentLevel) (string, error) {
-	var level string
-	switch difficulty {
-	case Pro:
-		level = "professional"
-	case Medium:
-		level = "medium level"
-	case Begginer:
-		level = "beginner"
-	default:
-		level = "medium level"
-	}
-	system := fmt.Sprintf("You are a %s shogi player. You are going to receive a sfen string representing a shogi game and are going to respond with the next movement for the current player according to your level", level)
-	return c.Ask(sfen, system)
+func (c *OpenAIAgent) AskMovement(ctx context.Context, r Request) (string, error) {
+	return c.Ask(ctx, r, movementSystem(r.Level))
 }
 
-func (c *OpenAIAgent) Ask(message string, system string) (string, error) {
+// Ask sends the prompt of r with the system prompt and returns the reply.
+func (c *OpenAIAgent) Ask(ctx context.Context, r Request, system string) (string, error) {
+	var tools []Tool
+	if c.tools != nil {
+		tools = c.tools(r)
+		system += toolsPrompt
+	}
 	var messages []openai.ChatCompletionMessageParamUnion
 	if system != "" {
 		messages = append(messages, openai.SystemMessage(system))
 	}
-	messages = append(messages, openai.UserMessage(message))
-	chatCompletion, err := c.client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
+	messages = append(messages, openai.UserMessage(r.Prompt()))
+	params := openai.ChatCompletionNewParams{
 		Messages: openai.F(messages),
 		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
 			openai.ResponseFormatJSONSchemaParam{
@@ -80,10 +100,71 @@ func (c *OpenAIAgent) Ask(message string, system string) (string, error) {
 			},
 		),
 		Model: openai.F(openai.ChatModelGPT4o),
+	}
+	if len(tools) > 0 {
+		definitions := make([]openai.ChatCompletionToolParam, 0, len(tools))
+		for _, t := range tools {
+			definitions = append(definitions, openai.ChatCompletionToolParam{
+				Type: openai.F(openai.ChatCompletionToolTypeFunction),
+				Function: openai.F(shared.FunctionDefinitionParam{
+					Name:        openai.F(t.Name),
+					Description: openai.F(t.Description),
+					Parameters:  openai.F(openai.FunctionParameters(t.Parameters)),
+				}),
+			})
+		}
+		params.Tools = openai.F(definitions)
+	}
+
+	// The tools called are run and their results sent back until the model answers.
+	for round := 0; ; round++ {
+		chatCompletion, err := c.client.New(ctx, params)
+		if err != nil {
+			return "", err
+		}
+		if len(chatCompletion.Choices) == 0 {
+			return "", errors.New("final answer not found")
+		}
+		reply := chatCompletion.Choices[0].Message
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+		if round == MaxToolRounds {
+			return "", ErrTooManyToolCalls
+		}
+		messages = append(messages, reply)
+		for _, call := range reply.ToolCalls {
+			result, isErr := callTool(tools, call.Function.Name, json.RawMessage(call.Function.Arguments))
+			if isErr {
+				result = "error: " + result
+			}
+			messages = append(messages, openai.ToolMessage(call.ID, result))
+		}
+		params.Messages = openai.F(messages)
+	}
+}
+
+func (c *OpenAIAgent) Chat(ctx context.Context, system string, messages []Message) (string, error) {
+	params := []openai.ChatCompletionMessageParamUnion{}
+	if system != "" {
+		params = append(params, openai.SystemMessage(system))
+	}
+	for _, m := range messages {
+		if m.Role == AssistantRole {
+			params = append(params, openai.AssistantMessage(m.Content))
+		} else {
+			params = append(params, openai.UserMessage(m.Content))
+		}
+	}
+	chatCompletion, err := c.client.New(ctx, openai.ChatCompletionNewParams{
+		Messages: openai.F(params),
+		Model:    openai.F(openai.ChatModelGPT4o),
 	})
 	if err != nil {
 		return "", err
 	}
-
+	if len(chatCompletion.Choices) == 0 {
+		return "", errors.New("final answer not found")
+	}
 	return chatCompletion.Choices[0].Message.Content, nil
 }
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// None is the provider of no agent, disabling the AI features.
+const None = "none"
+
+// ErrNoCredentials is returned by the providers missing the API key or the server of their model.
+var ErrNoCredentials = errors.New("agent: missing credentials")
+
+// Env looks a setting up by name, e.g. os.Getenv.
+type Env func(key string) string
+
+// Provider returns the agent of a provider configured by env, nil when it disables the agent.
+type Provider func(env Env) (Agent, error)
+
+// providers are the providers registered by name.
+var (
+	providersMu sync.Mutex
+	providers   = map[string]Provider{
+		"claude": ClaudeProvider(),
+		"openai": OpenAIProvider(),
+		"local":  LocalProvider(),
+		None:     func(Env) (Agent, error) { return nil, nil },
+	}
+)
+
+// Register makes p the provider called name, replacing the one registered before.
+func Register(name string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = p
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New returns the agent of the provider called name configured by env, nil for None.
+func New(name string, env Env) (Agent, error) {
+	providersMu.Lock()
+	p, ok := providers[name]
+	providersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("agent: unknown provider %q, expecting one of %v", name, Providers())
+	}
+	return p(env)
+}
+
+// ClaudeProvider returns the provider of Claude agents, with the API key CLAUDE_API_KEY.
+func ClaudeProvider(options ...func(*ClaudeAgent)) Provider {
+	return func(env Env) (Agent, error) {
+		key := env("CLAUDE_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("%w: no CLAUDE_API_KEY found in env", ErrNoCredentials)
+		}
+		return NewClaudeAgent(key, options...), nil
+	}
+}
+
+// OpenAIProvider returns the provider of OpenAI agents, with the API key OPENAI_API_KEY.
+func OpenAIProvider(options ...func(*OpenAIAgent)) Provider {
+	return func(env Env) (Agent, error) {
+		key := env("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("%w: no OPENAI_API_KEY found in env", ErrNoCredentials)
+		}
+		return NewOpenAIAgent(key, options...), nil
+	}
+}
+
+// LocalProvider returns the provider of agents asking a model served over HTTP, configured by:
+//
+//	LOCAL_AGENT_URL          base URL, e.g. http://localhost:11434 or http://localhost:8080/v1
+//	LOCAL_AGENT_API          openai (chat completions, the default) or ollama
+//	LOCAL_AGENT_MODEL        name of the model
+//	LOCAL_AGENT_TEMPERATURE  sampling temperature
+//	LOCAL_AGENT_TIMEOUT      bound of every request, e.g. 2m
+//	LOCAL_AGENT_KEY          bearer token, if the server asks for one
+func LocalProvider(options ...func(*LocalAgent)) Provider {
+	return func(env Env) (Agent, error) {
+		url := env("LOCAL_AGENT_URL")
+		if url == "" {
+			return nil, fmt.Errorf("%w: no LOCAL_AGENT_URL found in env", ErrNoCredentials)
+		}
+		configured := []func(*LocalAgent){WithModel(env("LOCAL_AGENT_MODEL"))}
+		if s := env("LOCAL_AGENT_API"); s != "" {
+			api, err := ParseLocalAPI(s)
+			if err != nil {
+				return nil, err
+			}
+			configured = append(configured, WithAPI(api))
+		}
+		if s := env("LOCAL_AGENT_TEMPERATURE"); s != "" {
+			t, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("agent: invalid LOCAL_AGENT_TEMPERATURE: %w", err)
+			}
+			configured = append(configured, WithTemperature(t))
+		}
+		if s := env("LOCAL_AGENT_TIMEOUT"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("agent: invalid LOCAL_AGENT_TIMEOUT: %w", err)
+			}
+			configured = append(configured, WithTimeout(d))
+		}
+		if key := env("LOCAL_AGENT_KEY"); key != "" {
+			configured = append(configured, WithAPIKey(key))
+		}
+		return NewLocalAgent(url, append(configured, options...)...), nil
+	}
+}
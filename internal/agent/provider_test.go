@@ -0,0 +1,72 @@
+package agent_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+)
+
+// env returns the settings of values.
+func env(values map[string]string) agent.Env {
+	return func(key string) string { return values[key] }
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		provider string
+		env      map[string]string
+		want     string
+		err      error
+		errMsg   string
+	}{
+		{name: "claude", provider: "claude", env: map[string]string{"CLAUDE_API_KEY": "key"}, want: "*agent.ClaudeAgent"},
+		{name: "claude without key", provider: "claude", err: agent.ErrNoCredentials},
+		{name: "openai", provider: "openai", env: map[string]string{"OPENAI_API_KEY": "key"}, want: "*agent.OpenAIAgent"},
+		{name: "openai with the claude key", provider: "openai", env: map[string]string{"CLAUDE_API_KEY": "key"}, err: agent.ErrNoCredentials},
+		{name: "local", provider: "local", env: map[string]string{"LOCAL_AGENT_URL": "http://localhost:11434", "LOCAL_AGENT_API": "ollama"}, want: "*agent.LocalAgent"},
+		{name: "local without server", provider: "local", err: agent.ErrNoCredentials},
+		{
+			name:     "local with invalid temperature",
+			provider: "local",
+			env:      map[string]string{"LOCAL_AGENT_URL": "http://localhost:11434", "LOCAL_AGENT_TEMPERATURE": "warm"},
+			errMsg:   "LOCAL_AGENT_TEMPERATURE",
+		},
+		{name: "none", provider: agent.None, want: "<nil>"},
+		{name: "unknown", provider: "gemini", errMsg: "unknown provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := agent.New(tt.provider, env(tt.env))
+			switch {
+			case tt.err != nil || tt.errMsg != "":
+				if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("New() error = %v, want %v %q", err, tt.err, tt.errMsg)
+				}
+			case err != nil:
+				t.Errorf("New() failed: %v", err)
+			default:
+				if got := fmt.Sprintf("%T", a); got != tt.want {
+					t.Errorf("New() = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	agent.Register("fake", func(e agent.Env) (agent.Agent, error) {
+		return agent.NewClaudeAgent(e("FAKE_KEY")), nil
+	})
+	if providers := agent.Providers(); !slices.Contains(providers, "fake") || !slices.IsSorted(providers) {
+		t.Errorf("Providers() = %v, want fake among the sorted names", providers)
+	}
+	a, err := agent.New("fake", env(nil))
+	if err != nil || fmt.Sprintf("%T", a) != "*agent.ClaudeAgent" {
+		t.Errorf("New(fake) = %T, %v, want the registered agent", a, err)
+	}
+}
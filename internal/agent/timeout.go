@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultTimeout bounds the calls to the agents of the game unless configured otherwise.
+const DefaultTimeout = 2 * time.Minute
+
+// TimeoutAgent bounds every call to another agent.
+type TimeoutAgent struct {
+	agent   Agent
+	timeout time.Duration
+}
+
+// NewTimeoutAgent returns an agent asking a, cancelling the calls that take longer than d.
+func NewTimeoutAgent(a Agent, d time.Duration) *TimeoutAgent {
+	return &TimeoutAgent{agent: a, timeout: d}
+}
+
+// Agent returns the agent asked.
+func (t *TimeoutAgent) Agent() Agent {
+	return t.agent
+}
+
+func (t *TimeoutAgent) AskHint(ctx context.Context, r Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.agent.AskHint(ctx, r)
+}
+
+func (t *TimeoutAgent) AskMovement(ctx context.Context, r Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.agent.AskMovement(ctx, r)
+}
+
+func (t *TimeoutAgent) Chat(ctx context.Context, system string, messages []Message) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.agent.Chat(ctx, system, messages)
+}
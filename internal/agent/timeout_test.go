@@ -0,0 +1,57 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+)
+
+// waitingAgent answers once its context is done, with the context error.
+type waitingAgent struct{}
+
+func (waitingAgent) AskHint(ctx context.Context, _ agent.Request) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (waitingAgent) AskMovement(ctx context.Context, _ agent.Request) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (waitingAgent) Chat(ctx context.Context, _ string, _ []agent.Message) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestTimeoutAgent(t *testing.T) {
+	a := agent.NewTimeoutAgent(waitingAgent{}, 10*time.Millisecond)
+	tests := []struct {
+		name string // description of this test case
+		ask  func(ctx context.Context) (string, error)
+	}{
+		{name: "hint", ask: func(ctx context.Context) (string, error) { return a.AskHint(ctx, agent.Request{}) }},
+		{name: "movement", ask: func(ctx context.Context) (string, error) { return a.AskMovement(ctx, agent.Request{}) }},
+		{name: "chat", ask: func(ctx context.Context) (string, error) { return a.Chat(ctx, "", nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.ask(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want context.DeadlineExceeded", err)
+			}
+			// A call cancelled before its timeout ends with the cancellation.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := tt.ask(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("cancelled error = %v, want context.Canceled", err)
+			}
+		})
+	}
+
+	if got := agent.Unwrap(agent.NewTimeoutAgent(a, time.Second)); got != (waitingAgent{}) {
+		t.Errorf("Unwrap() = %T, want the agent asked", got)
+	}
+}
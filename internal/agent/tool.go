@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxToolRounds is the number of replies calling tools a model may send before answering.
+const MaxToolRounds = 10
+
+// ErrTooManyToolCalls is returned when the model keeps calling tools without answering.
+var ErrTooManyToolCalls = errors.New("agent: model kept calling tools without answering")
+
+// Tool is a function a model may call before answering, e.g. to query the board.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments, an object.
+	Parameters map[string]any
+	// Call runs the tool with the JSON arguments the model sent and returns the result for it.
+	Call func(args json.RawMessage) (string, error)
+}
+
+// Tools returns the tools given to the model asked r.
+type Tools func(r Request) []Tool
+
+// toolsPrompt is appended to the system prompt when the model is given tools.
+const toolsPrompt = " Before answering you can call the tools to list the legal moves, try a move, see the pieces " +
+	"in hand and whether a king is in check. Then answer with the JSON object only."
+
+// callTool runs the tool named name and returns its result, or the error for the model and true.
+func callTool(tools []Tool, name string, args json.RawMessage) (string, bool) {
+	for _, t := range tools {
+		if t.Name != name {
+			continue
+		}
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		result, err := t.Call(args)
+		if err != nil {
+			return err.Error(), true
+		}
+		return result, false
+	}
+	return fmt.Sprintf("unknown tool %q", name), true
+}
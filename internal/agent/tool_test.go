@@ -0,0 +1,266 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const toolSFEN = "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1"
+
+// echoTools returns an echo tool, answering its text argument, and a failing tool. The SFEN of the
+// requests they were made for are appended to asked.
+func echoTools(asked *[]string) agent.Tools {
+	return func(r agent.Request) []agent.Tool {
+		*asked = append(*asked, r.SFEN)
+		return []agent.Tool{
+			{
+				Name:        "echo",
+				Description: "Answer the text.",
+				Parameters:  map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}},
+				Call: func(args json.RawMessage) (string, error) {
+					var a struct{ Text string }
+					if err := json.Unmarshal(args, &a); err != nil {
+						return "", err
+					}
+					return "echo " + a.Text, nil
+				},
+			},
+			{
+				Name:        "fail",
+				Description: "Always fails.",
+				Parameters:  map[string]any{"type": "object"},
+				Call: func(json.RawMessage) (string, error) {
+					return "", errors.New("no such position")
+				},
+			},
+		}
+	}
+}
+
+// fakeClaude replies with its scripted responses in turn, keeping the requests it was sent.
+type fakeClaude struct {
+	responses []anthropic.MessagesResponse
+	requests  []anthropic.MessagesRequest
+}
+
+func (f *fakeClaude) CreateMessages(_ context.Context, request anthropic.MessagesRequest) (anthropic.MessagesResponse, error) {
+	// The messages are copied as the agent appends to them.
+	request.Messages = append([]anthropic.Message(nil), request.Messages...)
+	f.requests = append(f.requests, request)
+	if len(f.requests) > len(f.responses) {
+		return anthropic.MessagesResponse{}, errors.New("no more responses")
+	}
+	return f.responses[len(f.requests)-1], nil
+}
+
+// claudeToolUse is a response calling the tool name with input.
+func claudeToolUse(id, name, input string) anthropic.MessagesResponse {
+	return anthropic.MessagesResponse{
+		StopReason: anthropic.MessagesStopReasonToolUse,
+		Content: []anthropic.MessageContent{
+			anthropic.NewTextMessageContent("Let me check."),
+			anthropic.NewToolUseMessageContent(id, name, json.RawMessage(input)),
+		},
+	}
+}
+
+// claudeAnswer is a response answering text.
+func claudeAnswer(text string) anthropic.MessagesResponse {
+	return anthropic.MessagesResponse{
+		StopReason: anthropic.MessagesStopReasonEndTurn,
+		Content:    []anthropic.MessageContent{anthropic.NewTextMessageContent(text)},
+	}
+}
+
+func TestClaudeAgent_tools(t *testing.T) {
+	tests := []struct {
+		name      string // description of this test case
+		responses []anthropic.MessagesResponse
+		want      string
+		// results are the tool results sent back, one user message for every tool call response.
+		results []string
+		errMsg  string
+	}{
+		{
+			name:      "answer without tools",
+			responses: []anthropic.MessagesResponse{claudeAnswer(`{"next_move": "3g3f"}`)},
+			want:      "3g3f",
+		},
+		{
+			name: "tool calls then answer",
+			responses: []anthropic.MessagesResponse{
+				claudeToolUse("call-1", "echo", `{"text": "3g3f"}`),
+				claudeToolUse("call-2", "fail", `{}`),
+				claudeToolUse("call-3", "castle", `{}`),
+				claudeAnswer(`{"next_move": "2g2f"}`),
+			},
+			want:    "2g2f",
+			results: []string{"echo 3g3f", "no such position", `unknown tool "castle"`},
+		},
+		{
+			name: "too many tool calls",
+			responses: func() []anthropic.MessagesResponse {
+				responses := []anthropic.MessagesResponse{}
+				for range agent.MaxToolRounds + 1 {
+					responses = append(responses, claudeToolUse("call", "echo", `{"text": "again"}`))
+				}
+				return responses
+			}(),
+			errMsg: "without answering",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClaude{responses: tt.responses}
+			var asked []string
+			a := agent.NewClaudeAgent("", agent.WithClaudeClient(client), agent.WithClaudeTools(echoTools(&asked)))
+			move, err := a.AskMovement(context.Background(), agent.Request{SFEN: toolSFEN, Level: agent.Pro})
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("AskMovement() error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AskMovement() failed: %v", err)
+			}
+			if move != tt.want {
+				t.Errorf("AskMovement() = %q, want %q", move, tt.want)
+			}
+
+			if len(asked) != 1 || asked[0] != toolSFEN {
+				t.Errorf("tools made for %q, want the SFEN", asked)
+			}
+			first := client.requests[0]
+			if !strings.Contains(first.System, "USI") {
+				t.Errorf("system prompt = %q, want the USI notation", first.System)
+			}
+			if len(first.Tools) != 2 || first.Tools[0].Name != "echo" || first.Tools[1].Name != "fail" {
+				t.Errorf("tools = %+v, want echo and fail", first.Tools)
+			}
+			if len(client.requests) != len(tt.results)+1 {
+				t.Fatalf("sent %d requests, want %d", len(client.requests), len(tt.results)+1)
+			}
+			for i, want := range tt.results {
+				messages := client.requests[i+1].Messages
+				if len(messages) != 3+2*i {
+					t.Fatalf("request %d has %d messages, want the question and every call and result", i+1, len(messages))
+				}
+				call, result := messages[len(messages)-2], messages[len(messages)-1]
+				if call.Role != anthropic.RoleAssistant || len(call.Content) != 2 {
+					t.Errorf("request %d sends %+v, want the tool call", i+1, call)
+				}
+				if result.Role != anthropic.RoleUser || len(result.Content) != 1 || result.Content[0].MessageContentToolResult == nil {
+					t.Fatalf("request %d sends %+v, want the tool result", i+1, result)
+				}
+				r := result.Content[0].MessageContentToolResult
+				if *r.ToolUseID != call.Content[1].ID || len(r.Content) != 1 || *r.Content[0].Text != want {
+					t.Errorf("request %d sends result %+v, want %q for %s", i+1, r, want, call.Content[1].ID)
+				}
+				if isErr := i > 0; *r.IsError != isErr {
+					t.Errorf("request %d result is error = %v, want %v", i+1, *r.IsError, isErr)
+				}
+			}
+		})
+	}
+}
+
+// fakeOpenAI replies with its scripted completions in turn, keeping the requests it was sent as JSON.
+type fakeOpenAI struct {
+	replies  []openai.ChatCompletionMessage
+	requests []map[string]any
+}
+
+func (f *fakeOpenAI) New(_ context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) (*openai.ChatCompletion, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var request map[string]any
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+	f.requests = append(f.requests, request)
+	if len(f.requests) > len(f.replies) {
+		return nil, errors.New("no more replies")
+	}
+	return &openai.ChatCompletion{
+		Choices: []openai.ChatCompletionChoice{{Message: f.replies[len(f.requests)-1]}},
+	}, nil
+}
+
+// openAIToolCall is a reply calling the tool name with arguments.
+func openAIToolCall(id, name, arguments string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatCompletionMessageRoleAssistant,
+		ToolCalls: []openai.ChatCompletionMessageToolCall{{
+			ID:       id,
+			Type:     openai.ChatCompletionMessageToolCallTypeFunction,
+			Function: openai.ChatCompletionMessageToolCallFunction{Name: name, Arguments: arguments},
+		}},
+	}
+}
+
+func TestOpenAIAgent_tools(t *testing.T) {
+	client := &fakeOpenAI{replies: []openai.ChatCompletionMessage{
+		openAIToolCall("call-1", "echo", `{"text": "3g3f"}`),
+		openAIToolCall("call-2", "fail", ``),
+		{Role: openai.ChatCompletionMessageRoleAssistant, Content: `{"next_move":"2g2f"}`},
+	}}
+	var asked []string
+	a := agent.NewOpenAIAgent("", agent.WithOpenAIClient(client), agent.WithOpenAITools(echoTools(&asked)))
+	hint, err := a.AskHint(context.Background(), agent.Request{SFEN: toolSFEN})
+	if err != nil {
+		t.Fatalf("AskHint() failed: %v", err)
+	}
+	if hint != `{"next_move":"2g2f"}` {
+		t.Errorf("AskHint() = %q, want the final answer", hint)
+	}
+	if len(asked) != 1 || asked[0] != toolSFEN {
+		t.Errorf("tools made for %q, want the SFEN", asked)
+	}
+	if len(client.requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(client.requests))
+	}
+
+	tools, _ := client.requests[0]["tools"].([]any)
+	if len(tools) != 2 {
+		t.Fatalf("tools = %v, want echo and fail", client.requests[0]["tools"])
+	}
+	if function, _ := tools[0].(map[string]any)["function"].(map[string]any); function["name"] != "echo" || function["parameters"] == nil {
+		t.Errorf("tools[0] = %v, want the echo function and its parameters", tools[0])
+	}
+
+	for i, want := range []string{"echo 3g3f", "error: no such position"} {
+		messages, _ := client.requests[i+1]["messages"].([]any)
+		if len(messages) != 4+2*i {
+			t.Fatalf("request %d has %d messages, want the prompts and every call and result", i+1, len(messages))
+		}
+		call, _ := messages[len(messages)-2].(map[string]any)
+		result, _ := messages[len(messages)-1].(map[string]any)
+		if call["role"] != "assistant" || call["tool_calls"] == nil {
+			t.Errorf("request %d sends %v, want the tool call", i+1, call)
+		}
+		id := "call-" + string(rune('1'+i))
+		if result["role"] != "tool" || result["tool_call_id"] != id || !strings.Contains(toJSON(t, result["content"]), want) {
+			t.Errorf("request %d sends %v, want %q for %s", i+1, result, want, id)
+		}
+	}
+}
+
+func toJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	return string(data)
+}
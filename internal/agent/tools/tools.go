@@ -0,0 +1,165 @@
+// Package tools gives agents functions to query a shogi position while choosing a move: the legal
+// moves, the position after a move, the pieces in hand and whether a king is in check.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// sfenProperty is the optional argument of every tool naming the position queried.
+var sfenProperty = map[string]any{
+	"type":        "string",
+	"description": "SFEN of the position, e.g. one returned by play_move. Defaults to the position asked about.",
+}
+
+// arguments are the arguments of the tools.
+type arguments struct {
+	SFEN string `json:"sfen"`
+	Move string `json:"move"`
+}
+
+// New returns the tools querying the position of r. It is an agent.Tools.
+func New(r agent.Request) []agent.Tool {
+	sfen := r.SFEN
+	return []agent.Tool{
+		{
+			Name:        "legal_moves",
+			Description: "List every legal move of the side to move in USI, the drops of the pieces in hand included, such as 7g7f, 8h2b+ or P*5e.",
+			Parameters:  schema(nil),
+			Call: func(args json.RawMessage) (string, error) {
+				b, _, err := load(sfen, args)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s can play: %s", side(b.Turn), strings.Join(validate.Moves(b), ", ")), nil
+			},
+		},
+		{
+			Name:        "play_move",
+			Description: "Play a move without committing to it and return the SFEN of the resulting position.",
+			Parameters: schema(map[string]any{
+				"move": map[string]any{
+					"type":        "string",
+					"description": "The move in USI, such as 7g7f, 8h2b+ or P*5e.",
+				},
+			}, "move"),
+			Call: func(args json.RawMessage) (string, error) {
+				b, a, err := load(sfen, args)
+				if err != nil {
+					return "", err
+				}
+				m, err := validate.Resolve(b, a.Move)
+				if err != nil {
+					return "", err
+				}
+				if err := b.ProcessMove(&m); err != nil {
+					return "", err
+				}
+				result := "SFEN after " + a.Move + ": " + b.String()
+				if b.IsCheckmate() {
+					result += "\n" + side(b.Turn) + " is checkmated."
+				} else if b.InCheck(b.Turn) {
+					result += "\n" + side(b.Turn) + " is in check."
+				}
+				return result, nil
+			},
+		},
+		{
+			Name:        "pieces_in_hand",
+			Description: "List the captured pieces each side holds and can drop.",
+			Parameters:  schema(nil),
+			Call: func(args json.RawMessage) (string, error) {
+				b, _, err := load(sfen, args)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s: %s\n%s: %s", side(shogi.Black), hand(b.Hand, shogi.Black),
+					side(shogi.White), hand(b.Hand, shogi.White)), nil
+			},
+		},
+		{
+			Name:        "in_check",
+			Description: "Tell whether the king of each side is in check, and whether the side to move is checkmated.",
+			Parameters:  schema(nil),
+			Call: func(args json.RawMessage) (string, error) {
+				b, _, err := load(sfen, args)
+				if err != nil {
+					return "", err
+				}
+				lines := []string{}
+				for _, c := range []shogi.Color{shogi.Black, shogi.White} {
+					status := "is not in check"
+					switch {
+					case c == b.Turn && b.IsCheckmate():
+						status = "is checkmated"
+					case b.InCheck(c):
+						status = "is in check"
+					}
+					lines = append(lines, side(c)+" "+status)
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		},
+	}
+}
+
+// schema returns the JSON schema of an object with properties, besides the SFEN, and required.
+func schema(properties map[string]any, required ...string) map[string]any {
+	all := map[string]any{"sfen": sfenProperty}
+	for name, p := range properties {
+		all[name] = p
+	}
+	s := map[string]any{"type": "object", "properties": all}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// load decodes args and returns the board of the SFEN they name, or else of sfen.
+func load(sfen string, args json.RawMessage) (shogi.Board, arguments, error) {
+	var a arguments
+	if err := json.Unmarshal(args, &a); err != nil {
+		return shogi.Board{}, a, fmt.Errorf("tools: invalid arguments: %w", err)
+	}
+	if a.SFEN != "" {
+		sfen = strings.TrimPrefix(strings.TrimSpace(a.SFEN), "sfen ")
+	}
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(sfen); err != nil {
+		return shogi.Board{}, a, fmt.Errorf("tools: %w", err)
+	}
+	return b, a, nil
+}
+
+// side names the player c.
+func side(c shogi.Color) string {
+	if c == shogi.White {
+		return "Gote (white)"
+	}
+	return "Sente (black)"
+}
+
+// hand lists the pieces in hand of c, e.g. R 2P.
+func hand(h shogi.Hand, c shogi.Color) string {
+	pieces := []string{}
+	for _, pt := range []shogi.PieceType{shogi.Rook, shogi.Bishop, shogi.Gold, shogi.Silver, shogi.Knight, shogi.Lance, shogi.Pawn} {
+		switch n := h.Count(c, pt); {
+		case n == 1:
+			pieces = append(pieces, pt.String())
+		case n > 1:
+			pieces = append(pieces, strconv.Itoa(n)+pt.String())
+		}
+	}
+	if len(pieces) == 0 {
+		return "none"
+	}
+	return strings.Join(pieces, " ")
+}
@@ -0,0 +1,176 @@
+package tools_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/tools"
+)
+
+const (
+	start = "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1"
+	// afterBishops is after 7g7f 3c3d 8h2b+ 3a2b, sente holding a bishop and gote a bishop.
+	afterBishops = "lnsgkg1nl/1r5s1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL b Bb 5"
+	// check is gote's king checked by sente's gold.
+	check = "4k4/4G4/9/9/9/9/9/9/4K4 w - 1"
+	// mate is gote's king checkmated by sente's gold, protected by a silver.
+	mate = "4k4/4G4/4S4/9/9/9/9/9/4K4 w - 1"
+)
+
+// call calls the tool name of the tools for the position sfen with args.
+func call(t *testing.T, sfen, name, args string) (string, error) {
+	t.Helper()
+	for _, tool := range tools.New(agent.Request{SFEN: sfen}) {
+		if tool.Name == name {
+			return tool.Call(json.RawMessage(args))
+		}
+	}
+	t.Fatalf("no tool %s", name)
+	return "", nil
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string // description of this test case
+		sfen   string
+		tool   string
+		args   string
+		want   []string
+		errMsg string
+	}{
+		{
+			name: "legal moves",
+			sfen: start,
+			tool: "legal_moves",
+			args: `{}`,
+			want: []string{"Sente (black) can play: ", "3g3f", "5i6h"},
+		},
+		{
+			name: "legal moves of another position",
+			sfen: start,
+			tool: "legal_moves",
+			args: `{"sfen": "sfen ` + check + `"}`,
+			want: []string{"Gote (white) can play: ", "5a5b"},
+		},
+		{
+			name: "legal moves with drops",
+			sfen: afterBishops,
+			tool: "legal_moves",
+			args: `{}`,
+			want: []string{"8i7g", "B*5e", "B*1h"},
+		},
+		{
+			name: "play a drop",
+			sfen: afterBishops,
+			tool: "play_move",
+			args: `{"move": "B*5e"}`,
+			want: []string{"SFEN after B*5e: lnsgkg1nl/1r5s1/pppppp1pp/6p2/4B4/2P6/PP1PPPPPP/7R1/LNSGKGSNL w b 6"},
+		},
+		{
+			name: "play move",
+			sfen: start,
+			tool: "play_move",
+			args: `{"move": "7g7f"}`,
+			want: []string{"SFEN after 7g7f: lnsgkgsnl/1r5b1/ppppppppp/9/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL w - 2"},
+		},
+		{
+			name: "play move giving check",
+			sfen: "4k4/9/4G4/9/9/9/9/9/4K4 b - 1",
+			tool: "play_move",
+			args: `{"move": "5c5b"}`,
+			want: []string{"Gote (white) is in check."},
+		},
+		{
+			name:   "illegal move",
+			sfen:   start,
+			tool:   "play_move",
+			args:   `{"move": "3g3e"}`,
+			errMsg: "not a legal move",
+		},
+		{
+			name: "pieces in hand",
+			sfen: afterBishops,
+			tool: "pieces_in_hand",
+			args: `{}`,
+			want: []string{"Sente (black): B\nGote (white): B"},
+		},
+		{
+			name: "no pieces in hand",
+			sfen: start,
+			tool: "pieces_in_hand",
+			args: `{}`,
+			want: []string{"Sente (black): none\nGote (white): none"},
+		},
+		{
+			name: "not in check",
+			sfen: start,
+			tool: "in_check",
+			args: `{}`,
+			want: []string{"Sente (black) is not in check\nGote (white) is not in check"},
+		},
+		{
+			name: "in check",
+			sfen: check,
+			tool: "in_check",
+			args: `{}`,
+			want: []string{"Gote (white) is in check"},
+		},
+		{
+			name: "checkmated",
+			sfen: mate,
+			tool: "in_check",
+			args: `{}`,
+			want: []string{"Gote (white) is checkmated"},
+		},
+		{
+			name:   "invalid sfen",
+			sfen:   "castle",
+			tool:   "in_check",
+			args:   `{}`,
+			errMsg: "sfen",
+		},
+		{
+			name:   "invalid arguments",
+			sfen:   start,
+			tool:   "play_move",
+			args:   `["3g3f"]`,
+			errMsg: "invalid arguments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := call(t, tt.sfen, tt.tool, tt.args)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("%s() = %q, %v, want error %q", tt.tool, got, err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s() failed: %v", tt.tool, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("%s() = %q, want it to contain %q", tt.tool, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_schemas(t *testing.T) {
+	var _ agent.Tools = tools.New
+	for _, tool := range tools.New(agent.Request{SFEN: start}) {
+		if tool.Parameters["type"] != "object" {
+			t.Errorf("%s parameters = %v, want an object", tool.Name, tool.Parameters)
+		}
+		if _, ok := tool.Parameters["properties"].(map[string]any)["sfen"]; !ok {
+			t.Errorf("%s parameters = %v, want the optional sfen", tool.Name, tool.Parameters)
+		}
+		if _, err := json.Marshal(tool.Parameters); err != nil {
+			t.Errorf("%s parameters can't be sent: %v", tool.Name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package usi answers the move requests of the game with the search of a USI engine, an agent
+// working offline that can't chat.
+package usi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// ErrChat is returned by Chat, an engine only chooses moves.
+var ErrChat = errors.New("usi: an engine can't chat, choose another agent to explain moves")
+
+// Agent is an agent searching the positions asked about with a USI engine.
+type Agent struct {
+	engine *engine.GUIEngine
+	// searches are the searches run for the moves of every level, the hints are searched as Pro.
+	searches map[agent.AgentLevel]engine.GoParams
+	shutdown func()
+
+	// mu is held during a search, the engine searches one position at a time.
+	mu sync.Mutex
+}
+
+// WithSearch sets the search run for the moves of level.
+func WithSearch(level agent.AgentLevel, p engine.GoParams) func(*Agent) {
+	return func(a *Agent) {
+		a.searches[level] = p
+	}
+}
+
+// WithShutdown sets the function Close calls to shut the engine down.
+func WithShutdown(f func()) func(*Agent) {
+	return func(a *Agent) {
+		a.shutdown = f
+	}
+}
+
+// New returns an agent searching with e, longer for stronger levels.
+func New(e *engine.GUIEngine, options ...func(*Agent)) *Agent {
+	a := &Agent{
+		engine: e,
+		searches: map[agent.AgentLevel]engine.GoParams{
+			agent.Begginer: {MoveTime: 100 * time.Millisecond},
+			agent.Medium:   {MoveTime: 500 * time.Millisecond},
+			agent.Pro:      {MoveTime: 2 * time.Second},
+		},
+	}
+	for _, f := range options {
+		f(a)
+	}
+	return a
+}
+
+func (a *Agent) AskHint(ctx context.Context, r agent.Request) (string, error) {
+	return a.search(ctx, r, a.searches[agent.Pro])
+}
+
+func (a *Agent) AskMovement(ctx context.Context, r agent.Request) (string, error) {
+	return a.search(ctx, r, a.searches[r.Level])
+}
+
+func (a *Agent) Chat(context.Context, string, []agent.Message) (string, error) {
+	return "", ErrChat
+}
+
+// Close shuts the engine down.
+func (a *Agent) Close() error {
+	if a.shutdown != nil {
+		a.shutdown()
+	}
+	return nil
+}
+
+// search runs p on the position of r and returns the best move in USI.
+func (a *Agent) search(ctx context.Context, r agent.Request, p engine.GoParams) (string, error) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(r.SFEN); err != nil {
+		return "", fmt.Errorf("usi: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.engine.Init(ctx, nil); err != nil {
+		return "", fmt.Errorf("usi: %w", err)
+	}
+	if err := a.engine.SendPosition(r.SFEN, nil); err != nil {
+		return "", fmt.Errorf("usi: %w", err)
+	}
+	bm, err := a.engine.Search(ctx, p)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return "", fmt.Errorf("usi: %w", err)
+	}
+	switch {
+	case bm.Resign:
+		return "", errors.New("usi: engine resigns")
+	case bm.Win:
+		return "", errors.New("usi: engine declares an entering king win")
+	}
+	if _, err := b.ResolveUSIMove(bm.Move); err != nil {
+		return "", fmt.Errorf("usi: engine played %s: %w", bm.Move, err)
+	}
+	return bm.Move, nil
+}
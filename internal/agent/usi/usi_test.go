@@ -0,0 +1,64 @@
+package usi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/usi"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestAgent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+	closed := false
+	a := usi.New(engine.NewGUIEngine(le),
+		usi.WithSearch(agent.Pro, engine.GoParams{Depth: 1}),
+		usi.WithSearch(agent.Begginer, engine.GoParams{Depth: 1}),
+		usi.WithShutdown(func() { closed = true }))
+
+	g := shogi.NewGame("sente", "gote")
+	tests := []struct {
+		name string // description of this test case
+		ask  func(r agent.Request) (string, error)
+	}{
+		{name: "hint", ask: func(r agent.Request) (string, error) { return a.AskHint(ctx, r) }},
+		{name: "movement", ask: func(r agent.Request) (string, error) {
+			r.Level = agent.Begginer
+			return a.AskMovement(ctx, r)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := g.Board().Clone()
+			reply, err := tt.ask(agent.Request{SFEN: b.String()})
+			if err != nil {
+				t.Fatalf("ask failed: %v", err)
+			}
+			m, err := validate.Resolve(b, reply)
+			if err != nil {
+				t.Fatalf("reply %q is not a legal move: %v", reply, err)
+			}
+			if err := g.Move(m); err != nil {
+				t.Fatalf("Move(%s) failed: %v", reply, err)
+			}
+		})
+	}
+
+	if _, err := a.Chat(ctx, "You are a tutor.", nil); !errors.Is(err, usi.ErrChat) {
+		t.Errorf("Chat() error = %v, want ErrChat", err)
+	}
+	if _, err := a.AskHint(ctx, agent.Request{SFEN: "not a position"}); err == nil {
+		t.Errorf("AskHint() of an invalid SFEN succeeded")
+	}
+	if err := a.Close(); err != nil || !closed {
+		t.Errorf("Close() = %v, shut down %v", err, closed)
+	}
+}
@@ -0,0 +1,174 @@
+// Package validate checks the moves suggested by an agent against the legal moves of the board,
+// asking the agent again, with the reason and the legal moves, when a reply can't be played.
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// DefaultRetries is the number of times the agent is asked again unless WithRetries is given.
+const DefaultRetries = 3
+
+// ErrNoLegalMove is returned when the agent never replied with a legal move.
+var ErrNoLegalMove = errors.New("validate: agent gave no legal move")
+
+// Validator asks an agent for moves and only returns legal ones.
+//
+// Moves are written in USI, as agents are prompted for them: the origin and destination squares
+// such as 7g7f, followed by + when the piece promotes, or the piece dropped and its square such
+// as P*5e.
+type Validator struct {
+	agent   agent.Agent
+	retries int
+	// OnReject is called with every reply rejected and the reason.
+	OnReject func(reply string, err error)
+}
+
+// WithRetries asks the agent again up to n times after a reply is rejected.
+func WithRetries(n int) func(*Validator) {
+	return func(v *Validator) {
+		v.retries = max(n, 0)
+	}
+}
+
+// New returns a validator of the replies of a.
+func New(a agent.Agent, options ...func(*Validator)) *Validator {
+	v := &Validator{agent: a, retries: DefaultRetries}
+	for _, f := range options {
+		f(v)
+	}
+	return v
+}
+
+// Hint asks the agent for a hint for the player to move on b, reached after history, the moves
+// played in USI. It returns the legal move and the move in USI.
+func (v *Validator) Hint(ctx context.Context, b shogi.Board, history []string) (shogi.Move, string, error) {
+	return v.ask(ctx, b, request(b, history), v.agent.AskHint)
+}
+
+// Movement asks the agent, playing at level, for its move on b, reached after history.
+func (v *Validator) Movement(ctx context.Context, b shogi.Board, history []string, level agent.AgentLevel) (shogi.Move, string, error) {
+	r := request(b, history)
+	r.Level = level
+	return v.ask(ctx, b, r, v.agent.AskMovement)
+}
+
+// request is the request of the position b reached after history.
+func request(b shogi.Board, history []string) agent.Request {
+	side := agent.Sente
+	if b.Turn == shogi.White {
+		side = agent.Gote
+	}
+	return agent.Request{SFEN: b.String(), Moves: history, Side: side}
+}
+
+// ask sends r, the request of b, with f until a reply decodes to a legal move, adding to the
+// request why the previous reply was rejected and the moves that could be played. It gives up at
+// once when ctx is done.
+func (v *Validator) ask(ctx context.Context, b shogi.Board, r agent.Request, f func(context.Context, agent.Request) (string, error)) (shogi.Move, string, error) {
+	legal := legalMoves(b)
+	if len(legal) == 0 {
+		return shogi.Move{}, "", fmt.Errorf("%w: no move can be played", ErrNoLegalMove)
+	}
+	var reply string
+	var err error
+	for attempt := 0; attempt <= v.retries; attempt++ {
+		reply, err = f(ctx, r)
+		if ctx.Err() != nil {
+			return shogi.Move{}, "", fmt.Errorf("validate: %w", ctx.Err())
+		}
+		if err == nil {
+			var m shogi.Move
+			var usi string
+			if m, usi, err = check(b, reply, legal); err == nil {
+				return m, usi, nil
+			}
+		}
+		if v.OnReject != nil {
+			v.OnReject(reply, err)
+		}
+		r.Feedback = retryMessage(reply, err, legal)
+	}
+	return shogi.Move{}, "", fmt.Errorf("%w in %d attempts, last reply %q: %v", ErrNoLegalMove, v.retries+1, reply, err)
+}
+
+// Resolve returns the legal move of b written s in USI.
+func Resolve(b shogi.Board, s string) (shogi.Move, error) {
+	m, _, err := check(b, s, legalMoves(b))
+	return m, err
+}
+
+// Moves returns the moves that can be played on b in USI, drops included.
+func Moves(b shogi.Board) []string {
+	return usiMoves(legalMoves(b))
+}
+
+// legalMove is a legal move and how it is written in USI.
+type legalMove struct {
+	move shogi.Move
+	usi  string
+}
+
+// legalMoves returns the moves that can be played on b, once for every way they are written.
+func legalMoves(b shogi.Board) []legalMove {
+	moves := []legalMove{}
+	for _, m := range b.Clone().LegalMoves() {
+		usi := m.USI()
+		if !slices.ContainsFunc(moves, func(l legalMove) bool { return l.usi == usi }) {
+			moves = append(moves, legalMove{move: m, usi: usi})
+		}
+	}
+	return moves
+}
+
+// check decodes reply, either the move or the JSON object agents are asked for, and finds it
+// among the legal moves of b.
+func check(b shogi.Board, reply string, legal []legalMove) (shogi.Move, string, error) {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "{") {
+		var m agent.Movement
+		if err := json.Unmarshal([]byte(text), &m); err != nil {
+			return shogi.Move{}, "", fmt.Errorf("reply is not a valid JSON object: %w", err)
+		}
+		text = strings.TrimSpace(m.NextMove)
+	}
+	text = strings.Trim(text, "\"'`.")
+	if text == "" {
+		return shogi.Move{}, "", errors.New("reply has no move")
+	}
+	for _, l := range legal {
+		if l.usi == text {
+			return l.move, l.usi, nil
+		}
+	}
+	if _, err := shogi.ParseUSIMove(text); err != nil {
+		return shogi.Move{}, "", fmt.Errorf("%s is not a move in USI, expecting e.g. 7g7f, 8h2b+ or P*5e", text)
+	}
+	if _, err := b.ResolveUSIMove(text); err != nil {
+		return shogi.Move{}, "", fmt.Errorf("%s is not a legal move in this position: %v", text, err)
+	}
+	return shogi.Move{}, "", fmt.Errorf("%s is not a legal move in this position", text)
+}
+
+// retryMessage is the feedback asking again for a move after reply was rejected for err.
+func retryMessage(reply string, err error, legal []legalMove) string {
+	return fmt.Sprintf("Your previous reply %q was rejected: %v. Reply with exactly one of these legal moves in USI: %s",
+		reply, err, strings.Join(usiMoves(legal), ", "))
+}
+
+// usiMoves returns how the legal moves are written.
+func usiMoves(legal []legalMove) []string {
+	moves := make([]string, 0, len(legal))
+	for _, l := range legal {
+		moves = append(moves, l.usi)
+	}
+	return moves
+}
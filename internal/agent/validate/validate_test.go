@@ -0,0 +1,174 @@
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// fakeAgent replies with its scripted replies in turn, keeping the requests it was sent.
+type fakeAgent struct {
+	replies  []string
+	requests []agent.Request
+}
+
+func (f *fakeAgent) reply(r agent.Request) (string, error) {
+	f.requests = append(f.requests, r)
+	if len(f.requests) > len(f.replies) {
+		return "", errors.New("no more replies")
+	}
+	return f.replies[len(f.requests)-1], nil
+}
+
+func (f *fakeAgent) AskHint(_ context.Context, r agent.Request) (string, error) {
+	return f.reply(r)
+}
+
+func (f *fakeAgent) AskMovement(_ context.Context, r agent.Request) (string, error) {
+	return f.reply(r)
+}
+
+func (f *fakeAgent) Chat(context.Context, string, []agent.Message) (string, error) {
+	return "", errors.New("no chat")
+}
+
+// handSFEN is a position where both players hold a pawn, the fifth files being empty.
+const handSFEN = "lnsgkgsnl/1r5b1/pppp1pppp/9/9/9/PPPP1PPPP/1B5R1/LNSGKGSNL b Pp 1"
+
+func TestValidator_Hint(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		sfen     string
+		replies  []string
+		want     string
+		attempts int
+		wantErr  bool
+	}{
+		{name: "legal move", replies: []string{"7g7f"}, want: "7g7f", attempts: 1},
+		{name: "json reply", replies: []string{`{"next_move": "2g2f"}`}, want: "2g2f", attempts: 1},
+		{name: "promotion", sfen: "4k4/9/9/9/9/9/9/1B7/4K4 b - 1", replies: []string{"8h2b+"}, want: "8h2b+", attempts: 1},
+		{name: "illegal then legal", replies: []string{"3g3e", "2h7h"}, want: "2h7h", attempts: 2},
+		// Mirrored, 8h3h would move the rook; in USI it is the bishop, which can't go there.
+		{name: "mirrored files then legal", replies: []string{"8h3h", "2g2f"}, want: "2g2f", attempts: 2},
+		{name: "not a move then legal", replies: []string{"Pawn to 7f", "7g7f"}, want: "7g7f", attempts: 2},
+		{name: "drop", sfen: handSFEN, replies: []string{"P*5e"}, want: "P*5e", attempts: 1},
+		{name: "drop without a piece in hand", replies: []string{"P*5e", "7g7f"}, want: "7g7f", attempts: 2},
+		{name: "never complies", replies: []string{"3g3e", "3g3e", "3g3e", "3g3e"}, attempts: 4, wantErr: true},
+		{name: "agent error", replies: []string{}, attempts: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &fakeAgent{replies: tt.replies}
+			rejected := 0
+			v := validate.New(a)
+			v.OnReject = func(string, error) { rejected++ }
+			b := shogi.NewBoard()
+			if tt.sfen == "" {
+				tt.sfen = shogi.StartingPosition
+			}
+			if err := b.LoadSfen(tt.sfen); err != nil {
+				t.Fatalf("LoadSfen() failed: %v", err)
+			}
+			m, usi, err := v.Hint(context.Background(), b, nil)
+			wantRejected := tt.attempts - 1
+			if tt.wantErr {
+				wantRejected = tt.attempts
+			}
+			if len(a.requests) != tt.attempts || rejected != wantRejected {
+				t.Errorf("Hint() asked %d times and rejected %d replies, want %d and %d", len(a.requests), rejected, tt.attempts, wantRejected)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, validate.ErrNoLegalMove) {
+					t.Errorf("Hint() error = %v, want ErrNoLegalMove", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Hint() failed: %v", err)
+			}
+			if usi != tt.want || m.USI() != tt.want {
+				t.Errorf("Hint() = %s, %q, want %q", m.USI(), usi, tt.want)
+			}
+			if err := b.ProcessMove(&m); err != nil {
+				t.Errorf("ProcessMove(%s) failed: %v", m.USI(), err)
+			}
+		})
+	}
+}
+
+func TestValidator_retryMessage(t *testing.T) {
+	a := &fakeAgent{replies: []string{"3g3e", "3g3f"}}
+	b := *shogi.NewGame("sente", "gote").Board()
+	history := []string{"7g7f", "3c3d"}
+	if _, _, err := validate.New(a).Movement(context.Background(), b, history, agent.Pro); err != nil {
+		t.Fatalf("Movement() failed: %v", err)
+	}
+	first := a.requests[0]
+	if first.SFEN != b.String() || first.Side != agent.Sente || first.Level != agent.Pro || first.Feedback != "" ||
+		strings.Join(first.Moves, " ") != "7g7f 3c3d" {
+		t.Errorf("first request = %+v, want the position, the moves and the level", first)
+	}
+	if a.requests[1].SFEN != b.String() || a.requests[1].Level != agent.Pro {
+		t.Errorf("retry request = %+v, want the same position and level", a.requests[1])
+	}
+	retry := a.requests[1].Feedback
+	for _, want := range []string{`"3g3e" was rejected`, "not a legal move", "USI", "3g3f", "2h7h"} {
+		if !strings.Contains(retry, want) {
+			t.Errorf("retry message = %q, want it to contain %q", retry, want)
+		}
+	}
+}
+
+func TestMoves(t *testing.T) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(handSFEN); err != nil {
+		t.Fatalf("LoadSfen() failed: %v", err)
+	}
+	moves := validate.Moves(b)
+	if len(moves) != len(b.LegalMoves()) {
+		t.Errorf("Moves() lists %d moves, want the %d legal moves", len(moves), len(b.LegalMoves()))
+	}
+	for _, want := range []string{"7g7f", "2h5h", "P*5e"} {
+		if !slices.Contains(moves, want) {
+			t.Errorf("Moves() = %v, want %s", moves, want)
+		}
+	}
+}
+
+func TestValidator_WithRetries(t *testing.T) {
+	a := &fakeAgent{replies: []string{"1a1a", "1a1a"}}
+	_, _, err := validate.New(a, validate.WithRetries(0)).Hint(context.Background(), *shogi.NewGame("sente", "gote").Board(), nil)
+	if !errors.Is(err, validate.ErrNoLegalMove) || len(a.requests) != 1 {
+		t.Errorf("Hint() = %v after %d attempts, want ErrNoLegalMove after 1", err, len(a.requests))
+	}
+	if !strings.Contains(err.Error(), `last reply "1a1a"`) {
+		t.Errorf("Hint() error = %q, want the last reply", err)
+	}
+}
+
+func TestValidator_cancel(t *testing.T) {
+	a := &fakeAgent{replies: []string{"3g3e", "3g3f"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := validate.New(a).Hint(ctx, *shogi.NewGame("sente", "gote").Board(), nil)
+	if !errors.Is(err, context.Canceled) || len(a.requests) != 1 {
+		t.Errorf("Hint() = %v after %d attempts, want context.Canceled without retrying", err, len(a.requests))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	b := *shogi.NewGame("sente", "gote").Board()
+	m, err := validate.Resolve(b, "2g2f")
+	if err != nil || m.Piece.Type != shogi.NewPiece("P", false).Type || m.USI() != "2g2f" {
+		t.Errorf("Resolve(2g2f) = %v, %v, want the pawn move 2g2f", m, err)
+	}
+	if _, err := validate.Resolve(b, "3g3e"); err == nil {
+		t.Errorf("Resolve(3g3e) succeeded, want illegal")
+	}
+}
@@ -0,0 +1,262 @@
+// Package book is an opening book: the moves to play in known positions, read from and written
+// to the YaneuraOu standard book format.
+package book
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// YaneuraOu standard book format, DB2016:
+//
+//	#YANEURAOU-DB2016 1.00
+//	sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1
+//	7g7f 3c3d 50 32 12          <move> <ponder|none> <value> <depth> [<count>]
+//	2g2f none 30 32 8
+//
+// A position is followed by its moves, the best first. Lines starting with # are comments.
+
+// Header is the first line of a book file.
+const Header = "#YANEURAOU-DB2016 1.00"
+
+// noPonder is the ponder move of a book move without one.
+const noPonder = "none"
+
+// Move is a move of the book.
+type Move struct {
+	// Move and Ponder, the expected reply, are in USI notation. Ponder is empty when unknown.
+	Move   string
+	Ponder string
+	// Value is the score of the move in centipawns, for the side to move.
+	Value int
+	// Depth is the depth the move was searched to, 0 for a move taken from game records.
+	Depth int
+	// Count is how many times the move was played.
+	Count int
+}
+
+func (m Move) String() string {
+	ponder := m.Ponder
+	if ponder == "" {
+		ponder = noPonder
+	}
+	return fmt.Sprintf("%s %s %d %d %d", m.Move, ponder, m.Value, m.Depth, m.Count)
+}
+
+// entry are the moves of a position, with the ply it was first reached at.
+type entry struct {
+	ply   int
+	moves []Move
+}
+
+// Book is a set of positions and the moves to play in them. It isn't safe for concurrent use.
+type Book struct {
+	// positions are the entries by shogi.Board.Position.
+	positions map[string]*entry
+}
+
+// New returns an empty book.
+func New() *Book {
+	return &Book{positions: map[string]*entry{}}
+}
+
+// Len returns the number of positions in the book.
+func (b *Book) Len() int {
+	return len(b.positions)
+}
+
+// Add adds m to the moves of the position of sfen, replacing a move already in the book.
+func (b *Book) Add(sfen string, m Move) error {
+	board := shogi.NewBoard()
+	if err := board.LoadSfen(sfen); err != nil {
+		return fmt.Errorf("book: %w", err)
+	}
+	ply := 1
+	if fields := strings.Fields(sfen); len(fields) == 4 {
+		if n, err := strconv.Atoi(fields[3]); err == nil {
+			ply = n
+		}
+	}
+	b.add(board.Position(), ply, m)
+	return nil
+}
+
+func (b *Book) add(position string, ply int, m Move) {
+	e := b.positions[position]
+	if e == nil {
+		e = &entry{ply: ply}
+		b.positions[position] = e
+	}
+	if i := slices.IndexFunc(e.moves, func(o Move) bool { return o.Move == m.Move }); i >= 0 {
+		e.moves[i] = m
+	} else {
+		e.moves = append(e.moves, m)
+	}
+	sortMoves(e.moves)
+}
+
+// sortMoves puts the most played moves first, then the best valued.
+func sortMoves(moves []Move) {
+	slices.SortStableFunc(moves, func(a, b Move) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return b.Value - a.Value
+	})
+}
+
+// Moves returns the legal moves of the book in the position of board, the most played first.
+func (b *Book) Moves(board shogi.Board) []Move {
+	e := b.positions[board.Position()]
+	if e == nil {
+		return nil
+	}
+	legal := map[string]bool{}
+	for _, m := range board.Clone().LegalMoves() {
+		legal[m.USI()] = true
+	}
+	moves := []Move{}
+	for _, m := range e.moves {
+		if legal[m.Move] {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// Probe picks a move of the book for board, at random in proportion to how often each move was
+// played. It reports false when the position isn't in the book.
+func (b *Book) Probe(board shogi.Board) (Move, bool) {
+	moves := b.Moves(board)
+	if len(moves) == 0 {
+		return Move{}, false
+	}
+	total := 0
+	for _, m := range moves {
+		total += max(m.Count, 1)
+	}
+	n := rand.IntN(total)
+	for _, m := range moves {
+		n -= max(m.Count, 1)
+		if n < 0 {
+			return m, true
+		}
+	}
+	return moves[0], true
+}
+
+// Read reads a book in the YaneuraOu format.
+func Read(r io.Reader) (*Book, error) {
+	b := New()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var position string
+	ply := 0
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"):
+			continue
+		case strings.HasPrefix(line, "sfen "):
+			board := shogi.NewBoard()
+			sfen := strings.TrimPrefix(line, "sfen ")
+			if err := board.LoadSfen(sfen); err != nil {
+				return nil, fmt.Errorf("book: line %d: %w", n, err)
+			}
+			position, ply = board.Position(), 1
+			if fields := strings.Fields(sfen); len(fields) == 4 {
+				if p, err := strconv.Atoi(fields[3]); err == nil {
+					ply = p
+				}
+			}
+			continue
+		}
+		if position == "" {
+			return nil, fmt.Errorf("book: line %d: move before any sfen", n)
+		}
+		m, err := parseMove(line)
+		if err != nil {
+			return nil, fmt.Errorf("book: line %d: %w", n, err)
+		}
+		b.add(position, ply, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parseMove reads a move line: <move> <ponder> <value> <depth> [<count>]
+func parseMove(line string) (Move, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return Move{}, fmt.Errorf("expecting <move> <ponder> <value> <depth> [<count>], received %q", line)
+	}
+	m := Move{Move: fields[0], Ponder: fields[1]}
+	if m.Ponder == noPonder {
+		m.Ponder = ""
+	}
+	var err error
+	if m.Value, err = strconv.Atoi(fields[2]); err != nil {
+		return Move{}, fmt.Errorf("invalid value %q", fields[2])
+	}
+	if m.Depth, err = strconv.Atoi(fields[3]); err != nil {
+		return Move{}, fmt.Errorf("invalid depth %q", fields[3])
+	}
+	if len(fields) > 4 {
+		if m.Count, err = strconv.Atoi(fields[4]); err != nil {
+			return Move{}, fmt.Errorf("invalid count %q", fields[4])
+		}
+	}
+	return m, nil
+}
+
+// Write writes the book in the YaneuraOu format, the positions sorted by SFEN.
+func (b *Book) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, Header)
+	positions := make([]string, 0, len(b.positions))
+	for p := range b.positions {
+		positions = append(positions, p)
+	}
+	slices.Sort(positions)
+	for _, p := range positions {
+		e := b.positions[p]
+		fmt.Fprintf(bw, "sfen %s %d\n", p, e.ply)
+		for _, m := range e.moves {
+			fmt.Fprintln(bw, m)
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadFile reads the book in path.
+func ReadFile(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// WriteFile writes the book to path.
+func (b *Book) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
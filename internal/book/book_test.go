@@ -0,0 +1,170 @@
+package book_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/book"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func record(result shogi.Outcome, moves ...string) kifu.Record {
+	r := kifu.NewRecord("sente", "gote")
+	for _, m := range moves {
+		r.Moves = append(r.Moves, kifu.Move{USI: m})
+	}
+	r.Result = result
+	return r
+}
+
+func board(t *testing.T, moves ...string) shogi.Board {
+	t.Helper()
+	b, err := record(shogi.NoOutcome, moves...).Replay(-1)
+	if err != nil {
+		t.Fatalf("Replay() failed: %v", err)
+	}
+	return b
+}
+
+func TestBuilder(t *testing.T) {
+	b := book.NewBuilder(book.WithMaxPly(2), book.WithMinCount(2))
+	records := []kifu.Record{
+		record(shogi.BlackWon, "7g7f", "3c3d", "2g2f"),
+		record(shogi.BlackWon, "7g7f", "3c3d", "6g6f"),
+		record(shogi.Draw, "7g7f", "8c8d"),
+		record(shogi.WhiteWon, "2g2f", "8c8d"),
+	}
+	for _, r := range records {
+		if err := b.Add(r); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	bk := b.Book()
+
+	// 2g2f and 8c8d were played once, and the third moves are past the max ply.
+	if bk.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", bk.Len())
+	}
+	want := []book.Move{{Move: "7g7f", Ponder: "3c3d", Value: 508, Count: 3}}
+	if got := bk.Moves(board(t)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Moves() = %v, want %v", got, want)
+	}
+	want = []book.Move{{Move: "3c3d", Ponder: "2g2f", Value: -659, Count: 2}}
+	if got := bk.Moves(board(t, "7g7f")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Moves(7g7f) = %v, want %v", got, want)
+	}
+	if got := bk.Moves(board(t, "2g2f")); len(got) != 0 {
+		t.Errorf("Moves(2g2f) = %v, want none", got)
+	}
+}
+
+const yaneuraou = `#YANEURAOU-DB2016 1.00
+sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1
+2g2f 8c8d 30 24 10
+7g7f none 50 24 20
+// a comment
+sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL w - 2
+3c3d 2g2f 0 20
+`
+
+func TestRead_Write(t *testing.T) {
+	bk, err := book.Read(strings.NewReader(yaneuraou))
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if bk.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", bk.Len())
+	}
+	want := []book.Move{
+		{Move: "7g7f", Value: 50, Depth: 24, Count: 20},
+		{Move: "2g2f", Ponder: "8c8d", Value: 30, Depth: 24, Count: 10},
+	}
+	if got := bk.Moves(board(t)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Moves() = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := bk.Write(&buf); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	wantText := `#YANEURAOU-DB2016 1.00
+sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL w - 2
+3c3d 2g2f 0 20 0
+sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1
+7g7f none 50 24 20
+2g2f 8c8d 30 24 10
+`
+	if buf.String() != wantText {
+		t.Errorf("Write() =\n%s\nwant\n%s", buf.String(), wantText)
+	}
+	again, err := book.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := again.Moves(board(t)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Moves() after a round trip = %v, want %v", got, want)
+	}
+}
+
+func TestRead_rejects(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		text string
+	}{
+		{
+			name: "move before any position",
+			text: "7g7f none 0 0 1\n",
+		},
+		{
+			name: "invalid sfen",
+			text: "sfen invalid\n",
+		},
+		{
+			name: "missing depth",
+			text: "sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1\n7g7f none 0\n",
+		},
+		{
+			name: "invalid value",
+			text: "sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1\n7g7f none high 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := book.Read(strings.NewReader(tt.text)); err == nil {
+				t.Errorf("Read() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func TestBook_Probe(t *testing.T) {
+	bk := book.New()
+	if err := bk.Add(shogi.StartingPosition, book.Move{Move: "7g7f", Count: 3}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	// Illegal moves of the book are never played.
+	if err := bk.Add(shogi.StartingPosition, book.Move{Move: "7g7e", Count: 100}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := bk.Add(shogi.StartingPosition, book.Move{Move: "2g2f", Count: 1}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	played := map[string]int{}
+	for range 200 {
+		m, ok := bk.Probe(board(t))
+		if !ok {
+			t.Fatalf("Probe() found no move")
+		}
+		played[m.Move]++
+	}
+	if played["7g7e"] > 0 || played["7g7f"] <= played["2g2f"] || played["2g2f"] == 0 {
+		t.Errorf("Probe() played %v, want 7g7f about 3 times as often as 2g2f", played)
+	}
+	if _, ok := bk.Probe(board(t, "7g7f")); ok {
+		t.Errorf("Probe() found a move out of the book")
+	}
+}
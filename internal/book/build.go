@@ -0,0 +1,138 @@
+package book
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// maxValue bounds the value derived from a win rate.
+const maxValue = 2000
+
+// stats are the games a move was played in, from the point of view of the player making it.
+type stats struct {
+	count  int
+	wins   int
+	draws  int
+	ponder map[string]int
+}
+
+// Builder builds a book from game records.
+type Builder struct {
+	maxPly   int
+	minCount int
+	// moves are the stats of the moves played in every position, by shogi.Board.Position.
+	moves map[string]map[string]*stats
+	plies map[string]int
+}
+
+// WithMaxPly only adds the first n moves of every game, 0 adds them all.
+func WithMaxPly(n int) func(*Builder) {
+	return func(b *Builder) {
+		b.maxPly = n
+	}
+}
+
+// WithMinCount leaves out the moves played in fewer than n games.
+func WithMinCount(n int) func(*Builder) {
+	return func(b *Builder) {
+		b.minCount = n
+	}
+}
+
+// NewBuilder returns a builder of books of the first 32 moves of the games, played at least once.
+func NewBuilder(options ...func(*Builder)) *Builder {
+	b := &Builder{
+		maxPly:   32,
+		minCount: 1,
+		moves:    map[string]map[string]*stats{},
+		plies:    map[string]int{},
+	}
+	for _, f := range options {
+		f(b)
+	}
+	return b
+}
+
+// Add counts the moves of r. Records without a result count as played but neither won nor drawn.
+func (bd *Builder) Add(r kifu.Record) error {
+	b, err := r.Board()
+	if err != nil {
+		return err
+	}
+	for i, m := range r.Moves {
+		if bd.maxPly > 0 && i >= bd.maxPly {
+			break
+		}
+		mo, err := b.ResolveUSIMove(m.USI)
+		if err != nil {
+			return fmt.Errorf("book: move %d: %w", i+1, err)
+		}
+		position, turn := b.Position(), b.Turn
+		if err := b.ProcessMove(&mo); err != nil {
+			return fmt.Errorf("book: move %d: %w", i+1, err)
+		}
+
+		if bd.moves[position] == nil {
+			bd.moves[position] = map[string]*stats{}
+			bd.plies[position] = i + 1
+		}
+		s := bd.moves[position][m.USI]
+		if s == nil {
+			s = &stats{ponder: map[string]int{}}
+			bd.moves[position][m.USI] = s
+		}
+		s.count++
+		switch r.Result {
+		case shogi.Winner(turn):
+			s.wins++
+		case shogi.Draw:
+			s.draws++
+		}
+		if i+1 < len(r.Moves) {
+			s.ponder[r.Moves[i+1].USI]++
+		}
+	}
+	return nil
+}
+
+// Book returns the book of the records added so far. The value of a move is derived from its
+// win rate, draws counting as half a win.
+func (bd *Builder) Book() *Book {
+	b := New()
+	for position, moves := range bd.moves {
+		for move, s := range moves {
+			if s.count < bd.minCount {
+				continue
+			}
+			b.add(position, bd.plies[position], Move{
+				Move:   move,
+				Ponder: s.bestPonder(),
+				Value:  s.value(),
+				Count:  s.count,
+			})
+		}
+	}
+	return b
+}
+
+// value converts the win rate of the move to centipawns with the logistic model engines use,
+// smoothed so a single game doesn't make a move winning or lost.
+func (s *stats) value() int {
+	p := (float64(s.wins) + float64(s.draws)/2 + 1) / float64(s.count+2)
+	v := 600 * math.Log(p/(1-p))
+	return int(math.Round(math.Max(-maxValue, math.Min(maxValue, v))))
+}
+
+// bestPonder returns the most played reply.
+func (s *stats) bestPonder() string {
+	best, n := "", 0
+	for m, c := range s.ponder {
+		if c > n || c == n && m < best {
+			best, n = m, c
+		}
+	}
+	return best
+}
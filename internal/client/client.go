@@ -0,0 +1,368 @@
+// Package client plays games on a shogo server, keeping a shogi.Game in sync with the moves
+// of the remote opponent.
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Client is a player connected to a server.
+//
+// Messages are read by Run and delivered on Events, they only change the game once they are
+// given back to Handle. That way the game is only touched by the goroutine driving the UI.
+type Client struct {
+	conn   *protocol.Conn
+	closer io.Closer
+	name   string
+	game   *shogi.Game
+	color  shogi.Color
+	events chan protocol.Payload
+	// address is the server dialed, to reconnect to it.
+	address string
+	// token lets the player resume the current game.
+	token string
+
+	playing bool
+	// watching is set while following a game as a spectator.
+	watching bool
+	// drawOffered is set while the opponent's draw offer can be accepted.
+	drawOffered bool
+	// remote is set while a move of the opponent is played, so it isn't sent back.
+	remote bool
+}
+
+// WithName sets the name the player joins with.
+func WithName(name string) func(*Client) {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// New returns a client talking to a server over conn. The games it plays are loaded in game,
+// local moves played on game are sent to the server.
+func New(conn io.ReadWriteCloser, game *shogi.Game, options ...func(*Client)) *Client {
+	c := &Client{
+		conn:   protocol.NewConn(conn),
+		closer: conn,
+		name:   game.SentePlayer(),
+		game:   game,
+		events: make(chan protocol.Payload, 16),
+	}
+	for _, f := range options {
+		f(c)
+	}
+	game.SetMoveHandler(c.sendMove)
+	return c
+}
+
+// Dial connects to the server at address and joins it.
+func Dial(ctx context.Context, address string, game *shogi.Game, options ...func(*Client)) (*Client, error) {
+	c, err := Connect(ctx, address, game, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Join(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect connects to the server at address without joining, e.g. to watch a game.
+func Connect(ctx context.Context, address string, game *shogi.Game, options ...func(*Client)) (*Client, error) {
+	d := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := New(conn, game, options...)
+	c.address = address
+	return c, nil
+}
+
+// Reconnect dials the server again after the connection was lost and resumes the game in
+// progress. Run must have returned, it has to be started again to receive the messages.
+func (c *Client) Reconnect(ctx context.Context) error {
+	if c.address == "" || c.token == "" {
+		return fmt.Errorf("client: no game to resume")
+	}
+	d := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return err
+	}
+	c.conn = protocol.NewConn(conn)
+	c.closer = conn
+	c.events = make(chan protocol.Payload, 16)
+	if err := c.conn.Send(protocol.Resume{Token: c.token}); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// Join asks the server for a game.
+func (c *Client) Join() error {
+	return c.conn.Send(protocol.Join{Name: c.name})
+}
+
+// Watch asks the server to follow the game with id as a spectator, an empty id lists the games
+// in progress.
+func (c *Client) Watch(id string) error {
+	return c.conn.Send(protocol.Watch{GameID: id})
+}
+
+// Run reads messages from the server and delivers them on Events until the connection is
+// closed or ctx is done. Events is closed when it returns.
+func (c *Client) Run(ctx context.Context) error {
+	defer close(c.events)
+	stop := context.AfterFunc(ctx, func() { c.closer.Close() })
+	defer stop()
+
+	for {
+		p, err := c.conn.Receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		select {
+		case c.events <- p:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Events returns the messages received from the server.
+func (c *Client) Events() <-chan protocol.Payload {
+	return c.events
+}
+
+// Handle applies a message received from the server to the game.
+func (c *Client) Handle(p protocol.Payload) error {
+	switch p := p.(type) {
+	case protocol.Start:
+		return c.start(p)
+	case protocol.History:
+		return c.watch(p)
+	case protocol.Move:
+		return c.playRemote(p)
+	case protocol.Clock:
+		if clock := c.game.Clock(); clock != nil {
+			clock.Set(shogi.Black, time.Duration(p.Sente)*time.Millisecond)
+			clock.Set(shogi.White, time.Duration(p.Gote)*time.Millisecond)
+		}
+	case protocol.DrawOffer:
+		c.drawOffered = c.playing
+	case protocol.Presence:
+		// The server stops the clocks while a player is away.
+		if clock := c.game.Clock(); clock != nil && (c.playing || c.watching) {
+			if p.Connected {
+				clock.Start(c.game.Board().Turn)
+			} else {
+				clock.Stop()
+			}
+		}
+	case protocol.GameOver:
+		c.playing, c.watching, c.drawOffered = false, false, false
+		c.token = ""
+		c.game.End(shogi.Outcome(p.Result))
+	}
+	return nil
+}
+
+func (c *Client) start(p protocol.Start) error {
+	var color shogi.Color
+	switch p.Color {
+	case shogi.Black.String():
+		color = shogi.Black
+	case shogi.White.String():
+		color = shogi.White
+	default:
+		return fmt.Errorf("client: invalid color %q", p.Color)
+	}
+	if err := c.load(p.Sente, p.Gote, p.Position, p.TimeControl); err != nil {
+		return err
+	}
+
+	c.color = color
+	c.token = p.Token
+	c.playing = true
+	c.watching = false
+	c.drawOffered = false
+	return nil
+}
+
+// watch loads the game a spectator starts watching, or a player resumes.
+func (c *Client) watch(p protocol.History) error {
+	if err := c.load(p.Sente, p.Gote, p.Position, p.TimeControl); err != nil {
+		return err
+	}
+	c.remote = true
+	defer func() { c.remote = false }()
+	for _, usi := range p.Moves {
+		m, err := c.game.Board().ResolveUSIMove(usi)
+		if err != nil {
+			return err
+		}
+		if err := c.game.Move(m); err != nil {
+			return err
+		}
+	}
+	c.drawOffered = false
+	if p.Color == "" {
+		c.playing, c.watching = false, true
+		return nil
+	}
+	c.playing, c.watching = true, false
+	c.color = shogi.Black
+	if p.Color == shogi.White.String() {
+		c.color = shogi.White
+	}
+	c.token = p.Token
+	return nil
+}
+
+// load replaces the game with a new one between sente and gote, keeping the AI client.
+func (c *Client) load(sente, gote, position, timeControl string) error {
+	options := []func(*shogi.Game){}
+	if timeControl != "" {
+		tc, err := shogi.ParseTimeControl(timeControl)
+		if err != nil {
+			return err
+		}
+		options = append(options, shogi.WithClock(tc))
+	}
+	b := shogi.NewBoard()
+	if position == "" {
+		position = shogi.StartingPosition
+	}
+	if err := b.LoadSfen(position); err != nil {
+		return err
+	}
+	g := shogi.NewGame(sente, gote, options...)
+	g.SetBoard(&b)
+	g.SetAIClient(c.game.GetAIClient())
+	g.SetMoveHandler(c.sendMove)
+	*c.game = *g
+	return nil
+}
+
+func (c *Client) playRemote(p protocol.Move) error {
+	if !c.playing && !c.watching {
+		return fmt.Errorf("client: move %s without a game", p.Move)
+	}
+	if ply := len(c.game.Moves()) + 1; p.Ply != ply {
+		return fmt.Errorf("client: move %s is ply %d, expecting %d", p.Move, p.Ply, ply)
+	}
+	b := c.game.Board()
+	if c.playing && b.Turn == c.color {
+		return fmt.Errorf("client: opponent moved %s on our turn", p.Move)
+	}
+	m, err := b.ResolveUSIMove(p.Move)
+	if err != nil {
+		return err
+	}
+	if !b.IsLegal(m) {
+		return fmt.Errorf("client: illegal move %s from the opponent", p.Move)
+	}
+
+	c.remote = true
+	defer func() { c.remote = false }()
+	// Any draw offer of the opponent was turned down by playing on.
+	c.drawOffered = false
+	return c.game.Move(m)
+}
+
+// sendMove is the move handler of the game, it sends local moves to the server.
+func (c *Client) sendMove(m shogi.Move) error {
+	if c.remote {
+		return nil
+	}
+	if !c.playing {
+		return fmt.Errorf("client: no game in progress")
+	}
+	b := c.game.Board()
+	if b.Turn != c.color {
+		return fmt.Errorf("client: not your turn")
+	}
+	if !b.IsLegal(m) {
+		return fmt.Errorf("client: illegal move %s", m.USI())
+	}
+	return c.conn.Send(protocol.Move{Move: m.USI(), Ply: len(c.game.Moves()) + 1})
+}
+
+// Resign gives up the current game.
+func (c *Client) Resign() error {
+	if !c.playing {
+		return fmt.Errorf("client: no game in progress")
+	}
+	if err := c.conn.Send(protocol.Resign{}); err != nil {
+		return err
+	}
+	c.playing = false
+	c.token = ""
+	c.game.End(shogi.Winner(c.color.Opponent()))
+	return nil
+}
+
+// OfferDraw offers a draw to the opponent.
+func (c *Client) OfferDraw() error {
+	if !c.playing {
+		return fmt.Errorf("client: no game in progress")
+	}
+	return c.conn.Send(protocol.DrawOffer{})
+}
+
+// AcceptDraw accepts the draw offered by the opponent, the server ends the game.
+func (c *Client) AcceptDraw() error {
+	if !c.drawOffered {
+		return fmt.Errorf("client: no draw offered")
+	}
+	c.drawOffered = false
+	return c.conn.Send(protocol.DrawAccept{})
+}
+
+// Chat sends a text message to the opponent.
+func (c *Client) Chat(text string) error {
+	return c.conn.Send(protocol.Chat{From: c.name, Text: text})
+}
+
+// Name returns the name the player joined with.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Color returns the side the player plays in the current or last game.
+func (c *Client) Color() shogi.Color {
+	return c.color
+}
+
+// Playing reports whether a game is in progress.
+func (c *Client) Playing() bool {
+	return c.playing
+}
+
+// Token returns the token to resume the current game with, empty if there is none.
+func (c *Client) Token() string {
+	return c.token
+}
+
+// Watching reports whether a game is followed as a spectator.
+func (c *Client) Watching() bool {
+	return c.watching
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.closer.Close()
+}
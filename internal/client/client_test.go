@@ -0,0 +1,358 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/client"
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// stubServer is the server end of a client connection, driven by the test.
+type stubServer struct {
+	t    *testing.T
+	conn *protocol.Conn
+}
+
+func (s stubServer) send(p protocol.Payload) {
+	s.t.Helper()
+	if err := s.conn.Send(p); err != nil {
+		s.t.Fatalf("server Send() failed: %v", err)
+	}
+}
+
+func (s stubServer) receive() protocol.Payload {
+	s.t.Helper()
+	p, err := s.conn.Receive()
+	if err != nil {
+		s.t.Fatalf("server Receive() failed: %v", err)
+	}
+	return p
+}
+
+// connect returns a client joined to a stub server and running.
+func connect(t *testing.T) (*client.Client, stubServer) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { serverConn.Close() })
+	server := stubServer{t: t, conn: protocol.NewConn(serverConn)}
+
+	c := client.New(clientConn, shogi.NewGame("me", "opponent"), client.WithName("me"))
+	go func() {
+		if err := c.Join(); err != nil {
+			t.Errorf("Join() failed: %v", err)
+		}
+	}()
+	if got := server.receive(); got != (protocol.Join{Name: "me"}) {
+		t.Fatalf("server received %#v, want join", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go c.Run(ctx)
+	return c, server
+}
+
+// handleNext gives the next message received by c to Handle.
+func handleNext(t *testing.T, c *client.Client) (protocol.Payload, error) {
+	t.Helper()
+	select {
+	case p, ok := <-c.Events():
+		if !ok {
+			t.Fatal("Events() closed")
+		}
+		return p, c.Handle(p)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for a message")
+	}
+	return nil, nil
+}
+
+// playLocal plays a move on the game of c in the background, as the move is sent to the
+// server while it is played.
+func playLocal(g *shogi.Game, usi string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- g.Move(m)
+	}()
+	return done
+}
+
+func TestClient_plays_a_game(t *testing.T) {
+	g := shogi.NewGame("me", "opponent")
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	server := stubServer{t: t, conn: protocol.NewConn(serverConn)}
+	c := client.New(clientConn, g)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	server.send(protocol.Start{Sente: "opponent", Gote: "me", Color: "w", TimeControl: "10m+30s"})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(start) failed: %v", err)
+	}
+	if !c.Playing() || c.Color() != shogi.White {
+		t.Fatalf("after start Playing() = %v, Color() = %v", c.Playing(), c.Color())
+	}
+	if g.SentePlayer() != "opponent" || g.Clock() == nil {
+		t.Errorf("game not loaded from start, sente %q, clock %v", g.SentePlayer(), g.Clock())
+	}
+
+	if err := <-playLocal(g, "7g7f"); err == nil {
+		t.Errorf("Move() succeeded on the opponent's turn")
+	}
+
+	server.send(protocol.Move{Move: "7g7f", Ply: 1})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(move) failed: %v", err)
+	}
+
+	done := playLocal(g, "3c3d")
+	if got := server.receive(); got != (protocol.Move{Move: "3c3d", Ply: 2}) {
+		t.Errorf("server received %#v, want move 3c3d", got)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if len(g.Moves()) != 2 {
+		t.Errorf("Moves() = %d, want 2", len(g.Moves()))
+	}
+
+	server.send(protocol.Clock{Sente: 500000, Gote: 590000})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(clock) failed: %v", err)
+	}
+	if got := g.Clock().Remaining(shogi.White); got != 590*time.Second {
+		t.Errorf("Remaining() of gote = %v, want 9m50s", got)
+	}
+
+	server.send(protocol.GameOver{Result: string(shogi.WhiteWon), Reason: "resign"})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(game over) failed: %v", err)
+	}
+	if c.Playing() || g.Outcome() != shogi.WhiteWon {
+		t.Errorf("after game over Playing() = %v, Outcome() = %v", c.Playing(), g.Outcome())
+	}
+}
+
+func TestClient_Handle_rejects_remote_moves(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		move protocol.Move
+	}{
+		{
+			name: "illegal move",
+			move: protocol.Move{Move: "7g7e", Ply: 1},
+		},
+		{
+			name: "out of sync",
+			move: protocol.Move{Move: "7g7f", Ply: 3},
+		},
+		{
+			name: "not a move",
+			move: protocol.Move{Move: "hello", Ply: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := connect(t)
+			server.send(protocol.Start{Sente: "opponent", Gote: "me", Color: "w"})
+			if _, err := handleNext(t, c); err != nil {
+				t.Fatalf("Handle(start) failed: %v", err)
+			}
+			server.send(tt.move)
+			if _, err := handleNext(t, c); err == nil {
+				t.Errorf("Handle(%v) succeeded unexpectedly", tt.move)
+			}
+		})
+	}
+}
+
+func TestClient_draw_and_chat(t *testing.T) {
+	c, server := connect(t)
+	if err := c.AcceptDraw(); err == nil {
+		t.Errorf("AcceptDraw() succeeded without an offer")
+	}
+
+	server.send(protocol.Start{Sente: "me", Gote: "opponent", Color: "b"})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(start) failed: %v", err)
+	}
+	server.send(protocol.Chat{From: "opponent", Text: "draw?"})
+	if p, _ := handleNext(t, c); p != (protocol.Chat{From: "opponent", Text: "draw?"}) {
+		t.Errorf("Events() = %#v, want the chat message", p)
+	}
+	server.send(protocol.DrawOffer{})
+	handleNext(t, c)
+
+	go c.AcceptDraw()
+	if got := server.receive(); got != (protocol.DrawAccept{}) {
+		t.Errorf("server received %#v, want draw accept", got)
+	}
+	go c.Chat("thanks")
+	if got := server.receive(); got != (protocol.Chat{From: "me", Text: "thanks"}) {
+		t.Errorf("server received %#v, want chat", got)
+	}
+}
+
+func TestClient_Resign(t *testing.T) {
+	c, server := connect(t)
+	server.send(protocol.Start{Sente: "me", Gote: "opponent", Color: "b"})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(start) failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Resign() }()
+	if got := server.receive(); got != (protocol.Resign{}) {
+		t.Errorf("server received %#v, want resign", got)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Resign() failed: %v", err)
+	}
+	if c.Playing() {
+		t.Errorf("Playing() after Resign()")
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	g := shogi.NewGame("me", "opponent")
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	server := stubServer{t: t, conn: protocol.NewConn(serverConn)}
+	c := client.New(clientConn, g)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	go func() {
+		if err := c.Watch("7"); err != nil {
+			t.Errorf("Watch() failed: %v", err)
+		}
+	}()
+	if got := server.receive(); got != (protocol.Watch{GameID: "7"}) {
+		t.Fatalf("server received %#v, want watch", got)
+	}
+
+	server.send(protocol.History{ID: "7", Sente: "habu", Gote: "fujii", TimeControl: "10m", Moves: []string{"7g7f", "3c3d"}})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(history) failed: %v", err)
+	}
+	if !c.Watching() || c.Playing() {
+		t.Fatalf("after history Watching() = %v, Playing() = %v", c.Watching(), c.Playing())
+	}
+	if g.SentePlayer() != "habu" || len(g.Moves()) != 2 || g.Clock() == nil {
+		t.Errorf("game not loaded from history, sente %q, %d moves, clock %v", g.SentePlayer(), len(g.Moves()), g.Clock())
+	}
+
+	if err := <-playLocal(g, "2g2f"); err == nil {
+		t.Errorf("Move() succeeded as a spectator")
+	}
+
+	// Both sides' moves are followed.
+	for i, m := range []string{"2g2f", "8c8d"} {
+		server.send(protocol.Move{Move: m, Ply: i + 3})
+		if _, err := handleNext(t, c); err != nil {
+			t.Fatalf("Handle(%s) failed: %v", m, err)
+		}
+	}
+
+	server.send(protocol.GameOver{Result: shogi.WhiteWon.String(), Reason: "resign"})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(game over) failed: %v", err)
+	}
+	if c.Watching() || g.Outcome() != shogi.WhiteWon {
+		t.Errorf("after game over Watching() = %v, Outcome() = %v", c.Watching(), g.Outcome())
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer l.Close()
+	accept := func() stubServer {
+		t.Helper()
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatalf("Accept() failed: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return stubServer{t: t, conn: protocol.NewConn(conn)}
+	}
+
+	g := shogi.NewGame("me", "opponent")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := client.Dial(ctx, l.Addr().String(), g, client.WithName("me"))
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer c.Close()
+	if err := c.Reconnect(ctx); err == nil {
+		t.Errorf("Reconnect() succeeded without a game")
+	}
+
+	server := accept()
+	server.receive()
+	server.send(protocol.Start{Sente: "opponent", Gote: "me", Color: "w", TimeControl: "10m", Token: "4f2a"})
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(start) failed: %v", err)
+	}
+	// The connection drops.
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	<-done
+
+	if err := c.Reconnect(ctx); err != nil {
+		t.Fatalf("Reconnect() failed: %v", err)
+	}
+	server = accept()
+	if got := server.receive(); got != (protocol.Resume{Token: "4f2a"}) {
+		t.Fatalf("server received %#v, want resume", got)
+	}
+	go c.Run(ctx)
+	server.send(protocol.History{ID: "1", Color: "w", Token: "4f2a", Sente: "opponent", Gote: "me", TimeControl: "10m", Moves: []string{"7g7f"}})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(history) failed: %v", err)
+	}
+	if !c.Playing() || c.Color() != shogi.White || c.Token() != "4f2a" || len(g.Moves()) != 1 {
+		t.Fatalf("after resume Playing() = %v, Color() = %v, Token() = %q, %d moves", c.Playing(), c.Color(), c.Token(), len(g.Moves()))
+	}
+
+	server.send(protocol.Presence{Name: "opponent", Grace: 30000})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(presence) failed: %v", err)
+	}
+	if g.Clock().Running() {
+		t.Errorf("clock running while the opponent is away")
+	}
+	server.send(protocol.Presence{Name: "opponent", Connected: true})
+	if _, err := handleNext(t, c); err != nil {
+		t.Fatalf("Handle(presence) failed: %v", err)
+	}
+	if !g.Clock().Running() || g.Clock().Turn() != shogi.White {
+		t.Errorf("clock not running for gote once the opponent is back")
+	}
+
+	moved := playLocal(g, "3c3d")
+	if got := server.receive(); got != (protocol.Move{Move: "3c3d", Ply: 2}) {
+		t.Fatalf("server received %#v, want the move", got)
+	}
+	if err := <-moved; err != nil {
+		t.Errorf("Move() failed: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// currentProvider returns the provider of the AI agent of game, None when it has none.
+func (c *Commands) currentProvider(game *shogi.Game) string {
+	if game.GetAIClient() == nil {
+		return agent.None
+	}
+	return c.provider
+}
+
+// switchAgent runs the agent command:
+//
+//	agent          show the provider of the AI agent and the ones available
+//	agent <name>   play with the agent of another provider, or none to disable the AI features
+func (c *Commands) switchAgent(game *shogi.Game, gui *gui.GUI, name string) string {
+	if name == "" {
+		return fmt.Sprintf("AI agent: %s, type agent <%s> to switch.", c.currentProvider(game), strings.Join(agent.Providers(), "|"))
+	}
+	a, err := c.openAgent(name)
+	if err != nil {
+		gui.AppendLog(fmt.Sprintf("shogo error: %v", err))
+		return fmt.Sprintf("⚠ %v", err)
+	}
+	// Nothing uses the previous agent once it is closed: the requests of the commands and the
+	// search of the computer player are cancelled and waited for, the player then asks the new one.
+	c.stopRequests()
+	if c.Players != nil {
+		c.Players.Retry()
+	}
+	closeAgent(game.GetAIClient())
+	game.SetAIClient(a)
+	c.provider = name
+	// The explanation was asked to the previous agent, its conversation ends with it.
+	gui.Explanation, c.tutor = nil, nil
+	if a == nil {
+		gui.AppendLog("AI agent disabled.")
+		return "AI agent disabled, type agent <name> to choose one."
+	}
+	gui.AppendLog(fmt.Sprintf("AI agent: %s", name))
+	return fmt.Sprintf("Playing with the %s agent.", name)
+}
+
+// closeAgent shuts down the agent asked by a when it runs a process, such as an engine.
+func closeAgent(a agent.Agent) {
+	if c, ok := agent.Unwrap(a).(io.Closer); ok {
+		_ = c.Close()
+	}
+}
@@ -1,69 +1,298 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/db"
+	"github.com/juanpablocruz/shogo/clientr/internal/explain"
 	"github.com/juanpablocruz/shogo/clientr/internal/gui"
 	"github.com/juanpablocruz/shogo/clientr/internal/input"
+	"github.com/juanpablocruz/shogo/clientr/internal/player"
+	"github.com/juanpablocruz/shogo/clientr/internal/save"
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
 )
 
-func resetGame(game *shogi.Game) *shogi.Game {
-	newGame := shogi.NewGame(game.SentePlayer(), game.GotePlayer())
-	return newGame
+// AutosaveName is the save written when quitting an unfinished game.
+const AutosaveName = "autosave"
+
+// Commands runs the commands typed in the prompt, keeping what they share during a session.
+type Commands struct {
+	// Players asks the computer players of the game for their moves, nil when there are none. The
+	// retry and agent commands ask it again.
+	Players *player.Controller
+
+	// store keeps the games of the save, load and list commands.
+	store *save.Store
+	// database is the game database of the db command, opened on first use from databasePath.
+	database     *db.DB
+	databasePath string
+	// provider names the provider of the AI agent in use and openAgent opens the agent of a
+	// provider for the agent command.
+	provider  string
+	openAgent func(name string) (agent.Agent, error)
+	// tutor holds the conversation of the last explanation, for the questions of the ask command.
+	tutor *explain.Tutor
+
+	// inflight is the agent request running, nil when none is, and latest the last one started,
+	// which returns after all the others.
+	inflightMu sync.Mutex
+	inflight   *request
+	latest     *request
+}
+
+// WithSaveDir sets the directory of the save, load and list commands, save.DefaultDir by default.
+func WithSaveDir(dir string) func(*Commands) {
+	return func(c *Commands) {
+		c.store = save.NewStore(dir)
+	}
+}
+
+// WithDatabase sets the file of the game database searched by the db command, db.DefaultPath by
+// default.
+func WithDatabase(path string) func(*Commands) {
+	return func(c *Commands) {
+		c.databasePath = path
+	}
 }
 
-func saveGame(game *shogi.Game) string {
-	return game.Board().String()
+// WithAgents sets the provider of the AI agent in use, current, and how the agent command opens
+// the agent of another provider, from the environment by default.
+func WithAgents(current string, open func(name string) (agent.Agent, error)) func(*Commands) {
+	return func(c *Commands) {
+		c.provider, c.openAgent = current, open
+	}
 }
 
-func hint(game *shogi.Game, gui *gui.GUI, in *input.Input) string {
-	gui.DrawMsgLabel("Thinking...", gui.Theme)
-	gui.Render(game, in)
+// New returns the commands of a session, options configure them.
+func New(options ...func(*Commands)) *Commands {
+	c := &Commands{
+		store:        save.NewStore(save.DefaultDir()),
+		databasePath: db.DefaultPath(),
+		openAgent: func(name string) (agent.Agent, error) {
+			return agent.New(name, os.Getenv)
+		},
+	}
+	for _, f := range options {
+		f(c)
+	}
+	return c
+}
 
-	if game.GetAIClient() != nil {
-		ai := game.GetAIClient()
-		boardStr := game.Board().String()
-		gui.AppendLog(fmt.Sprintf("Sending AI client: %s", boardStr))
-		h, err := ai.AskHint(boardStr)
+// searchDatabase logs the moves played from the current position in the game database.
+func (c *Commands) searchDatabase(game *shogi.Game, gui *gui.GUI) string {
+	if c.database == nil {
+		d, err := db.Open(c.databasePath)
 		if err != nil {
-			gui.AppendLog(fmt.Sprintf("shogo error: ask hint error %v", err))
-			return ""
+			return fmt.Sprintf("\u26A0 %v", err)
 		}
+		c.database = d
+	}
+	r := c.database.Search(*game.Board())
+	if r.Games == 0 {
+		return fmt.Sprintf("Position not found in %d games.", c.database.Len())
+	}
+	const shown = 5
+	for i := min(len(r.Next), shown) - 1; i >= 0; i-- {
+		gui.AppendLog(fmt.Sprintf("db %s", r.Next[i]))
+	}
+	return fmt.Sprintf("Position reached in %s.", r.Stats)
+}
 
-		gui.AppendLog(fmt.Sprintf("AI responds: %s", h))
-		gui.SetHint(h)
-		return h
-	} else {
-		gui.AppendLog("No ai client found")
+// resetGame replaces the game in place with a new one between the same players, with the same
+// time control and agent.
+func resetGame(game *shogi.Game) {
+	options := []func(*shogi.Game){}
+	if clock := game.Clock(); clock != nil {
+		options = append(options, shogi.WithClock(clock.TimeControl()))
+	}
+	newGame := shogi.NewGame(game.SentePlayer(), game.GotePlayer(), options...)
+	newGame.SetAIClient(game.GetAIClient())
+	*game = *newGame
+}
+
+// saveGame saves the game as name, named after the current time if empty.
+func (c *Commands) saveGame(game *shogi.Game, gui *gui.GUI, name string) string {
+	if name == "" {
+		name = time.Now().Format("20060102-150405")
+	}
+	if err := c.store.Save(c.newSave(name, game, gui)); err != nil {
+		return fmt.Sprintf("\u26A0 %v", err)
+	}
+	gui.AppendLog(fmt.Sprintf("Saved %s: %s", name, game.Board().String()))
+	return fmt.Sprintf("Saved as %s, type load %s to resume it.", name, name)
+}
+
+// newSave returns the save of the game named name, with the pending hint and the provider of its
+// agent.
+func (c *Commands) newSave(name string, game *shogi.Game, gui *gui.GUI) save.Game {
+	s := save.New(name, game, gui.Hint)
+	s.Agent = c.currentProvider(game)
+	return s
+}
+
+// loadGame replaces the game with the save named name.
+func (c *Commands) loadGame(game *shogi.Game, gui *gui.GUI, name string) string {
+	if name == "" {
+		return "\u26A0 Usage: load <name>"
+	}
+	s, err := c.store.Load(name)
+	if err != nil {
+		return fmt.Sprintf("\u26A0 %v", err)
+	}
+	if err := c.restore(game, gui, s); err != nil {
+		return fmt.Sprintf("\u26A0 %v", err)
+	}
+	return fmt.Sprintf("Loaded %s, %d moves played.", name, len(s.Moves))
+}
+
+// restore replaces the game with s in place, played with the agent of the provider it was saved
+// with, or the current one when that can't be opened.
+func (c *Commands) restore(game *shogi.Game, gui *gui.GUI, s save.Game) error {
+	g, err := s.Restore(game.GetAIClient())
+	if err != nil {
+		return err
+	}
+	*game = *g
+	if s.Agent != "" && s.Agent != c.currentProvider(game) {
+		c.switchAgent(game, gui, s.Agent)
+	}
+	gui.Hint = s.Hint
+	gui.AppendLog(fmt.Sprintf("Loaded %s: %s vs %s", s.Name, s.Sente, s.Gote))
+	return nil
+}
+
+// listGames logs the saved games.
+func (c *Commands) listGames(gui *gui.GUI) string {
+	games, err := c.store.List()
+	if err != nil {
+		return fmt.Sprintf("\u26A0 %v", err)
+	}
+	if len(games) == 0 {
+		return fmt.Sprintf("No saved games in %s", c.store.Dir())
+	}
+	for i := len(games) - 1; i >= 0; i-- {
+		g := games[i]
+		status := "in progress"
+		if g.Finished() {
+			status = g.Outcome.String()
+		}
+		gui.AppendLog(fmt.Sprintf("%s: %s vs %s, %d moves, %s (%s)", g.Name, g.Sente, g.Gote, len(g.Moves), status, g.SavedAt.Format("2006-01-02 15:04")))
 	}
+	return fmt.Sprintf("%d saved games, type load <name> to resume one.", len(games))
+}
+
+// Autosave saves an unfinished game as AutosaveName, for ResumeLast to pick it up.
+func (c *Commands) Autosave(game *shogi.Game, gui *gui.GUI) {
+	if len(game.Moves()) == 0 || game.Outcome() != shogi.NoOutcome {
+		return
+	}
+	_ = c.store.Save(c.newSave(AutosaveName, game, gui))
+}
 
-	return strings.Repeat(" ", 80)
+// ResumeLast replaces the game with the last unfinished save, it reports whether there was one.
+func (c *Commands) ResumeLast(game *shogi.Game, gui *gui.GUI) (bool, error) {
+	s, err := c.store.Last()
+	if errors.Is(err, save.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, c.restore(game, gui, s)
 }
 
-func ProcessCmd(cmd string, game *shogi.Game, gui *gui.GUI, in *input.Input) (string, *shogi.Game) {
+// hint asks the AI agent for a hint in the background, shown when it replies unless the position
+// changed meanwhile.
+func (c *Commands) hint(game *shogi.Game, gui *gui.GUI) string {
+	ai := game.GetAIClient()
+	if ai == nil {
+		gui.AppendLog("No ai client found")
+		return strings.Repeat(" ", 80)
+	}
+	b := game.Board().Clone()
+	position := b.String()
+	history := make([]string, 0, len(game.Moves()))
+	for _, m := range game.Moves() {
+		history = append(history, m.USI())
+	}
+	gui.AppendLog(fmt.Sprintf("Sending AI client: %s", position))
+	c.startRequest(gui, func(ctx context.Context) func() string {
+		rejected := []string{}
+		v := validate.New(ai)
+		v.OnReject = func(reply string, err error) {
+			rejected = append(rejected, fmt.Sprintf("AI reply %q rejected: %v", reply, err))
+		}
+		_, h, err := v.Hint(ctx, b, history)
+		return func() string {
+			for _, r := range rejected {
+				gui.AppendLog(r)
+			}
+			if err != nil {
+				gui.AppendLog(fmt.Sprintf("shogo error: ask hint error %v", err))
+				return "\u26A0 The AI gave no legal move, try hint again."
+			}
+			if game.Board().String() != position {
+				gui.AppendLog(fmt.Sprintf("AI responds %s for a previous position, ignored", h))
+				return strings.Repeat(" ", 80)
+			}
+			gui.AppendLog(fmt.Sprintf("AI responds: %s", h))
+			gui.SetHint(h)
+			return h
+		}
+	})
+	return thinking
+}
+
+func (c *Commands) ProcessCmd(cmd string, game *shogi.Game, gui *gui.GUI, in *input.Input) (string, *shogi.Game) {
 	cmd = strings.TrimSpace(cmd)
 
 	if len(cmd) == 0 {
 		return strings.Repeat(" ", 80), game
 	}
 
+	verb, arg, _ := strings.Cut(cmd, " ")
+	arg = strings.TrimSpace(arg)
+	switch verb {
+	case "save":
+		return c.saveGame(game, gui, arg), game
+	case "load":
+		return c.loadGame(game, gui, arg), game
+	case "list":
+		return c.listGames(gui), game
+	case "db":
+		return c.searchDatabase(game, gui), game
+	case "why", "explain":
+		return c.explainMove(game, gui, verb, arg), game
+	case "ask":
+		return c.askTutor(gui, arg), game
+	case "agent":
+		return c.switchAgent(game, gui, arg), game
+	}
+
 	switch cmd {
 	case "quit":
+		c.Autosave(game, gui)
 		gui.Quit()
 		os.Exit(0)
 		return "", game
-	case "save":
-		return saveGame(game), game
 	case "reset":
-		return strings.Repeat(" ", 80), resetGame(game)
+		resetGame(game)
+		return strings.Repeat(" ", 80), game
 	case "hint":
-		return hint(game, gui, in), game
+		return c.hint(game, gui), game
+	case "retry":
+		return c.retryTurn(), game
 	case "y":
 		if gui.Hint != "" {
-			m, err := game.Notation().DecodeHodgesMove(gui.Hint)
+			m, err := validate.Resolve(*game.Board(), gui.Hint)
 			gui.Hint = ""
 			if err != nil {
 				return strings.Repeat(" ", 80), game
@@ -77,6 +306,9 @@ func ProcessCmd(cmd string, game *shogi.Game, gui *gui.GUI, in *input.Input) (st
 
 		gui.Hint = ""
 	default:
+		if o := game.Outcome(); o != shogi.NoOutcome {
+			return fmt.Sprintf("Game over %s, type reset to play again.", o), game
+		}
 
 		m, err := game.Notation().DecodeHodgesMove(cmd)
 		if err == nil {
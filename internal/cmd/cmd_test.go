@@ -0,0 +1,365 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/cassette"
+	"github.com/juanpablocruz/shogo/clientr/internal/cmd"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/player"
+	"github.com/juanpablocruz/shogo/clientr/internal/save"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// newGUI returns a GUI drawing on a simulation screen.
+func newGUI(t *testing.T) *gui.GUI {
+	t.Helper()
+	g, err := gui.NewScreenGUI(tcell.NewSimulationScreen(""))
+	if err != nil {
+		t.Fatalf("NewScreenGUI() failed: %v", err)
+	}
+	t.Cleanup((*g.Screen).Fini)
+	return g
+}
+
+// awaitReply waits for the reply of the request started by a command and applies it.
+func awaitReply(t *testing.T, g *gui.GUI) string {
+	t.Helper()
+	replies := make(chan cmd.Reply, 1)
+	go func() {
+		for {
+			switch ev := (*g.Screen).PollEvent().(type) {
+			case nil:
+				return
+			case *tcell.EventInterrupt:
+				if r, ok := ev.Data().(cmd.Reply); ok {
+					replies <- r
+					return
+				}
+			}
+		}
+	}()
+	select {
+	case r := <-replies:
+		return r.Apply()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no reply posted")
+		return ""
+	}
+}
+
+func TestProcessCmd_hint(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		cassette string
+		moves    []string
+		want     string
+	}{
+		{
+			// The agent's first reply is illegal, the hint is its answer to the retry.
+			name:     "hint after a rejected reply",
+			cassette: "testdata/hint.json",
+			want:     "7g7f",
+		},
+		{
+			name:     "position not recorded",
+			cassette: "testdata/hint.json",
+			moves:    []string{"7g7f"},
+			want:     "The AI gave no legal move",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayer, err := cassette.Open(tt.cassette)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			game := shogi.NewGame("sente", "gote")
+			game.SetAIClient(replayer)
+			for _, usi := range tt.moves {
+				m, err := game.Board().ResolveUSIMove(usi)
+				if err != nil {
+					t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+				}
+				if err := game.Move(m); err != nil {
+					t.Fatalf("Move(%s) failed: %v", usi, err)
+				}
+			}
+			g := newGUI(t)
+
+			if msg, _ := cmd.New().ProcessCmd("hint", game, g, nil); !strings.Contains(msg, "Esc") {
+				t.Errorf("ProcessCmd(hint) = %q, want the request running", msg)
+			}
+			msg := awaitReply(t, g)
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("hint reply = %q, want %q", msg, tt.want)
+			}
+			if strings.HasPrefix(msg, "⚠") == (g.Hint != "") {
+				t.Errorf("hint shown = %q after %q", g.Hint, msg)
+			}
+		})
+	}
+}
+
+// slowAgent thinks about its first hint until its context is done, then hints P-3f.
+type slowAgent struct {
+	agent.Agent
+	asked     int
+	started   chan struct{}
+	cancelled chan error
+}
+
+func (s *slowAgent) AskHint(ctx context.Context, _ agent.Request) (string, error) {
+	s.asked++
+	if s.asked > 1 {
+		return `{"next_move": "3g3f"}`, nil
+	}
+	close(s.started)
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return "", ctx.Err()
+}
+
+func TestCancel(t *testing.T) {
+	ai := &slowAgent{started: make(chan struct{}), cancelled: make(chan error, 1)}
+	game := shogi.NewGame("sente", "gote")
+	game.SetAIClient(ai)
+	g := newGUI(t)
+	commands := cmd.New()
+
+	if commands.Cancel() {
+		t.Errorf("Cancel() = true before any request")
+	}
+	commands.ProcessCmd("hint", game, g, nil)
+	<-ai.started
+	if !commands.Cancel() {
+		t.Fatalf("Cancel() = false while asking a hint")
+	}
+	if err := <-ai.cancelled; err != context.Canceled {
+		t.Errorf("agent context ended with %v, want context.Canceled", err)
+	}
+	if commands.Cancel() {
+		t.Errorf("Cancel() = true after the request was cancelled")
+	}
+
+	// The next request waits for the cancelled one, whose reply would come first.
+	commands.ProcessCmd("hint", game, g, nil)
+	if msg := awaitReply(t, g); msg != "3g3f" {
+		t.Errorf("reply after the cancelled request = %q, want the next hint", msg)
+	}
+}
+
+// closingAgent is an agent recording whether it was closed.
+type closingAgent struct {
+	agent.Agent
+	closed bool
+}
+
+func (c *closingAgent) Close() error {
+	c.closed = true
+	return nil
+}
+
+// chatAgent answers every question of a conversation with the same text.
+type chatAgent struct {
+	agent.Agent
+}
+
+func (chatAgent) Chat(context.Context, string, []agent.Message) (string, error) {
+	return "It develops the gold.", nil
+}
+
+func TestProcessCmd_why(t *testing.T) {
+	tests := []struct {
+		name  string // description of this test case
+		moves []string
+		want  string
+	}{
+		{name: "single piece", moves: []string{"7g7f"}, want: "Why P-3f?"},
+		// Both golds could move to 5h before the move, not after it.
+		{name: "two pieces to the square", moves: []string{"6i5h"}, want: "Why G4i-5h?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := shogi.NewGame("sente", "gote")
+			game.SetAIClient(chatAgent{})
+			for _, usi := range tt.moves {
+				m, err := game.Board().ResolveUSIMove(usi)
+				if err != nil {
+					t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+				}
+				if err := game.Move(m); err != nil {
+					t.Fatalf("Move(%s) failed: %v", usi, err)
+				}
+			}
+			g := newGUI(t)
+
+			cmd.New().ProcessCmd("why", game, g, nil)
+			if msg := awaitReply(t, g); strings.HasPrefix(msg, "⚠") {
+				t.Fatalf("why reply = %q", msg)
+			}
+			if g.Explanation == nil || g.Explanation.Title != tt.want {
+				t.Errorf("explanation = %+v, want title %q", g.Explanation, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessCmd_agent(t *testing.T) {
+	first, second := &closingAgent{}, &closingAgent{}
+	commands := cmd.New(cmd.WithAgents("first", func(name string) (agent.Agent, error) {
+		switch name {
+		case "second":
+			return agent.NewTimeoutAgent(second, time.Minute), nil
+		case agent.None:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: no key for %s", agent.ErrNoCredentials, name)
+	}))
+	game := shogi.NewGame("sente", "gote")
+	game.SetAIClient(first)
+	g := newGUI(t)
+
+	tests := []struct {
+		name  string // description of this test case
+		cmd   string
+		want  string
+		agent agent.Agent
+		// closed is whether the first agent was closed by now.
+		closed bool
+	}{
+		{name: "show the agent", cmd: "agent", want: "AI agent: first", agent: first},
+		{name: "missing credentials", cmd: "agent third", want: "no key for third", agent: first},
+		{name: "switch", cmd: "agent second", want: "second", agent: second, closed: true},
+		{name: "reset keeps the agent", cmd: "reset", agent: second, closed: true},
+		{name: "disable", cmd: "agent none", want: "disabled", agent: nil, closed: true},
+		{name: "show no agent", cmd: "agent", want: "AI agent: none", agent: nil, closed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg string
+			msg, game = commands.ProcessCmd(tt.cmd, game, g, nil)
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("ProcessCmd(%s) = %q, want %q", tt.cmd, msg, tt.want)
+			}
+			if got := agent.Unwrap(game.GetAIClient()); got != tt.agent {
+				t.Errorf("agent = %v, want %v", got, tt.agent)
+			}
+			if first.closed != tt.closed {
+				t.Errorf("first agent closed = %v, want %v", first.closed, tt.closed)
+			}
+		})
+	}
+	if !second.closed {
+		t.Errorf("agent switched off was not closed")
+	}
+}
+
+func TestProcessCmd_retry(t *testing.T) {
+	game := shogi.NewGame("cpu", "human")
+	g := newGUI(t)
+	commands := cmd.New()
+	if msg, _ := commands.ProcessCmd("retry", game, g, nil); !strings.HasPrefix(msg, "⚠") {
+		t.Errorf("ProcessCmd(retry) without computer players = %q, want a warning", msg)
+	}
+
+	players := player.NewController(map[shogi.Color]player.Mover{shogi.Black: player.NewAgent(agent.Pro)})
+	turns := make(chan player.Turn, 1)
+	players.OnTurn = func(t player.Turn) { turns <- t }
+	defer players.Stop()
+	commands.Players = players
+
+	// Without agent the turn fails, it is asked again only after retry.
+	for _, retry := range []bool{false, true} {
+		if retry {
+			commands.ProcessCmd("retry", game, g, nil)
+		}
+		players.Follow(game)
+		select {
+		case turn := <-turns:
+			if !errors.Is(turn.Err, player.ErrNoAgent) {
+				t.Errorf("turn error = %v, want ErrNoAgent", turn.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("computer player not asked, retry %v", retry)
+		}
+	}
+}
+
+func TestProcessCmd_reset(t *testing.T) {
+	tc := shogi.TimeControl{Kind: shogi.SuddenDeath, Main: time.Minute}
+	game := shogi.NewGame("sente", "gote", shogi.WithClock(tc))
+	game.Clock().Set(shogi.Black, time.Second)
+	game.End(shogi.Winner(shogi.White))
+
+	if msg, _ := cmd.New().ProcessCmd("reset", game, newGUI(t), nil); strings.HasPrefix(msg, "⚠") {
+		t.Fatalf("ProcessCmd(reset) = %q", msg)
+	}
+	if o := game.Outcome(); o != shogi.NoOutcome {
+		t.Errorf("outcome after reset = %s, want none", o)
+	}
+	// The clock of the new game runs from the start.
+	if got := game.Clock().Remaining(shogi.Black); got <= time.Second {
+		t.Errorf("time left after reset = %v, want about %v", got, tc.Main)
+	}
+}
+
+func TestProcessCmd_save(t *testing.T) {
+	dir := t.TempDir()
+	saved := &closingAgent{}
+	commands := cmd.New(cmd.WithSaveDir(dir), cmd.WithAgents("replay", func(name string) (agent.Agent, error) {
+		switch name {
+		case "replay":
+			return saved, nil
+		case agent.None:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: no key for %s", agent.ErrNoCredentials, name)
+	}))
+	g := newGUI(t)
+
+	tests := []struct {
+		name string // description of this test case
+		ai   agent.Agent
+		want string
+	}{
+		{name: "provider of the agent", ai: saved, want: "replay"},
+		{name: "no agent", want: agent.None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := shogi.NewGame("sente", "gote")
+			game.SetAIClient(tt.ai)
+			if msg, _ := commands.ProcessCmd("save club", game, g, nil); !strings.HasPrefix(msg, "Saved") {
+				t.Fatalf("ProcessCmd(save club) = %q", msg)
+			}
+			s, err := save.NewStore(dir).Load("club")
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if s.Agent != tt.want {
+				t.Errorf("saved agent = %q, want %q", s.Agent, tt.want)
+			}
+
+			// The game resumes with the agent it was played with.
+			other := agent.None
+			if tt.ai == nil {
+				other = "replay"
+			}
+			commands.ProcessCmd("agent "+other, game, g, nil)
+			if msg, _ := commands.ProcessCmd("load club", game, g, nil); !strings.HasPrefix(msg, "Loaded") {
+				t.Fatalf("ProcessCmd(load club) = %q", msg)
+			}
+			if got := game.GetAIClient(); got != tt.ai {
+				t.Errorf("agent after load = %v, want %v", got, tt.ai)
+			}
+		})
+	}
+}
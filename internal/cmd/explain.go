@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/explain"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// explainMove runs the why and explain commands:
+//
+//	why               explain the pending hint, or the last move played
+//	explain <move>    explain a move of the current position, e.g. explain P-2f or explain 2g2f
+//	explain off       close the explanation
+func (c *Commands) explainMove(game *shogi.Game, gui *gui.GUI, verb, arg string) string {
+	if arg == "off" {
+		gui.Explanation, c.tutor = nil, nil
+		return strings.Repeat(" ", 80)
+	}
+	ai := game.GetAIClient()
+	if ai == nil {
+		gui.AppendLog("No ai client found")
+		return strings.Repeat(" ", 80)
+	}
+
+	var m shogi.Move
+	last := false
+	switch {
+	case arg != "":
+		var err error
+		if m, err = explain.ParseMove(*game.Board(), arg); err != nil {
+			return fmt.Sprintf("⚠ %v", err)
+		}
+	case verb == "explain":
+		return "⚠ Usage: explain <move> | explain off"
+	case gui.Hint != "":
+		var err error
+		if m, err = explain.ParseMove(*game.Board(), gui.Hint); err != nil {
+			return fmt.Sprintf("⚠ %v", err)
+		}
+	case len(game.Moves()) > 0:
+		last = true
+	default:
+		return "⚠ Nothing to explain, type explain <move>."
+	}
+
+	g, err := snapshot(game)
+	if err != nil {
+		return fmt.Sprintf("⚠ %v", err)
+	}
+	// The move is written as played on the board before it, to tell apart the pieces it could
+	// have been played with.
+	board := game.Board()
+	if last {
+		moves := game.Moves()
+		before, err := replay(game, len(moves)-1)
+		if err != nil {
+			return fmt.Sprintf("⚠ %v", err)
+		}
+		board, m = before.Board(), *moves[len(moves)-1]
+	}
+	title := "Why " + shogi.Notation{Board: *board}.EncodeMovement(m) + "?"
+	t := explain.New(ai)
+	c.startRequest(gui, func(ctx context.Context) func() string {
+		var answer string
+		var err error
+		if last {
+			answer, err = t.ExplainLast(ctx, g)
+		} else {
+			answer, err = t.Explain(ctx, g, m)
+		}
+		return func() string {
+			if err != nil {
+				gui.AppendLog(fmt.Sprintf("shogo error: explain error %v", err))
+				return "⚠ The AI couldn't explain the move, try again."
+			}
+			c.tutor = t
+			gui.ShowExplanation(title).Append(answer)
+			return "Type ask <question> to ask more, explain off to close."
+		}
+	})
+	return thinking
+}
+
+// askTutor runs the ask command, a follow-up question about the last explanation.
+func (c *Commands) askTutor(gui *gui.GUI, question string) string {
+	if question == "" {
+		return "⚠ Usage: ask <question>"
+	}
+	if c.tutor == nil || gui.Explanation == nil {
+		return fmt.Sprintf("⚠ %v, type why or explain <move> first.", explain.ErrNoConversation)
+	}
+	t := c.tutor
+	c.startRequest(gui, func(ctx context.Context) func() string {
+		answer, err := t.Ask(ctx, question)
+		return func() string {
+			if err != nil {
+				gui.AppendLog(fmt.Sprintf("shogo error: ask error %v", err))
+				return "⚠ The AI couldn't answer, try again."
+			}
+			if c.tutor != t || gui.Explanation == nil {
+				return strings.Repeat(" ", 80)
+			}
+			gui.Explanation.Append("» " + question)
+			gui.Explanation.Append(answer)
+			return strings.Repeat(" ", 80)
+		}
+	})
+	return thinking
+}
@@ -0,0 +1,13 @@
+package cmd
+
+import "strings"
+
+// retryTurn runs the retry command, asking again the computer player whose turn failed or was
+// cancelled.
+func (c *Commands) retryTurn() string {
+	if c.Players == nil {
+		return "⚠ No computer player to ask again."
+	}
+	c.Players.Retry()
+	return strings.Repeat(" ", 80)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/gui"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// thinking is the message shown while an agent request runs.
+const thinking = "Thinking... press Esc to cancel."
+
+// Reply is posted to the event loop, as the data of an interrupt event, when an agent request
+// started by a command ends.
+type Reply struct {
+	apply func() string
+}
+
+// Apply shows the result of the request and returns the message of the prompt. It must be called
+// from the event loop.
+func (r Reply) Apply() string {
+	return r.apply()
+}
+
+// request is an agent request running in the background.
+type request struct {
+	cancel context.CancelFunc
+	// done is closed when the request returned, the next one waits for it so the agent and the
+	// tutor are never asked twice at once.
+	done chan struct{}
+}
+
+// startRequest runs ask in the background, cancelling the request in flight, and posts the
+// function it returns as a Reply. A request cancelled posts nothing.
+func (c *Commands) startRequest(gui *gui.GUI, ask func(ctx context.Context) func() string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &request{cancel: cancel, done: make(chan struct{})}
+
+	c.inflightMu.Lock()
+	if c.inflight != nil {
+		c.inflight.cancel()
+	}
+	previous := c.latest
+	c.inflight, c.latest = r, r
+	c.inflightMu.Unlock()
+
+	go func() {
+		defer close(r.done)
+		defer cancel()
+		if previous != nil {
+			<-previous.done
+		}
+		apply := ask(ctx)
+
+		c.inflightMu.Lock()
+		defer c.inflightMu.Unlock()
+		if c.inflight != r {
+			return
+		}
+		c.inflight = nil
+		_ = (*gui.Screen).PostEvent(tcell.NewEventInterrupt(Reply{apply: apply}))
+	}()
+}
+
+// Cancel cancels the agent request in flight, its reply is never shown. It reports whether a
+// request was in flight.
+func (c *Commands) Cancel() bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if c.inflight == nil {
+		return false
+	}
+	c.inflight.cancel()
+	c.inflight = nil
+	return true
+}
+
+// stopRequests cancels the agent request in flight and waits for every request to return, e.g.
+// before the agent is closed.
+func (c *Commands) stopRequests() {
+	c.inflightMu.Lock()
+	if c.inflight != nil {
+		c.inflight.cancel()
+		c.inflight = nil
+	}
+	last := c.latest
+	c.inflightMu.Unlock()
+	if last != nil {
+		<-last.done
+	}
+}
+
+// snapshot returns a copy of the position and the moves of game, for the requests running in the
+// background while the game goes on.
+func snapshot(game *shogi.Game) (*shogi.Game, error) {
+	return replay(game, len(game.Moves()))
+}
+
+// replay returns a copy of game with only its first n moves played.
+func replay(game *shogi.Game, n int) (*shogi.Game, error) {
+	g := shogi.NewGame(game.SentePlayer(), game.GotePlayer())
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(game.StartPosition()); err != nil {
+		return nil, err
+	}
+	g.SetBoard(&b)
+	for i, played := range game.Moves()[:n] {
+		m, err := g.Board().ResolveUSIMove(played.USI())
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if err := g.Move(m); err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+	}
+	return g, nil
+}
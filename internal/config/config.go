@@ -9,15 +9,54 @@ type Config struct {
 	GotePlayer  string `json:"gotePlayer"`
 	SentePlayer string `json:"sentePlayer"`
 	Port        int    `json:"port"`
+	// Host is the address of the server to play online on.
+	Host string `json:"host"`
+	// Clock is the time control of the game, empty to play without clocks.
+	Clock string `json:"clock"`
+	// Online plays on the server at Port instead of locally.
+	Online bool `json:"online"`
+	// Name is the name of the player on the server.
+	Name string `json:"name"`
+	// CSA plays on the CSA server at Host and Port, logging in as Name.
+	CSA bool `json:"csa"`
+	// Password is the password of Name on the CSA server.
+	Password string `json:"password"`
+	// SaveDir is the directory of the saved games, empty for the default one.
+	SaveDir string `json:"saveDir"`
+	// Resume loads the last unfinished saved game on startup.
+	Resume bool `json:"resume"`
+	// DB is the game database searched by the db command, empty for the default one.
+	DB string `json:"db"`
+	// Book is the opening book shown in the book moves panel, empty to hide it.
+	Book string `json:"book"`
+	// AnalysisEngine is the engine run by the analyze command, a binary or builtin.
+	AnalysisEngine string `json:"analysisEngine"`
+	// MultiPV is the number of lines shown by the analyze command.
+	MultiPV int `json:"multiPV"`
+	// Agent is the provider of the AI agent, empty to pick it from the environment.
+	Agent string `json:"agent"`
 }
 
 func Init() Config {
 	var config Config
 
-	sente := flag.String("sente", "human", "sente(black) piece input")
-	gote := flag.String("gote", "cpu", "gote(white) piece input")
+	sente := flag.String("sente", "human", "sente(black) player: human, cpu[:beginner|medium|pro] for the AI agent or engine[:path] for a USI engine")
+	gote := flag.String("gote", "cpu", "gote(white) player: human, cpu[:beginner|medium|pro] for the AI agent or engine[:path] for a USI engine")
 
 	port := flag.Int("p", 8080, "server port to connect to")
+	host := flag.String("host", "127.0.0.1", "server address to connect to")
+	online := flag.Bool("online", false, "play on the server at host and port")
+	name := flag.String("name", "human", "player name on the server")
+	csa := flag.Bool("csa", false, "play on the CSA server at host and port, e.g. -host wdoor.c.u-tokyo.ac.jp -p 4081")
+	password := flag.String("password", "", "password on the CSA server")
+	saveDir := flag.String("saves", "", "directory of the saved games, defaults to shogo/saves in the user config directory")
+	resume := flag.Bool("resume", false, "resume the last unfinished saved game")
+	database := flag.String("db", "", "game database searched by the db command, see shogo db")
+	bookFile := flag.String("book", "", "opening book in the YaneuraOu format to show in the book moves panel, see shogo book")
+	analysisEngine := flag.String("engine", "builtin", "USI engine binary run by the analyze command, or builtin")
+	multiPV := flag.Int("multipv", 3, "lines shown by the analyze command")
+	agentProvider := flag.String("agent", "", "AI agent provider: claude, openai, local, engine, replay or none, defaults to $AGENT or claude")
+	clock := flag.String("clock", "", "time control, e.g. 10m, 10m+30sx3, fischer:5m+10s or canadian:10m+5m/20")
 
 	flag.Parse()
 
@@ -29,6 +68,19 @@ func Init() Config {
 	config.SentePlayer = *sente
 	config.GotePlayer = *gote
 	config.Port = *port
+	config.Host = *host
+	config.Clock = *clock
+	config.Online = *online
+	config.Name = *name
+	config.CSA = *csa
+	config.Password = *password
+	config.SaveDir = *saveDir
+	config.Resume = *resume
+	config.DB = *database
+	config.Book = *bookFile
+	config.AnalysisEngine = *analysisEngine
+	config.MultiPV = *multiPV
+	config.Agent = *agentProvider
 
 	return config
 }
@@ -0,0 +1,447 @@
+package csa
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// ErrRejected is returned by Play when the game is rejected by the opponent or the server.
+var ErrRejected = errors.New("csa: game rejected")
+
+// Result is how a game ended, from the point of view of the client.
+type Result struct {
+	// Outcome is the final line of the server without the #: WIN, LOSE, DRAW, CENSORED or CHUDAN.
+	Outcome string
+	// Reason is the line announcing how the game ended without the #, e.g. RESIGN or TIME_UP.
+	Reason string
+	Record kifu.Record
+}
+
+// Client is a connection to a CSA server.
+type Client struct {
+	conn   io.ReadWriteCloser
+	player Player
+	logger *log.Logger
+
+	mu    sync.Mutex
+	lines chan string
+	err   error
+}
+
+// WithLogger logs the lines exchanged with the server to l.
+func WithLogger(l *log.Logger) func(*Client) {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// New returns a client talking to a server over conn, player chooses its moves.
+func New(conn io.ReadWriteCloser, player Player, options ...func(*Client)) *Client {
+	c := &Client{
+		conn:   conn,
+		player: player,
+		logger: log.New(io.Discard, "", 0),
+		lines:  make(chan string, 16),
+	}
+	for _, f := range options {
+		f(c)
+	}
+	go c.read()
+	return c
+}
+
+// Dial connects to the server at address and logs in.
+func Dial(ctx context.Context, address, user, password string, player Player, options ...func(*Client)) (*Client, error) {
+	d := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := New(conn, player, options...)
+	if err := c.Login(ctx, user, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// read delivers the lines sent by the server on c.lines, until the connection is closed.
+func (c *Client) read() {
+	defer close(c.lines)
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		c.logger.Printf("< %s", line)
+		c.lines <- line
+	}
+	c.mu.Lock()
+	c.err = scanner.Err()
+	if c.err == nil {
+		c.err = io.EOF
+	}
+	c.mu.Unlock()
+}
+
+// receive returns the next line sent by the server.
+func (c *Client) receive(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return "", c.err
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *Client) send(line string) error {
+	c.logger.Printf("> %s", line)
+	_, err := io.WriteString(c.conn, line+"\n")
+	return err
+}
+
+// Login logs in as user.
+func (c *Client) Login(ctx context.Context, user, password string) error {
+	if err := c.send(fmt.Sprintf("LOGIN %s %s", user, password)); err != nil {
+		return err
+	}
+	for {
+		line, err := c.receive(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "LOGIN:"+user+" OK":
+			return nil
+		case strings.HasPrefix(line, "LOGIN:"):
+			return fmt.Errorf("csa: login failed, %s", strings.TrimPrefix(line, "LOGIN:"))
+		}
+	}
+}
+
+// Logout ends the session.
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.send("LOGOUT"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.receive(ctx)
+		if err != nil {
+			return err
+		}
+		if line == "LOGOUT:completed" {
+			return nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Play waits for the next game, agrees to it and plays it to the end.
+func (c *Client) Play(ctx context.Context) (Result, error) {
+	s, err := c.readSummary(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := c.player.NewGame(ctx, s); err != nil {
+		_ = c.send("REJECT " + s.GameID)
+		return Result{}, err
+	}
+	if err := c.send("AGREE " + s.GameID); err != nil {
+		return Result{}, err
+	}
+	for {
+		line, err := c.receive(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		if line == "START:"+s.GameID {
+			break
+		}
+		if strings.HasPrefix(line, "REJECT:") {
+			return Result{}, fmt.Errorf("%w: %s", ErrRejected, strings.TrimPrefix(line, "REJECT:"))
+		}
+	}
+	return c.playGame(ctx, s)
+}
+
+// readSummary reads lines until a complete Game_Summary.
+func (c *Client) readSummary(ctx context.Context) (Summary, error) {
+	var lines []string
+	for {
+		line, err := c.receive(ctx)
+		if err != nil {
+			return Summary{}, err
+		}
+		if line == "BEGIN Game_Summary" {
+			lines = []string{}
+		}
+		if lines == nil {
+			continue
+		}
+		lines = append(lines, line)
+		if line == "END Game_Summary" {
+			return ParseSummary(lines)
+		}
+	}
+}
+
+// thought is the move chosen by the player.
+type thought struct {
+	move string
+	err  error
+}
+
+// game is the state of a game being played.
+type game struct {
+	summary Summary
+	board   shogi.Board
+	record  kifu.Record
+	// used is the time each player spent.
+	used [2]time.Duration
+	// declared is set once a player declared an entering king win.
+	declared bool
+}
+
+func (c *Client) playGame(ctx context.Context, s Summary) (Result, error) {
+	g := &game{summary: s, record: kifu.NewRecord(s.Sente, s.Gote)}
+	g.record.Event = s.GameID
+	g.record.StartTime = time.Now()
+	g.record.StartPosition = s.Position
+	var err error
+	if g.board, err = g.record.Board(); err != nil {
+		return Result{}, err
+	}
+	for _, m := range s.Moves {
+		if err := g.play(m); err != nil {
+			return Result{}, err
+		}
+	}
+
+	var thinking chan thought
+	cancelThink := context.CancelFunc(func() {})
+	defer func() { cancelThink() }()
+	// sent is set while the move of the player waits to be echoed by the server.
+	sent := false
+	reason := ""
+
+	for {
+		if thinking == nil && !sent && g.board.Turn == s.YourTurn {
+			thinking, cancelThink = c.think(ctx, g.position())
+		}
+
+		var line string
+		select {
+		case t := <-thinking:
+			thinking = nil
+			if t.err != nil {
+				_ = c.send("%TORYO")
+				return Result{}, t.err
+			}
+			if err := c.sendMove(g, t.move); err != nil {
+				return Result{}, err
+			}
+			sent = true
+			continue
+		case l, ok := <-c.lines:
+			if !ok {
+				_, err := c.receive(ctx)
+				return Result{}, err
+			}
+			line = l
+		case <-ctx.Done():
+			_ = c.send("%CHUDAN")
+			return Result{}, ctx.Err()
+		}
+
+		switch {
+		case line == "":
+		case line[0] == '+' || line[0] == '-':
+			m, err := g.parseMove(line)
+			if err != nil {
+				return Result{}, err
+			}
+			if err := g.play(m); err != nil {
+				return Result{}, err
+			}
+			if g.board.Turn != s.YourTurn {
+				sent = false
+			}
+		case line[0] == '%':
+			special, _, _ := strings.Cut(line[1:], ",")
+			g.declared = g.declared || special == string(kifu.Kachi)
+		case line[0] == '#':
+			switch outcome := line[1:]; outcome {
+			case "WIN", "LOSE", "DRAW", "CENSORED", "CHUDAN":
+				cancelThink()
+				if thinking != nil {
+					<-thinking
+				}
+				r := g.finish(outcome, reason)
+				c.player.GameOver(r)
+				return r, nil
+			default:
+				reason = outcome
+			}
+		}
+	}
+}
+
+// think asks the player for a move in the background, the move is delivered on the returned
+// channel. Cancelling the search still delivers a result.
+func (c *Client) think(ctx context.Context, p Position) (chan thought, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan thought, 1)
+	go func() {
+		move, err := c.player.Think(ctx, p)
+		done <- thought{move, err}
+	}()
+	return done, cancel
+}
+
+// sendMove sends the move chosen by the player.
+func (c *Client) sendMove(g *game, move string) error {
+	switch move {
+	case MoveResign:
+		return c.send("%TORYO")
+	case MoveWin:
+		return c.send("%KACHI")
+	}
+	m, err := g.board.ResolveUSIMove(move)
+	if err != nil {
+		return err
+	}
+	return c.send(kifu.EncodeCSAMove(m))
+}
+
+// parseMove reads a move echoed by the server, e.g. +7776FU,T3
+func (g *game) parseMove(line string) (kifu.Move, error) {
+	parts := strings.Split(line, ",")
+	usi, err := kifu.DecodeCSAMove(g.board, parts[0])
+	if err != nil {
+		return kifu.Move{}, err
+	}
+	m := kifu.Move{USI: usi}
+	for _, p := range parts[1:] {
+		if strings.HasPrefix(p, "T") {
+			t, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil {
+				return kifu.Move{}, fmt.Errorf("csa: invalid time in %q", line)
+			}
+			m.Time = time.Duration(t * float64(g.summary.TimeUnit))
+		}
+	}
+	return m, nil
+}
+
+// play plays m on the board of the game and records it.
+func (g *game) play(m kifu.Move) error {
+	mo, err := g.board.ResolveUSIMove(m.USI)
+	if err != nil {
+		return err
+	}
+	turn := g.board.Turn
+	if err := g.board.ProcessMove(&mo); err != nil {
+		return err
+	}
+	g.used[turn] += m.Time
+	g.record.Moves = append(g.record.Moves, m)
+	return nil
+}
+
+// position returns the position to think on, with the time left to both players.
+func (g *game) position() Position {
+	s := g.summary
+	p := Position{SFEN: s.Position, Moves: make([]string, 0, len(g.record.Moves))}
+	for _, m := range g.record.Moves {
+		p.Moves = append(p.Moves, m.USI)
+	}
+	if s.TotalTime == 0 && s.Byoyomi == 0 && s.Increment == 0 {
+		p.Time = engine.GoParams{Infinite: true}
+		return p
+	}
+
+	left := func(c shogi.Color) time.Duration {
+		moves := 0
+		for i := range g.record.Moves {
+			// Moves alternate from the side to move of the start position.
+			if (i%2 == 0) == (c == g.startTurn()) {
+				moves++
+			}
+		}
+		return max(0, s.TotalTime-g.used[c]+time.Duration(moves)*s.Increment)
+	}
+	p.Time = engine.GoParams{
+		BTime:   left(shogi.Black),
+		WTime:   left(shogi.White),
+		Byoyomi: s.Byoyomi,
+		BInc:    s.Increment,
+		WInc:    s.Increment,
+	}
+	return p
+}
+
+// startTurn returns the side to move in the start position.
+func (g *game) startTurn() shogi.Color {
+	if len(g.record.Moves)%2 == 0 {
+		return g.board.Turn
+	}
+	return g.board.Turn.Opponent()
+}
+
+// finish fills the record of the game once the server announced outcome after reason.
+func (g *game) finish(outcome, reason string) Result {
+	r := &g.record
+	me := g.summary.YourTurn
+	switch outcome {
+	case "WIN":
+		r.Result = shogi.Winner(me)
+	case "LOSE":
+		r.Result = shogi.Winner(me.Opponent())
+	case "DRAW":
+		r.Result = shogi.Draw
+	}
+
+	switch reason {
+	case "RESIGN":
+		r.Termination = kifu.Resign
+	case "TIME_UP":
+		r.Termination = kifu.TimeUp
+	case "ILLEGAL_MOVE":
+		r.Termination = kifu.IllegalAction
+	case "SENNICHITE", "OUTE_SENNICHITE":
+		r.Termination = kifu.Sennichite
+	case "JISHOGI":
+		r.Termination = kifu.Jishogi
+		if g.declared {
+			r.Termination = kifu.Kachi
+		}
+	case "MAX_MOVES":
+		r.Termination = kifu.MaxMoves
+	default:
+		r.Termination = kifu.Chudan
+	}
+	if g.board.IsCheckmate() && r.Termination == kifu.Chudan {
+		r.Termination = kifu.Checkmate
+	}
+	return Result{Outcome: outcome, Reason: reason, Record: *r}
+}
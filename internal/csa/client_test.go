@@ -0,0 +1,331 @@
+package csa_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/csa"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+const summary = `BEGIN Game_Summary
+Protocol_Version:1.2
+Protocol_Mode:Server
+Format:Shogi 1.0
+Game_ID:test-game-1
+Name+:shogo
+Name-:scripted
+Your_Turn:+
+Rematch_On_Draw:NO
+To_Move:+
+Max_Moves:256
+BEGIN Time
+Time_Unit:1sec
+Total_Time:600
+Byoyomi:10
+END Time
+BEGIN Position
+PI
++
+END Position
+END Game_Summary`
+
+// scriptedServer plays the server side of a CSA session, line by line.
+type scriptedServer struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// expect reads the next line from the client and checks it starts with prefix.
+func (s scriptedServer) expect(prefix string) string {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if !s.scanner.Scan() {
+		s.t.Errorf("server: expecting %q, connection closed: %v", prefix, s.scanner.Err())
+		return ""
+	}
+	line := s.scanner.Text()
+	if !strings.HasPrefix(line, prefix) {
+		s.t.Errorf("server: received %q, want %q", line, prefix)
+	}
+	return line
+}
+
+func (s scriptedServer) send(lines ...string) {
+	s.t.Helper()
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(s.conn, "%s\n", l); err != nil {
+			s.t.Errorf("server: send %q: %v", l, err)
+		}
+	}
+}
+
+// startServer runs script as the server of one connection and returns its address.
+func startServer(t *testing.T, script func(s scriptedServer)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		l.Close()
+		if err != nil {
+			t.Errorf("Accept() failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		script(scriptedServer{t: t, conn: conn, scanner: bufio.NewScanner(conn)})
+	}()
+	t.Cleanup(func() { <-done })
+	return l.Addr().String()
+}
+
+// scriptedPlayer plays a fixed list of moves and records what it was told.
+type scriptedPlayer struct {
+	moves     []string
+	positions []csa.Position
+	summary   csa.Summary
+	result    csa.Result
+}
+
+func (p *scriptedPlayer) NewGame(ctx context.Context, s csa.Summary) error {
+	p.summary = s
+	return nil
+}
+
+func (p *scriptedPlayer) Think(ctx context.Context, pos csa.Position) (string, error) {
+	p.positions = append(p.positions, pos)
+	if len(p.moves) == 0 {
+		return csa.MoveResign, nil
+	}
+	m := p.moves[0]
+	p.moves = p.moves[1:]
+	return m, nil
+}
+
+func (p *scriptedPlayer) GameOver(r csa.Result) {
+	p.result = r
+}
+
+func TestClient_Play(t *testing.T) {
+	address := startServer(t, func(s scriptedServer) {
+		s.expect("LOGIN shogo secret")
+		s.send("LOGIN:shogo OK")
+		s.send(strings.Split(summary, "\n")...)
+		s.expect("AGREE test-game-1")
+		s.send("START:test-game-1")
+		s.expect("+7776FU")
+		s.send("+7776FU,T3", "", "-3334FU,T1")
+		s.expect("+8822UM")
+		s.send("+8822UM,T5", "-3122GI,T2")
+		s.expect("%TORYO")
+		s.send("%TORYO,T0", "#RESIGN", "#LOSE")
+		s.expect("LOGOUT")
+		s.send("LOGOUT:completed")
+	})
+
+	ctx := context.Background()
+	player := &scriptedPlayer{moves: []string{"7g7f", "8h2b+"}}
+	c, err := csa.Dial(ctx, address, "shogo", "secret", player)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer c.Close()
+
+	r, err := c.Play(ctx)
+	if err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if r.Outcome != "LOSE" || r.Reason != "RESIGN" {
+		t.Errorf("Play() = %s %s, want LOSE RESIGN", r.Outcome, r.Reason)
+	}
+	if r.Record.Result != shogi.WhiteWon || r.Record.Termination != kifu.Resign {
+		t.Errorf("Play() record = %s %s, want %s %s", r.Record.Result, r.Record.Termination, shogi.WhiteWon, kifu.Resign)
+	}
+	wantMoves := []kifu.Move{
+		{USI: "7g7f", Time: 3 * time.Second},
+		{USI: "3c3d", Time: time.Second},
+		{USI: "8h2b+", Time: 5 * time.Second},
+		{USI: "3a2b", Time: 2 * time.Second},
+	}
+	if fmt.Sprint(r.Record.Moves) != fmt.Sprint(wantMoves) {
+		t.Errorf("Play() moves = %v, want %v", r.Record.Moves, wantMoves)
+	}
+	if player.result.Outcome != "LOSE" {
+		t.Errorf("GameOver() = %v, want LOSE", player.result.Outcome)
+	}
+
+	if len(player.positions) != 3 {
+		t.Fatalf("Think() called %d times, want 3", len(player.positions))
+	}
+	last := player.positions[2]
+	if strings.Join(last.Moves, " ") != "7g7f 3c3d 8h2b+ 3a2b" {
+		t.Errorf("Think() moves = %v", last.Moves)
+	}
+	if last.Time.BTime != 592*time.Second || last.Time.WTime != 597*time.Second || last.Time.Byoyomi != 10*time.Second {
+		t.Errorf("Think() time = %+v", last.Time)
+	}
+
+	if err := c.Logout(ctx); err != nil {
+		t.Errorf("Logout() failed: %v", err)
+	}
+}
+
+func TestClient_Login_incorrect(t *testing.T) {
+	address := startServer(t, func(s scriptedServer) {
+		s.expect("LOGIN shogo wrong")
+		s.send("LOGIN:incorrect")
+	})
+	if _, err := csa.Dial(context.Background(), address, "shogo", "wrong", &scriptedPlayer{}); err == nil {
+		t.Errorf("Dial() succeeded unexpectedly")
+	}
+}
+
+func TestClient_Play_rejected(t *testing.T) {
+	address := startServer(t, func(s scriptedServer) {
+		s.expect("LOGIN")
+		s.send("LOGIN:shogo OK")
+		s.send(strings.Split(summary, "\n")...)
+		s.expect("AGREE test-game-1")
+		s.send("REJECT:test-game-1 by scripted")
+	})
+	c, err := csa.Dial(context.Background(), address, "shogo", "secret", &scriptedPlayer{})
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Play(context.Background()); err == nil {
+		t.Errorf("Play() succeeded unexpectedly")
+	}
+}
+
+func TestClient_Play_human_time_up(t *testing.T) {
+	address := startServer(t, func(s scriptedServer) {
+		s.expect("LOGIN")
+		s.send("LOGIN:human OK")
+		s.send(strings.Split(strings.Replace(summary, "Your_Turn:+", "Your_Turn:-", 1), "\n")...)
+		s.expect("AGREE")
+		s.send("START:test-game-1", "+2726FU,T7")
+		s.expect("-8384FU")
+		s.send("-8384FU,T12", "#TIME_UP", "#WIN")
+	})
+
+	human := csa.NewHumanPlayer()
+	turns := make(chan csa.Position, 1)
+	human.OnTurn = func(p csa.Position) { turns <- p }
+	c, err := csa.Dial(context.Background(), address, "human", "secret", human)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer c.Close()
+
+	go func() {
+		p := <-turns
+		if strings.Join(p.Moves, " ") != "2g2f" {
+			t.Errorf("OnTurn() moves = %v, want 2g2f", p.Moves)
+		}
+		human.Play("8c8d")
+	}()
+	r, err := c.Play(context.Background())
+	if err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if r.Record.Result != shogi.WhiteWon || r.Record.Termination != kifu.TimeUp {
+		t.Errorf("Play() record = %s %s, want %s %s", r.Record.Result, r.Record.Termination, shogi.WhiteWon, kifu.TimeUp)
+	}
+}
+
+func TestClient_Play_engine(t *testing.T) {
+	address := startServer(t, func(s scriptedServer) {
+		s.expect("LOGIN")
+		s.send("LOGIN:builtin OK")
+		s.send(strings.Split(summary, "\n")...)
+		s.expect("AGREE")
+		s.send("START:test-game-1")
+		move := s.expect("+")
+		s.send(move+",T1", "#CHUDAN")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+	player := &csa.EnginePlayer{Engine: engine.NewGUIEngine(le)}
+
+	c, err := csa.Dial(ctx, address, "builtin", "secret", player)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer c.Close()
+	r, err := c.Play(ctx)
+	if err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if r.Outcome != "CHUDAN" || len(r.Record.Moves) != 1 {
+		t.Errorf("Play() = %s with %d moves, want CHUDAN with 1", r.Outcome, len(r.Record.Moves))
+	}
+}
+
+func TestEnginePlayer_NewGame(t *testing.T) {
+	api := engine.ServerLocalEngine{EngineCh: make(chan string, 2), GUICh: make(chan string, 10)}
+	api.EngineCh <- "usiok"
+	api.EngineCh <- "readyok"
+	player := &csa.EnginePlayer{
+		Engine:  engine.NewGUIEngine(api),
+		Options: map[string]string{"USI_Hash": "256", "MultiPV": "1", "Threads": "4"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := player.NewGame(ctx, csa.Summary{}); err != nil {
+		t.Fatalf("NewGame() failed: %v", err)
+	}
+	close(api.GUICh)
+	sent := []string{}
+	for cmd := range api.GUICh {
+		sent = append(sent, cmd)
+	}
+	want := []string{
+		"usi",
+		"setoption name MultiPV value 1",
+		"setoption name Threads value 4",
+		"setoption name USI_Hash value 256",
+		"usinewgame",
+		"isready",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("NewGame() sent %q, want %q", sent, want)
+	}
+}
+
+func TestParseSummary(t *testing.T) {
+	lines := strings.Split(strings.Replace(summary, "PI\n+", "PI\n+\n+7776FU,T2\n-3334FU,T4", 1), "\n")
+	lines = append(lines[:12], append([]string{"Increment:5"}, lines[12:]...)...)
+	s, err := csa.ParseSummary(lines)
+	if err != nil {
+		t.Fatalf("ParseSummary() failed: %v", err)
+	}
+	if s.GameID != "test-game-1" || s.Sente != "shogo" || s.Gote != "scripted" || s.YourTurn != shogi.Black {
+		t.Errorf("ParseSummary() = %+v", s)
+	}
+	if s.TotalTime != 600*time.Second || s.Byoyomi != 10*time.Second || s.Increment != 5*time.Second {
+		t.Errorf("ParseSummary() times = %v %v %v", s.TotalTime, s.Byoyomi, s.Increment)
+	}
+	if s.Position != shogi.StartingPosition || len(s.Moves) != 2 || s.Moves[1].USI != "3c3d" || s.Moves[1].Time != 4*time.Second {
+		t.Errorf("ParseSummary() position = %s %v", s.Position, s.Moves)
+	}
+	if tc := s.TimeControl(); tc.Kind != shogi.Fischer {
+		t.Errorf("TimeControl() = %v, want fischer", tc)
+	}
+}
@@ -0,0 +1,157 @@
+package csa
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Special moves a Player can return from Think.
+const (
+	MoveResign = "resign"
+	// MoveWin declares an entering king win.
+	MoveWin = "win"
+)
+
+// Position is the state of the game when a player is asked for a move.
+type Position struct {
+	// SFEN is the position the game started from.
+	SFEN string
+	// Moves are the moves played since, in USI notation.
+	Moves []string
+	// Time is the clock of both players, as sent to a USI engine.
+	Time engine.GoParams
+}
+
+// Game returns the game of the position, created with options.
+func (p Position) Game(sente, gote string, options ...func(*shogi.Game)) (*shogi.Game, error) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(p.SFEN); err != nil {
+		return nil, err
+	}
+	g := shogi.NewGame(sente, gote, options...)
+	g.SetBoard(&b)
+	for _, usi := range p.Moves {
+		m, err := b.ResolveUSIMove(usi)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.Move(m); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Player chooses the moves of the client.
+type Player interface {
+	// NewGame prepares the player for the game of the summary, an error rejects the game.
+	NewGame(ctx context.Context, s Summary) error
+	// Think returns the move to play in USI notation, MoveResign or MoveWin. The player should
+	// return as soon as possible once ctx is done.
+	Think(ctx context.Context, p Position) (string, error)
+	// GameOver tells the player how the game ended.
+	GameOver(r Result)
+}
+
+// EnginePlayer plays the moves of a USI engine.
+type EnginePlayer struct {
+	Engine *engine.GUIEngine
+	// Options are sent to the engine before the first game.
+	Options map[string]string
+	summary Summary
+}
+
+func (p *EnginePlayer) NewGame(ctx context.Context, s Summary) error {
+	p.summary = s
+	if err := p.Engine.Init(ctx, p.Options); err != nil {
+		return err
+	}
+	return p.Engine.NewGame(ctx)
+}
+
+func (p *EnginePlayer) Think(ctx context.Context, pos Position) (string, error) {
+	g, err := pos.Game(p.summary.Sente, p.summary.Gote)
+	if err != nil {
+		return "", err
+	}
+	if err := p.Engine.SetPosition(g); err != nil {
+		return "", err
+	}
+	bm, err := p.Engine.Search(ctx, pos.Time)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case bm.Resign:
+		return MoveResign, nil
+	case bm.Win:
+		return MoveWin, nil
+	}
+	return bm.Move, nil
+}
+
+func (p *EnginePlayer) GameOver(r Result) {
+	outcome := "draw"
+	switch r.Record.Result {
+	case shogi.Winner(p.summary.YourTurn):
+		outcome = "win"
+	case shogi.Winner(p.summary.YourTurn.Opponent()):
+		outcome = "lose"
+	}
+	_ = p.Engine.ProcessCMD(shogi.Gameover, outcome)
+}
+
+// HumanPlayer plays the moves a person enters, e.g. in the TUI.
+type HumanPlayer struct {
+	moves chan string
+	// OnTurn is called when it is the human's turn to move.
+	OnTurn func(Position)
+	// OnGameOver is called when the game ends.
+	OnGameOver func(Result)
+}
+
+// NewHumanPlayer returns a player waiting for the moves given to Play.
+func NewHumanPlayer() *HumanPlayer {
+	return &HumanPlayer{moves: make(chan string, 1)}
+}
+
+// Play enters the next move in USI notation, MoveResign or MoveWin. It fails if a move is
+// already waiting to be played.
+func (h *HumanPlayer) Play(move string) error {
+	select {
+	case h.moves <- move:
+		return nil
+	default:
+		return fmt.Errorf("csa: a move is already waiting")
+	}
+}
+
+func (h *HumanPlayer) NewGame(ctx context.Context, s Summary) error {
+	// Forget moves entered after the last game ended.
+	select {
+	case <-h.moves:
+	default:
+	}
+	return nil
+}
+
+func (h *HumanPlayer) Think(ctx context.Context, p Position) (string, error) {
+	if h.OnTurn != nil {
+		h.OnTurn(p)
+	}
+	select {
+	case m := <-h.moves:
+		return m, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (h *HumanPlayer) GameOver(r Result) {
+	if h.OnGameOver != nil {
+		h.OnGameOver(r)
+	}
+}
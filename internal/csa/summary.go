@@ -0,0 +1,164 @@
+// Package csa plays games on servers speaking the CSA network protocol, such as Floodgate.
+package csa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// CSA server protocol, V1.2.1:
+//
+//	LOGIN <name> <password>     LOGIN:<name> OK | LOGIN:incorrect
+//	                            BEGIN Game_Summary ... END Game_Summary
+//	AGREE <game id>             START:<game id> | REJECT:<game id> by <name>
+//	+7776FU                     +7776FU,T3          moves are echoed to both players with the time spent
+//	%TORYO                      %TORYO,T5           special moves: resign and entering king declaration
+//	                            #RESIGN             how the game ended
+//	                            #WIN | #LOSE | #DRAW | #CENSORED | #CHUDAN
+//	LOGOUT                      LOGOUT:completed
+//
+// An empty line is a keep-alive and is ignored.
+
+// Summary is the Game_Summary the server sends before a game:
+//
+//	BEGIN Game_Summary
+//	Game_ID:20150505-CSA25-3-5-7
+//	Name+:TANUKI
+//	Name-:KITSUNE
+//	Your_Turn:+
+//	Max_Moves:256
+//	BEGIN Time
+//	Time_Unit:1sec
+//	Total_Time:600
+//	Byoyomi:10
+//	END Time
+//	BEGIN Position
+//	PI
+//	+
+//	END Position
+//	END Game_Summary
+type Summary struct {
+	GameID   string
+	Sente    string
+	Gote     string
+	YourTurn shogi.Color
+	MaxMoves int
+	// TimeUnit is the unit of the times of the summary and of the T<n> of the moves.
+	TimeUnit  time.Duration
+	TotalTime time.Duration
+	Byoyomi   time.Duration
+	Increment time.Duration
+	// Position is the SFEN the game started from.
+	Position string
+	// Moves are the moves already played, when a game is resumed.
+	Moves []kifu.Move
+}
+
+// ParseSummary reads the lines of a Game_Summary, BEGIN and END lines included.
+func ParseSummary(lines []string) (Summary, error) {
+	s := Summary{TimeUnit: time.Second}
+	section := ""
+	position := []string{}
+	var total, byoyomi, increment int
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "BEGIN "):
+			section = strings.TrimPrefix(line, "BEGIN ")
+			continue
+		case strings.HasPrefix(line, "END "):
+			section = ""
+			continue
+		case section == "Position":
+			position = append(position, line)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "Game_ID":
+			s.GameID = value
+		case "Name+":
+			s.Sente = value
+		case "Name-":
+			s.Gote = value
+		case "Your_Turn":
+			s.YourTurn = shogi.Black
+			if value == "-" {
+				s.YourTurn = shogi.White
+			}
+		case "Max_Moves":
+			s.MaxMoves, err = strconv.Atoi(value)
+		case "Time_Unit":
+			s.TimeUnit, err = parseTimeUnit(value)
+		case "Total_Time":
+			total, err = strconv.Atoi(value)
+		case "Byoyomi":
+			byoyomi, err = strconv.Atoi(value)
+		case "Increment":
+			increment, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return Summary{}, fmt.Errorf("csa: invalid %s in game summary: %w", key, err)
+		}
+	}
+	if s.GameID == "" {
+		return Summary{}, fmt.Errorf("csa: game summary without Game_ID")
+	}
+	s.TotalTime = time.Duration(total) * s.TimeUnit
+	s.Byoyomi = time.Duration(byoyomi) * s.TimeUnit
+	s.Increment = time.Duration(increment) * s.TimeUnit
+
+	r, err := kifu.ReadCSA(strings.NewReader(strings.Join(position, "\n")))
+	if err != nil {
+		return Summary{}, fmt.Errorf("csa: game summary position: %w", err)
+	}
+	s.Position = r.StartPosition
+	for _, m := range r.Moves {
+		// The record reads T<n> as seconds.
+		m.Time = time.Duration(m.Time.Seconds() * float64(s.TimeUnit))
+		s.Moves = append(s.Moves, m)
+	}
+	return s, nil
+}
+
+// parseTimeUnit reads a Time_Unit such as 1sec, 1min or 100msec.
+func parseTimeUnit(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("csa: invalid time unit %q", s)
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return 0, err
+	}
+	switch s[i:] {
+	case "msec":
+		return time.Duration(n) * time.Millisecond, nil
+	case "sec":
+		return time.Duration(n) * time.Second, nil
+	case "min":
+		return time.Duration(n) * time.Minute, nil
+	}
+	return 0, fmt.Errorf("csa: invalid time unit %q", s)
+}
+
+// TimeControl returns the clock of the game.
+func (s Summary) TimeControl() shogi.TimeControl {
+	switch {
+	case s.Increment > 0:
+		return shogi.TimeControl{Kind: shogi.Fischer, Main: s.TotalTime, Increment: s.Increment}
+	case s.Byoyomi > 0:
+		return shogi.TimeControl{Kind: shogi.Byoyomi, Main: s.TotalTime, Byoyomi: s.Byoyomi}
+	}
+	return shogi.TimeControl{Kind: shogi.SuddenDeath, Main: s.TotalTime}
+}
@@ -0,0 +1,270 @@
+// Package db is a local database of game records, indexed by the positions they reach.
+//
+// The whole database is kept in memory and written to a single gob file by Save.
+package db
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// version is the version of the file format.
+const version = 1
+
+// Game is a record of the database.
+type Game struct {
+	ID int
+	// Source is the file the record was imported from.
+	Source string
+	Record kifu.Record
+}
+
+// Occurrence is a position reached in a game, after Ply moves.
+type Occurrence struct {
+	Game int
+	Ply  int
+}
+
+// DB is a database of games. It isn't safe for concurrent use.
+type DB struct {
+	path  string
+	games []Game
+	// index are the occurrences of every position, by shogi.Board.Hash.
+	index map[uint64][]Occurrence
+	// fingerprints of the imported records, to skip duplicates.
+	fingerprints map[uint64]bool
+}
+
+// file is the content of the database file.
+type file struct {
+	Version int
+	Games   []Game
+	Index   map[uint64][]Occurrence
+}
+
+// DefaultPath returns the database file used by default, shogo/games.db in the user's config
+// directory.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".shogo", "games.db")
+	}
+	return filepath.Join(dir, "shogo", "games.db")
+}
+
+// Open reads the database at path, a database that doesn't exist yet is empty.
+func Open(path string) (*DB, error) {
+	d := &DB{
+		path:         path,
+		index:        map[uint64][]Occurrence{},
+		fingerprints: map[uint64]bool{},
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var content file
+	if err := gob.NewDecoder(f).Decode(&content); err != nil {
+		return nil, fmt.Errorf("db: reading %s: %w", path, err)
+	}
+	if content.Version != version {
+		return nil, fmt.Errorf("db: %s has version %d, expecting %d", path, content.Version, version)
+	}
+	d.games = content.Games
+	if content.Index != nil {
+		d.index = content.Index
+	}
+	for _, g := range d.games {
+		d.fingerprints[fingerprint(g.Record)] = true
+	}
+	return d, nil
+}
+
+// Save writes the database to its file.
+func (d *DB) Save() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(file{Version: version, Games: d.games, Index: d.index}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// Len returns the number of games.
+func (d *DB) Len() int {
+	return len(d.games)
+}
+
+// Game returns the game with id.
+func (d *DB) Game(id int) (Game, bool) {
+	if id < 1 || id > len(d.games) {
+		return Game{}, false
+	}
+	return d.games[id-1], true
+}
+
+// Import adds r to the database and indexes its positions. It reports false, adding nothing,
+// when the same game was already imported.
+func (d *DB) Import(r kifu.Record, source string) (bool, error) {
+	fp := fingerprint(r)
+	if d.fingerprints[fp] {
+		return false, nil
+	}
+	b, err := r.Board()
+	if err != nil {
+		return false, err
+	}
+	id := len(d.games) + 1
+	hashes := []uint64{b.Hash()}
+	for i, m := range r.Moves {
+		mo, err := b.ResolveUSIMove(m.USI)
+		if err != nil {
+			return false, fmt.Errorf("db: move %d: %w", i+1, err)
+		}
+		if err := b.ProcessMove(&mo); err != nil {
+			return false, fmt.Errorf("db: move %d: %w", i+1, err)
+		}
+		hashes = append(hashes, b.Hash())
+	}
+
+	for ply, h := range hashes {
+		d.index[h] = append(d.index[h], Occurrence{Game: id, Ply: ply})
+	}
+	d.games = append(d.games, Game{ID: id, Source: source, Record: r})
+	d.fingerprints[fp] = true
+	return true, nil
+}
+
+// ImportFile imports the CSA record in path.
+func (d *DB) ImportFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	r, err := kifu.ReadCSA(f)
+	if err != nil {
+		return false, fmt.Errorf("db: %s: %w", path, err)
+	}
+	ok, err := d.Import(r, path)
+	if err != nil {
+		return false, fmt.Errorf("db: %s: %w", path, err)
+	}
+	return ok, nil
+}
+
+// fingerprint identifies a record by its players, start and moves.
+func fingerprint(r kifu.Record) uint64 {
+	h := fnv.New64a()
+	moves := make([]string, 0, len(r.Moves))
+	for _, m := range r.Moves {
+		moves = append(moves, m.USI)
+	}
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s", r.Sente, r.Gote, r.StartTime, r.StartPosition, strings.Join(moves, " "))
+	return h.Sum64()
+}
+
+// Stats are the results of a set of games.
+type Stats struct {
+	Games     int
+	SenteWins int
+	GoteWins  int
+	Draws     int
+}
+
+func (s *Stats) add(o shogi.Outcome) {
+	s.Games++
+	switch o {
+	case shogi.BlackWon:
+		s.SenteWins++
+	case shogi.WhiteWon:
+		s.GoteWins++
+	case shogi.Draw:
+		s.Draws++
+	}
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d games, +%d -%d =%d", s.Games, s.SenteWins, s.GoteWins, s.Draws)
+}
+
+// Continuation is a move played from a position, with the results of the games it was played in.
+type Continuation struct {
+	Move string
+	Stats
+}
+
+func (c Continuation) String() string {
+	return fmt.Sprintf("%s: %s", c.Move, c.Stats)
+}
+
+// Result is what the database knows about a position.
+type Result struct {
+	// Stats are the results of the games that reached the position.
+	Stats
+	// Occurrences are the games that reached the position, with the first ply they reached it at.
+	Occurrences []Occurrence
+	// Next are the moves played from the position, the most played first.
+	Next []Continuation
+}
+
+// Search returns the games that reached the position of b and what was played next.
+func (d *DB) Search(b shogi.Board) Result {
+	var r Result
+	next := map[string]*Continuation{}
+	seen := map[int]bool{}
+	for _, o := range d.index[b.Hash()] {
+		g := d.games[o.Game-1]
+		// Guard against hash collisions.
+		if at, err := g.Record.Replay(o.Ply); err != nil || at.Position() != b.Position() {
+			continue
+		}
+		if seen[o.Game] {
+			continue
+		}
+		seen[o.Game] = true
+		r.Occurrences = append(r.Occurrences, o)
+		r.add(g.Record.Result)
+		if o.Ply < len(g.Record.Moves) {
+			m := g.Record.Moves[o.Ply].USI
+			if next[m] == nil {
+				next[m] = &Continuation{Move: m}
+			}
+			next[m].add(g.Record.Result)
+		}
+	}
+	for _, c := range next {
+		r.Next = append(r.Next, *c)
+	}
+	sort.Slice(r.Next, func(i, j int) bool {
+		if r.Next[i].Games != r.Next[j].Games {
+			return r.Next[i].Games > r.Next[j].Games
+		}
+		return r.Next[i].Move < r.Next[j].Move
+	})
+	return r
+}
@@ -0,0 +1,124 @@
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/db"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func record(sente, gote string, result shogi.Outcome, moves ...string) kifu.Record {
+	r := kifu.NewRecord(sente, gote)
+	for _, m := range moves {
+		r.Moves = append(r.Moves, kifu.Move{USI: m})
+	}
+	r.Result = result
+	r.Termination = kifu.Resign
+	return r
+}
+
+func board(t *testing.T, moves ...string) shogi.Board {
+	t.Helper()
+	b, err := record("", "", shogi.NoOutcome, moves...).Replay(-1)
+	if err != nil {
+		t.Fatalf("Replay() failed: %v", err)
+	}
+	return b
+}
+
+func TestDB(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "games.db")
+	d, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	records := []kifu.Record{
+		record("a", "b", shogi.BlackWon, "7g7f", "3c3d", "2g2f"),
+		record("c", "d", shogi.WhiteWon, "7g7f", "8c8d"),
+		record("e", "f", shogi.Draw, "2g2f", "3c3d", "7g7f", "8c8d"),
+	}
+	for _, r := range records {
+		if ok, err := d.Import(r, "test"); !ok || err != nil {
+			t.Fatalf("Import() = %v, %v", ok, err)
+		}
+	}
+	if ok, err := d.Import(records[0], "again"); ok || err != nil {
+		t.Errorf("Import() of a duplicate = %v, %v, want false", ok, err)
+	}
+
+	// The records are also imported from a file.
+	csaPath := filepath.Join(dir, "game.csa")
+	f, err := os.Create(csaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kifu.WriteCSA(f, record("g", "h", shogi.BlackWon, "7g7f", "3c3d", "8h2b+")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if ok, err := d.ImportFile(csaPath); !ok || err != nil {
+		t.Fatalf("ImportFile() = %v, %v", ok, err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	d, err = db.Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if d.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", d.Len())
+	}
+	if g, ok := d.Game(4); !ok || g.Source != csaPath || g.Record.Sente != "g" {
+		t.Errorf("Game(4) = %+v, %v", g, ok)
+	}
+
+	tests := []struct {
+		name  string // description of this test case
+		moves []string
+		stats db.Stats
+		next  []db.Continuation
+	}{
+		{
+			name:  "start position",
+			stats: db.Stats{Games: 4, SenteWins: 2, GoteWins: 1, Draws: 1},
+			next: []db.Continuation{
+				{Move: "7g7f", Stats: db.Stats{Games: 3, SenteWins: 2, GoteWins: 1}},
+				{Move: "2g2f", Stats: db.Stats{Games: 1, Draws: 1}},
+			},
+		},
+		{
+			name:  "transposition",
+			moves: []string{"7g7f", "3c3d", "2g2f"},
+			stats: db.Stats{Games: 2, SenteWins: 1, Draws: 1},
+			next: []db.Continuation{
+				{Move: "8c8d", Stats: db.Stats{Games: 1, Draws: 1}},
+			},
+		},
+		{
+			name:  "unknown position",
+			moves: []string{"5g5f"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := d.Search(board(t, tt.moves...))
+			if r.Stats != tt.stats {
+				t.Errorf("Search() stats = %v, want %v", r.Stats, tt.stats)
+			}
+			if len(r.Occurrences) != tt.stats.Games {
+				t.Errorf("Search() occurrences = %v, want %d", r.Occurrences, tt.stats.Games)
+			}
+			if !reflect.DeepEqual(r.Next, tt.next) {
+				t.Errorf("Search() next = %+v, want %+v", r.Next, tt.next)
+			}
+		})
+	}
+}
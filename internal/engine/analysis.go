@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Analysis runs an engine in analysis mode: an infinite multi-PV search of one position at a time,
+// restarted whenever another position is given to Analyze.
+type Analysis struct {
+	engine  *GUIEngine
+	multiPV int
+
+	// OnUpdate is called, from the goroutine reading the engine, whenever a line changes.
+	OnUpdate func()
+
+	mu       sync.Mutex
+	position string
+	lines    []Info
+	// latest is the number of the last search started, active the one reading the engine.
+	latest int
+	active int
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewAnalysis returns an analysis of e reporting the best multiPV lines.
+func NewAnalysis(e *GUIEngine, multiPV int) *Analysis {
+	a := &Analysis{engine: e, multiPV: max(multiPV, 1)}
+	e.OnInfo = a.receiveInfo
+	return a
+}
+
+// Start initializes the engine and switches it to analysis mode. USI_AnalyseMode and USI_MultiPV,
+// or MultiPV as YaneuraOu calls it, are only set when the engine announced them.
+func (a *Analysis) Start(ctx context.Context) error {
+	if err := a.engine.Init(ctx, nil); err != nil {
+		return err
+	}
+	options := []struct{ name, value string }{
+		{AnalyseModeOption, "true"},
+		{MultiPVOption, strconv.Itoa(a.multiPV)},
+		{"MultiPV", strconv.Itoa(a.multiPV)},
+	}
+	for _, o := range options {
+		if _, _, ok := lookupOption(a.engine.EngineOptions, o.name); !ok {
+			continue
+		}
+		if err := a.engine.ProcessCMD(shogi.SetOption, o.name, o.value); err != nil {
+			return err
+		}
+	}
+	return a.engine.Ready(ctx)
+}
+
+// Analyze stops the running search and starts analysing the current position of g. It returns
+// at once, the lines are collected in the background.
+func (a *Analysis) Analyze(g *shogi.Game) {
+	// The position is read now, the game may change while the previous search stops.
+	start, moves := g.StartPosition(), make([]string, 0, len(g.Moves()))
+	for _, m := range g.Moves() {
+		moves = append(moves, m.USI())
+	}
+	position := g.Board().String()
+
+	a.mu.Lock()
+	if a.cancel != nil {
+		a.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	previous, done := a.done, make(chan struct{})
+	a.latest++
+	search := a.latest
+	a.cancel, a.done = cancel, done
+	a.position, a.lines, a.err = position, nil, nil
+	a.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		a.mu.Lock()
+		a.active = search
+		a.mu.Unlock()
+
+		err := a.engine.SendPosition(start, moves)
+		if err == nil {
+			_, err = a.engine.Search(ctx, GoParams{Infinite: true})
+		}
+		if err != nil && ctx.Err() == nil {
+			a.mu.Lock()
+			a.err = err
+			a.mu.Unlock()
+			a.update()
+		}
+	}()
+}
+
+// MultiPV returns the number of lines the analysis reports.
+func (a *Analysis) MultiPV() int {
+	return a.multiPV
+}
+
+// Position returns the SFEN of the position being analysed, empty before Analyze.
+func (a *Analysis) Position() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.position
+}
+
+// Lines returns the last line reported for every PV of the position, the best first, and the
+// error that ended the search if it failed.
+func (a *Analysis) Lines() ([]Info, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// A line is missing until the engine reports it.
+	lines := slices.DeleteFunc(slices.Clone(a.lines), func(l Info) bool { return len(l.PV) == 0 })
+	return lines, a.err
+}
+
+// Stop stops the running search and waits for the engine to answer it.
+func (a *Analysis) Stop() {
+	a.mu.Lock()
+	if a.cancel != nil {
+		a.cancel()
+	}
+	done := a.done
+	a.position, a.lines = "", nil
+	a.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// receiveInfo keeps the lines of the latest search with a score and a PV.
+func (a *Analysis) receiveInfo(info Info) {
+	if !info.HasScore || len(info.PV) == 0 {
+		return
+	}
+	a.mu.Lock()
+	if a.active != a.latest {
+		a.mu.Unlock()
+		return
+	}
+	n := max(info.MultiPV, 1)
+	if n > a.multiPV {
+		a.mu.Unlock()
+		return
+	}
+	for len(a.lines) < n {
+		a.lines = append(a.lines, Info{})
+	}
+	a.lines[n-1] = info
+	a.mu.Unlock()
+	a.update()
+}
+
+func (a *Analysis) update() {
+	if a.OnUpdate != nil {
+		a.OnUpdate()
+	}
+}
+
+// FormatPV writes pv, a line of USI moves played from b, in the notation of the game. Moves that
+// can't be played are left in USI notation.
+func FormatPV(b shogi.Board, pv []string) string {
+	moves, _ := Info{PV: pv}.ReadablePV(b)
+	return strings.Join(append(moves, pv[len(moves):]...), " ")
+}
@@ -0,0 +1,110 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestAnalysis(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+
+	a := engine.NewAnalysis(engine.NewGUIEngine(le), 3)
+	updates := make(chan struct{}, 16)
+	a.OnUpdate = func() {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	// waitLines waits for the three lines of the position of g.
+	waitLines := func(g *shogi.Game) []engine.Info {
+		t.Helper()
+		for {
+			lines, err := a.Lines()
+			if err != nil {
+				t.Fatalf("Lines() failed: %v", err)
+			}
+			if len(lines) == 3 && a.Position() == g.Board().String() {
+				return lines
+			}
+			select {
+			case <-updates:
+			case <-ctx.Done():
+				t.Fatalf("Lines() = %v, want 3 lines", lines)
+			}
+		}
+	}
+
+	g := shogi.NewGame("sente", "gote")
+	a.Analyze(g)
+	lines := waitLines(g)
+	for i, l := range lines {
+		if l.MultiPV != i+1 || len(l.PV) != 1 {
+			t.Errorf("Lines()[%d] = %+v, want multipv %d with a move", i, l, i+1)
+		}
+	}
+	if lines[0].Score.CP < lines[2].Score.CP {
+		t.Errorf("Lines() = %v, want the best line first", lines)
+	}
+
+	// Making a move restarts the analysis on the new position.
+	m, err := g.Board().ResolveUSIMove("7g7f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Move(m); err != nil {
+		t.Fatal(err)
+	}
+	a.Analyze(g)
+	lines = waitLines(g)
+	b := g.Board().Clone()
+	if m, err := b.ResolveUSIMove(lines[0].PV[0]); err != nil || !b.IsLegal(m) {
+		t.Errorf("Lines() = %v, want a legal move after 7g7f", lines)
+	}
+	a.Stop()
+	if lines, _ := a.Lines(); len(lines) != 0 {
+		t.Errorf("Lines() after Stop() = %v, want none", lines)
+	}
+}
+
+func TestFormatPV(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		pv   []string
+		want string
+	}{
+		{
+			// The files of the game notation are numbered as the board of the TUI, from the left.
+			name: "moves in the notation of the game",
+			pv:   []string{"7g7f", "3c3d", "8h2b+"},
+			want: "P-3f p-7d Bx8b+",
+		},
+		{
+			name: "an illegal move ends the conversion",
+			pv:   []string{"7g7f", "9i9a", "3c3d"},
+			want: "P-3f 9i9a 3c3d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := shogi.NewBoard()
+			if err := b.LoadSfen(shogi.StartingPosition); err != nil {
+				t.Fatal(err)
+			}
+			if got := engine.FormatPV(b, tt.pv); got != tt.want {
+				t.Errorf("FormatPV() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
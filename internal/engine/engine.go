@@ -3,18 +3,38 @@ package engine
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/juanpablocruz/shogo/clientr/internal/book"
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
 )
 
+// Options of the built-in engine.
+const (
+	// OwnBookOption lets the engine play the moves of its book, the USI standard option.
+	OwnBookOption = "USI_OwnBook"
+	// BookFileOption is the book to load, in the YaneuraOu format.
+	BookFileOption = "BookFile"
+	// MultiPVOption is the number of best lines reported by a search, the USI standard option.
+	MultiPVOption = "USI_MultiPV"
+	// AnalyseModeOption tells the engine it is analysing rather than playing, the USI standard option.
+	AnalyseModeOption = "USI_AnalyseMode"
+)
+
 type Engine struct {
 	IsInitialized bool
 	EngineID      string
 	EngineOptions map[string]EngineOption
 	EngineAPI     EngineAPI
 	Game          *shogi.Game
+
+	// pending holds the result of a `go ponder` or `go infinite` search until `stop` or `ponderhit`.
+	pending *BestMove
+	// book is the opening book loaded with BookFileOption.
+	book *book.Book
 }
 
 func NewEngine(id string, api EngineAPI, game *shogi.Game, options map[string]EngineOption) *Engine {
@@ -39,6 +59,34 @@ func (e *Engine) ListenCMD() error {
 	return e.ProcessGUICMD(cmd, args)
 }
 
+// Run processes the commands sent by the GUI until `quit` is received or ctx is done.
+// Unknown commands are ignored as the USI protocol requires, errors processing a command
+// are reported with `info string` so the engine keeps running.
+func (e *Engine) Run(ctx context.Context) error {
+	for {
+		m, err := e.EngineAPI.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		cmd, args, err := e.parseGUICommand(m)
+		if err != nil {
+			continue
+		}
+		if cmd == shogi.Quit {
+			return nil
+		}
+		if err := e.ProcessGUICMD(cmd, args); err != nil {
+			if err := e.sendInfo([]string{"string", err.Error()}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (e *Engine) parseGUICommand(str string) (shogi.GUICommand, []string, error) {
 	parts := strings.Split(str, " ")
 	if len(parts) < 1 {
@@ -80,12 +128,21 @@ func (e *Engine) parseGUICommand(str string) (shogi.GUICommand, []string, error)
 }
 
 func (e *Engine) ProcessGUICMD(cmd shogi.GUICommand, args []string) error {
-	// TODO: Implement
 	switch cmd {
 	case shogi.USI:
 		return e.ProcessCMD(shogi.Id)
+	case shogi.IsReady:
+		return e.ProcessCMD(shogi.ReadyOk)
+	case shogi.USINewGame:
+		return e.resetGame(shogi.StartingPosition)
 	case shogi.Position:
 		return e.ProcessPosition(args)
+	case shogi.SetOption:
+		return e.ProcessSetOption(args)
+	case shogi.Go:
+		return e.ProcessGo(args)
+	case shogi.Stop, shogi.Ponderhit:
+		return e.sendPending()
 	}
 	return nil
 }
@@ -95,11 +152,15 @@ func (e *Engine) ProcessCMD(cmd shogi.EngineCommand, args ...string) error {
 	case shogi.Id:
 		return e.sendId()
 	case shogi.BestMove:
-		return e.sendBestMove()
+		bm, err := ParseBestMove(args)
+		if err != nil {
+			return err
+		}
+		return e.sendBestMove(bm)
 	case shogi.Checkmate:
 		return e.sendCheckMate(args)
 	case shogi.Info:
-		return e.sendInfo()
+		return e.sendInfo(args)
 	case shogi.Option:
 		return e.sendOptions()
 	case shogi.ReadyOk:
@@ -142,9 +203,18 @@ func (e *Engine) sendReady() error {
 // The engine has stopped searching and found the move <move> best in this position. The engine can send the move it likes to ponder on.
 // The engine must not start pondering automatically. This command must always be sent if the engine stops searching,
 // also in pondering mode if there is a `stop` command, so for every `go` command a `bestmove` command is needed!
-func (e *Engine) sendBestMove() error {
-	// TODO: implement
-	return fmt.Errorf("notimplemented")
+func (e *Engine) sendBestMove(bm BestMove) error {
+	return e.EngineAPI.SendMessage(bm.String())
+}
+
+// sendPending sends the best move of a `go ponder` or `go infinite` search, if there is one.
+func (e *Engine) sendPending() error {
+	if e.pending == nil {
+		return nil
+	}
+	bm := *e.pending
+	e.pending = nil
+	return e.sendBestMove(bm)
 }
 
 // checkmate [<move1> ... <movei> | nomate | timeout | notimplemented]
@@ -194,9 +264,11 @@ func (e *Engine) sendCheckMate(args []string) error {
 // `currline <cpunr> <move1> ... <movei>` - This is the current line the engine is calculating. <cpunr> is the number of the cpu if the engine
 // is running on more than one cpu. <cpunr> = 1,2,3,... If the engine is just using one cpu, <cpunr> can be omitted.
 // If <cpunr> is greater than 1, always send all k lines in k strings together. The engine should only send this if the option USI_ShowCurrLine is set to true.
-func (e *Engine) sendInfo() error {
-	// TODO: implement
-	return fmt.Errorf("notimplemented")
+func (e *Engine) sendInfo(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("info expecting arguments, none received")
+	}
+	return e.EngineAPI.SendMessage(fmt.Sprintf("info %s", strings.Join(args, " ")))
 }
 
 // option
@@ -260,17 +332,67 @@ func (e *Engine) sendInfo() error {
 // "option name LearningFile type filename default /shogi/my-shogi-engine/learn.bin"
 // "option name ResetLearning type button\n"
 func (e *Engine) sendOptions() error {
-	for key, opt := range e.EngineOptions {
-		defaultVal := ""
-		if opt.Default != "" {
-			defaultVal = fmt.Sprintf(" default %s", opt.Default)
+	keys := slices.Sorted(maps.Keys(e.EngineOptions))
+	for _, key := range keys {
+		opt := e.EngineOptions[key]
+		opt.Name = key
+		if err := e.EngineAPI.SendMessage(fmt.Sprintf("option %s\n", opt)); err != nil {
+			return err
 		}
-		optStr := fmt.Sprintf("option name %s type %s%s\n", key, opt.Type, defaultVal)
-		_ = e.EngineAPI.SendMessage(optStr)
 	}
 	return nil
 }
 
+// setoption name <id> [value <x>]
+// The value is validated against the option announced with `option` before it is stored,
+// unknown options and out of range values are rejected.
+func (e *Engine) ProcessSetOption(args []string) error {
+	name, value, err := parseSetOption(args)
+	if err != nil {
+		return err
+	}
+
+	key, opt, exists := lookupOption(e.EngineOptions, name)
+	if !exists {
+		return fmt.Errorf("unknown option %s", name)
+	}
+	if err := opt.Validate(value); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(key, BookFileOption) {
+		if err := e.loadBook(value); err != nil {
+			return err
+		}
+	}
+	opt.Value = value
+	e.EngineOptions[key] = opt
+	return nil
+}
+
+// loadBook replaces the book of the engine with the one in path, an empty path unloads it.
+func (e *Engine) loadBook(path string) error {
+	if path == "" {
+		e.book = nil
+		return nil
+	}
+	b, err := book.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	e.book = b
+	return nil
+}
+
+// ownBook reports whether the engine plays from its book.
+func (e *Engine) ownBook() bool {
+	if e.book == nil {
+		return false
+	}
+	_, opt, exists := lookupOption(e.EngineOptions, OwnBookOption)
+	return !exists || opt.Value == "true"
+}
+
 /*
 		Process the position command from the gui
 
@@ -278,48 +400,43 @@ func (e *Engine) sendOptions() error {
 
 	  Set up the position described in sfenstring on the internal board and play the moves on the internal board.
 	  If the game was played from the start position, the string `startpos` will be sent.
+	  A sfen without the `sfen` keyword is also accepted, and moves can be sent in USI or board notation.
+	  Without a start position the moves are played on the current game.
 */
 func (e *Engine) ProcessPosition(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("position requires at least 2 arguments: moves and the movement string, received: %v", args)
+	if len(args) < 1 {
+		return fmt.Errorf("position requires at least 1 argument: startpos, sfen or moves, received: %v", args)
 	}
 
-	startPost := ""
-	isMove := false
-	currStr := ""
+	setup := args
 	movements := []string{}
-	for _, arg := range args {
-		switch arg {
-		case "moves":
-			isMove = true
-			startPost = currStr
-			currStr = ""
-		default:
-			if isMove {
-				movements = append(movements, arg)
-			} else {
-				currStr = fmt.Sprintf("%s %s", currStr, arg)
-			}
-		}
-	}
-
-	if len(movements) < 1 {
+	if i := slices.Index(args, "moves"); i >= 0 {
+		setup = args[:i]
+		movements = args[i+1:]
+	} else if args[0] != "startpos" && args[0] != "sfen" {
 		return fmt.Errorf("invalid position command, expecting `moves`, received: %v", args)
 	}
 
-	if startPost != "" {
-		b, err := e.Game.Notation().DecodeBoard(startPost)
-		if err != nil {
+	if len(setup) > 0 {
+		sfen := strings.Join(setup, " ")
+		switch setup[0] {
+		case "startpos":
+			sfen = shogi.StartingPosition
+		case "sfen":
+			sfen = strings.Join(setup[1:], " ")
+		}
+		if err := e.resetGame(sfen); err != nil {
 			return err
 		}
-
-		e.Game.SetBoard(&b)
 	}
 
 	for _, m := range movements {
-		mo, err := e.Game.Notation().DecodeMovement(m)
+		mo, err := e.Game.Board().ResolveUSIMove(m)
 		if err != nil {
-			continue
+			mo, err = e.Game.Notation().DecodeMovement(m)
+			if err != nil {
+				continue
+			}
 		}
 
 		err = e.Game.Move(mo)
@@ -330,3 +447,39 @@ func (e *Engine) ProcessPosition(args []string) error {
 
 	return nil
 }
+
+// resetGame replaces the game of the engine with a new one starting at sfen.
+func (e *Engine) resetGame(sfen string) error {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(sfen); err != nil {
+		return err
+	}
+
+	g := shogi.NewGame(e.Game.SentePlayer(), e.Game.GotePlayer())
+	g.SetBoard(&b)
+	e.Game = g
+	e.pending = nil
+	return nil
+}
+
+// go [ponder] [btime <x>] [wtime <x>] [byoyomi <x>] [infinite] ...
+// Start calculating on the current position set up with the `position` command.
+// The built-in engine answers at once, the result of a ponder or infinite search is held until `stop` or `ponderhit`.
+func (e *Engine) ProcessGo(args []string) error {
+	params, err := ParseGoParams(args)
+	if err != nil {
+		return err
+	}
+
+	bm, infos := e.think(params)
+	for _, info := range infos {
+		if err := e.sendInfo(info); err != nil {
+			return err
+		}
+	}
+	if params.Ponder || params.Infinite {
+		e.pending = &bm
+		return nil
+	}
+	return e.sendBestMove(bm)
+}
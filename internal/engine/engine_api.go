@@ -9,13 +9,6 @@ import (
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
 )
 
-type EngineOption struct {
-	Name        string
-	Description string
-	Type        string
-	Default     string
-}
-
 type EngineAPI interface {
 	SendMessage(string) error
 	ReceiveMessage(context.Context) (string, error)
@@ -55,7 +48,11 @@ func NewLocalEngine(g *shogi.Game) LocalEngine {
 		EngineCh: engineCh,
 		GUICh:    guiCh,
 	}
-	engineOptions := make(map[string]EngineOption)
+	engineOptions := map[string]EngineOption{
+		OwnBookOption:  {Name: OwnBookOption, Type: OptionCheck, Default: "true", Value: "true"},
+		BookFileOption: {Name: BookFileOption, Type: OptionFilename},
+		MultiPVOption:  {Name: MultiPVOption, Type: OptionSpin, Default: "1", Min: 1, Max: 16, HasMin: true, HasMax: true, Value: "1"},
+	}
 	id := uuid.New().String()
 	engine := NewEngine(id, sle, g, engineOptions)
 
@@ -67,6 +64,11 @@ func NewLocalEngine(g *shogi.Game) LocalEngine {
 	}
 }
 
+// Run runs the built-in engine behind the local endpoint until it receives `quit` or ctx is done.
+func (e LocalEngine) Run(ctx context.Context) error {
+	return e.engine.Run(ctx)
+}
+
 func (e LocalEngine) SendMessage(s string) error {
 	e.engineCh <- s
 	return nil
@@ -3,7 +3,10 @@ package engine_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -284,3 +287,148 @@ func TestEngine_ProcessPosition_no_moves(t *testing.T) {
 		t.Errorf("ProcessCMD() failed: moves not loaded, want %d got: %d", 0, len(e.Game.Moves()))
 	}
 }
+
+func TestEngine_Run_plays_legal_moves(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		position string
+		wantMate bool
+	}{
+		{
+			name:     "start position",
+			position: "position startpos moves 7g7f 3c3d",
+		},
+		{
+			name:     "takes the mate in one",
+			position: "position sfen 4k4/9/4P4/9/9/9/9/9/4K4 b G 1",
+			wantMate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			localApi := engine.ServerLocalEngine{
+				EngineCh: make(chan string, 4),
+				GUICh:    make(chan string, 4),
+			}
+			e := engine.NewEngine("id", localApi, shogi.NewGame("sente", "gote"), make(map[string]engine.EngineOption))
+			done := make(chan error, 1)
+			go func() { done <- e.Run(ctx) }()
+
+			localApi.EngineCh <- tt.position
+			localApi.EngineCh <- "go btime 1000 wtime 1000 byoyomi 1000"
+
+			var bm engine.BestMove
+			for {
+				msg, err := receiveMessage(ctx, localApi.GUICh)
+				if err != nil {
+					t.Fatalf("Run() no bestmove: %v", err)
+				}
+				if args, ok := strings.CutPrefix(msg, "bestmove "); ok {
+					bm, err = engine.ParseBestMove(strings.Split(args, " "))
+					if err != nil {
+						t.Fatalf("Run() invalid bestmove: %v", err)
+					}
+					break
+				}
+			}
+
+			b := e.Game.Board().Clone()
+			m, err := b.ResolveUSIMove(bm.Move)
+			if err != nil || !b.IsLegal(m) {
+				t.Fatalf("Run() played illegal move %s: %v", bm.Move, err)
+			}
+			if err := b.ProcessMove(&m); err != nil {
+				t.Fatalf("ProcessMove() failed: %v", err)
+			}
+			if b.IsCheckmate() != tt.wantMate {
+				t.Errorf("Run() played %s, want mate %v", bm.Move, tt.wantMate)
+			}
+
+			localApi.EngineCh <- "quit"
+			if err := <-done; err != nil {
+				t.Errorf("Run() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestEngine_Run_book(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.db")
+	book := "#YANEURAOU-DB2016 1.00\nsfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1\n1g1f 3c3d 42 0 3\n"
+	if err := os.WriteFile(path, []byte(book), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string // description of this test case
+		ownBook  string
+		wantInfo string
+		wantMove string
+	}{
+		{
+			name:     "plays the book move",
+			ownBook:  "true",
+			wantInfo: "info depth 0 score cp 42 pv 1g1f 3c3d",
+			wantMove: "bestmove 1g1f ponder 3c3d",
+		},
+		{
+			name:     "searches with the book disabled",
+			ownBook:  "false",
+			wantInfo: "info depth 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			localApi := engine.ServerLocalEngine{
+				EngineCh: make(chan string, 4),
+				GUICh:    make(chan string, 4),
+			}
+			options := map[string]engine.EngineOption{
+				engine.OwnBookOption:  {Type: engine.OptionCheck, Default: "true", Value: "true"},
+				engine.BookFileOption: {Type: engine.OptionFilename},
+			}
+			e := engine.NewEngine("id", localApi, shogi.NewGame("sente", "gote"), options)
+			if err := e.ProcessSetOption([]string{"name", "BookFile", "value", path}); err != nil {
+				t.Fatalf("ProcessSetOption() failed: %v", err)
+			}
+			if err := e.ProcessSetOption([]string{"name", "USI_OwnBook", "value", tt.ownBook}); err != nil {
+				t.Fatalf("ProcessSetOption() failed: %v", err)
+			}
+			if err := e.ProcessPosition([]string{"startpos"}); err != nil {
+				t.Fatalf("ProcessPosition() failed: %v", err)
+			}
+			if err := e.ProcessGo([]string{"byoyomi", "1000"}); err != nil {
+				t.Fatalf("ProcessGo() failed: %v", err)
+			}
+
+			info, err := receiveMessage(ctx, localApi.GUICh)
+			if err != nil || !strings.HasPrefix(info, tt.wantInfo) {
+				t.Errorf("ProcessGo() info = %q, %v, want %q", info, err, tt.wantInfo)
+			}
+			bm, err := receiveMessage(ctx, localApi.GUICh)
+			if err != nil || tt.wantMove != "" && strings.TrimSpace(bm) != tt.wantMove {
+				t.Errorf("ProcessGo() = %q, %v, want %q", bm, err, tt.wantMove)
+			}
+		})
+	}
+}
+
+func TestEngine_ProcessSetOption_BookFile_missing(t *testing.T) {
+	options := map[string]engine.EngineOption{
+		engine.BookFileOption: {Type: engine.OptionFilename},
+	}
+	e := engine.NewEngine("id", engine.ServerLocalEngine{}, shogi.NewGame("sente", "gote"), options)
+	path := filepath.Join(t.TempDir(), "missing.db")
+	if err := e.ProcessSetOption([]string{"name", "BookFile", "value", path}); err == nil {
+		t.Errorf("ProcessSetOption() succeeded with a missing book")
+	}
+	if v := e.EngineOptions[engine.BookFileOption].Value; v != "" {
+		t.Errorf("ProcessSetOption() stored BookFile %q after failing", v)
+	}
+}
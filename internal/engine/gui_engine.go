@@ -3,7 +3,11 @@ package engine
 import (
 	"context"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
@@ -14,6 +18,18 @@ type GUIEngine struct {
 	EngineID      string
 	EngineOptions map[string]EngineOption
 	EngineAPI     EngineAPI
+
+	// OnInfo is called with every `info` line sent by the engine.
+	OnInfo func(info Info)
+	// Logger logs the lines of the engine skipped during a search, nowhere when nil.
+	Logger *log.Logger
+
+	mu        sync.Mutex
+	searching bool
+	infos     []Info
+	bestMoves chan BestMove
+	// fresh is set from the usinewgame of Init until the first search.
+	fresh bool
 }
 
 func NewGUIEngine(e EngineAPI) *GUIEngine {
@@ -22,6 +38,7 @@ func NewGUIEngine(e EngineAPI) *GUIEngine {
 		EngineID:      "",
 		EngineOptions: make(map[string]EngineOption),
 		EngineAPI:     e,
+		bestMoves:     make(chan BestMove, 1),
 	}
 }
 
@@ -69,9 +86,9 @@ func (e *GUIEngine) ParseEngineCmd(str string) (shogi.EngineCommand, []string, e
 func (e *GUIEngine) ProcessEngineCMD(cmd shogi.EngineCommand, args []string) error {
 	switch cmd {
 	case shogi.Info:
-		return nil
+		return e.receiveInfo(args)
 	case shogi.BestMove:
-		return nil
+		return e.receiveBestMove(args)
 	case shogi.Option:
 		return e.receiveOptions(args)
 	default:
@@ -103,12 +120,20 @@ func (e *GUIEngine) ProcessCMD(cmd shogi.GUICommand, args ...string) error {
 		sfen := args[0]
 		return e.position(sfen, args[1:])
 	case shogi.SetOption:
-		if len(args) < 2 {
+		if len(args) < 1 {
 			return fmt.Errorf("invalid command setoption arguments, %v", args)
 		}
-		return e.setOption(args[0], args[1])
+		value := ""
+		if len(args) > 1 {
+			value = strings.Join(args[1:], " ")
+		}
+		return e.setOption(args[0], value)
 	case shogi.Go:
-		return e.sendGo()
+		params, err := ParseGoParams(args)
+		if err != nil {
+			return err
+		}
+		return e.sendGo(params)
 	case shogi.Stop:
 		return e.stop()
 	case shogi.Ponderhit:
@@ -132,77 +157,12 @@ func (e *GUIEngine) receiveMessage(ctx context.Context) (string, error) {
 	return e.EngineAPI.ReceiveMessage(ctx)
 }
 
+// option name <id> type <t> [default <x>] [min <x>] [max <x>] [var <x>]*
+// Options are stored by name so that later `setoption` commands can be validated against them.
 func (e *GUIEngine) receiveOptions(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("invalid response received, expecting option <args>, but received %v", args)
-	}
-	var opt EngineOption
-	key := ""
-
-	for _, ar := range args {
-		switch ar {
-		case "name":
-			key = "Name"
-		case "type":
-			key = "Type"
-		case "default":
-			key = "Default"
-		default:
-			if key == "" {
-				return fmt.Errorf("invalid option, expecting name|type|default, received %s", ar)
-			}
-			switch key {
-			case "Name":
-				// `name <id>` - The option has the name <id>. Whitespace is not allowed in an option name. Note that the name should normally not be displayed directly in the GUI:
-				// The GUI should look up the option name in the translation file, and present the translation into the users preferred language in the engine's option dialog.
-				// Certain options have a fixed value for <id>, which means that the semantics of this option is fixed. Usually those options should not be displayed in the normal engine
-				// options window of the GUI but get a special treatment. USI_Pondering for example should be set automatically when pondering is enabled or disabled in the GUI options.
-				// The same for USI_AnalyseMode which should also be set automatically by the GUI. All those certain options have the prefix USI_.
-				// If the GUI gets an unknown option with the prefix USI_, it should just ignore it and not display it in the engine's options dialog.
-				//
-				// The options with fixed semantics are:
-				// <id> = USI_Hash, type spin
-				// The value in MB for memory for hash tables can be changed, this should be answered with the first `setoptions` command at program boot if the engine has sent
-				// the appropiate option name `Hash` command, wchich should be supported by all engines! So the engine should use a very small hash first as default.
-				// <id> = USI_Ponder, type check
-				// This means that the engine is able to ponder (i.e. think during the opponent's time). The GUI will send this whenever pondering is possible or not. Note: The engine
-				// should not start pondering on its own if this is enabled, this option is only needed because the engine might change its time management algorithm when pondering is allowed.
-				// <id> = USI_OwnBook, type check
-				// This means that the engine has its own opening book which is accessed by the engine itself. If this is set, the engine takes care of the opening book and the GUI will
-				// never execute a move out of this book for the engine. If this is set to false by the GUI, the engine should not access its own book.
-				// <id> = USI_MultiPV, type spin
-				// The engine supports multi best line or k-best mode. The default value is 1.
-				// <id> = USI_ShowCurrLine, type check
-				// The engine can show the current line it is calculating. See `info currline` above. This option should be false by default.
-				// <id> = USI_ShowRefutations, type check
-				// The engine can show a move and its refutation in a line. See `info refutations` above. This option should be false by default.
-				// <id> = USI_LimitStrength, type check
-				// The engine is able to limit its strength to a specific dan/kyu number. This should always be implemented together with USI_Strength. This option should be false by default.
-				// <id> = USI_Strength, type spin
-				// The engine can limit its strength within the given interval. Negative numbers are kyu levels, while positive numbers are amateur dan levels.
-				// If USI_LimitStrength is set to false, this value should be ignored. If USI_LimitStrength is set to true, the engine should play with this specific strength.
-				// This option should always be implemented together with USI_LimitStrength
-				// <id> = USI_AnalyseMode, type check
-				// The engine wants to behave differently when analysing or playing a game. For example when playing it can use some kind of learning, or an asymetric evaluation function.
-				// The GUI should set this option to false if the engine is playing a game, and to true if the engine is analysing.
-				opt.Name = ar
-			case "Type":
-				// `type <t>` - The option has type t. There are 5 different types of options the engine can send:
-				// check - A checkbox that can either be true or false
-				// spin - A spin wheel or slider that can be an integer in a certain range.
-				// combo - A combo box that can have different predefined strings as a value.
-				// button - A button that can be pressed to send a command to the engine.
-				// string - A text field that has a string as a value, an empty string has the value <empty>.
-				// filename - Similar to string, but is presented as a file browser instead of a text field in the GUI.
-				opt.Type = ar
-			case "Default":
-				// `default <x>` - The default value of this parameter is x.
-				// `min <x>` - The minimum value of this parameter is x.
-				// `max <x>` - The maximum value of this parameter is x.
-				opt.Default = fmt.Sprintf("%s %s", opt.Default, ar)
-			default:
-			}
-		}
+	opt, err := ParseEngineOption(args)
+	if err != nil {
+		return fmt.Errorf("invalid response received: %w", err)
 	}
 	e.EngineOptions[opt.Name] = opt
 	return nil
@@ -247,7 +207,7 @@ func (e *GUIEngine) initializeUSI() error {
 				if len(args) < 2 {
 					return fmt.Errorf("invalid response received, expecting id name <id>, received id %v", args)
 				}
-				e.EngineID = args[1]
+				e.EngineID = strings.Join(args[1:], " ")
 			case "usiok":
 				return nil
 			default:
@@ -255,6 +215,11 @@ func (e *GUIEngine) initializeUSI() error {
 			}
 		case 1:
 			switch cmd {
+			case "id":
+				// `id author <x>` follows the engine name.
+				if len(args) > 1 && args[0] == "name" {
+					e.EngineID = strings.Join(args[1:], " ")
+				}
 			case "option":
 				if err := e.receiveOptions(args); err != nil {
 					return err
@@ -284,8 +249,102 @@ func (e *GUIEngine) synchReady() error {
 	return e.sendCommand("isready")
 }
 
+// Ready sends `isready` and processes the engine output until it answers `readyok`.
+func (e *GUIEngine) Ready(ctx context.Context) error {
+	if err := e.synchReady(); err != nil {
+		return err
+	}
+	for {
+		msg, err := e.receiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		cmd, args, err := e.ParseEngineCmd(msg)
+		if err != nil {
+			continue
+		}
+		if cmd == shogi.ReadyOk {
+			return nil
+		}
+		if err := e.ProcessEngineCMD(cmd, args); err != nil {
+			return err
+		}
+	}
+}
+
+// Init runs the handshake of the engine the first time it is called: usi, the options with
+// setoption in the order of their names, usinewgame and isready until the engine answers readyok.
+// Later calls return at once.
+func (e *GUIEngine) Init(ctx context.Context, options map[string]string) error {
+	if e.IsInitialized {
+		return nil
+	}
+	if err := e.initializeUSI(); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		if err := e.setOption(name, options[name]); err != nil {
+			return err
+		}
+	}
+	if err := e.newGame(); err != nil {
+		return err
+	}
+	if err := e.Ready(ctx); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.fresh = true
+	e.mu.Unlock()
+	e.IsInitialized = true
+	return nil
+}
+
+// NewGame sends usinewgame and waits for readyok, unless the game started by Init wasn't searched
+// yet.
+func (e *GUIEngine) NewGame(ctx context.Context) error {
+	e.mu.Lock()
+	fresh := e.fresh
+	e.fresh = false
+	e.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	if err := e.newGame(); err != nil {
+		return err
+	}
+	return e.Ready(ctx)
+}
+
+// setoption name <id> [value <x>]
+// Options announced by the engine are validated before being sent, buttons are sent without a value.
+// Until the engine has announced its options any name is forwarded as is.
 func (e *GUIEngine) setOption(option string, value string) error {
-	return e.sendCommand(fmt.Sprintf("setoption name %s value %s", option, value))
+	key, opt, exists := lookupOption(e.EngineOptions, option)
+	if !exists {
+		if len(e.EngineOptions) > 0 {
+			return fmt.Errorf("unknown option %s", option)
+		}
+		if value == "" {
+			return fmt.Errorf("invalid command setoption, option %s requires a value", option)
+		}
+		return e.sendCommand(fmt.Sprintf("setoption name %s value %s", option, value))
+	}
+
+	if err := opt.Validate(value); err != nil {
+		return err
+	}
+
+	if opt.Type == OptionButton {
+		return e.sendCommand(fmt.Sprintf("setoption name %s", key))
+	}
+
+	if err := e.sendCommand(fmt.Sprintf("setoption name %s value %s", key, encodeOptionValue(value))); err != nil {
+		return err
+	}
+	opt.Value = value
+	e.EngineOptions[key] = opt
+	return nil
 }
 
 // register later
@@ -373,11 +432,6 @@ func (e *GUIEngine) position(sfen string, moves []string) error {
 	return e.sendCommand(fmt.Sprintf("position %s %s", sfen, movesString))
 }
 
-func (e *GUIEngine) sendGo() error {
-	// TODO: implement this?
-	return nil
-}
-
 func (e *GUIEngine) stop() error {
 	return e.sendCommand("stop")
 }
@@ -393,6 +447,6 @@ func (e *GUIEngine) gameOver(outcome string) error {
 	return e.sendCommand(fmt.Sprintf("gameover %s", outcome))
 }
 
-func (e GUIEngine) quit() error {
+func (e *GUIEngine) quit() error {
 	return e.sendCommand("quit")
 }
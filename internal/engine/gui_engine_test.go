@@ -2,6 +2,8 @@ package engine_test
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -236,3 +238,71 @@ func TestGUIEngine_ProcessCMD(t *testing.T) {
 		})
 	}
 }
+
+func TestGUIEngine_Init(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 4),
+		GUICh:    make(chan string, 4),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	e := engine.NewGUIEngine(localApi)
+
+	sent := make(chan []string, 1)
+	go func() {
+		cmds := []string{}
+		defer func() { sent <- cmds }()
+		for {
+			msg, err := receiveMessage(ctx, localApi.GUICh)
+			if err != nil || msg == "quit" {
+				return
+			}
+			cmds = append(cmds, msg)
+			switch {
+			case msg == "usi":
+				localApi.EngineCh <- "usiok"
+			case msg == "isready":
+				localApi.EngineCh <- "readyok"
+			case strings.HasPrefix(msg, "go"):
+				localApi.EngineCh <- "bestmove 7g7f"
+			}
+		}
+	}()
+
+	options := map[string]string{"USI_Hash": "256", "MultiPV": "1"}
+	// The second Init does nothing and the game it started needs no usinewgame until searched.
+	for range 2 {
+		if err := e.Init(ctx, options); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+	}
+	if err := e.NewGame(ctx); err != nil {
+		t.Fatalf("NewGame() failed: %v", err)
+	}
+	if _, err := e.Search(ctx, engine.GoParams{Depth: 1}); err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	if err := e.NewGame(ctx); err != nil {
+		t.Fatalf("NewGame() failed: %v", err)
+	}
+	if err := e.ProcessCMD(shogi.Quit); err != nil {
+		t.Fatalf("ProcessCMD(quit) failed: %v", err)
+	}
+
+	want := []string{
+		"usi",
+		"setoption name MultiPV value 1",
+		"setoption name USI_Hash value 256",
+		"usinewgame",
+		"isready",
+		"go depth 1",
+		"usinewgame",
+		"isready",
+	}
+	if got := <-sent; !slices.Equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if !e.IsInitialized {
+		t.Errorf("IsInitialized not set by Init()")
+	}
+}
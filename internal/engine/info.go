@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Score is the evaluation of an `info score` from the engine's point of view.
+type Score struct {
+	// CP is the score in centipawns, only meaningful when IsMate is false.
+	CP int
+	// IsMate marks a mate score, Mate is the number of plies to mate, negative when the
+	// engine is getting mated. Engines may send `mate +` or `mate -` without a distance,
+	// in which case Mate is 0 and MateSign tells the side.
+	IsMate   bool
+	Mate     int
+	MateSign int
+	// LowerBound and UpperBound mark scores that are just a bound.
+	LowerBound bool
+	UpperBound bool
+}
+
+func (s Score) String() string {
+	var str string
+	if s.IsMate {
+		switch {
+		case s.Mate != 0:
+			str = fmt.Sprintf("mate %d", s.Mate)
+		case s.MateSign < 0:
+			str = "mate -"
+		default:
+			str = "mate +"
+		}
+	} else {
+		str = fmt.Sprintf("%+.2f", float64(s.CP)/100)
+	}
+	if s.LowerBound {
+		str += " (lower)"
+	}
+	if s.UpperBound {
+		str += " (upper)"
+	}
+	return str
+}
+
+// Centipawns returns the score in centipawns, mate scores are mapped beyond any material score
+// so that shorter mates score higher.
+func (s Score) Centipawns() int {
+	if !s.IsMate {
+		return s.CP
+	}
+	if s.Mate < 0 || (s.Mate == 0 && s.MateSign < 0) {
+		return -mateScore - s.Mate
+	}
+	return mateScore - s.Mate
+}
+
+// Info is a parsed `info` line, see shogi.Info for the meaning of each field.
+type Info struct {
+	Depth          int
+	SelDepth       int
+	Time           time.Duration
+	Nodes          int64
+	NPS            int64
+	HashFull       int
+	CPULoad        int
+	HasScore       bool
+	Score          Score
+	MultiPV        int
+	PV             []string
+	CurrMove       string
+	CurrMoveNumber int
+	String         string
+}
+
+// ParseInfo parses the arguments of an `info` command.
+// Unknown tokens are skipped, `string` and `pv` take the rest of the line.
+func ParseInfo(args []string) (Info, error) {
+	var info Info
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "string":
+			info.String = strings.Join(args[i+1:], " ")
+			return info, nil
+		case "pv":
+			info.PV = append([]string{}, args[i+1:]...)
+			return info, nil
+		case "currmove":
+			if i+1 >= len(args) {
+				return Info{}, fmt.Errorf("invalid info, currmove requires a move")
+			}
+			info.CurrMove = args[i+1]
+			i++
+		case "score":
+			n, err := parseScore(args[i+1:], &info.Score)
+			if err != nil {
+				return Info{}, err
+			}
+			info.HasScore = true
+			i += n
+		case "depth", "seldepth", "time", "nodes", "nps", "hashfull", "cpuload", "multipv", "currmovenumber":
+			if i+1 >= len(args) {
+				return Info{}, fmt.Errorf("invalid info, %s requires a value", args[i])
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Info{}, fmt.Errorf("invalid info, %s is not an integer: %w", args[i], err)
+			}
+			switch args[i] {
+			case "depth":
+				info.Depth = int(n)
+			case "seldepth":
+				info.SelDepth = int(n)
+			case "time":
+				info.Time = time.Duration(n) * time.Millisecond
+			case "nodes":
+				info.Nodes = n
+			case "nps":
+				info.NPS = n
+			case "hashfull":
+				info.HashFull = int(n)
+			case "cpuload":
+				info.CPULoad = int(n)
+			case "multipv":
+				info.MultiPV = int(n)
+			case "currmovenumber":
+				info.CurrMoveNumber = int(n)
+			}
+			i++
+		}
+	}
+	return info, nil
+}
+
+// parseScore parses `cp <x>` or `mate <y>` followed by an optional bound and returns the
+// number of tokens consumed.
+func parseScore(args []string, s *Score) (int, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("invalid info, score requires cp <x> or mate <y>, received %v", args)
+	}
+	switch args[0] {
+	case "cp":
+		cp, err := strconv.Atoi(args[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid info, score cp is not an integer: %w", err)
+		}
+		s.CP = cp
+	case "mate":
+		s.IsMate = true
+		switch args[1] {
+		case "+":
+			s.MateSign = 1
+		case "-":
+			s.MateSign = -1
+		default:
+			mate, err := strconv.Atoi(args[1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid info, score mate is not an integer: %w", err)
+			}
+			s.Mate = mate
+			s.MateSign = 1
+			if mate < 0 {
+				s.MateSign = -1
+			}
+		}
+	default:
+		return 0, fmt.Errorf("invalid info, score requires cp <x> or mate <y>, received %v", args)
+	}
+
+	n := 2
+	if len(args) > 2 {
+		switch args[2] {
+		case "lowerbound":
+			s.LowerBound = true
+			n++
+		case "upperbound":
+			s.UpperBound = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ReadablePV converts the PV to the notation used by the TUI by playing it on a copy of b,
+// which must be the position the engine searched. If a move can't be played the moves
+// converted so far are returned along with the error.
+func (i Info) ReadablePV(b shogi.Board) ([]string, error) {
+	board := b.Clone()
+	pv := make([]string, 0, len(i.PV))
+	for _, usi := range i.PV {
+		m, err := board.ResolveUSIMove(usi)
+		if err != nil {
+			return pv, err
+		}
+		pv = append(pv, shogi.Notation{Board: board}.EncodeMovement(m))
+		if err := board.ProcessMove(&m); err != nil {
+			return pv, err
+		}
+	}
+	return pv, nil
+}
@@ -0,0 +1,126 @@
+package engine_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		line    string
+		want    engine.Info
+		wantErr bool
+	}{
+		{
+			name: "pv line",
+			line: "depth 2 seldepth 4 score cp 214 time 1242 nodes 2124 nps 34928 hashfull 12 multipv 1 pv 2g2f 8c8d 2f2e",
+			want: engine.Info{
+				Depth: 2, SelDepth: 4, Time: 1242 * time.Millisecond, Nodes: 2124, NPS: 34928, HashFull: 12, MultiPV: 1,
+				HasScore: true, Score: engine.Score{CP: 214},
+				PV: []string{"2g2f", "8c8d", "2f2e"},
+			},
+		},
+		{
+			name: "mate with bound",
+			line: "depth 10 score mate -5 upperbound pv 5a4b",
+			want: engine.Info{
+				Depth: 10, HasScore: true, Score: engine.Score{IsMate: true, Mate: -5, MateSign: -1, UpperBound: true},
+				PV: []string{"5a4b"},
+			},
+		},
+		{
+			name: "mate without distance",
+			line: "score mate + pv 2b3a+",
+			want: engine.Info{HasScore: true, Score: engine.Score{IsMate: true, MateSign: 1}, PV: []string{"2b3a+"}},
+		},
+		{
+			name: "currmove",
+			line: "currmove 2g2f currmovenumber 1",
+			want: engine.Info{CurrMove: "2g2f", CurrMoveNumber: 1},
+		},
+		{
+			name: "string takes the rest of the line",
+			line: "depth 3 string book move 7g7f found",
+			want: engine.Info{Depth: 3, String: "book move 7g7f found"},
+		},
+		{
+			name:    "bad number fails",
+			line:    "depth two",
+			wantErr: true,
+		},
+		{
+			name:    "bad score fails",
+			line:    "score lots 3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := engine.ParseInfo(strings.Split(tt.line, " "))
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseInfo() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseInfo() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScore_String(t *testing.T) {
+	tests := []struct {
+		score engine.Score
+		want  string
+	}{
+		{score: engine.Score{CP: 214}, want: "+2.14"},
+		{score: engine.Score{CP: -50, LowerBound: true}, want: "-0.50 (lower)"},
+		{score: engine.Score{IsMate: true, Mate: 7, MateSign: 1}, want: "mate 7"},
+		{score: engine.Score{IsMate: true, MateSign: -1}, want: "mate -"},
+	}
+	for _, tt := range tests {
+		if got := tt.score.String(); got != tt.want {
+			t.Errorf("String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestInfo_ReadablePV(t *testing.T) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(shogi.StartingPosition); err != nil {
+		t.Fatalf("LoadSfen() failed: %v", err)
+	}
+	info := engine.Info{PV: []string{"7g7f", "3c3d", "8h2b+", "3a2b", "B*4e"}}
+
+	got, err := info.ReadablePV(b)
+	if err != nil {
+		t.Fatalf("ReadablePV() failed: %v", err)
+	}
+	want := []string{"P-3f", "p-7d", "Bx8b+", "sx8b", "B*6e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadablePV() = %v, want %v", got, want)
+	}
+	if b.String() != shogi.StartingPosition {
+		t.Errorf("ReadablePV() modified the board: %s", b.String())
+	}
+
+	info.PV = []string{"7g7f", "7g7f"}
+	got, err = info.ReadablePV(b)
+	if err == nil {
+		t.Errorf("ReadablePV() succeeded unexpectedly with an illegal move")
+	}
+	if len(got) != 1 {
+		t.Errorf("ReadablePV() expecting the moves before the error, got %v", got)
+	}
+}
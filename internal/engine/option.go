@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OptionType is the `type <t>` token of an USI `option` command.
+type OptionType string
+
+const (
+	// check - A checkbox that can either be true or false
+	OptionCheck OptionType = "check"
+	// spin - A spin wheel or slider that can be an integer in a certain range.
+	OptionSpin OptionType = "spin"
+	// combo - A combo box that can have different predefined strings as a value.
+	OptionCombo OptionType = "combo"
+	// button - A button that can be pressed to send a command to the engine.
+	OptionButton OptionType = "button"
+	// string - A text field that has a string as a value, an empty string has the value <empty>.
+	OptionString OptionType = "string"
+	// filename - Similar to string, but is presented as a file browser instead of a text field in the GUI.
+	OptionFilename OptionType = "filename"
+)
+
+// emptyOptionValue is how USI encodes an empty string or filename value.
+const emptyOptionValue = "<empty>"
+
+type EngineOption struct {
+	Name        string
+	Description string
+	Type        OptionType
+	Default     string
+	// Min and Max bound the value of spin options, when HasMin and HasMax are set. A spin
+	// option announced without bounds accepts any integer.
+	Min    int
+	Max    int
+	HasMin bool
+	HasMax bool
+	// Vars holds the predefined values of a combo option.
+	Vars []string
+	// Value is the current value of the option, it starts as Default and changes with `setoption`.
+	Value string
+}
+
+// ParseEngineOption parses the arguments of an engine `option` command:
+//
+//	name <id> type <t> [default <x>] [min <x>] [max <x>] [var <x>]*
+//
+// Default, string and var values may contain whitespace, so every token up to the next
+// keyword is part of the value.
+func ParseEngineOption(args []string) (EngineOption, error) {
+	if len(args) < 1 {
+		return EngineOption{}, fmt.Errorf("invalid option, expecting option <args>, but received %v", args)
+	}
+
+	var opt EngineOption
+	values := make(map[string][]string)
+	vars := [][]string{}
+	key := ""
+
+	for _, ar := range args {
+		switch ar {
+		case "name", "type", "default", "min", "max":
+			key = ar
+			values[key] = []string{}
+		case "var":
+			key = ar
+			vars = append(vars, []string{})
+		default:
+			switch key {
+			case "":
+				return EngineOption{}, fmt.Errorf("invalid option, expecting name|type|default|min|max|var, received %s", ar)
+			case "var":
+				vars[len(vars)-1] = append(vars[len(vars)-1], ar)
+			default:
+				values[key] = append(values[key], ar)
+			}
+		}
+	}
+
+	// Whitespace is not allowed in an option name.
+	name := values["name"]
+	if len(name) != 1 {
+		return EngineOption{}, fmt.Errorf("invalid option, expecting name <id>, received %v", name)
+	}
+	opt.Name = name[0]
+
+	t := values["type"]
+	if len(t) != 1 {
+		return EngineOption{}, fmt.Errorf("invalid option %s, expecting type <t>, received %v", opt.Name, t)
+	}
+	opt.Type = OptionType(t[0])
+	if !opt.Type.IsValid() {
+		return EngineOption{}, fmt.Errorf("invalid option %s, unknown type %s", opt.Name, opt.Type)
+	}
+
+	if d, exists := values["default"]; exists {
+		opt.Default = strings.Join(d, " ")
+		if opt.Default == emptyOptionValue {
+			opt.Default = ""
+		}
+	}
+
+	for _, bound := range []string{"min", "max"} {
+		v, exists := values[bound]
+		if !exists {
+			continue
+		}
+		if len(v) != 1 {
+			return EngineOption{}, fmt.Errorf("invalid option %s, expecting %s <x>, received %v", opt.Name, bound, v)
+		}
+		n, err := strconv.Atoi(v[0])
+		if err != nil {
+			return EngineOption{}, fmt.Errorf("invalid option %s, %s is not an integer: %w", opt.Name, bound, err)
+		}
+		if bound == "min" {
+			opt.Min, opt.HasMin = n, true
+		} else {
+			opt.Max, opt.HasMax = n, true
+		}
+	}
+
+	for _, v := range vars {
+		opt.Vars = append(opt.Vars, strings.Join(v, " "))
+	}
+
+	if opt.Type == OptionSpin && opt.HasMin && opt.HasMax && opt.Min > opt.Max {
+		return EngineOption{}, fmt.Errorf("invalid option %s, min %d is greater than max %d", opt.Name, opt.Min, opt.Max)
+	}
+	if opt.Type == OptionCombo && len(opt.Vars) == 0 {
+		return EngineOption{}, fmt.Errorf("invalid option %s, combo requires at least one var", opt.Name)
+	}
+	if opt.Type != OptionButton && opt.Default != "" {
+		if err := opt.Validate(opt.Default); err != nil {
+			return EngineOption{}, fmt.Errorf("invalid option %s default: %w", opt.Name, err)
+		}
+	}
+
+	opt.Value = opt.Default
+	return opt, nil
+}
+
+func (t OptionType) IsValid() bool {
+	switch t {
+	case OptionCheck, OptionSpin, OptionCombo, OptionButton, OptionString, OptionFilename:
+		return true
+	}
+	return false
+}
+
+// Validate checks that value is acceptable for the option as it would be sent with
+// `setoption name <id> value <x>`.
+func (o EngineOption) Validate(value string) error {
+	switch o.Type {
+	case OptionCheck:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("option %s expects true or false, received %q", o.Name, value)
+		}
+	case OptionSpin:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("option %s expects an integer, received %q", o.Name, value)
+		}
+		switch {
+		case o.HasMin && o.HasMax && (n < o.Min || n > o.Max):
+			return fmt.Errorf("option %s expects a value between %d and %d, received %d", o.Name, o.Min, o.Max, n)
+		case o.HasMin && n < o.Min:
+			return fmt.Errorf("option %s expects a value of at least %d, received %d", o.Name, o.Min, n)
+		case o.HasMax && n > o.Max:
+			return fmt.Errorf("option %s expects a value of at most %d, received %d", o.Name, o.Max, n)
+		}
+	case OptionCombo:
+		if !slices.ContainsFunc(o.Vars, func(v string) bool { return strings.EqualFold(v, value) }) {
+			return fmt.Errorf("option %s expects one of %v, received %q", o.Name, o.Vars, value)
+		}
+	case OptionButton:
+		if value != "" {
+			return fmt.Errorf("option %s is a button and takes no value, received %q", o.Name, value)
+		}
+	case OptionString, OptionFilename:
+	default:
+		return fmt.Errorf("option %s has unknown type %s", o.Name, o.Type)
+	}
+	return nil
+}
+
+// String encodes the option as the arguments of an engine `option` command, e.g.
+// name Selectivity type spin default 2 min 0 max 4
+func (o EngineOption) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "name %s type %s", o.Name, o.Type)
+
+	switch o.Type {
+	case OptionButton:
+		return sb.String()
+	case OptionString, OptionFilename:
+		if o.Default == "" {
+			fmt.Fprintf(&sb, " default %s", emptyOptionValue)
+		} else {
+			fmt.Fprintf(&sb, " default %s", o.Default)
+		}
+		return sb.String()
+	}
+
+	if o.Default != "" {
+		fmt.Fprintf(&sb, " default %s", o.Default)
+	}
+	if o.Type == OptionSpin && o.HasMin {
+		fmt.Fprintf(&sb, " min %d", o.Min)
+	}
+	if o.Type == OptionSpin && o.HasMax {
+		fmt.Fprintf(&sb, " max %d", o.Max)
+	}
+	for _, v := range o.Vars {
+		fmt.Fprintf(&sb, " var %s", v)
+	}
+	return sb.String()
+}
+
+// lookupOption finds an option by name, option names are not case sensitive.
+func lookupOption(options map[string]EngineOption, name string) (string, EngineOption, bool) {
+	if opt, exists := options[name]; exists {
+		return name, opt, true
+	}
+	for key, opt := range options {
+		if strings.EqualFold(key, name) {
+			return key, opt, true
+		}
+	}
+	return "", EngineOption{}, false
+}
+
+// parseSetOption parses the arguments of a `setoption name <id> [value <x>]` command.
+func parseSetOption(args []string) (string, string, error) {
+	if len(args) < 2 || args[0] != "name" {
+		return "", "", fmt.Errorf("invalid setoption, expecting name <id> [value <x>], received %v", args)
+	}
+	name := args[1]
+	if len(args) == 2 {
+		return name, "", nil
+	}
+	if args[2] != "value" {
+		return "", "", fmt.Errorf("invalid setoption, expecting value <x>, received %v", args[2:])
+	}
+	value := strings.Join(args[3:], " ")
+	if value == emptyOptionValue {
+		value = ""
+	}
+	return name, value, nil
+}
+
+func encodeOptionValue(value string) string {
+	if value == "" {
+		return emptyOptionValue
+	}
+	return value
+}
@@ -0,0 +1,201 @@
+package engine_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestParseEngineOption(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		line    string
+		want    engine.EngineOption
+		wantErr bool
+	}{
+		{
+			name: "check",
+			line: "name Nullmove type check default true",
+			want: engine.EngineOption{Name: "Nullmove", Type: engine.OptionCheck, Default: "true", Value: "true"},
+		},
+		{
+			name: "spin with bounds",
+			line: "name Selectivity type spin default 2 min 0 max 4",
+			want: engine.EngineOption{Name: "Selectivity", Type: engine.OptionSpin, Default: "2", Min: 0, Max: 4, HasMin: true, HasMax: true, Value: "2"},
+		},
+		{
+			name: "combo with vars",
+			line: "name Style type combo default Normal var Solid var Normal var Risky",
+			want: engine.EngineOption{Name: "Style", Type: engine.OptionCombo, Default: "Normal", Vars: []string{"Solid", "Normal", "Risky"}, Value: "Normal"},
+		},
+		{
+			name: "filename",
+			line: "name LearningFile type filename default /shogi/my-shogi-engine/learn.bin",
+			want: engine.EngineOption{Name: "LearningFile", Type: engine.OptionFilename, Default: "/shogi/my-shogi-engine/learn.bin", Value: "/shogi/my-shogi-engine/learn.bin"},
+		},
+		{
+			name: "string with empty default",
+			line: "name BookFile type string default <empty>",
+			want: engine.EngineOption{Name: "BookFile", Type: engine.OptionString},
+		},
+		{
+			name: "button",
+			line: "name ResetLearning type button",
+			want: engine.EngineOption{Name: "ResetLearning", Type: engine.OptionButton},
+		},
+		{
+			name:    "unknown type fails",
+			line:    "name Foo type slider default 1",
+			wantErr: true,
+		},
+		{
+			name:    "default out of bounds fails",
+			line:    "name Selectivity type spin default 5 min 0 max 4",
+			wantErr: true,
+		},
+		{
+			name:    "combo without vars fails",
+			line:    "name Style type combo default Normal",
+			wantErr: true,
+		},
+		{
+			name:    "missing name fails",
+			line:    "type check default true",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := engine.ParseEngineOption(strings.Split(tt.line, " "))
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseEngineOption() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseEngineOption() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEngineOption() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.line {
+				t.Errorf("String() = %s, want %s", got.String(), tt.line)
+			}
+		})
+	}
+}
+
+func TestEngineOption_Validate(t *testing.T) {
+	spin := engine.EngineOption{Name: "Selectivity", Type: engine.OptionSpin, Min: 0, Max: 4, HasMin: true, HasMax: true}
+	combo := engine.EngineOption{Name: "Style", Type: engine.OptionCombo, Vars: []string{"Solid", "Normal", "Risky"}}
+	zero, err := engine.ParseEngineOption(strings.Fields("name Fixed type spin default 0 min 0 max 0"))
+	if err != nil {
+		t.Fatalf("ParseEngineOption() failed: %v", err)
+	}
+	tests := []struct {
+		name    string // description of this test case
+		opt     engine.EngineOption
+		value   string
+		wantErr bool
+	}{
+		{name: "check true", opt: engine.EngineOption{Type: engine.OptionCheck}, value: "true"},
+		{name: "check invalid", opt: engine.EngineOption{Type: engine.OptionCheck}, value: "yes", wantErr: true},
+		{name: "spin in range", opt: spin, value: "4"},
+		{name: "spin above max", opt: spin, value: "5", wantErr: true},
+		{name: "spin below min", opt: spin, value: "-1", wantErr: true},
+		{name: "spin not a number", opt: spin, value: "two", wantErr: true},
+		{name: "spin without bounds", opt: engine.EngineOption{Type: engine.OptionSpin}, value: "1024"},
+		{name: "spin bounded to 0", opt: zero, value: "1", wantErr: true},
+		{name: "spin at its only value", opt: zero, value: "0"},
+		{name: "spin with only a min", opt: engine.EngineOption{Type: engine.OptionSpin, Min: 1, HasMin: true}, value: "0", wantErr: true},
+		{name: "combo var", opt: combo, value: "risky"},
+		{name: "combo unknown var", opt: combo, value: "Wild", wantErr: true},
+		{name: "button without value", opt: engine.EngineOption{Type: engine.OptionButton}},
+		{name: "button with value", opt: engine.EngineOption{Type: engine.OptionButton}, value: "1", wantErr: true},
+		{name: "string", opt: engine.EngineOption{Type: engine.OptionString}, value: "any text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := tt.opt.Validate(tt.value)
+			if gotErr != nil && !tt.wantErr {
+				t.Errorf("Validate() failed: %v", gotErr)
+			}
+			if gotErr == nil && tt.wantErr {
+				t.Fatal("Validate() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func TestEngine_ProcessSetOption(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 2),
+		GUICh:    make(chan string, 2),
+	}
+	opts := map[string]engine.EngineOption{
+		"USI_Hash": {Name: "USI_Hash", Type: engine.OptionSpin, Default: "16", Min: 1, Max: 1024, HasMin: true, HasMax: true, Value: "16"},
+	}
+	e := engine.NewEngine("id", localApi, shogi.NewGame("sente", "gote"), opts)
+
+	if err := e.ProcessGUICMD(shogi.SetOption, []string{"name", "usi_hash", "value", "256"}); err != nil {
+		t.Fatalf("ProcessGUICMD() failed: %v", err)
+	}
+	if e.EngineOptions["USI_Hash"].Value != "256" {
+		t.Errorf("ProcessGUICMD() failed: want value 256 got %s", e.EngineOptions["USI_Hash"].Value)
+	}
+	if err := e.ProcessGUICMD(shogi.SetOption, []string{"name", "USI_Hash", "value", "4096"}); err == nil {
+		t.Errorf("ProcessGUICMD() succeeded unexpectedly with an out of range value")
+	}
+	if err := e.ProcessGUICMD(shogi.SetOption, []string{"name", "Unknown", "value", "1"}); err == nil {
+		t.Errorf("ProcessGUICMD() succeeded unexpectedly with an unknown option")
+	}
+}
+
+func TestGUIEngine_SetOption_validates(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 2),
+		GUICh:    make(chan string, 2),
+	}
+	e := engine.NewGUIEngine(localApi)
+	if err := e.ProcessEngineCMD(shogi.Option, strings.Split("name Selectivity type spin default 2 min 0 max 4", " ")); err != nil {
+		t.Fatalf("ProcessEngineCMD() failed: %v", err)
+	}
+	if err := e.ProcessEngineCMD(shogi.Option, strings.Split("name ResetLearning type button", " ")); err != nil {
+		t.Fatalf("ProcessEngineCMD() failed: %v", err)
+	}
+
+	if err := e.ProcessCMD(shogi.SetOption, "Selectivity", "9"); err == nil {
+		t.Errorf("ProcessCMD() succeeded unexpectedly with an out of range value")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := e.ProcessCMD(shogi.SetOption, "Selectivity", "3"); err != nil {
+		t.Fatalf("ProcessCMD() failed: %v", err)
+	}
+	msg, err := receiveMessage(ctx, localApi.GUICh)
+	if err != nil {
+		t.Fatalf("ProcessCMD() setoption: %v", err)
+	}
+	if msg != "setoption name Selectivity value 3" {
+		t.Errorf("ProcessCMD() setoption want %s got %s", "setoption name Selectivity value 3", msg)
+	}
+
+	if err := e.ProcessCMD(shogi.SetOption, "ResetLearning"); err != nil {
+		t.Fatalf("ProcessCMD() failed: %v", err)
+	}
+	msg, err = receiveMessage(ctx, localApi.GUICh)
+	if err != nil {
+		t.Fatalf("ProcessCMD() setoption: %v", err)
+	}
+	if msg != "setoption name ResetLearning" {
+		t.Errorf("ProcessCMD() setoption want %s got %s", "setoption name ResetLearning", msg)
+	}
+}
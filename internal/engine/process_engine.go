@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQuitTimeout is how long Close waits for the engine to exit after `quit` before killing it.
+	defaultQuitTimeout = 2 * time.Second
+	// maxStderrSize is the amount of stderr output kept from the engine process.
+	maxStderrSize = 64 * 1024
+)
+
+// ProcessEngine is an EngineAPI that talks USI with an external engine binary
+// (YaneuraOu, Apery, ...) over the stdin and stdout of a subprocess.
+// Messages are framed as lines, stderr is captured so it can be shown when the engine misbehaves.
+type ProcessEngine struct {
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	lines       chan string
+	done        chan struct{}
+	stderr      *stderrBuffer
+	quitTimeout time.Duration
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// WithArgs sets the command line arguments passed to the engine binary.
+func WithArgs(args ...string) func(*ProcessEngine) {
+	return func(e *ProcessEngine) {
+		e.cmd.Args = append([]string{e.cmd.Path}, args...)
+	}
+}
+
+// WithEnv sets the environment of the engine process, see exec.Cmd.Env.
+func WithEnv(env []string) func(*ProcessEngine) {
+	return func(e *ProcessEngine) {
+		e.cmd.Env = env
+	}
+}
+
+// WithDir sets the working directory of the engine process, most engines look for their
+// evaluation files relative to it.
+func WithDir(dir string) func(*ProcessEngine) {
+	return func(e *ProcessEngine) {
+		e.cmd.Dir = dir
+	}
+}
+
+// WithQuitTimeout sets how long Close waits after `quit` before killing the engine.
+func WithQuitTimeout(d time.Duration) func(*ProcessEngine) {
+	return func(e *ProcessEngine) {
+		e.quitTimeout = d
+	}
+}
+
+// NewProcessEngine launches the engine binary at path and starts reading its output.
+func NewProcessEngine(path string, options ...func(*ProcessEngine)) (*ProcessEngine, error) {
+	e := &ProcessEngine{
+		cmd:         exec.Command(path),
+		lines:       make(chan string, 128),
+		done:        make(chan struct{}),
+		stderr:      &stderrBuffer{max: maxStderrSize},
+		quitTimeout: defaultQuitTimeout,
+	}
+
+	for _, f := range options {
+		f(e)
+	}
+
+	stdin, err := e.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("engine: unable to open stdin of %s: %w", path, err)
+	}
+	stdout, err := e.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("engine: unable to open stdout of %s: %w", path, err)
+	}
+	e.stdin = stdin
+	e.cmd.Stderr = e.stderr
+
+	if err := e.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("engine: unable to start %s: %w", path, err)
+	}
+
+	go e.readLoop(stdout)
+
+	return e, nil
+}
+
+func (e *ProcessEngine) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+	for scanner.Scan() {
+		e.lines <- strings.TrimRight(scanner.Text(), "\r")
+	}
+	close(e.lines)
+
+	// Wait must only be called once stdout has been fully read.
+	err := e.cmd.Wait()
+	e.mu.Lock()
+	if err != nil {
+		e.err = err
+	} else {
+		e.err = io.EOF
+	}
+	e.mu.Unlock()
+	close(e.done)
+}
+
+// SendMessage writes a single command line to the engine.
+func (e *ProcessEngine) SendMessage(s string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return fmt.Errorf("engine: process already closed")
+	}
+	_, err := io.WriteString(e.stdin, strings.TrimRight(s, "\r\n")+"\n")
+	if err != nil {
+		return fmt.Errorf("engine: unable to write to engine: %w", err)
+	}
+	return nil
+}
+
+// ReceiveMessage returns the next line written by the engine. Once the process exits and
+// its output has been consumed it returns the exit error, or io.EOF if it exited cleanly.
+func (e *ProcessEngine) ReceiveMessage(ctx context.Context) (string, error) {
+	select {
+	case m, ok := <-e.lines:
+		if !ok {
+			<-e.done
+			return "", e.exitErr()
+		}
+		return m, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("timeout waiting for message")
+	}
+}
+
+func (e *ProcessEngine) exitErr() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == io.EOF {
+		return io.EOF
+	}
+	if stderr := e.stderr.String(); stderr != "" {
+		return fmt.Errorf("engine: process exited: %w: %s", e.err, stderr)
+	}
+	return fmt.Errorf("engine: process exited: %w", e.err)
+}
+
+// Stderr returns the last output the engine wrote to stderr.
+func (e *ProcessEngine) Stderr() string {
+	return e.stderr.String()
+}
+
+// Done is closed once the engine process has exited.
+func (e *ProcessEngine) Done() <-chan struct{} {
+	return e.done
+}
+
+// Close asks the engine to `quit` and waits for it to exit, killing the process if it
+// is still running after the quit timeout. Close can be called more than once.
+func (e *ProcessEngine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		<-e.done
+		return nil
+	}
+	// Errors are ignored as the process may already be gone.
+	_, _ = io.WriteString(e.stdin, "quit\n")
+	_ = e.stdin.Close()
+	e.closed = true
+	e.mu.Unlock()
+
+	// Drain the output so the reader is never blocked on a full channel.
+	go func() {
+		for range e.lines {
+		}
+	}()
+
+	timer := time.NewTimer(e.quitTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-timer.C:
+	}
+
+	if err := e.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("engine: unable to kill process: %w", err)
+	}
+	<-e.done
+	return nil
+}
+
+// stderrBuffer keeps the tail of the engine stderr, it is written by the exec package
+// from its own goroutine.
+type stderrBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
@@ -0,0 +1,157 @@
+package engine_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// fakeEngineEnv makes the test binary behave as a tiny USI engine instead of running the tests,
+// so the ProcessEngine can be exercised against a real subprocess.
+const fakeEngineEnv = "SHOGO_FAKE_ENGINE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeEngineEnv); mode != "" {
+		runFakeEngine(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeEngine answers the handful of USI commands used by the tests.
+// In "stubborn" mode it ignores `quit` and a closed stdin so the caller has to kill it, in "crash" mode
+// it exits with an error as soon as it receives `go`.
+func runFakeEngine(mode string) {
+	fmt.Fprintln(os.Stderr, "fake engine starting")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), " ")
+		switch parts[0] {
+		case "usi":
+			fmt.Println("id name fake engine")
+			fmt.Println("id author shogo")
+			fmt.Println("option name USI_Hash type spin default 16 min 1 max 1024")
+			fmt.Println("option name Style type combo default Normal var Solid var Normal var Risky")
+			fmt.Println("usiok")
+		case "isready":
+			fmt.Println("readyok")
+		case "go":
+			if mode == "crash" {
+				fmt.Fprintln(os.Stderr, "fake engine crashed")
+				os.Exit(3)
+			}
+			fmt.Println("info depth 1 score cp 30 pv 7g7f 3c3d")
+			fmt.Println("bestmove 7g7f ponder 3c3d")
+		case "quit":
+			if mode != "stubborn" {
+				return
+			}
+		}
+	}
+	if mode == "stubborn" {
+		time.Sleep(time.Hour)
+	}
+}
+
+func newFakeProcessEngine(t *testing.T, mode string) *engine.ProcessEngine {
+	t.Helper()
+	e, err := engine.NewProcessEngine(os.Args[0],
+		engine.WithEnv(append(os.Environ(), fakeEngineEnv+"="+mode)),
+		engine.WithQuitTimeout(200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewProcessEngine() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+func TestProcessEngine_USI(t *testing.T) {
+	pe := newFakeProcessEngine(t, "normal")
+	e := engine.NewGUIEngine(pe)
+
+	if err := e.ProcessCMD(shogi.USI); err != nil {
+		t.Fatalf("ProcessCMD() usi failed: %v", err)
+	}
+	if e.EngineID != "fake engine" {
+		t.Errorf("ProcessCMD() usi: expecting id 'fake engine' got '%s'", e.EngineID)
+	}
+	if opt, exists := e.EngineOptions["USI_Hash"]; !exists || opt.Max != 1024 {
+		t.Errorf("ProcessCMD() usi: option USI_Hash not parsed: %v", e.EngineOptions)
+	}
+	if err := e.ProcessCMD(shogi.SetOption, "USI_Hash", "2048"); err == nil {
+		t.Errorf("ProcessCMD() setoption succeeded unexpectedly with an out of range value")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := e.ProcessCMD(shogi.IsReady); err != nil {
+		t.Fatalf("ProcessCMD() isready failed: %v", err)
+	}
+	msg, err := pe.ReceiveMessage(ctx)
+	if err != nil {
+		t.Fatalf("ReceiveMessage() failed: %v", err)
+	}
+	if msg != "readyok" {
+		t.Errorf("ReceiveMessage() want readyok got %s", msg)
+	}
+
+	if !strings.Contains(pe.Stderr(), "fake engine starting") {
+		t.Errorf("Stderr() not captured, got %q", pe.Stderr())
+	}
+}
+
+func TestProcessEngine_Close_quits(t *testing.T) {
+	pe := newFakeProcessEngine(t, "normal")
+
+	if err := pe.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	select {
+	case <-pe.Done():
+	default:
+		t.Fatal("Close() returned before the engine exited")
+	}
+	if err := pe.SendMessage("usi"); err == nil {
+		t.Errorf("SendMessage() succeeded unexpectedly after Close()")
+	}
+}
+
+func TestProcessEngine_Close_kills_stubborn_engine(t *testing.T) {
+	pe := newFakeProcessEngine(t, "stubborn")
+
+	start := time.Now()
+	if err := pe.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("Close() did not wait for the quit timeout before killing")
+	}
+}
+
+func TestProcessEngine_ReceiveMessage_reports_exit(t *testing.T) {
+	pe := newFakeProcessEngine(t, "crash")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := pe.SendMessage("go"); err != nil {
+		t.Fatalf("SendMessage() failed: %v", err)
+	}
+	_, err := pe.ReceiveMessage(ctx)
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("ReceiveMessage() expecting exit error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "fake engine crashed") {
+		t.Errorf("ReceiveMessage() error does not include stderr: %v", err)
+	}
+}
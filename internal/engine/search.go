@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// GoParams are the arguments of the `go` command. Zero values are not sent.
+type GoParams struct {
+	// SearchMoves restricts the search to these USI moves.
+	SearchMoves []string
+	Ponder      bool
+	BTime       time.Duration
+	WTime       time.Duration
+	BInc        time.Duration
+	WInc        time.Duration
+	Byoyomi     time.Duration
+	MovesToGo   int
+	Depth       int
+	Nodes       int
+	MoveTime    time.Duration
+	Infinite    bool
+}
+
+// NewGoParams returns the params of a search played on clock, with the time left to both players
+// and the byoyomi or the increment of its time control. Canadian byoyomi is sent as the time left
+// per move in the current period of the side to move.
+func NewGoParams(clock *shogi.Clock) GoParams {
+	p := GoParams{BTime: clock.Remaining(shogi.Black), WTime: clock.Remaining(shogi.White)}
+	tc := clock.TimeControl()
+	switch tc.Kind {
+	case shogi.Byoyomi:
+		p.Byoyomi = tc.Byoyomi
+	case shogi.Fischer:
+		p.BInc, p.WInc = tc.Increment, tc.Increment
+	case shogi.Canadian:
+		if s := clock.State(clock.Turn()); s.Moves > 0 {
+			p.Byoyomi = s.Period / time.Duration(s.Moves)
+		}
+	}
+	return p
+}
+
+// String encodes the params as a `go` command, e.g. go btime 60000 wtime 60000 byoyomi 10000
+func (p GoParams) String() string {
+	parts := []string{"go"}
+	if p.Ponder {
+		parts = append(parts, "ponder")
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"btime", p.BTime},
+		{"wtime", p.WTime},
+		{"binc", p.BInc},
+		{"winc", p.WInc},
+		{"byoyomi", p.Byoyomi},
+	}
+	for _, d := range durations {
+		if d.value > 0 {
+			parts = append(parts, d.name, strconv.FormatInt(d.value.Milliseconds(), 10))
+		}
+	}
+	// When a clock is used both sides must be sent, even if they have no time left.
+	if p.BTime > 0 || p.WTime > 0 || p.Byoyomi > 0 || p.BInc > 0 || p.WInc > 0 {
+		if p.BTime == 0 {
+			parts = append(parts, "btime", "0")
+		}
+		if p.WTime == 0 {
+			parts = append(parts, "wtime", "0")
+		}
+	}
+	if p.MovesToGo > 0 {
+		parts = append(parts, "movestogo", strconv.Itoa(p.MovesToGo))
+	}
+	if p.Depth > 0 {
+		parts = append(parts, "depth", strconv.Itoa(p.Depth))
+	}
+	if p.Nodes > 0 {
+		parts = append(parts, "nodes", strconv.Itoa(p.Nodes))
+	}
+	if p.MoveTime > 0 {
+		parts = append(parts, "movetime", strconv.FormatInt(p.MoveTime.Milliseconds(), 10))
+	}
+	if p.Infinite {
+		parts = append(parts, "infinite")
+	}
+	// searchmoves takes the rest of the line so it goes last.
+	if len(p.SearchMoves) > 0 {
+		parts = append(parts, "searchmoves")
+		parts = append(parts, p.SearchMoves...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseGoParams parses the arguments of a `go` command.
+func ParseGoParams(args []string) (GoParams, error) {
+	var p GoParams
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "ponder":
+			p.Ponder = true
+		case "infinite":
+			p.Infinite = true
+		case "searchmoves":
+			p.SearchMoves = append([]string{}, args[i+1:]...)
+			return p, nil
+		case "btime", "wtime", "binc", "winc", "byoyomi", "movetime", "movestogo", "depth", "nodes":
+			if i+1 >= len(args) {
+				return GoParams{}, fmt.Errorf("invalid go, %s requires a value", args[i])
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return GoParams{}, fmt.Errorf("invalid go, %s is not an integer: %w", args[i], err)
+			}
+			ms := time.Duration(n) * time.Millisecond
+			switch args[i] {
+			case "btime":
+				p.BTime = ms
+			case "wtime":
+				p.WTime = ms
+			case "binc":
+				p.BInc = ms
+			case "winc":
+				p.WInc = ms
+			case "byoyomi":
+				p.Byoyomi = ms
+			case "movetime":
+				p.MoveTime = ms
+			case "movestogo":
+				p.MovesToGo = n
+			case "depth":
+				p.Depth = n
+			case "nodes":
+				p.Nodes = n
+			}
+			i++
+		default:
+			return GoParams{}, fmt.Errorf("invalid go, unknown argument %s", args[i])
+		}
+	}
+	return p, nil
+}
+
+// BestMove is the outcome of a search, sent by the engine with
+//
+//	bestmove <move1> [ponder <move2>]
+//	bestmove [resign | win]
+type BestMove struct {
+	// Move is the best move in USI notation, empty if the engine resigned or declared a win.
+	Move   string
+	Ponder string
+	Resign bool
+	Win    bool
+	// Infos holds every `info` line received during the search.
+	Infos []Info
+}
+
+// String encodes the best move as a `bestmove` command.
+func (bm BestMove) String() string {
+	switch {
+	case bm.Resign:
+		return "bestmove resign"
+	case bm.Win:
+		return "bestmove win"
+	case bm.Ponder != "":
+		return fmt.Sprintf("bestmove %s ponder %s", bm.Move, bm.Ponder)
+	}
+	return fmt.Sprintf("bestmove %s", bm.Move)
+}
+
+// Score returns the last score the engine reported for its main line during the search.
+func (bm BestMove) Score() (Score, bool) {
+	for i := len(bm.Infos) - 1; i >= 0; i-- {
+		info := bm.Infos[i]
+		if info.HasScore && info.MultiPV <= 1 {
+			return info.Score, true
+		}
+	}
+	return Score{}, false
+}
+
+// ParseBestMove parses the arguments of a `bestmove` command.
+func ParseBestMove(args []string) (BestMove, error) {
+	if len(args) < 1 || args[0] == "" {
+		return BestMove{}, fmt.Errorf("invalid bestmove, expecting bestmove <move> [ponder <move>], received %v", args)
+	}
+
+	var bm BestMove
+	switch args[0] {
+	case "resign":
+		bm.Resign = true
+	case "win":
+		bm.Win = true
+	default:
+		if _, err := shogi.ParseUSIMove(args[0]); err != nil {
+			return BestMove{}, fmt.Errorf("invalid bestmove: %w", err)
+		}
+		bm.Move = args[0]
+	}
+
+	if len(args) >= 3 && args[1] == "ponder" {
+		bm.Ponder = args[2]
+	}
+	return bm, nil
+}
+
+// sendGo starts a search on the position previously set with `position`.
+// Info lines are collected until the matching `bestmove`, which is delivered on BestMoves.
+func (e *GUIEngine) sendGo(params GoParams) error {
+	e.mu.Lock()
+	e.infos = []Info{}
+	e.searching = true
+	e.fresh = false
+	e.mu.Unlock()
+
+	return e.sendCommand(params.String())
+}
+
+// receiveInfo stores an `info` line for the running search and hands it to OnInfo.
+func (e *GUIEngine) receiveInfo(args []string) error {
+	info, err := ParseInfo(args)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	if e.searching {
+		e.infos = append(e.infos, info)
+	}
+	onInfo := e.OnInfo
+	e.mu.Unlock()
+
+	if onInfo != nil {
+		onInfo(info)
+	}
+	return nil
+}
+
+// receiveBestMove ends the running search and delivers its result, replacing any result
+// nobody has read yet.
+func (e *GUIEngine) receiveBestMove(args []string) error {
+	bm, err := ParseBestMove(args)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	bm.Infos = e.infos
+	e.infos = nil
+	e.searching = false
+	e.mu.Unlock()
+
+	select {
+	case <-e.bestMoves:
+	default:
+	}
+	e.bestMoves <- bm
+	return nil
+}
+
+// BestMoves delivers the result of every search started with `go`.
+func (e *GUIEngine) BestMoves() <-chan BestMove {
+	return e.bestMoves
+}
+
+// SetPosition sends the position of the game, as its start position and the moves played since.
+func (e *GUIEngine) SetPosition(g *shogi.Game) error {
+	moves := make([]string, 0, len(g.Moves()))
+	for _, m := range g.Moves() {
+		moves = append(moves, m.USI())
+	}
+	return e.SendPosition(g.StartPosition(), moves)
+}
+
+// SendPosition sends the position reached by playing moves, in USI notation, from sfen.
+func (e *GUIEngine) SendPosition(sfen string, moves []string) error {
+	cmd := "position startpos"
+	if sfen != shogi.StartingPosition {
+		cmd = fmt.Sprintf("position sfen %s", sfen)
+	}
+	if len(moves) > 0 {
+		cmd = fmt.Sprintf("%s moves %s", cmd, strings.Join(moves, " "))
+	}
+	return e.sendCommand(cmd)
+}
+
+// Search sends `go` and processes the engine output until the search finishes, returning its
+// best move. If ctx is done, or the output can't be read, before that the engine is told to
+// `stop` and the best move sent in reply is still waited for, as every `go` must be answered.
+func (e *GUIEngine) Search(ctx context.Context, params GoParams) (BestMove, error) {
+	// Drop any result of a previous search nobody read.
+	select {
+	case <-e.bestMoves:
+	default:
+	}
+
+	if err := e.sendGo(params); err != nil {
+		return BestMove{}, err
+	}
+
+	bm, err := e.awaitBestMove(ctx)
+	if err == nil {
+		return bm, nil
+	}
+
+	if err := e.stop(); err != nil {
+		return BestMove{}, err
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	stopped, stopErr := e.awaitBestMove(stopCtx)
+	if ctx.Err() == nil {
+		return BestMove{}, err
+	}
+	return stopped, stopErr
+}
+
+// awaitBestMove processes the engine output until a best move is delivered. The info lines that
+// can't be parsed are logged and skipped.
+func (e *GUIEngine) awaitBestMove(ctx context.Context) (BestMove, error) {
+	for {
+		select {
+		case bm := <-e.bestMoves:
+			return bm, nil
+		default:
+		}
+
+		msg, err := e.receiveMessage(ctx)
+		if err != nil {
+			return BestMove{}, err
+		}
+
+		cmd, args, err := e.ParseEngineCmd(msg)
+		if err != nil {
+			// Engines are allowed to print anything, unknown lines are ignored.
+			continue
+		}
+		err = e.ProcessEngineCMD(cmd, args)
+		switch {
+		case err != nil && cmd == shogi.Info:
+			if e.Logger != nil {
+				e.Logger.Printf("engine: skipping %q: %v", msg, err)
+			}
+		case err != nil:
+			return BestMove{}, err
+		}
+	}
+}
+
+// stopTimeout is how long Search waits for the best move after sending `stop`.
+const stopTimeout = 5 * time.Second
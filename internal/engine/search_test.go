@@ -0,0 +1,303 @@
+package engine_test
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestGoParams_String(t *testing.T) {
+	tests := []struct {
+		name   string // description of this test case
+		params engine.GoParams
+		want   string
+	}{
+		{
+			name:   "byoyomi",
+			params: engine.GoParams{BTime: time.Minute, WTime: time.Minute, Byoyomi: 10 * time.Second},
+			want:   "go btime 60000 wtime 60000 byoyomi 10000",
+		},
+		{
+			name:   "fischer",
+			params: engine.GoParams{BTime: 30 * time.Second, WTime: 20 * time.Second, BInc: time.Second, WInc: time.Second},
+			want:   "go btime 30000 wtime 20000 binc 1000 winc 1000",
+		},
+		{
+			name:   "one side out of time",
+			params: engine.GoParams{BTime: 5 * time.Second, Byoyomi: time.Second},
+			want:   "go btime 5000 byoyomi 1000 wtime 0",
+		},
+		{
+			name:   "byoyomi only",
+			params: engine.GoParams{Byoyomi: time.Second},
+			want:   "go byoyomi 1000 btime 0 wtime 0",
+		},
+		{
+			name:   "infinite with searchmoves",
+			params: engine.GoParams{Infinite: true, SearchMoves: []string{"7g7f", "2g2f"}},
+			want:   "go infinite searchmoves 7g7f 2g2f",
+		},
+		{
+			name:   "ponder depth",
+			params: engine.GoParams{Ponder: true, Depth: 10},
+			want:   "go ponder depth 10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.params.String()
+			if got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+			parsed, err := engine.ParseGoParams(strings.Split(got, " ")[1:])
+			if err != nil {
+				t.Fatalf("ParseGoParams() failed: %v", err)
+			}
+			if parsed.String() != got {
+				t.Errorf("ParseGoParams() = %v, want %v", parsed.String(), got)
+			}
+		})
+	}
+}
+
+func TestNewGoParams(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		tc   string
+		// gote is the time left to gote, sente has the full time.
+		gote shogi.ClockState
+		want string
+	}{
+		{name: "sudden death", tc: "10m", gote: shogi.ClockState{Main: 5 * time.Minute}, want: "go btime 600000 wtime 300000"},
+		{
+			name: "byoyomi",
+			tc:   "1m+30sx3",
+			gote: shogi.ClockState{Period: 30 * time.Second, Periods: 2},
+			want: "go btime 60000 byoyomi 30000 wtime 0",
+		},
+		{name: "fischer", tc: "fischer:5m+10s", gote: shogi.ClockState{Main: time.Minute}, want: "go btime 300000 wtime 60000 binc 10000 winc 10000"},
+		{
+			name: "canadian",
+			tc:   "canadian:0s+5m/20",
+			gote: shogi.ClockState{Period: 2 * time.Minute, Moves: 4},
+			want: "go byoyomi 15000 btime 0 wtime 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := shogi.ParseTimeControl(tt.tc)
+			if err != nil {
+				t.Fatalf("ParseTimeControl(%s) failed: %v", tt.tc, err)
+			}
+			clock := shogi.NewClock(tc, shogi.WithNow(func() time.Time { return time.Unix(0, 0) }))
+			clock.SetState(shogi.White, tt.gote)
+			clock.Start(shogi.Black)
+			if got := engine.NewGoParams(clock).String(); got != tt.want {
+				t.Errorf("NewGoParams() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBestMove(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		args    []string
+		want    engine.BestMove
+		wantErr bool
+	}{
+		{name: "move", args: []string{"7g7f"}, want: engine.BestMove{Move: "7g7f"}},
+		{name: "move with ponder", args: []string{"8h2b+", "ponder", "3a2b"}, want: engine.BestMove{Move: "8h2b+", Ponder: "3a2b"}},
+		{name: "drop", args: []string{"P*5e"}, want: engine.BestMove{Move: "P*5e"}},
+		{name: "resign", args: []string{"resign"}, want: engine.BestMove{Resign: true}},
+		{name: "win", args: []string{"win"}, want: engine.BestMove{Win: true}},
+		{name: "invalid move", args: []string{"7z7f"}, wantErr: true},
+		{name: "no args", args: []string{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := engine.ParseBestMove(tt.args)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseBestMove() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseBestMove() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBestMove() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGUIEngine_Search(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 5),
+		GUICh:    make(chan string, 5),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	e := engine.NewGUIEngine(localApi)
+
+	infos := 0
+	e.OnInfo = func(info engine.Info) { infos++ }
+
+	go func() {
+		msg, err := receiveMessage(ctx, localApi.GUICh)
+		if err != nil {
+			t.Errorf("Search() %v", err)
+			return
+		}
+		if msg != "go btime 1000 wtime 1000 byoyomi 1000" {
+			t.Errorf("Search() sent %s", msg)
+		}
+		localApi.EngineCh <- "info depth 1 score cp 10 pv 2g2f"
+		localApi.EngineCh <- "info string thinking"
+		localApi.EngineCh <- "bestmove 2g2f ponder 8c8d"
+	}()
+
+	bm, err := e.Search(ctx, engine.GoParams{BTime: time.Second, WTime: time.Second, Byoyomi: time.Second})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	if bm.Move != "2g2f" || bm.Ponder != "8c8d" {
+		t.Errorf("Search() = %+v, want 2g2f ponder 8c8d", bm)
+	}
+	if len(bm.Infos) != 2 || infos != 2 {
+		t.Errorf("Search() expecting 2 info lines collected, got %d (callback %d)", len(bm.Infos), infos)
+	}
+}
+
+func TestGUIEngine_Search_stops_on_cancel(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 5),
+		GUICh:    make(chan string, 5),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := engine.NewGUIEngine(localApi)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer waitCancel()
+		if msg, _ := receiveMessage(waitCtx, localApi.GUICh); msg != "go infinite" {
+			t.Errorf("Search() sent %s", msg)
+		}
+		cancel()
+		if msg, _ := receiveMessage(waitCtx, localApi.GUICh); msg != "stop" {
+			t.Errorf("Search() expecting stop after cancel, sent %s", msg)
+		}
+		localApi.EngineCh <- "bestmove resign"
+	}()
+
+	bm, err := e.Search(ctx, engine.GoParams{Infinite: true})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	if !bm.Resign {
+		t.Errorf("Search() = %+v, want resign", bm)
+	}
+	<-done
+}
+
+func TestGUIEngine_Search_malformed_output(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// lines are sent by the engine, the last one answers stop if it is sent.
+		lines []string
+		want  string
+		// stop is whether the search is stopped, err whether it fails.
+		stop bool
+		err  bool
+	}{
+		{name: "malformed info is skipped", lines: []string{"info depth deep", "bestmove 7g7f"}, want: "7g7f"},
+		{name: "malformed bestmove stops the search", lines: []string{"bestmove 7z7f", "bestmove 7g7f"}, stop: true, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localApi := engine.ServerLocalEngine{
+				EngineCh: make(chan string, 5),
+				GUICh:    make(chan string, 5),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			e := engine.NewGUIEngine(localApi)
+			var logged strings.Builder
+			e.Logger = log.New(&logged, "", 0)
+			for _, line := range tt.lines {
+				localApi.EngineCh <- line
+			}
+
+			bm, err := e.Search(ctx, engine.GoParams{Depth: 1})
+			if (err != nil) != tt.err {
+				t.Fatalf("Search() error = %v, want error %v", err, tt.err)
+			}
+			if bm.Move != tt.want {
+				t.Errorf("Search() = %+v, want %s", bm, tt.want)
+			}
+			if msg, _ := receiveMessage(ctx, localApi.GUICh); msg != "go depth 1" {
+				t.Errorf("Search() sent %s", msg)
+			}
+			waitCtx, waitCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer waitCancel()
+			if msg, _ := receiveMessage(waitCtx, localApi.GUICh); (msg == "stop") != tt.stop {
+				t.Errorf("Search() sent %q after go, want stop %v", msg, tt.stop)
+			}
+			if !tt.stop && !strings.Contains(logged.String(), "info depth deep") {
+				t.Errorf("Search() logged %q, want the malformed info", logged.String())
+			}
+		})
+	}
+}
+
+func TestGUIEngine_SetPosition(t *testing.T) {
+	localApi := engine.ServerLocalEngine{
+		EngineCh: make(chan string, 2),
+		GUICh:    make(chan string, 2),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	e := engine.NewGUIEngine(localApi)
+
+	g := shogi.NewGame("sente", "gote")
+	if err := e.SetPosition(g); err != nil {
+		t.Fatalf("SetPosition() failed: %v", err)
+	}
+	if msg, _ := receiveMessage(ctx, localApi.GUICh); msg != "position startpos" {
+		t.Errorf("SetPosition() want position startpos, got %s", msg)
+	}
+
+	m, _ := shogi.ParseUSIMove("7g7f")
+	_ = g.Move(m)
+	if err := e.SetPosition(g); err != nil {
+		t.Fatalf("SetPosition() failed: %v", err)
+	}
+	if msg, _ := receiveMessage(ctx, localApi.GUICh); msg != "position startpos moves 7g7f" {
+		t.Errorf("SetPosition() want position startpos moves 7g7f, got %s", msg)
+	}
+}
+
+func TestProcessEngine_Search(t *testing.T) {
+	pe := newFakeProcessEngine(t, "normal")
+	e := engine.NewGUIEngine(pe)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	bm, err := e.Search(ctx, engine.GoParams{MoveTime: time.Second})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	if bm.Move != "7g7f" || bm.Ponder != "3c3d" || len(bm.Infos) != 1 {
+		t.Errorf("Search() = %+v, want 7g7f ponder 3c3d with one info", bm)
+	}
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// The built-in engine plays a one ply search over material: every legal move is scored by the
+// material balance after it, a piece left hanging on its destination counts as lost and a move that
+// mates wins outright. It is meant as a sparring partner and a reference for match tests, not as a
+// strong player.
+
+const mateScore = 30000
+
+// pieceValues are the material values of the built-in engine in centipawns, promoted pieces
+// are valued by promotedValues. Pieces in hand are worth the same as on the board.
+var pieceValues = map[shogi.PieceType]int{
+	shogi.Pawn:   100,
+	shogi.Lance:  300,
+	shogi.Knight: 400,
+	shogi.Silver: 500,
+	shogi.Gold:   600,
+	shogi.Bishop: 800,
+	shogi.Rook:   1000,
+}
+
+var promotedValues = map[shogi.PieceType]int{
+	shogi.Pawn:   600,
+	shogi.Lance:  600,
+	shogi.Knight: 600,
+	shogi.Silver: 600,
+	shogi.Bishop: 1100,
+	shogi.Rook:   1300,
+}
+
+func pieceValue(p shogi.Piece) int {
+	if p.IsPromoted {
+		return promotedValues[p.Type]
+	}
+	return pieceValues[p.Type]
+}
+
+// material returns the material balance of the board from the point of view of player c.
+func material(b shogi.Board, c shogi.Color) int {
+	score := 0
+	for _, color := range []shogi.Color{shogi.Black, shogi.White} {
+		sum := 0
+		for _, p := range b.PiecesOf(color) {
+			sum += pieceValue(p)
+		}
+		for pt, v := range pieceValues {
+			sum += b.Hand.Count(color, pt) * v
+		}
+		if color == c {
+			score += sum
+		} else {
+			score -= sum
+		}
+	}
+	return score
+}
+
+// evaluateMove scores the position after m from the point of view of the player making it.
+func evaluateMove(b shogi.Board, m shogi.Move) int {
+	us := b.Turn
+	after := b.Clone()
+	if err := after.ProcessMove(&m); err != nil {
+		return -mateScore
+	}
+	if after.InCheck(after.Turn) && len(after.LegalMoves()) == 0 {
+		return mateScore
+	}
+
+	score := material(after, us)
+	if after.IsAttacked(m.Destination, after.Turn) {
+		moved, err := after.GetPieceAtSquare(m.Destination)
+		if err == nil {
+			lost := pieceValue(moved)
+			if after.IsAttacked(m.Destination, us) {
+				// Defended pieces are assumed to be traded for something of half their value.
+				lost /= 2
+			}
+			score -= lost
+		}
+	}
+	return score
+}
+
+// think picks the move to play on the current position and the info lines describing it, one per
+// line of MultiPVOption.
+func (e *Engine) think(params GoParams) (BestMove, [][]string) {
+	b := e.Game.Board().Clone()
+	moves := b.LegalMoves()
+	if len(params.SearchMoves) > 0 {
+		moves = slices.DeleteFunc(moves, func(m shogi.Move) bool {
+			return !slices.Contains(params.SearchMoves, m.USI())
+		})
+	}
+	if len(moves) == 0 {
+		return BestMove{Resign: true}, nil
+	}
+	if len(params.SearchMoves) == 0 && e.ownBook() {
+		// Book moves are reported at depth 0, with the value of the book.
+		if m, ok := e.book.Probe(b); ok {
+			info := []string{"depth", "0", "score", "cp", strconv.Itoa(m.Value), "pv", m.Move}
+			if m.Ponder != "" {
+				info = append(info, m.Ponder)
+			}
+			return BestMove{Move: m.Move, Ponder: m.Ponder}, [][]string{info}
+		}
+	}
+
+	type scored struct {
+		move  shogi.Move
+		score int
+	}
+	lines := make([]scored, 0, len(moves))
+	for _, m := range moves {
+		// A small random term keeps the engine from playing the same game every time.
+		lines = append(lines, scored{m, evaluateMove(b, m) + rand.IntN(10)})
+	}
+	slices.SortStableFunc(lines, func(a, b scored) int { return b.score - a.score })
+
+	multiPV := e.multiPV()
+	infos := [][]string{}
+	for i, l := range lines[:min(multiPV, len(lines))] {
+		info := []string{"depth", "1", "nodes", strconv.Itoa(len(moves))}
+		if multiPV > 1 {
+			info = append(info, "multipv", strconv.Itoa(i+1))
+		}
+		if l.score >= mateScore {
+			info = append(info, "score", "mate", "1")
+		} else {
+			info = append(info, "score", "cp", strconv.Itoa(l.score))
+		}
+		infos = append(infos, append(info, "pv", l.move.USI()))
+	}
+	return BestMove{Move: lines[0].move.USI()}, infos
+}
+
+// multiPV returns the number of lines to report, set with MultiPVOption.
+func (e *Engine) multiPV() int {
+	_, opt, exists := lookupOption(e.EngineOptions, MultiPVOption)
+	if !exists {
+		return 1
+	}
+	n, err := strconv.Atoi(opt.Value)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+// Package explain asks an agent to explain shogi moves in plain language, keeping the conversation
+// so that follow-up questions are answered in its context.
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// System is the system prompt of the conversations.
+const System = "You are a shogi tutor explaining moves to a club player in plain language: the idea of the move, " +
+	"the threats it creates or answers, and what to watch for next. Squares are written with files numbered 1 to 9 " +
+	"from the left of the board as sente sees it and ranks a to i from the top. Moves are written as the piece, " +
+	"its origin when needed, - for a move, x for a capture or * for a drop, the destination and + when promoting. " +
+	"Answer in plain text, without markdown, in no more than 200 words."
+
+// History is the number of moves played before the move explained that are sent with it.
+const History = 10
+
+// ErrNoConversation is returned by Ask before any move was explained.
+var ErrNoConversation = errors.New("explain: no move explained yet")
+
+// Tutor explains moves with an agent.
+type Tutor struct {
+	agent        agent.Agent
+	conversation *agent.Conversation
+}
+
+// New returns a tutor asking a.
+func New(a agent.Agent) *Tutor {
+	return &Tutor{agent: a}
+}
+
+// Explain starts a conversation about m, a candidate move in the current position of g.
+func (t *Tutor) Explain(ctx context.Context, g *shogi.Game, m shogi.Move) (string, error) {
+	b, history, err := replay(g, len(g.Moves()))
+	if err != nil {
+		return "", err
+	}
+	return t.start(ctx, b, history, m, "is a candidate move")
+}
+
+// ExplainLast starts a conversation about the last move played in g.
+func (t *Tutor) ExplainLast(ctx context.Context, g *shogi.Game) (string, error) {
+	moves := g.Moves()
+	if len(moves) == 0 {
+		return "", errors.New("explain: no move played yet")
+	}
+	b, history, err := replay(g, len(moves)-1)
+	if err != nil {
+		return "", err
+	}
+	m, err := b.ResolveUSIMove(moves[len(moves)-1].USI())
+	if err != nil {
+		return "", fmt.Errorf("explain: %w", err)
+	}
+	return t.start(ctx, b, history, m, "was just played")
+}
+
+// Ask asks a follow-up question in the conversation of the last move explained.
+func (t *Tutor) Ask(ctx context.Context, question string) (string, error) {
+	if t.conversation == nil {
+		return "", ErrNoConversation
+	}
+	return t.conversation.Ask(ctx, question)
+}
+
+// start starts a new conversation asking why m, played on b after history, is or was played.
+func (t *Tutor) start(ctx context.Context, b shogi.Board, history []string, m shogi.Move, status string) (string, error) {
+	t.conversation = agent.NewConversation(t.agent, System)
+	return t.conversation.Ask(ctx, Question(b, history, m, status))
+}
+
+// Question is the first question of a conversation about m, a move on b played after history.
+// status tells whether it is a candidate move or was played.
+func Question(b shogi.Board, history []string, m shogi.Move, status string) string {
+	side := "Sente (black)"
+	if b.Turn == shogi.White {
+		side = "Gote (white)"
+	}
+	if len(history) > History {
+		history = history[len(history)-History:]
+	}
+	moves := "none, it is the start of the game"
+	if len(history) > 0 {
+		moves = strings.Join(history, " ")
+	}
+	return fmt.Sprintf("Position (SFEN): %s\nSide to move: %s\nLast moves: %s\nThe move %s (USI %s) %s. Why?",
+		b.String(), side, moves, shogi.Notation{Board: b}.EncodeMovement(m), m.USI(), status)
+}
+
+// replay returns the board after the first n moves of g and those moves in game notation.
+func replay(g *shogi.Game, n int) (shogi.Board, []string, error) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(g.StartPosition()); err != nil {
+		return shogi.Board{}, nil, fmt.Errorf("explain: %w", err)
+	}
+	history := make([]string, 0, n)
+	for i, played := range g.Moves()[:n] {
+		m, err := b.ResolveUSIMove(played.USI())
+		if err != nil {
+			return shogi.Board{}, nil, fmt.Errorf("explain: move %d: %w", i+1, err)
+		}
+		history = append(history, shogi.Notation{Board: b}.EncodeMovement(m))
+		if err := b.ProcessMove(&m); err != nil {
+			return shogi.Board{}, nil, fmt.Errorf("explain: move %d: %w", i+1, err)
+		}
+	}
+	return b, history, nil
+}
+
+// ParseMove returns the legal move of b written s, either in USI such as 7g7f or in game
+// notation such as P-3f, with or without the origin of the piece.
+func ParseMove(b shogi.Board, s string) (shogi.Move, error) {
+	if m, err := validate.Resolve(b, s); err == nil {
+		return m, nil
+	}
+	n := shogi.Notation{Board: b}
+	for _, m := range b.Clone().LegalMoves() {
+		// Moves are matched written as shown in the moves panel, or with their origin.
+		written := []string{n.EncodeMovement(m)}
+		if m.Type != shogi.Drop {
+			full := m.Piece.String() + m.Origin.String() + m.Type.String() + m.Destination.String()
+			if m.IsPromoting {
+				full += "+"
+			}
+			written = append(written, full)
+		}
+		for _, w := range written {
+			if strings.EqualFold(w, s) {
+				return m, nil
+			}
+		}
+	}
+	return shogi.Move{}, fmt.Errorf("explain: %s is not a legal move", s)
+}
@@ -0,0 +1,138 @@
+package explain_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/explain"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// chatAgent answers every chat with its numbered answer, keeping the conversations it was sent.
+type chatAgent struct {
+	system string
+	chats  [][]agent.Message
+}
+
+func (c *chatAgent) AskHint(context.Context, agent.Request) (string, error) {
+	return "", errors.New("no hints")
+}
+
+func (c *chatAgent) AskMovement(context.Context, agent.Request) (string, error) {
+	return "", errors.New("no moves")
+}
+
+func (c *chatAgent) Chat(_ context.Context, system string, messages []agent.Message) (string, error) {
+	c.system = system
+	c.chats = append(c.chats, messages)
+	return "answer " + string(rune('0'+len(c.chats))), nil
+}
+
+// playGame plays the USI moves on a new game.
+func playGame(t *testing.T, moves ...string) *shogi.Game {
+	t.Helper()
+	g := shogi.NewGame("sente", "gote")
+	for _, usi := range moves {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+		}
+		if err := g.Move(m); err != nil {
+			t.Fatalf("Move(%s) failed: %v", usi, err)
+		}
+	}
+	return g
+}
+
+func TestTutor_Explain(t *testing.T) {
+	a := &chatAgent{}
+	tutor := explain.New(a)
+	if _, err := tutor.Ask(context.Background(), "and then?"); !errors.Is(err, explain.ErrNoConversation) {
+		t.Errorf("Ask() before Explain() = %v, want ErrNoConversation", err)
+	}
+
+	g := playGame(t, "7g7f", "3c3d")
+	m, err := explain.ParseMove(*g.Board(), "P-2f")
+	if err != nil {
+		t.Fatalf("ParseMove() failed: %v", err)
+	}
+	answer, err := tutor.Explain(context.Background(), g, m)
+	if err != nil || answer != "answer 1" {
+		t.Fatalf("Explain() = %q, %v", answer, err)
+	}
+	question := a.chats[0][0].Content
+	for _, want := range []string{g.Board().String(), "Side to move: Sente", "Last moves: P-3f p-7d", "The move P-2f (USI 8g8f) is a candidate move"} {
+		if !strings.Contains(question, want) {
+			t.Errorf("question = %q, want it to contain %q", question, want)
+		}
+	}
+	if a.system != explain.System {
+		t.Errorf("system prompt = %q, want explain.System", a.system)
+	}
+
+	if answer, err := tutor.Ask(context.Background(), "What if gote plays p-8d?"); err != nil || answer != "answer 2" {
+		t.Fatalf("Ask() = %q, %v", answer, err)
+	}
+	followUp := a.chats[1]
+	if len(followUp) != 3 || followUp[0].Content != question || followUp[1].Role != agent.AssistantRole || followUp[2].Content != "What if gote plays p-8d?" {
+		t.Errorf("follow-up conversation = %+v, want the question, the answer and the follow-up", followUp)
+	}
+}
+
+func TestTutor_ExplainLast(t *testing.T) {
+	a := &chatAgent{}
+	if _, err := explain.New(a).ExplainLast(context.Background(), shogi.NewGame("sente", "gote")); err == nil {
+		t.Errorf("ExplainLast() succeeded without moves")
+	}
+
+	g := playGame(t, "7g7f", "3c3d", "8h2b+")
+	if _, err := explain.New(a).ExplainLast(context.Background(), g); err != nil {
+		t.Fatalf("ExplainLast() failed: %v", err)
+	}
+	question := a.chats[0][0].Content
+	for _, want := range []string{"Last moves: P-3f p-7d\n", "(USI 8h2b+) was just played", "Side to move: Sente"} {
+		if !strings.Contains(question, want) {
+			t.Errorf("question = %q, want it to contain %q", question, want)
+		}
+	}
+}
+
+func TestParseMove(t *testing.T) {
+	b := *playGame(t, "7g7f", "3c3d").Board()
+	tests := []struct {
+		name    string // description of this test case
+		move    string
+		want    string
+		wantErr bool
+	}{
+		{name: "usi", move: "8g8f", want: "8g8f"},
+		{name: "usi with promotion", move: "8h2b+", want: "8h2b+"},
+		{name: "game notation", move: "P-2f", want: "8g8f"},
+		{name: "lowercase", move: "p-2f", want: "8g8f"},
+		{name: "capture with promotion", move: "Bx8b+", want: "8h2b+"},
+		{name: "capture without promotion", move: "Bx8b", want: "8h2b"},
+		{name: "with origin", move: "B2hx8b+", want: "8h2b+"},
+		{name: "illegal", move: "P-2e", wantErr: true},
+		{name: "not a move", move: "castle", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := explain.ParseMove(b, tt.move)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMove(%s) = %s, want an error", tt.move, m.USI())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMove(%s) failed: %v", tt.move, err)
+			}
+			if m.USI() != tt.want {
+				t.Errorf("ParseMove(%s) = %s, want %s", tt.move, m.USI(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	// explanationWidth and explanationHeight are the size of the text of the explanation panel.
+	explanationWidth  = 58
+	explanationHeight = 12
+)
+
+// Explanation is a conversation with the agent, shown in a panel scrolled with PgUp and PgDn.
+type Explanation struct {
+	Title string
+	// lines is the text wrapped to the width of the panel, offset the first line shown.
+	lines  []string
+	offset int
+}
+
+// NewExplanation returns an empty explanation titled title.
+func NewExplanation(title string) *Explanation {
+	return &Explanation{Title: title}
+}
+
+// Append adds text, a question or an answer, and scrolls to its start.
+func (e *Explanation) Append(text string) {
+	if len(e.lines) > 0 {
+		e.lines = append(e.lines, "")
+	}
+	start := len(e.lines)
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		e.lines = append(e.lines, wrap(paragraph, explanationWidth)...)
+	}
+	e.offset = start
+	e.Scroll(0)
+}
+
+// Scroll moves the text shown by n lines, down when positive.
+func (e *Explanation) Scroll(n int) {
+	e.offset = max(0, min(e.offset+n, len(e.lines)-explanationHeight))
+}
+
+// Lines returns the wrapped text.
+func (e *Explanation) Lines() []string {
+	return e.lines
+}
+
+// wrap splits text into lines of at most width runes, breaking between words.
+func wrap(text string, width int) []string {
+	lines := []string{}
+	line := []rune{}
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		for len(w) > width {
+			if len(line) > 0 {
+				lines, line = append(lines, string(line)), nil
+			}
+			lines, w = append(lines, string(w[:width])), w[width:]
+		}
+		if len(line) > 0 && len(line)+1+len(w) > width {
+			lines, line = append(lines, string(line)), nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// ShowExplanation shows an empty explanation panel titled title, replacing the one shown.
+func (gui *GUI) ShowExplanation(title string) *Explanation {
+	gui.Explanation = NewExplanation(title)
+	return gui.Explanation
+}
+
+// ScrollExplanation scrolls the explanation panel by n lines, if shown.
+func (gui *GUI) ScrollExplanation(n int) {
+	if gui.Explanation != nil {
+		gui.Explanation.Scroll(n)
+	}
+}
+
+// drawExplanation draws the explanation panel next to the moves, below the book and analysis panels.
+func (gui GUI) drawExplanation() {
+	e := gui.Explanation
+	if e == nil {
+		return
+	}
+	leftMargin := leftMargin + 48
+	topMargin := topMargin
+	if gui.Book != nil {
+		topMargin += 7
+	}
+	if gui.Analysis != nil {
+		topMargin += gui.Analysis.MultiPV() + 2
+	}
+	boxStyle := tcell.StyleDefault.Foreground(gui.Theme.MoveBox)
+	title := []rune(e.Title)
+	if len(title) > explanationWidth-4 {
+		title = append(title[:explanationWidth-5], '…')
+	}
+	gui.drawLabel(leftMargin, topMargin, boxStyle, "┏━━━━ "+string(title)+" "+strings.Repeat("━", explanationWidth-4-len(title))+"┓")
+	for i := 0; i < explanationHeight; i++ {
+		row := ""
+		if n := e.offset + i; n < len(e.lines) {
+			row = e.lines[n]
+		}
+		gui.drawLabel(leftMargin, topMargin+i+1, boxStyle, fmt.Sprintf("┃ %-*s ┃", explanationWidth, row))
+	}
+	footer := ""
+	if len(e.lines) > explanationHeight {
+		footer = fmt.Sprintf(" PgUp/PgDn %d-%d/%d ", e.offset+1, min(e.offset+explanationHeight, len(e.lines)), len(e.lines))
+	}
+	gui.drawLabel(leftMargin, topMargin+explanationHeight+1, boxStyle,
+		"┗"+strings.Repeat("━", explanationWidth+2-len([]rune(footer)))+footer+"┛")
+}
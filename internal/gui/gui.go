@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/book"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
 	"github.com/juanpablocruz/shogo/clientr/internal/theme"
 )
 
@@ -18,23 +20,38 @@ type GUI struct {
 
 	Theme theme.Theme
 
-	Hint       string
+	Hint string
+	// Book is the opening book shown in the book moves panel, nil hides the panel.
+	Book *book.Book
+	// Analysis is the engine analysis shown next to the board, nil hides the panel.
+	Analysis *engine.Analysis
+	// Explanation is the conversation of the explain command, nil hides the panel.
+	Explanation *Explanation
+
 	logs       []string
 	maxLogs    int
 	logPointer int
 }
 
 func NewGUI() *GUI {
-	defStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
-	boxStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorPurple)
-
 	s, err := tcell.NewScreen()
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
+	gui, err := NewScreenGUI(s)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	return gui
+}
+
+// NewScreenGUI returns a GUI drawing on s, which is initialized, e.g. a tcell.SimulationScreen in tests.
+func NewScreenGUI(s tcell.Screen) (*GUI, error) {
+	defStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
+	boxStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorPurple)
 
 	if err := s.Init(); err != nil {
-		log.Fatalf("%+v", err)
+		return nil, err
 	}
 
 	s.SetStyle(defStyle)
@@ -49,7 +66,7 @@ func NewGUI() *GUI {
 		maxLogs:    10,
 		logs:       make([]string, 10),
 		logPointer: 0,
-	}
+	}, nil
 }
 
 func (gui GUI) drawText(x1, y1, x2, y2 int, style tcell.Style, text string) {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
 	"github.com/juanpablocruz/shogo/clientr/internal/input"
 	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
 	"github.com/juanpablocruz/shogo/clientr/internal/theme"
@@ -109,21 +110,96 @@ func (gui GUI) drawMoves(gs *shogi.Game) {
 	gui.drawLabel(leftMargin, topMargin+6, boxStyle, "┗━━━━━━━━━━━━━━━━━━━━━┛")
 }
 
+// drawBook draws the panel with the moves of the book in the current position, next to the moves.
+func (gui GUI) drawBook(gs *shogi.Game) {
+	if gui.Book == nil {
+		return
+	}
+	leftMargin := leftMargin + 48
+	boxStyle := tcell.StyleDefault.Foreground(gui.Theme.MoveBox)
+	gui.drawLabel(leftMargin, topMargin, boxStyle, "┏━━━━ Book moves ━━━━━┓")
+	moves := gui.Book.Moves(*gs.Board())
+	for i := 0; i < 5; i++ {
+		row := fmt.Sprintf("┃ %-19v ┃", "")
+		switch {
+		case i < len(moves):
+			m := moves[i]
+			row = fmt.Sprintf("┃ %-5v %5d cp %4d ┃", m.Move, m.Value, m.Count)
+		case i == 0:
+			row = fmt.Sprintf("┃ %-19v ┃", "out of book")
+		}
+		gui.drawLabel(leftMargin, topMargin+i+1, boxStyle, row)
+	}
+	gui.drawLabel(leftMargin, topMargin+6, boxStyle, "┗━━━━━━━━━━━━━━━━━━━━━┛")
+}
+
+// analysisWidth is the width of the lines of the analysis panel.
+const analysisWidth = 44
+
+// drawAnalysis draws the lines of the engine analysis next to the moves, below the book moves.
+func (gui GUI) drawAnalysis() {
+	if gui.Analysis == nil {
+		return
+	}
+	leftMargin := leftMargin + 48
+	topMargin := topMargin
+	if gui.Book != nil {
+		topMargin += 7
+	}
+	boxStyle := tcell.StyleDefault.Foreground(gui.Theme.MoveBox)
+	gui.drawLabel(leftMargin, topMargin, boxStyle, "┏━━━━ Analysis "+strings.Repeat("━", analysisWidth-12)+"┓")
+
+	rows := make([]string, gui.Analysis.MultiPV())
+	lines, err := gui.Analysis.Lines()
+	b := shogi.NewBoard()
+	switch {
+	case err != nil:
+		rows[0] = fmt.Sprintf("⚠ %v", err)
+	case b.LoadSfen(gui.Analysis.Position()) != nil:
+	case len(lines) == 0:
+		rows[0] = "thinking..."
+	}
+	for i, l := range lines {
+		if i < len(rows) {
+			rows[i] = fmt.Sprintf("%d %7s d%-2d %s", i+1, l.Score, l.Depth, engine.FormatPV(b, l.PV))
+		}
+	}
+	for i, row := range rows {
+		if r := []rune(row); len(r) > analysisWidth {
+			row = string(r[:analysisWidth-1]) + "…"
+		}
+		gui.drawLabel(leftMargin, topMargin+i+1, boxStyle, fmt.Sprintf("┃ %-*s ┃", analysisWidth, row))
+	}
+	gui.drawLabel(leftMargin, topMargin+len(rows)+1, boxStyle, "┗"+strings.Repeat("━", analysisWidth+2)+"┛")
+}
+
 func (gui GUI) drawPlayers(game *shogi.Game) {
 	leftMargin := leftMargin + 22
 	emojiStyle := tcell.StyleDefault.Foreground(gui.Theme.Emoji)
 	black := fmt.Sprintf("%v %v (Sente)", "☗", game.SentePlayer())
-	gui.drawLabel(leftMargin, topMargin+8, emojiStyle, black)
 	white := fmt.Sprintf("%v %v (Gonte)", "☖", game.GotePlayer())
+	if clock := game.Clock(); clock != nil {
+		black = fmt.Sprintf("%s %-14s", black, clock.Format(shogi.Black))
+		white = fmt.Sprintf("%s %-14s", white, clock.Format(shogi.White))
+	}
+	gui.drawLabel(leftMargin, topMargin+8, emojiStyle, black)
 	gui.drawLabel(leftMargin, topMargin-2, emojiStyle, white)
 }
 
+// Render draws the game, and the prompt with the input unless i is nil.
 func (gui GUI) Render(gs *shogi.Game, i *input.Input) {
 	gui.drawMoveLabel(gs)
 	gui.drawBoard(gs, gui.Theme)
-	gui.drawPrompt(i, gui.Theme)
+	if i != nil {
+		gui.drawPrompt(i, gui.Theme)
+	} else {
+		(*gui.Screen).HideCursor()
+	}
 	gui.drawPlayers(gs)
 	gui.drawMoves(gs)
+	gui.drawBook(gs)
+	gui.drawAnalysis()
+	gui.drawExplanation()
 	gui.drawHint(gs)
 	gui.drawLogs()
 
@@ -201,11 +277,12 @@ func (gui *GUI) SetHint(movement string) {
 	gui.Hint = movement
 }
 
+// drawHint highlights the move of the hint, written in USI, and asks whether to play it.
 func (gui *GUI) drawHint(g *shogi.Game) {
 	if len(gui.Hint) < 4 {
 		return
 	}
-	m, err := g.Notation().DecodeHodgesMove(gui.Hint)
+	m, err := g.Board().ResolveUSIMove(gui.Hint)
 	if err != nil {
 		gui.AppendLog(fmt.Sprintf("error parsing move %s, %v", gui.Hint, err))
 		return
@@ -213,63 +290,43 @@ func (gui *GUI) drawHint(g *shogi.Game) {
 
 	gui.DrawMsgLabel(fmt.Sprintf("(%s) Accept hint? y/n", gui.Hint), gui.Theme)
 
-	srcFile := int(m.Origin.File())
-	srcRank := int(m.Origin.Rank())
-	dstFile := int(m.Destination.File())
-	dstRank := int(m.Destination.Rank())
-
-	srcX := leftMargin + 2 + 2*srcFile
-	srcY := topMargin + srcRank
-	dstX := leftMargin + 2 + 2*dstFile
-	dstY := topMargin + dstRank
-
-	boardPiece := g.Board().BitBoard[m.Origin]
-	var piece shogi.Piece
-	if boardPiece == "" {
-		piece = shogi.Piece{Type: shogi.NoPiece}
-	} else {
-		isPromoted := false
-		if strings.Contains(boardPiece, "+") {
-			isPromoted = true
-			boardPiece = boardPiece[1:]
-		}
-		piece = shogi.NewPiece(boardPiece, isPromoted)
-	}
-
-	srcBg := squareBg(m.Origin, gui.Theme)
-	// dstBg := squareBg(m.Destination, gui.Theme)
-
 	highlightFg := gui.Theme.PieceHint
 	highlightBg := gui.Theme.SquareHint
 
-	srcHighlightStyle := tcell.StyleDefault.Background(srcBg).Foreground(highlightFg).Bold(true)
-
-	// Redraw the origin square with the highlighted piece.
-	pieceRune, _ := piece.Render()
-	gui.drawRune(srcX, srcY, srcHighlightStyle, pieceRune)
+	// A drop has no origin, only its destination is highlighted.
+	if m.Type != shogi.Drop {
+		srcX := leftMargin + 2 + 2*int(m.Origin.File())
+		srcY := topMargin + int(m.Origin.Rank())
+		srcBg := squareBg(m.Origin, gui.Theme)
+		srcHighlightStyle := tcell.StyleDefault.Background(srcBg).Foreground(highlightFg).Bold(true)
 
-	// For the destination square, get any piece present.
-	// g.Board().BitBoard[shogi.NewSquare(shogi.File(srcFile), shogi.Rank(srcRank))]
-
-	destCode := g.Board().BitBoard[m.Destination]
-	var destPiece shogi.Piece
-	if destCode == "" {
-		destPiece = shogi.Piece{Type: shogi.NoPiece}
-	} else {
-		isPromoted := false
-		if strings.Contains(destCode, "+") {
-			isPromoted = true
-			destCode = destCode[1:]
-		}
-		destPiece = shogi.NewPiece(destCode, isPromoted)
+		// Redraw the origin square with the highlighted piece.
+		pieceRune, _ := boardPiece(g, m.Origin).Render()
+		gui.drawRune(srcX, srcY, srcHighlightStyle, pieceRune)
 	}
 
 	// Redraw the destination square with the highlight background.
-	gui.drawSquare(dstX, dstY, destPiece, highlightBg, gui.Theme)
+	dstX := leftMargin + 2 + 2*int(m.Destination.File())
+	dstY := topMargin + int(m.Destination.Rank())
+	gui.drawSquare(dstX, dstY, boardPiece(g, m.Destination), highlightBg, gui.Theme)
 
 	(*gui.Screen).Show()
 }
 
+// boardPiece returns the piece on sq, NoPiece when it is empty.
+func boardPiece(g *shogi.Game, sq shogi.Square) shogi.Piece {
+	code := g.Board().BitBoard[sq]
+	if code == "" {
+		return shogi.Piece{Type: shogi.NoPiece}
+	}
+	isPromoted := false
+	if strings.Contains(code, "+") {
+		isPromoted = true
+		code = code[1:]
+	}
+	return shogi.NewPiece(code, isPromoted)
+}
+
 func (g *GUI) AppendLog(s string) {
 	g.logs[g.logPointer] = s
 	g.logPointer = (g.logPointer + 1) % g.maxLogs
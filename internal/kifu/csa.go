@@ -0,0 +1,427 @@
+package kifu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// CSA standard game record format, V2.2:
+//
+//	V2.2
+//	N+<sente>
+//	N-<gote>
+//	$EVENT:<event>
+//	$START_TIME:YYYY/MM/DD HH:MM:SS
+//	PI                          the starting position, or P1..P9 rows, P+/P- hands
+//	+                           the player on turn
+//	+7776FU                     <sign><from><to><piece after the move>, from is 00 for drops
+//	T3                          seconds spent on the move
+//	'<comment>
+//	%TORYO                      special move ending the game
+//
+// Squares are written as file and rank digits, e.g. 77 is 7g in USI.
+
+const csaTimeLayout = "2006/01/02 15:04:05"
+
+var csaPieceNames = map[shogi.PieceType]string{
+	shogi.King:   "OU",
+	shogi.Rook:   "HI",
+	shogi.Bishop: "KA",
+	shogi.Gold:   "KI",
+	shogi.Silver: "GI",
+	shogi.Knight: "KE",
+	shogi.Lance:  "KY",
+	shogi.Pawn:   "FU",
+}
+
+var csaPromotedNames = map[shogi.PieceType]string{
+	shogi.Rook:   "RY",
+	shogi.Bishop: "UM",
+	shogi.Silver: "NG",
+	shogi.Knight: "NK",
+	shogi.Lance:  "NY",
+	shogi.Pawn:   "TO",
+}
+
+func csaPiece(p shogi.Piece) string {
+	if p.IsPromoted {
+		return csaPromotedNames[p.Type]
+	}
+	return csaPieceNames[p.Type]
+}
+
+func parseCSAPiece(name string) (shogi.PieceType, bool, error) {
+	for pt, n := range csaPieceNames {
+		if n == name {
+			return pt, false, nil
+		}
+	}
+	for pt, n := range csaPromotedNames {
+		if n == name {
+			return pt, true, nil
+		}
+	}
+	return shogi.NoPiece, false, fmt.Errorf("kifu: unknown csa piece %q", name)
+}
+
+func csaSign(c shogi.Color) string {
+	if c == shogi.Black {
+		return "+"
+	}
+	return "-"
+}
+
+// csaSquare encodes a square as its file and rank digits.
+func csaSquare(sq shogi.Square) string {
+	usi := sq.USISquare()
+	return fmt.Sprintf("%c%c", usi[0], '1'+usi[1]-'a')
+}
+
+// usiSquare converts the file and rank digits of a CSA square to USI.
+func usiSquare(s string) (string, error) {
+	if len(s) != 2 || s[0] < '1' || s[0] > '9' || s[1] < '1' || s[1] > '9' {
+		return "", fmt.Errorf("kifu: invalid csa square %q", s)
+	}
+	return fmt.Sprintf("%c%c", s[0], 'a'+s[1]-'1'), nil
+}
+
+// EncodeCSAMove encodes a move resolved on a board in CSA notation, e.g. +7776FU
+func EncodeCSAMove(m shogi.Move) string {
+	from := "00"
+	if m.Type != shogi.Drop {
+		from = csaSquare(m.Origin)
+	}
+	p := m.Piece
+	if m.IsPromoting {
+		p.IsPromoted = true
+	}
+	return csaSign(m.Piece.Color) + from + csaSquare(m.Destination) + csaPiece(p)
+}
+
+// DecodeCSAMove converts a move in CSA notation to USI, using the board to tell promotions apart.
+func DecodeCSAMove(b shogi.Board, s string) (string, error) {
+	if len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return "", fmt.Errorf("kifu: invalid csa move %q", s)
+	}
+	if csaSign(b.Turn) != s[:1] {
+		return "", fmt.Errorf("kifu: csa move %q played out of turn", s)
+	}
+	pt, promoted, err := parseCSAPiece(s[5:7])
+	if err != nil {
+		return "", err
+	}
+	to, err := usiSquare(s[3:5])
+	if err != nil {
+		return "", err
+	}
+
+	if s[1:3] == "00" {
+		if promoted {
+			return "", fmt.Errorf("kifu: can't drop a promoted piece %q", s)
+		}
+		return fmt.Sprintf("%s*%s", pt.String(), to), nil
+	}
+
+	from, err := usiSquare(s[1:3])
+	if err != nil {
+		return "", err
+	}
+	usi := from + to
+	m, err := b.ResolveUSIMove(usi)
+	if err != nil {
+		return "", err
+	}
+	if m.Piece.Type != pt {
+		return "", fmt.Errorf("kifu: csa move %q doesn't match the piece on %s", s, from)
+	}
+	if promoted && !m.Piece.IsPromoted {
+		usi += "+"
+	}
+	return usi, nil
+}
+
+// WriteCSA writes the record in CSA format.
+func WriteCSA(w io.Writer, r Record) error {
+	var sb strings.Builder
+	sb.WriteString("V2.2\n")
+	fmt.Fprintf(&sb, "N+%s\n", r.Sente)
+	fmt.Fprintf(&sb, "N-%s\n", r.Gote)
+	if r.Event != "" {
+		fmt.Fprintf(&sb, "$EVENT:%s\n", r.Event)
+	}
+	if !r.StartTime.IsZero() {
+		fmt.Fprintf(&sb, "$START_TIME:%s\n", r.StartTime.Format(csaTimeLayout))
+	}
+	for _, c := range r.Comments {
+		writeCSAComment(&sb, c)
+	}
+
+	b, err := r.Board()
+	if err != nil {
+		return err
+	}
+	writeCSAPosition(&sb, b)
+
+	for i, m := range r.Moves {
+		mo, err := b.ResolveUSIMove(m.USI)
+		if err != nil {
+			return fmt.Errorf("kifu: move %d: %w", i+1, err)
+		}
+		sb.WriteString(EncodeCSAMove(mo) + "\n")
+		if m.Time > 0 {
+			fmt.Fprintf(&sb, "T%d\n", int(m.Time.Seconds()))
+		}
+		if m.Comment != "" {
+			writeCSAComment(&sb, m.Comment)
+		}
+		if err := b.ProcessMove(&mo); err != nil {
+			return fmt.Errorf("kifu: move %d: %w", i+1, err)
+		}
+	}
+
+	switch r.Termination {
+	case NoTermination:
+	case IllegalAction:
+		// The sign names the player that played the illegal move.
+		loser := shogi.White
+		if r.Result == shogi.WhiteWon {
+			loser = shogi.Black
+		}
+		fmt.Fprintf(&sb, "%%%s%s\n", csaSign(loser), IllegalAction)
+	default:
+		fmt.Fprintf(&sb, "%%%s\n", r.Termination)
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+func writeCSAComment(sb *strings.Builder, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(sb, "'%s\n", line)
+	}
+}
+
+func writeCSAPosition(sb *strings.Builder, b shogi.Board) {
+	start := shogi.NewBoard()
+	_ = start.LoadSfen(shogi.StartingPosition)
+	start.Turn = b.Turn
+	if sfenPosition(start.String()) == sfenPosition(b.String()) {
+		fmt.Fprintf(sb, "PI\n%s\n", csaSign(b.Turn))
+		return
+	}
+
+	for rank := 0; rank < 9; rank++ {
+		fmt.Fprintf(sb, "P%d", rank+1)
+		for file := 0; file < 9; file++ {
+			sq := shogi.Square(rank*9 + file)
+			p, err := b.GetPieceAtSquare(sq)
+			if err != nil {
+				sb.WriteString(" * ")
+				continue
+			}
+			sb.WriteString(csaSign(p.Color) + csaPiece(p))
+		}
+		sb.WriteString("\n")
+	}
+	for _, c := range []shogi.Color{shogi.Black, shogi.White} {
+		hand := ""
+		for _, pt := range []shogi.PieceType{shogi.Rook, shogi.Bishop, shogi.Gold, shogi.Silver, shogi.Knight, shogi.Lance, shogi.Pawn} {
+			hand += strings.Repeat("00"+csaPieceNames[pt], b.Hand.Count(c, pt))
+		}
+		if hand != "" {
+			fmt.Fprintf(sb, "P%s%s\n", csaSign(c), hand)
+		}
+	}
+	fmt.Fprintf(sb, "%s\n", csaSign(b.Turn))
+}
+
+// sfenPosition strips the move count of a SFEN string.
+func sfenPosition(sfen string) string {
+	parts := strings.Split(sfen, " ")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, " ")
+}
+
+// ReadCSA reads a record in CSA format. Handicap start positions written as PI followed by
+// the removed pieces and the AL hand shortcut are not supported.
+func ReadCSA(rd io.Reader) (Record, error) {
+	r := Record{Result: shogi.NoOutcome}
+	b := shogi.NewBoard()
+	started := false
+
+	scanner := bufio.NewScanner(rd)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "'") {
+			comment := line[1:]
+			if len(r.Moves) == 0 {
+				r.Comments = append(r.Comments, comment)
+				continue
+			}
+			last := &r.Moves[len(r.Moves)-1]
+			if last.Comment != "" {
+				last.Comment += "\n"
+			}
+			last.Comment += comment
+			continue
+		}
+
+		// Several statements can be written on the same line separated by commas.
+		for _, s := range strings.Split(line, ",") {
+			if err := readCSAStatement(&r, &b, &started, s); err != nil {
+				return Record{}, fmt.Errorf("kifu: line %d: %w", n, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	if !started {
+		return Record{}, fmt.Errorf("kifu: csa record without a start position")
+	}
+	return r, nil
+}
+
+func readCSAStatement(r *Record, b *shogi.Board, started *bool, s string) error {
+	switch {
+	case s == "" || strings.HasPrefix(s, "V"):
+	case strings.HasPrefix(s, "N+"):
+		r.Sente = s[2:]
+	case strings.HasPrefix(s, "N-"):
+		r.Gote = s[2:]
+	case strings.HasPrefix(s, "$EVENT:"):
+		r.Event = strings.TrimPrefix(s, "$EVENT:")
+	case strings.HasPrefix(s, "$START_TIME:"):
+		t, err := time.ParseInLocation(csaTimeLayout, strings.TrimPrefix(s, "$START_TIME:"), time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+		r.StartTime = t
+	case strings.HasPrefix(s, "$"):
+		// Other headers are ignored.
+	case s == "PI":
+		return b.LoadSfen(shogi.StartingPosition)
+	case strings.HasPrefix(s, "PI"):
+		return fmt.Errorf("handicap start positions are not supported: %s", s)
+	case len(s) > 1 && s[0] == 'P' && s[1] >= '1' && s[1] <= '9':
+		return readCSARow(b, int(s[1]-'1'), s[2:])
+	case strings.HasPrefix(s, "P+") || strings.HasPrefix(s, "P-"):
+		return readCSAPieces(b, s)
+	case (s == "+" || s == "-") && !*started:
+		b.Turn = shogi.Black
+		if s == "-" {
+			b.Turn = shogi.White
+		}
+		r.StartPosition = b.String()
+		*started = true
+	case (s[0] == '+' || s[0] == '-') && *started:
+		usi, err := DecodeCSAMove(*b, s)
+		if err != nil {
+			return err
+		}
+		m, err := b.ResolveUSIMove(usi)
+		if err != nil {
+			return err
+		}
+		if err := b.ProcessMove(&m); err != nil {
+			return err
+		}
+		r.Moves = append(r.Moves, Move{USI: usi})
+	case s[0] == 'T':
+		secs, err := strconv.ParseFloat(s[1:], 64)
+		if err != nil {
+			return fmt.Errorf("invalid time %q", s)
+		}
+		if len(r.Moves) > 0 {
+			r.Moves[len(r.Moves)-1].Time = time.Duration(secs * float64(time.Second))
+		}
+	case s[0] == '%':
+		readCSATermination(r, *b, s[1:])
+	default:
+		return fmt.Errorf("unknown statement %q", s)
+	}
+	return nil
+}
+
+func readCSATermination(r *Record, b shogi.Board, special string) {
+	switch special {
+	case "+" + string(IllegalAction):
+		r.Termination, r.Result = IllegalAction, shogi.WhiteWon
+	case "-" + string(IllegalAction):
+		r.Termination, r.Result = IllegalAction, shogi.BlackWon
+	case "ILLEGAL_MOVE":
+		r.Termination, r.Result = IllegalAction, shogi.Winner(b.Turn.Opponent())
+	default:
+		r.Termination = Termination(special)
+		r.Result = outcome(r.Termination, b.Turn)
+	}
+}
+
+// readCSARow reads the nine squares of a P1..P9 row, from file 9 to 1.
+func readCSARow(b *shogi.Board, rank int, row string) error {
+	if len(row) != 27 {
+		return fmt.Errorf("invalid row P%d%s", rank+1, row)
+	}
+	for file := 0; file < 9; file++ {
+		sq := row[file*3 : file*3+3]
+		if sq == " * " {
+			b.BitBoard[rank*9+file] = ""
+			continue
+		}
+		pt, promoted, err := parseCSAPiece(sq[1:])
+		if err != nil {
+			return err
+		}
+		p := shogi.Piece{Type: pt, Color: shogi.Black, IsPromoted: promoted}
+		if sq[0] == '-' {
+			p.Color = shogi.White
+		}
+		b.BitBoard[rank*9+file] = p.String()
+	}
+	return nil
+}
+
+// readCSAPieces reads a P+ or P- line, placing pieces on squares or in hand when the square is 00.
+func readCSAPieces(b *shogi.Board, s string) error {
+	c := shogi.Black
+	if s[1] == '-' {
+		c = shogi.White
+	}
+	pieces := s[2:]
+	if len(pieces)%4 != 0 {
+		return fmt.Errorf("invalid pieces %q", s)
+	}
+	for i := 0; i < len(pieces); i += 4 {
+		sq, name := pieces[i:i+2], pieces[i+2:i+4]
+		pt, promoted, err := parseCSAPiece(name)
+		if err != nil {
+			return err
+		}
+		if sq == "00" {
+			b.Hand.Add(c, pt)
+			continue
+		}
+		usi, err := usiSquare(sq)
+		if err != nil {
+			return err
+		}
+		dest, err := shogi.ParseUSISquare(usi)
+		if err != nil {
+			return err
+		}
+		b.BitBoard[dest] = shogi.Piece{Type: pt, Color: c, IsPromoted: promoted}.String()
+	}
+	return nil
+}
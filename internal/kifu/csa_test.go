@@ -0,0 +1,112 @@
+package kifu_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestCSA_round_trip(t *testing.T) {
+	tests := []struct {
+		name   string // description of this test case
+		record kifu.Record
+		want   []string // lines that must be written
+	}{
+		{
+			name: "resignation from the starting position",
+			record: kifu.Record{
+				Sente:         "engine a",
+				Gote:          "engine b",
+				Event:         "match",
+				StartTime:     time.Date(2024, 5, 1, 10, 30, 0, 0, time.Local),
+				StartPosition: shogi.StartingPosition,
+				Moves: []kifu.Move{
+					{USI: "7g7f", Time: 3 * time.Second, Comment: "book"},
+					{USI: "3c3d", Time: time.Second},
+					{USI: "8h2b+"},
+					{USI: "3a2b", Comment: "forced\nrecapture"},
+					{USI: "B*4e"},
+				},
+				Result:      shogi.BlackWon,
+				Termination: kifu.Resign,
+				Comments:    []string{"opening 1"},
+			},
+			want: []string{"PI", "+7776FU", "T3", "'book", "-3334FU", "+8822UM", "-3122GI", "'recapture", "+0045KA", "%TORYO"},
+		},
+		{
+			name: "illegal move from a sfen position",
+			record: kifu.Record{
+				Sente:         "engine a",
+				Gote:          "engine b",
+				StartPosition: "4k4/9/4P4/9/9/9/9/9/4K4 w Gp 1",
+				Moves:         []kifu.Move{{USI: "P*5b"}},
+				Result:        shogi.WhiteWon,
+				Termination:   kifu.IllegalAction,
+			},
+			want: []string{"P1 *  *  *  * -OU *  *  *  * ", "P+00KI", "P-00FU", "-", "-0052FU", "%+ILLEGAL_ACTION"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := kifu.WriteCSA(&sb, tt.record); err != nil {
+				t.Fatalf("WriteCSA() failed: %v", err)
+			}
+			lines := strings.Split(sb.String(), "\n")
+			for _, w := range tt.want {
+				found := false
+				for _, l := range lines {
+					if l == w {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("WriteCSA() missing line %q in:\n%s", w, sb.String())
+				}
+			}
+
+			got, err := kifu.ReadCSA(strings.NewReader(sb.String()))
+			if err != nil {
+				t.Fatalf("ReadCSA() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.record) {
+				t.Errorf("ReadCSA() = %+v, want %+v", got, tt.record)
+			}
+		})
+	}
+}
+
+func TestReadCSA_rejects(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		csa  string
+	}{
+		{name: "no start position", csa: "V2.2\nN+a\nN-b\n"},
+		{name: "move out of turn", csa: "V2.2\nPI\n+\n-3334FU\n"},
+		{name: "wrong piece", csa: "V2.2\nPI\n+\n+7776KY\n"},
+		{name: "unknown statement", csa: "V2.2\nPI\n+\nX\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kifu.ReadCSA(strings.NewReader(tt.csa)); err == nil {
+				t.Fatal("ReadCSA() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func TestRecord_Replay(t *testing.T) {
+	r := kifu.NewRecord("a", "b")
+	r.Moves = []kifu.Move{{USI: "7g7f"}, {USI: "3c3d"}}
+	b, err := r.Replay(-1)
+	if err != nil {
+		t.Fatalf("Replay() failed: %v", err)
+	}
+	if want := "lnsgkgsnl/1r5b1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL b - 3"; b.String() != want {
+		t.Errorf("Replay() = %s, want %s", b.String(), want)
+	}
+}
@@ -0,0 +1,127 @@
+// Package kifu reads and writes game records.
+package kifu
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Termination is how a game ended, named after the CSA special moves.
+type Termination string
+
+const (
+	// NoTermination is a game still being played.
+	NoTermination Termination = ""
+	// Resign, the player on turn resigned.
+	Resign Termination = "TORYO"
+	// Checkmate, the player on turn is mated.
+	Checkmate Termination = "TSUMI"
+	// TimeUp, the player on turn ran out of time.
+	TimeUp Termination = "TIME_UP"
+	// IllegalAction, the player named by the result played an illegal move.
+	IllegalAction Termination = "ILLEGAL_ACTION"
+	// Sennichite, the same position was repeated four times.
+	Sennichite Termination = "SENNICHITE"
+	// Jishogi, impasse with both kings entered.
+	Jishogi Termination = "JISHOGI"
+	// Kachi, the player on turn declared a win by entering king.
+	Kachi Termination = "KACHI"
+	// Hikiwake, the game was adjudicated or agreed as a draw.
+	Hikiwake Termination = "HIKIWAKE"
+	// MaxMoves, the game reached the move limit.
+	MaxMoves Termination = "MAX_MOVES"
+	// Chudan, the game was aborted.
+	Chudan Termination = "CHUDAN"
+)
+
+// Move is a move of a record in USI notation, with the time spent on it and an optional comment.
+type Move struct {
+	USI     string
+	Time    time.Duration
+	Comment string
+}
+
+// Record is a complete game.
+type Record struct {
+	Sente     string
+	Gote      string
+	Event     string
+	StartTime time.Time
+	// StartPosition is the SFEN of the position the game started from.
+	StartPosition string
+	Moves         []Move
+	Result        shogi.Outcome
+	Termination   Termination
+	// Comments are the comments of the record header.
+	Comments []string
+}
+
+// NewRecord returns an empty record of a game between sente and gote from the starting position.
+func NewRecord(sente, gote string) Record {
+	return Record{
+		Sente:         sente,
+		Gote:          gote,
+		StartPosition: shogi.StartingPosition,
+		Result:        shogi.NoOutcome,
+	}
+}
+
+// GameRecord returns the record of the moves played in g so far.
+func GameRecord(g *shogi.Game) Record {
+	r := NewRecord(g.SentePlayer(), g.GotePlayer())
+	r.StartPosition = g.StartPosition()
+	for _, m := range g.Moves() {
+		r.Moves = append(r.Moves, Move{USI: m.USI()})
+	}
+	r.Result = g.Outcome()
+	return r
+}
+
+// Board returns the start position of the record, as a board.
+func (r Record) Board() (shogi.Board, error) {
+	b := shogi.NewBoard()
+	sfen := r.StartPosition
+	if sfen == "" {
+		sfen = shogi.StartingPosition
+	}
+	if err := b.LoadSfen(sfen); err != nil {
+		return shogi.Board{}, err
+	}
+	return b, nil
+}
+
+// Replay plays the first n moves of the record from its start position, every move if n is negative.
+func (r Record) Replay(n int) (shogi.Board, error) {
+	b, err := r.Board()
+	if err != nil {
+		return shogi.Board{}, err
+	}
+	if n < 0 || n > len(r.Moves) {
+		n = len(r.Moves)
+	}
+	for i, m := range r.Moves[:n] {
+		mo, err := b.ResolveUSIMove(m.USI)
+		if err != nil {
+			return shogi.Board{}, fmt.Errorf("kifu: move %d: %w", i+1, err)
+		}
+		if err := b.ProcessMove(&mo); err != nil {
+			return shogi.Board{}, fmt.Errorf("kifu: move %d: %w", i+1, err)
+		}
+	}
+	return b, nil
+}
+
+// outcome returns the result of a game that ended by t with c on turn.
+func outcome(t Termination, c shogi.Color) shogi.Outcome {
+	switch t {
+	case Resign, Checkmate, TimeUp:
+		return shogi.Winner(c.Opponent())
+	case Kachi:
+		return shogi.Winner(c)
+	case Sennichite, Jishogi, Hikiwake, MaxMoves:
+		return shogi.Draw
+	}
+	return shogi.NoOutcome
+}
@@ -0,0 +1,513 @@
+// Package match plays games between two USI engines.
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+	"github.com/juanpablocruz/shogo/clientr/internal/stats"
+)
+
+// Player is one of the engines of a match.
+type Player struct {
+	// Name identifies the player in records and summaries, the engine id is used when empty.
+	Name   string
+	Engine *engine.GUIEngine
+	// Options are sent with `setoption` before the first game.
+	Options map[string]string
+}
+
+// TimeControl is the clock of each player. Time is the main time, after it runs out each move
+// must be played within Byoyomi. Increment is added after every move, Fischer style.
+type TimeControl struct {
+	Time      time.Duration
+	Byoyomi   time.Duration
+	Increment time.Duration
+	// Margin is the time a player can overstep its clock before losing, to absorb communication delays.
+	Margin time.Duration
+}
+
+// Adjudication are the rules to end a game before the engines do.
+type Adjudication struct {
+	// MaxPlies ends the game as a draw once reached, 0 means no limit.
+	MaxPlies int
+	// A player resigns when it reports a score of -ResignScore or worse, while its opponent reports
+	// ResignScore or better, for ResignMoves consecutive moves each. 0 moves disables the rule.
+	ResignScore int
+	ResignMoves int
+	// The game is a draw when both players report a score within DrawScore of 0 for DrawMoves
+	// consecutive moves each, once DrawMinPly plies were played. 0 moves disables the rule.
+	DrawScore  int
+	DrawMoves  int
+	DrawMinPly int
+}
+
+// GameResult is a finished game of a match.
+type GameResult struct {
+	// Number of the game, starting at 1.
+	Number int
+	Record kifu.Record
+	// FirstIsSente reports whether the first player of the match played sente.
+	FirstIsSente bool
+	// Path of the kifu file, empty if it wasn't written.
+	Path string
+}
+
+// Score returns the points of the first player of the match: 1 for a win, 0.5 for a draw.
+func (g GameResult) Score() float64 {
+	switch g.Record.Result {
+	case shogi.Draw:
+		return 0.5
+	case shogi.BlackWon:
+		if g.FirstIsSente {
+			return 1
+		}
+	case shogi.WhiteWon:
+		if !g.FirstIsSente {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Summary is the result of a match from the point of view of the first player.
+type Summary struct {
+	First  string
+	Second string
+	Wins   int
+	Losses int
+	Draws  int
+	// Pentanomial counts the finished pairs of games played from the same opening.
+	Pentanomial stats.Pentanomial
+	// LLR and SPRT are the state of the SPRT after the last pair, if the match runs one.
+	LLR  float64
+	SPRT stats.SPRTResult
+}
+
+// WLD returns the wins, losses and draws of the first player.
+func (s Summary) WLD() stats.WLD {
+	return stats.WLD{Wins: s.Wins, Losses: s.Losses, Draws: s.Draws}
+}
+
+// Games returns the number of games played.
+func (s Summary) Games() int {
+	return s.Wins + s.Losses + s.Draws
+}
+
+// Score returns the ratio of points of the first player.
+func (s Summary) Score() float64 {
+	if s.Games() == 0 {
+		return 0
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// String summarises the match, e.g. Score of a vs b: 3 - 1 - 2 [0.667] 6
+func (s Summary) String() string {
+	return fmt.Sprintf("Score of %s vs %s: %d - %d - %d [%.3f] %d", s.First, s.Second, s.Wins, s.Losses, s.Draws, s.Score(), s.Games())
+}
+
+func (s *Summary) add(g GameResult) {
+	switch g.Score() {
+	case 1:
+		s.Wins++
+	case 0.5:
+		s.Draws++
+	default:
+		s.Losses++
+	}
+}
+
+// Match plays a series of games between two players, alternating colours every game.
+// Both colours play each opening before moving on to the next one.
+type Match struct {
+	first        *Player
+	second       *Player
+	games        int
+	openings     []string
+	timeControl  TimeControl
+	adjudication Adjudication
+	event        string
+	outDir       string
+	sprt         *stats.SPRT
+
+	// OnGame is called after every game.
+	OnGame func(GameResult)
+}
+
+// WithGames sets the number of games to play.
+func WithGames(n int) func(*Match) {
+	return func(m *Match) {
+		m.games = n
+	}
+}
+
+// WithOpenings sets the SFEN positions games start from.
+func WithOpenings(openings []string) func(*Match) {
+	return func(m *Match) {
+		if len(openings) > 0 {
+			m.openings = openings
+		}
+	}
+}
+
+// WithTimeControl sets the clock of both players.
+func WithTimeControl(tc TimeControl) func(*Match) {
+	return func(m *Match) {
+		m.timeControl = tc
+	}
+}
+
+// WithAdjudication sets the rules to end games early.
+func WithAdjudication(a Adjudication) func(*Match) {
+	return func(m *Match) {
+		m.adjudication = a
+	}
+}
+
+// WithEvent sets the event name written in the records.
+func WithEvent(event string) func(*Match) {
+	return func(m *Match) {
+		m.event = event
+	}
+}
+
+// WithOutDir writes every game as a CSA file in dir.
+func WithOutDir(dir string) func(*Match) {
+	return func(m *Match) {
+		m.outDir = dir
+	}
+}
+
+// WithSPRT runs a SPRT after every pair of games and stops the match once it accepts a hypothesis.
+func WithSPRT(sprt stats.SPRT) func(*Match) {
+	return func(m *Match) {
+		m.sprt = &sprt
+	}
+}
+
+// New returns a match of 2 games from the starting position between first and second.
+func New(first, second *Player, options ...func(*Match)) *Match {
+	m := &Match{
+		first:    first,
+		second:   second,
+		games:    2,
+		openings: []string{shogi.StartingPosition},
+	}
+
+	for _, f := range options {
+		f(m)
+	}
+
+	return m
+}
+
+// Run initialises both engines and plays every game, stopping early if ctx is done.
+func (m *Match) Run(ctx context.Context) (Summary, error) {
+	for _, p := range []*Player{m.first, m.second} {
+		if err := initPlayer(ctx, p); err != nil {
+			return Summary{}, err
+		}
+	}
+	// Records and results are told apart by name, e.g. when an engine plays itself.
+	if m.first.Name == m.second.Name {
+		m.first.Name += "-1"
+		m.second.Name += "-2"
+	}
+	if m.outDir != "" {
+		if err := os.MkdirAll(m.outDir, 0o755); err != nil {
+			return Summary{}, fmt.Errorf("match: unable to create %s: %w", m.outDir, err)
+		}
+	}
+
+	summary := Summary{First: m.first.Name, Second: m.second.Name}
+	pairScore := 0.0
+	for n := 0; n < m.games; n++ {
+		sente, gote := m.first, m.second
+		if n%2 == 1 {
+			sente, gote = gote, sente
+		}
+		opening := m.openings[(n/2)%len(m.openings)]
+
+		rec, err := m.playGame(ctx, opening, sente, gote)
+		if err != nil {
+			return summary, fmt.Errorf("match: game %d: %w", n+1, err)
+		}
+
+		g := GameResult{Number: n + 1, Record: rec, FirstIsSente: sente == m.first}
+		if m.outDir != "" {
+			g.Path = filepath.Join(m.outDir, fmt.Sprintf("%03d-%s-vs-%s.csa", n+1, fileName(sente.Name), fileName(gote.Name)))
+			if err := writeRecord(g.Path, rec); err != nil {
+				return summary, err
+			}
+		}
+
+		summary.add(g)
+		if m.OnGame != nil {
+			m.OnGame(g)
+		}
+
+		if n%2 == 0 {
+			pairScore = g.Score()
+			continue
+		}
+		summary.Pentanomial.Add(pairScore, g.Score())
+		if m.sprt != nil {
+			summary.LLR, summary.SPRT = m.sprt.Test(summary.Pentanomial)
+			if summary.SPRT != stats.Continue {
+				break
+			}
+		}
+	}
+	return summary, nil
+}
+
+func initPlayer(ctx context.Context, p *Player) error {
+	if err := p.Engine.Init(ctx, p.Options); err != nil {
+		return fmt.Errorf("match: unable to initialise %s: %w", p.Name, err)
+	}
+	if p.Name == "" {
+		p.Name = p.Engine.EngineID
+	}
+	return nil
+}
+
+// game is the state of a game being played.
+type game struct {
+	*shogi.Game
+	record    kifu.Record
+	players   map[shogi.Color]*Player
+	clocks    map[shogi.Color]time.Duration
+	positions map[string]int
+	// resign, win and draw count the consecutive moves each player reported a score
+	// within the adjudication thresholds.
+	resign map[shogi.Color]int
+	win    map[shogi.Color]int
+	draw   map[shogi.Color]int
+}
+
+func (m *Match) playGame(ctx context.Context, opening string, sente, gote *Player) (kifu.Record, error) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(opening); err != nil {
+		return kifu.Record{}, err
+	}
+	g := &game{
+		Game:      shogi.NewGame(sente.Name, gote.Name),
+		record:    kifu.NewRecord(sente.Name, gote.Name),
+		players:   map[shogi.Color]*Player{shogi.Black: sente, shogi.White: gote},
+		clocks:    map[shogi.Color]time.Duration{shogi.Black: m.timeControl.Time, shogi.White: m.timeControl.Time},
+		positions: map[string]int{positionKey(b): 1},
+		resign:    map[shogi.Color]int{},
+		win:       map[shogi.Color]int{},
+		draw:      map[shogi.Color]int{},
+	}
+	g.SetBoard(&b)
+	g.record.StartPosition = opening
+	g.record.Event = m.event
+	g.record.StartTime = time.Now()
+
+	for _, p := range []*Player{sente, gote} {
+		if err := p.Engine.NewGame(ctx); err != nil {
+			return kifu.Record{}, err
+		}
+	}
+
+	for g.record.Termination == kifu.NoTermination {
+		if err := m.playMove(ctx, g); err != nil {
+			return kifu.Record{}, err
+		}
+	}
+
+	for c, p := range g.players {
+		outcome := "draw"
+		switch g.record.Result {
+		case shogi.Winner(c):
+			outcome = "win"
+		case shogi.Winner(c.Opponent()):
+			outcome = "lose"
+		}
+		if err := p.Engine.ProcessCMD(shogi.Gameover, outcome); err != nil {
+			return kifu.Record{}, err
+		}
+	}
+	return g.record, nil
+}
+
+// playMove asks the player on turn for a move and plays it, ending the game when a rule says so.
+func (m *Match) playMove(ctx context.Context, g *game) error {
+	b := g.Board()
+	turn := b.Turn
+	p := g.players[turn]
+
+	if len(b.LegalMoves()) == 0 {
+		g.end(kifu.Checkmate, shogi.Winner(turn.Opponent()))
+		return nil
+	}
+	if m.adjudication.MaxPlies > 0 && len(g.record.Moves) >= m.adjudication.MaxPlies {
+		g.end(kifu.MaxMoves, shogi.Draw)
+		return nil
+	}
+
+	if err := p.Engine.SetPosition(g.Game); err != nil {
+		return err
+	}
+
+	searchCtx, cancel := ctx, context.CancelFunc(func() {})
+	allowed := time.Duration(0)
+	if m.timeControl.Time > 0 || m.timeControl.Byoyomi > 0 {
+		allowed = g.clocks[turn] + m.timeControl.Byoyomi + m.timeControl.Margin
+		searchCtx, cancel = context.WithTimeout(ctx, allowed)
+	}
+	start := time.Now()
+	bm, err := p.Engine.Search(searchCtx, m.goParams(g))
+	elapsed := time.Since(start)
+	cancel()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		if !errors.Is(searchCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+		// The engine didn't even answer stop in time.
+		g.end(kifu.TimeUp, shogi.Winner(turn.Opponent()))
+		return nil
+	}
+	if allowed > 0 && elapsed > allowed {
+		g.end(kifu.TimeUp, shogi.Winner(turn.Opponent()))
+		return nil
+	}
+	if m.timeControl.Time > 0 || m.timeControl.Increment > 0 {
+		g.clocks[turn] = max(0, g.clocks[turn]-elapsed) + m.timeControl.Increment
+	}
+
+	switch {
+	case bm.Resign:
+		g.end(kifu.Resign, shogi.Winner(turn.Opponent()))
+		return nil
+	case bm.Win:
+		// The entering king declaration is trusted, as the rules to verify it vary.
+		g.end(kifu.Kachi, shogi.Winner(turn))
+		return nil
+	}
+
+	mo, err := b.ResolveUSIMove(bm.Move)
+	if err != nil || !b.IsLegal(mo) {
+		g.end(kifu.IllegalAction, shogi.Winner(turn.Opponent()))
+		g.record.Comments = append(g.record.Comments, fmt.Sprintf("%s played the illegal move %s", p.Name, bm.Move))
+		return nil
+	}
+	if err := g.Move(mo); err != nil {
+		return err
+	}
+
+	move := kifu.Move{USI: bm.Move, Time: elapsed}
+	score, hasScore := bm.Score()
+	if hasScore {
+		move.Comment = fmt.Sprintf("score %s", score)
+	}
+	g.record.Moves = append(g.record.Moves, move)
+
+	key := positionKey(*g.Board())
+	g.positions[key]++
+	if g.positions[key] >= 4 {
+		g.end(kifu.Sennichite, shogi.Draw)
+		return nil
+	}
+
+	if hasScore {
+		m.adjudicate(g, turn, score.Centipawns())
+	}
+	return nil
+}
+
+// adjudicate applies the score rules after player c reported cp.
+func (m *Match) adjudicate(g *game, c shogi.Color, cp int) {
+	a := m.adjudication
+	g.resign[c] = streak(g.resign[c], cp <= -a.ResignScore)
+	g.win[c] = streak(g.win[c], cp >= a.ResignScore)
+	g.draw[c] = streak(g.draw[c], abs(cp) <= a.DrawScore)
+
+	opp := c.Opponent()
+	if a.ResignMoves > 0 {
+		for _, loser := range []shogi.Color{c, opp} {
+			if g.resign[loser] >= a.ResignMoves && g.win[loser.Opponent()] >= a.ResignMoves {
+				g.end(kifu.Resign, shogi.Winner(loser.Opponent()))
+				g.record.Comments = append(g.record.Comments, "adjudicated by score")
+				return
+			}
+		}
+	}
+	if a.DrawMoves > 0 && len(g.record.Moves) >= a.DrawMinPly && g.draw[c] >= a.DrawMoves && g.draw[opp] >= a.DrawMoves {
+		g.end(kifu.Hikiwake, shogi.Draw)
+		g.record.Comments = append(g.record.Comments, "adjudicated by score")
+	}
+}
+
+// goParams returns the `go` arguments for the player on turn from the clocks.
+func (m *Match) goParams(g *game) engine.GoParams {
+	return engine.GoParams{
+		BTime:   g.clocks[shogi.Black],
+		WTime:   g.clocks[shogi.White],
+		BInc:    m.timeControl.Increment,
+		WInc:    m.timeControl.Increment,
+		Byoyomi: m.timeControl.Byoyomi,
+	}
+}
+
+func (g *game) end(t kifu.Termination, result shogi.Outcome) {
+	g.record.Termination = t
+	g.record.Result = result
+}
+
+func streak(n int, ok bool) int {
+	if ok {
+		return n + 1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// positionKey identifies a position for repetitions, the SFEN without its move count.
+func positionKey(b shogi.Board) string {
+	parts := strings.Split(b.String(), " ")
+	return strings.Join(parts[:3], " ")
+}
+
+// fileName makes a player name safe to use in a file name.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func writeRecord(path string, rec kifu.Record) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("match: unable to write %s: %w", path, err)
+	}
+	if err := kifu.WriteCSA(f, rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
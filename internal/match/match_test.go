@@ -0,0 +1,159 @@
+package match_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/match"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+	"github.com/juanpablocruz/shogo/clientr/internal/stats"
+)
+
+// scriptedEngine answers `go` with a fixed bestmove, or only once it receives `stop` if the move is empty.
+type scriptedEngine struct {
+	out  chan string
+	move string
+}
+
+func newScriptedEngine(move string) *scriptedEngine {
+	return &scriptedEngine{out: make(chan string, 8), move: move}
+}
+
+func (e *scriptedEngine) SendMessage(s string) error {
+	switch strings.Fields(s)[0] {
+	case "usi":
+		e.out <- "id name scripted"
+		e.out <- "usiok"
+	case "isready":
+		e.out <- "readyok"
+	case "go":
+		if e.move != "" {
+			e.out <- "info depth 1 score cp -5000"
+			e.out <- "bestmove " + e.move
+		}
+	case "stop":
+		e.out <- "bestmove resign"
+	}
+	return nil
+}
+
+func (e *scriptedEngine) ReceiveMessage(ctx context.Context) (string, error) {
+	select {
+	case m := <-e.out:
+		return m, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("timeout waiting for message")
+	}
+}
+
+func newBuiltinPlayer(ctx context.Context, name string) *match.Player {
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+	return &match.Player{Name: name, Engine: engine.NewGUIEngine(le)}
+}
+
+func TestMatch_Run(t *testing.T) {
+	tests := []struct {
+		name            string // description of this test case
+		move            string
+		wantTermination kifu.Termination
+	}{
+		{name: "opponent resigns", move: "resign", wantTermination: kifu.Resign},
+		{name: "opponent plays an illegal move", move: "1a1b", wantTermination: kifu.IllegalAction},
+		{name: "opponent runs out of time", move: "", wantTermination: kifu.TimeUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			first := newBuiltinPlayer(ctx, "builtin")
+			second := &match.Player{Engine: engine.NewGUIEngine(newScriptedEngine(tt.move))}
+			results := []match.GameResult{}
+			m := match.New(first, second,
+				match.WithGames(2),
+				match.WithTimeControl(match.TimeControl{Byoyomi: 100 * time.Millisecond, Margin: 100 * time.Millisecond}),
+			)
+			m.OnGame = func(g match.GameResult) { results = append(results, g) }
+
+			summary, err := m.Run(ctx)
+			if err != nil {
+				t.Fatalf("Run() failed: %v", err)
+			}
+			if summary.Wins != 2 || summary.Second != "scripted" {
+				t.Errorf("Run() want 2 wins for the first player, got %s", summary)
+			}
+			if summary.Pentanomial != (stats.Pentanomial{0, 0, 0, 0, 1}) {
+				t.Errorf("Run() want one pair won, got %s", summary.Pentanomial)
+			}
+			if len(results) != 2 || !results[0].FirstIsSente || results[1].FirstIsSente {
+				t.Fatalf("Run() colours not alternated: %+v", results)
+			}
+			for _, r := range results {
+				if r.Record.Termination != tt.wantTermination {
+					t.Errorf("Run() game %d want termination %s got %s", r.Number, tt.wantTermination, r.Record.Termination)
+				}
+			}
+		})
+	}
+}
+
+func TestMatch_Run_writes_records(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opening := "lnsgkgsnl/1r5b1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL b - 1"
+	m := match.New(newBuiltinPlayer(ctx, "a"), newBuiltinPlayer(ctx, "b"),
+		match.WithGames(2),
+		match.WithOpenings([]string{opening}),
+		match.WithAdjudication(match.Adjudication{MaxPlies: 20}),
+		match.WithOutDir(dir),
+	)
+	results := []match.GameResult{}
+	m.OnGame = func(g match.GameResult) { results = append(results, g) }
+
+	summary, err := m.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if summary.Games() != 2 {
+		t.Errorf("Run() want 2 games got %s", summary)
+	}
+	for _, r := range results {
+		f, err := os.Open(r.Path)
+		if err != nil {
+			t.Fatalf("Run() record not written: %v", err)
+		}
+		rec, err := kifu.ReadCSA(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("ReadCSA() failed: %v", err)
+		}
+		if rec.StartPosition != opening {
+			t.Errorf("Run() record start position want %s got %s", opening, rec.StartPosition)
+		}
+		if len(rec.Moves) != len(r.Record.Moves) || rec.Result != r.Record.Result {
+			t.Errorf("Run() record differs from the game: %+v", rec)
+		}
+	}
+}
+
+func TestReadOpenings(t *testing.T) {
+	openings, err := match.ReadOpenings(strings.NewReader("# openings\nstartpos\n\nsfen 4k4/9/9/9/9/9/9/9/4K4 b - 1\n"))
+	if err != nil {
+		t.Fatalf("ReadOpenings() failed: %v", err)
+	}
+	if len(openings) != 2 || openings[0] != shogi.StartingPosition {
+		t.Errorf("ReadOpenings() = %v", openings)
+	}
+	if _, err := match.ReadOpenings(strings.NewReader("not a sfen\n")); err == nil {
+		t.Errorf("ReadOpenings() succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,37 @@
+package match
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// ReadOpenings reads a list of start positions, one SFEN per line. `startpos` stands for the
+// starting position, empty lines and lines starting with # are skipped.
+func ReadOpenings(r io.Reader) ([]string, error) {
+	openings := []string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "sfen ")
+		if line == "startpos" {
+			line = shogi.StartingPosition
+		}
+
+		b := shogi.NewBoard()
+		if err := b.LoadSfen(line); err != nil {
+			return nil, fmt.Errorf("match: opening on line %d: %w", n, err)
+		}
+		openings = append(openings, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return openings, nil
+}
@@ -0,0 +1,34 @@
+package player
+
+import (
+	"context"
+	"errors"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/validate"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// ErrNoAgent is returned by an Agent player when the game has no AI agent.
+var ErrNoAgent = errors.New("player: no AI agent")
+
+// Agent is a computer player asking the AI agent of the game for its moves, which are checked
+// against the legal moves of the board.
+type Agent struct {
+	level   agent.AgentLevel
+	options []func(*validate.Validator)
+}
+
+// NewAgent returns a player asking for the moves of a player of level.
+func NewAgent(level agent.AgentLevel, options ...func(*validate.Validator)) *Agent {
+	return &Agent{level: level, options: options}
+}
+
+// Move asks the agent of p for its move, with the moves played. It returns when ctx is done.
+func (a *Agent) Move(ctx context.Context, p Position) (shogi.Move, error) {
+	if p.Agent == nil {
+		return shogi.Move{}, ErrNoAgent
+	}
+	m, _, err := validate.New(p.Agent, a.options...).Movement(ctx, p.Board, p.Moves, a.level)
+	return m, err
+}
@@ -0,0 +1,67 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Engine is a computer player searching its moves with a USI engine.
+type Engine struct {
+	engine *engine.GUIEngine
+	// params is the search run for every move, nil to search with the clock of the game.
+	params *engine.GoParams
+}
+
+// WithSearch sets the search run for every move. By default the engine searches with the time
+// left on the clock of the game, or one second per move without one.
+func WithSearch(p engine.GoParams) func(*Engine) {
+	return func(e *Engine) {
+		e.params = &p
+	}
+}
+
+// NewEngine returns a player searching with e.
+func NewEngine(e *engine.GUIEngine, options ...func(*Engine)) *Engine {
+	p := &Engine{engine: e}
+	for _, f := range options {
+		f(p)
+	}
+	return p
+}
+
+// Move searches the position and returns the best move, ErrResign or ErrWin. When ctx is done
+// the search is stopped.
+func (e *Engine) Move(ctx context.Context, p Position) (shogi.Move, error) {
+	if err := e.engine.Init(ctx, nil); err != nil {
+		return shogi.Move{}, err
+	}
+	if err := e.engine.SendPosition(p.Start, p.Moves); err != nil {
+		return shogi.Move{}, err
+	}
+	params := engine.GoParams{MoveTime: time.Second}
+	switch {
+	case e.params != nil:
+		params = *e.params
+	case p.Clock != nil:
+		params = engine.NewGoParams(p.Clock)
+	}
+	bm, err := e.engine.Search(ctx, params)
+	if err != nil {
+		return shogi.Move{}, err
+	}
+	switch {
+	case bm.Resign:
+		return shogi.Move{}, ErrResign
+	case bm.Win:
+		return shogi.Move{}, ErrWin
+	}
+	m, err := p.Board.ResolveUSIMove(bm.Move)
+	if err != nil || !p.Board.IsLegal(m) {
+		return shogi.Move{}, fmt.Errorf("player: engine played the illegal move %s", bm.Move)
+	}
+	return m, nil
+}
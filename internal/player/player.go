@@ -0,0 +1,182 @@
+// Package player plays the sides of a game that aren't human: when it is the turn of a computer
+// player, its agent or engine is asked for a move in the background.
+package player
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+var (
+	// ErrResign is returned by a Mover resigning instead of moving.
+	ErrResign = errors.New("player: resigns")
+	// ErrWin is returned by a Mover declaring an entering king win instead of moving.
+	ErrWin = errors.New("player: declares win")
+)
+
+// Position is the position a computer player moves in.
+type Position struct {
+	Board shogi.Board
+	// Start is the SFEN of the start of the game and Moves the USI moves played from it.
+	Start string
+	Moves []string
+	// Agent is the AI agent of the game, nil when the AI features are disabled.
+	Agent agent.Agent
+	// Clock is the clock of the game, nil when it is played without one.
+	Clock *shogi.Clock
+}
+
+// Mover chooses the move of a computer player.
+type Mover interface {
+	Move(ctx context.Context, p Position) (shogi.Move, error)
+}
+
+// Turn is the result of the turn of a computer player.
+type Turn struct {
+	// Position is the SFEN of the board the move was chosen on.
+	Position string
+	Color    shogi.Color
+	Move     shogi.Move
+	// Err is set when the player didn't move, ErrResign and ErrWin when it ended the game.
+	Err error
+}
+
+// Controller asks the computer players of a game for their moves.
+type Controller struct {
+	players map[shogi.Color]Mover
+
+	// OnTurn is called, from the goroutine of the search, when a computer player chose its move.
+	OnTurn func(Turn)
+
+	mu sync.Mutex
+	// position is the SFEN of the board being searched, or whose search failed, so it is asked once.
+	position string
+	cancel   context.CancelFunc
+	// done is closed when the last search returned, the next one waits for it so a player is
+	// never asked twice at once.
+	done chan struct{}
+}
+
+// NewController returns a controller of the computer players, the colors missing from players
+// are human.
+func NewController(players map[shogi.Color]Mover) *Controller {
+	return &Controller{players: players}
+}
+
+// Computer reports whether c is played by a computer.
+func (c *Controller) Computer(color shogi.Color) bool {
+	return c.players[color] != nil
+}
+
+// Follow asks the computer player on turn in g for its move, unless it was already asked on this
+// board. It returns at once, the move is given to OnTurn.
+func (c *Controller) Follow(g *shogi.Game) {
+	b := g.Board()
+	mover := c.players[b.Turn]
+	position := b.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if position == c.position {
+		return
+	}
+	c.stop()
+	if mover == nil || g.Outcome() != shogi.NoOutcome || len(b.Clone().LegalMoves()) == 0 {
+		return
+	}
+
+	p := Position{Board: b.Clone(), Start: g.StartPosition(), Agent: g.GetAIClient(), Clock: g.Clock()}
+	for _, m := range g.Moves() {
+		p.Moves = append(p.Moves, m.USI())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	previous, done := c.done, make(chan struct{})
+	c.position, c.cancel, c.done = position, cancel, done
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		m, err := mover.Move(ctx, p)
+		if ctx.Err() != nil {
+			return
+		}
+		if c.OnTurn != nil {
+			c.OnTurn(Turn{Position: position, Color: p.Board.Turn, Move: m, Err: err})
+		}
+	}()
+}
+
+// Play plays the move of t on g, unless the board changed since the player was asked. It reports
+// whether g was changed, the game ends when the player resigned or declared a win.
+func (c *Controller) Play(g *shogi.Game, t Turn) (bool, error) {
+	if g.Board().String() != t.Position || g.Outcome() != shogi.NoOutcome {
+		return false, nil
+	}
+	switch {
+	case errors.Is(t.Err, ErrResign):
+		g.End(shogi.Winner(t.Color.Opponent()))
+		return true, nil
+	case errors.Is(t.Err, ErrWin):
+		g.End(shogi.Winner(t.Color))
+		return true, nil
+	case t.Err != nil:
+		return false, t.Err
+	}
+	if err := g.Move(t.Move); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Cancel cancels the running search, its move is never given to OnTurn and the player isn't asked
+// again on this board. It reports whether a search was running.
+func (c *Controller) Cancel() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	c.cancel()
+	c.cancel = nil
+	return true
+}
+
+// Retry cancels the running search and forgets the board, so the player on turn is asked again by
+// the next Follow, e.g. once its agent changed or after its turn failed. It returns when the search
+// cancelled has returned.
+func (c *Controller) Retry() {
+	c.mu.Lock()
+	c.stop()
+	done := c.done
+	c.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Stop cancels the running search, its move is never given to OnTurn.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop()
+}
+
+func (c *Controller) stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.position, c.cancel = "", nil
+}
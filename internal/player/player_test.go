@@ -0,0 +1,281 @@
+package player_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/agent/cassette"
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/player"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// scriptedMover plays its USI moves in turn, or fails with err.
+type scriptedMover struct {
+	moves []string
+	err   error
+	asked int
+}
+
+func (s *scriptedMover) Move(_ context.Context, p player.Position) (shogi.Move, error) {
+	s.asked++
+	if s.err != nil {
+		return shogi.Move{}, s.err
+	}
+	return p.Board.ResolveUSIMove(s.moves[len(p.Moves)/2])
+}
+
+// awaitTurn returns the next turn given to OnTurn.
+func awaitTurn(t *testing.T, turns <-chan player.Turn) player.Turn {
+	t.Helper()
+	select {
+	case turn := <-turns:
+		return turn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no turn played")
+	}
+	return player.Turn{}
+}
+
+func TestController(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		err     error
+		played  bool
+		wantErr bool
+		outcome shogi.Outcome
+	}{
+		{name: "move", played: true, outcome: shogi.NoOutcome},
+		{name: "resign", err: player.ErrResign, played: true, outcome: shogi.BlackWon},
+		{name: "win", err: player.ErrWin, played: true, outcome: shogi.WhiteWon},
+		{name: "failure", err: errors.New("no move"), wantErr: true, outcome: shogi.NoOutcome},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gote := &scriptedMover{moves: []string{"3c3d"}, err: tt.err}
+			c := player.NewController(map[shogi.Color]player.Mover{shogi.White: gote})
+			turns := make(chan player.Turn, 1)
+			c.OnTurn = func(t player.Turn) { turns <- t }
+			defer c.Stop()
+
+			g := shogi.NewGame("human", "cpu")
+			c.Follow(g)
+			if len(turns) != 0 || gote.asked != 0 {
+				t.Fatalf("Follow() asked gote on the turn of sente")
+			}
+			m, err := g.Board().ResolveUSIMove("7g7f")
+			if err != nil {
+				t.Fatalf("ResolveUSIMove() failed: %v", err)
+			}
+			if err := g.Move(m); err != nil {
+				t.Fatalf("Move() failed: %v", err)
+			}
+			c.Follow(g)
+			turn := awaitTurn(t, turns)
+			// The board didn't change, gote isn't asked again.
+			c.Follow(g)
+
+			played, err := c.Play(g, turn)
+			if played != tt.played || (err != nil) != tt.wantErr {
+				t.Errorf("Play() = %v, %v, want %v, error %v", played, err, tt.played, tt.wantErr)
+			}
+			if o := g.Outcome(); o != tt.outcome {
+				t.Errorf("Outcome() = %s, want %s", o, tt.outcome)
+			}
+			if gote.asked != 1 {
+				t.Errorf("gote asked %d times, want once", gote.asked)
+			}
+			if tt.played && tt.err == nil && g.Board().Turn != shogi.Black {
+				t.Errorf("Play() left the turn to gote")
+			}
+		})
+	}
+}
+
+func TestController_Play_stale(t *testing.T) {
+	c := player.NewController(map[shogi.Color]player.Mover{shogi.Black: &scriptedMover{moves: []string{"7g7f"}}})
+	turns := make(chan player.Turn, 1)
+	c.OnTurn = func(t player.Turn) { turns <- t }
+	defer c.Stop()
+
+	g := shogi.NewGame("cpu", "human")
+	c.Follow(g)
+	turn := awaitTurn(t, turns)
+	// The game was reset to another position while the player thought.
+	g.SetBoard(&shogi.Board{})
+	if played, err := c.Play(g, turn); played || err != nil {
+		t.Errorf("Play() = %v, %v on a changed board, want it ignored", played, err)
+	}
+}
+
+// blockingMover thinks until its context is done.
+type blockingMover struct {
+	started chan struct{}
+}
+
+func (b blockingMover) Move(ctx context.Context, _ player.Position) (shogi.Move, error) {
+	close(b.started)
+	<-ctx.Done()
+	return shogi.Move{}, ctx.Err()
+}
+
+func TestController_Cancel(t *testing.T) {
+	mover := blockingMover{started: make(chan struct{})}
+	c := player.NewController(map[shogi.Color]player.Mover{shogi.Black: mover})
+	turns := make(chan player.Turn, 1)
+	c.OnTurn = func(t player.Turn) { turns <- t }
+	defer c.Stop()
+
+	if c.Cancel() {
+		t.Errorf("Cancel() = true before any search")
+	}
+	g := shogi.NewGame("cpu", "human")
+	c.Follow(g)
+	<-mover.started
+	if !c.Cancel() {
+		t.Fatalf("Cancel() = false while searching")
+	}
+	// The player isn't asked again on the same board, which would close started twice.
+	c.Follow(g)
+	if c.Cancel() {
+		t.Errorf("Cancel() = true after the search was cancelled")
+	}
+	select {
+	case turn := <-turns:
+		t.Errorf("cancelled search played %+v", turn)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestController_Retry(t *testing.T) {
+	c := player.NewController(map[shogi.Color]player.Mover{shogi.Black: player.NewAgent(agent.Pro)})
+	turns := make(chan player.Turn, 1)
+	c.OnTurn = func(t player.Turn) { turns <- t }
+	defer c.Stop()
+
+	g := shogi.NewGame("cpu", "human")
+	c.Follow(g)
+	if _, err := c.Play(g, awaitTurn(t, turns)); !errors.Is(err, player.ErrNoAgent) {
+		t.Fatalf("Play() error = %v, want ErrNoAgent", err)
+	}
+	c.Follow(g)
+	select {
+	case turn := <-turns:
+		t.Fatalf("failed turn asked again without Retry: %+v", turn)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.SetAIClient(&fakeAgent{replies: []string{"7g7f"}})
+	c.Retry()
+	c.Follow(g)
+	if played, err := c.Play(g, awaitTurn(t, turns)); !played || err != nil {
+		t.Fatalf("Play() after Retry() = %v, %v", played, err)
+	}
+	if got := usiMoves(g); strings.Join(got, " ") != "7g7f" {
+		t.Errorf("moves = %v, want the move of the new agent", got)
+	}
+}
+
+func TestEngine_Move(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+
+	// Sente searches to depth 1, gote with the time left on the clock.
+	players := []*player.Engine{
+		player.NewEngine(engine.NewGUIEngine(le), player.WithSearch(engine.GoParams{Depth: 1})),
+		player.NewEngine(engine.NewGUIEngine(le)),
+	}
+	g := shogi.NewGame("engine", "engine", shogi.WithClock(shogi.TimeControl{Kind: shogi.Byoyomi, Main: time.Minute, Byoyomi: time.Second}))
+	for _, e := range players {
+		m, err := e.Move(ctx, player.Position{Board: g.Board().Clone(), Start: g.StartPosition(), Moves: usiMoves(g), Clock: g.Clock()})
+		if err != nil {
+			t.Fatalf("Move() failed: %v", err)
+		}
+		if !g.Board().IsLegal(m) {
+			t.Fatalf("Move() = %s, want a legal move", m.USI())
+		}
+		if err := g.Move(m); err != nil {
+			t.Fatalf("Move(%s) failed: %v", m.USI(), err)
+		}
+	}
+}
+
+// fakeAgent replies with its moves in turn.
+type fakeAgent struct {
+	replies []string
+}
+
+func (f *fakeAgent) AskHint(context.Context, agent.Request) (string, error) {
+	return "", errors.New("no hints")
+}
+
+func (f *fakeAgent) AskMovement(context.Context, agent.Request) (string, error) {
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+	return reply, nil
+}
+
+func (f *fakeAgent) Chat(context.Context, string, []agent.Message) (string, error) {
+	return "", errors.New("no chat")
+}
+
+func TestAgent_Move(t *testing.T) {
+	a := player.NewAgent(agent.Pro)
+	g := shogi.NewGame("cpu", "human")
+	p := player.Position{Board: g.Board().Clone(), Start: g.StartPosition()}
+	if _, err := a.Move(context.Background(), p); !errors.Is(err, player.ErrNoAgent) {
+		t.Errorf("Move() without agent error = %v, want ErrNoAgent", err)
+	}
+	p.Agent = &fakeAgent{replies: []string{"5e5d", "3g3f"}}
+	m, err := a.Move(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if err := g.Move(m); err != nil {
+		t.Errorf("Move(%s) failed: %v", m.USI(), err)
+	}
+}
+
+func TestAgent_cassette(t *testing.T) {
+	replayer, err := cassette.Open("testdata/movement.json")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	c := player.NewController(map[shogi.Color]player.Mover{shogi.White: player.NewAgent(agent.Pro)})
+	turns := make(chan player.Turn, 1)
+	c.OnTurn = func(t player.Turn) { turns <- t }
+	defer c.Stop()
+
+	g := shogi.NewGame("human", "cpu")
+	g.SetAIClient(replayer)
+	for _, usi := range []string{"7g7f", "2g2f"} {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+		}
+		if err := g.Move(m); err != nil {
+			t.Fatalf("Move(%s) failed: %v", usi, err)
+		}
+		c.Follow(g)
+		if played, err := c.Play(g, awaitTurn(t, turns)); !played || err != nil {
+			t.Fatalf("Play() = %v, %v", played, err)
+		}
+	}
+	if got := usiMoves(g); strings.Join(got, " ") != "7g7f 3c3d 2g2f 8c8d" {
+		t.Errorf("moves = %v, want the recorded replies of gote", got)
+	}
+}
+
+func usiMoves(g *shogi.Game) []string {
+	moves := []string{}
+	for _, m := range g.Moves() {
+		moves = append(moves, m.USI())
+	}
+	return moves
+}
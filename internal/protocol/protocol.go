@@ -0,0 +1,269 @@
+// Package protocol defines the messages exchanged by shogo clients and servers to play online.
+//
+// Every message is a JSON object {"action": <action>, "data": {...}} written one after the
+// other on the connection, the fields of data depend on the action.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Actions of the messages.
+const (
+	ActionJoin       = "join"
+	ActionStart      = "start"
+	ActionMove       = "move"
+	ActionResign     = "resign"
+	ActionDrawOffer  = "draw_offer"
+	ActionDrawAccept = "draw_accept"
+	ActionChat       = "chat"
+	ActionClock      = "clock"
+	ActionGameOver   = "game_over"
+	ActionError      = "error"
+	ActionWatch      = "watch"
+	ActionGames      = "games"
+	ActionHistory    = "history"
+	ActionResume     = "resume"
+	ActionPresence   = "presence"
+)
+
+// Message is the framing of every message on the wire.
+type Message struct {
+	Action string                 `json:"action"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// Payload is the typed content of a message.
+type Payload interface {
+	// Action returns the action of the messages carrying the payload.
+	Action() string
+}
+
+// Join asks the server for a game.
+type Join struct {
+	Name string `json:"name"`
+}
+
+// Start announces a new game to both players.
+type Start struct {
+	// ID identifies the game on the server, for spectators to watch it.
+	ID    string `json:"id,omitempty"`
+	Sente string `json:"sente"`
+	Gote  string `json:"gote"`
+	// Color is the side of the player receiving the message, b or w.
+	Color string `json:"color"`
+	// Position is the SFEN the game starts from.
+	Position string `json:"position"`
+	// TimeControl is the clock of the game as read by shogi.ParseTimeControl, empty for none.
+	TimeControl string `json:"time_control,omitempty"`
+	// Token lets the player resume the game after losing the connection.
+	Token string `json:"token,omitempty"`
+}
+
+// Move is a move of the game in USI notation.
+type Move struct {
+	Move string `json:"move"`
+	// Ply is the number of the move in the game, starting at 1.
+	Ply int `json:"ply"`
+}
+
+// Resign gives up the game.
+type Resign struct{}
+
+// DrawOffer offers a draw to the opponent.
+type DrawOffer struct{}
+
+// DrawAccept accepts the draw offered by the opponent.
+type DrawAccept struct{}
+
+// Chat is a text message between the players.
+type Chat struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+// Clock is the main time left to each player in milliseconds, sent by the server to keep the
+// clocks of the clients in sync.
+type Clock struct {
+	Sente int64 `json:"sente"`
+	Gote  int64 `json:"gote"`
+}
+
+// GameOver announces the end of the game.
+type GameOver struct {
+	// Result is the shogi.Outcome of the game.
+	Result string `json:"result"`
+	// Reason tells how the game ended, e.g. resign, checkmate or time_up.
+	Reason string `json:"reason"`
+}
+
+// Error reports a message that couldn't be processed.
+type Error struct {
+	Message string `json:"message"`
+}
+
+// Watch asks to follow the game with GameID as a spectator, an empty GameID lists the games in
+// progress instead.
+type Watch struct {
+	GameID string `json:"game_id"`
+}
+
+// GameInfo describes a game in progress.
+type GameInfo struct {
+	ID    string `json:"id"`
+	Sente string `json:"sente"`
+	Gote  string `json:"gote"`
+	// Ply is the number of moves played.
+	Ply int `json:"ply"`
+}
+
+// Games lists the games in progress, in answer to a Watch without GameID.
+type Games struct {
+	Games []GameInfo `json:"games"`
+}
+
+// History is the game a spectator starts watching, or a player resumes. The moves and clocks
+// that follow are sent as they are to the players.
+type History struct {
+	ID string `json:"id"`
+	// Color is the side of a player resuming the game, b or w, empty for a spectator.
+	Color    string `json:"color,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Sente    string `json:"sente"`
+	Gote     string `json:"gote"`
+	Position string `json:"position"`
+	// TimeControl is the clock of the game as read by shogi.ParseTimeControl, empty for none.
+	TimeControl string `json:"time_control,omitempty"`
+	// Moves are the moves played so far in USI notation.
+	Moves []string `json:"moves"`
+}
+
+// Resume takes the place of a player who lost the connection, Token is the one sent in Start.
+type Resume struct {
+	Token string `json:"token"`
+}
+
+// Presence tells the opponent a player lost the connection or came back. While a player is away
+// the clocks are stopped, the game is lost if it doesn't come back within Grace milliseconds.
+type Presence struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	Grace     int64  `json:"grace,omitempty"`
+}
+
+func (Join) Action() string       { return ActionJoin }
+func (Start) Action() string      { return ActionStart }
+func (Move) Action() string       { return ActionMove }
+func (Resign) Action() string     { return ActionResign }
+func (DrawOffer) Action() string  { return ActionDrawOffer }
+func (DrawAccept) Action() string { return ActionDrawAccept }
+func (Chat) Action() string       { return ActionChat }
+func (Clock) Action() string      { return ActionClock }
+func (GameOver) Action() string   { return ActionGameOver }
+func (Error) Action() string      { return ActionError }
+func (Watch) Action() string      { return ActionWatch }
+func (Games) Action() string      { return ActionGames }
+func (History) Action() string    { return ActionHistory }
+func (Resume) Action() string     { return ActionResume }
+func (Presence) Action() string   { return ActionPresence }
+
+// Encode wraps p in a message.
+func Encode(p Payload) (Message, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return Message{}, fmt.Errorf("protocol: encoding %s: %w", p.Action(), err)
+	}
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return Message{}, fmt.Errorf("protocol: encoding %s: %w", p.Action(), err)
+	}
+	return Message{Action: p.Action(), Data: data}, nil
+}
+
+// Decode returns the payload of m.
+func Decode(m Message) (Payload, error) {
+	switch m.Action {
+	case ActionJoin:
+		return decode[Join](m)
+	case ActionStart:
+		return decode[Start](m)
+	case ActionMove:
+		return decode[Move](m)
+	case ActionResign:
+		return decode[Resign](m)
+	case ActionDrawOffer:
+		return decode[DrawOffer](m)
+	case ActionDrawAccept:
+		return decode[DrawAccept](m)
+	case ActionChat:
+		return decode[Chat](m)
+	case ActionClock:
+		return decode[Clock](m)
+	case ActionGameOver:
+		return decode[GameOver](m)
+	case ActionError:
+		return decode[Error](m)
+	case ActionWatch:
+		return decode[Watch](m)
+	case ActionGames:
+		return decode[Games](m)
+	case ActionHistory:
+		return decode[History](m)
+	case ActionResume:
+		return decode[Resume](m)
+	case ActionPresence:
+		return decode[Presence](m)
+	}
+	return nil, fmt.Errorf("protocol: unknown action %q", m.Action)
+}
+
+func decode[T Payload](m Message) (Payload, error) {
+	var p T
+	b, err := json.Marshal(m.Data)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: decoding %s: %w", m.Action, err)
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("protocol: decoding %s: %w", m.Action, err)
+	}
+	return p, nil
+}
+
+// Conn sends and receives messages over a stream, it is safe to send from several goroutines.
+type Conn struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// NewConn returns a Conn reading and writing messages on rw.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{
+		enc: json.NewEncoder(rw),
+		dec: json.NewDecoder(rw),
+	}
+}
+
+// Send writes p to the connection.
+func (c *Conn) Send(p Payload) error {
+	m, err := Encode(p)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(m)
+}
+
+// Receive reads the next message from the connection. It returns io.EOF once the connection
+// is closed by the other end.
+func (c *Conn) Receive() (Payload, error) {
+	var m Message
+	if err := c.dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return Decode(m)
+}
@@ -0,0 +1,80 @@
+package protocol_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+)
+
+func TestEncode_Decode(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		payload protocol.Payload
+	}{
+		{name: "join", payload: protocol.Join{Name: "habu"}},
+		{name: "start", payload: protocol.Start{ID: "3", Sente: "habu", Gote: "fujii", Color: "b", Position: "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1", TimeControl: "10m+30s", Token: "4f2a"}},
+		{name: "move", payload: protocol.Move{Move: "7g7f", Ply: 1}},
+		{name: "resign", payload: protocol.Resign{}},
+		{name: "draw offer", payload: protocol.DrawOffer{}},
+		{name: "draw accept", payload: protocol.DrawAccept{}},
+		{name: "chat", payload: protocol.Chat{From: "habu", Text: "yoroshiku"}},
+		{name: "clock", payload: protocol.Clock{Sente: 600000, Gote: 598250}},
+		{name: "game over", payload: protocol.GameOver{Result: "0-1", Reason: "resign"}},
+		{name: "error", payload: protocol.Error{Message: "not your turn"}},
+		{name: "watch", payload: protocol.Watch{GameID: "3"}},
+		{name: "games", payload: protocol.Games{Games: []protocol.GameInfo{{ID: "3", Sente: "habu", Gote: "fujii", Ply: 42}}}},
+		{name: "history", payload: protocol.History{ID: "3", Sente: "habu", Gote: "fujii", Position: "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1", Moves: []string{"7g7f", "3c3d"}}},
+		{name: "resumed history", payload: protocol.History{ID: "3", Color: "w", Token: "4f2a", Sente: "habu", Gote: "fujii", Position: "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1", Moves: []string{"7g7f"}}},
+		{name: "resume", payload: protocol.Resume{Token: "4f2a"}},
+		{name: "presence", payload: protocol.Presence{Name: "habu", Grace: 30000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := protocol.Encode(tt.payload)
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+			if m.Action != tt.payload.Action() {
+				t.Errorf("Encode() action = %q, want %q", m.Action, tt.payload.Action())
+			}
+			got, err := protocol.Decode(m)
+			if err != nil {
+				t.Fatalf("Decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.payload) {
+				t.Errorf("Decode() = %#v, want %#v", got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestDecode_unknown_action(t *testing.T) {
+	if _, err := protocol.Decode(protocol.Message{Action: "dance"}); err == nil {
+		t.Errorf("Decode() succeeded unexpectedly")
+	}
+}
+
+func TestConn(t *testing.T) {
+	var buf bytes.Buffer
+	conn := protocol.NewConn(&buf)
+	if err := conn.Send(protocol.Move{Move: "P*5e", Ply: 31}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if want := `{"action":"move","data":{"move":"P*5e","ply":31}}` + "\n"; buf.String() != want {
+		t.Errorf("Send() wrote %q, want %q", buf.String(), want)
+	}
+
+	buf.WriteString(`{"action":"chat","data":{"from":"gote","text":"hi"}}`)
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+	got, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+	if got != (protocol.Chat{From: "gote", Text: "hi"}) {
+		t.Errorf("Receive() = %#v", got)
+	}
+}
@@ -0,0 +1,360 @@
+// Package review annotates the moves of a game with the evaluation of an engine, flagging the
+// inaccuracies, mistakes and blunders of each player.
+package review
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Class is how good a move was, judged by how much evaluation it lost.
+type Class int
+
+const (
+	Good Class = iota
+	Inaccuracy
+	Mistake
+	Blunder
+)
+
+func (c Class) String() string {
+	switch c {
+	case Inaccuracy:
+		return "Inaccuracy"
+	case Mistake:
+		return "Mistake"
+	case Blunder:
+		return "Blunder"
+	}
+	return "Good"
+}
+
+// Thresholds are the evaluation drops, in centipawns, from which a move is classified.
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+// DefaultThresholds are the thresholds used unless WithThresholds is given.
+var DefaultThresholds = Thresholds{Inaccuracy: 150, Mistake: 400, Blunder: 900}
+
+// classify returns the class of a move that lost loss centipawns.
+func (t Thresholds) classify(loss int) Class {
+	switch {
+	case loss >= t.Blunder:
+		return Blunder
+	case loss >= t.Mistake:
+		return Mistake
+	case loss >= t.Inaccuracy:
+		return Inaccuracy
+	}
+	return Good
+}
+
+// maxEval bounds the evaluations compared, so that missing a faster mate in a won position or
+// losing slower in a lost one isn't a blunder.
+const maxEval = 3000
+
+// Move is a reviewed move. Evaluations are in centipawns, from the point of view of the player
+// making the move.
+type Move struct {
+	Ply   int
+	Color shogi.Color
+	USI   string
+	// Before is the evaluation of the position the move was played in, After of the position it led to.
+	Before int
+	After  int
+	// Loss is how much evaluation the move gave away, 0 when it was the engine's choice.
+	Loss  int
+	Class Class
+	// Best is the move the engine preferred and PV its line, from the position before the move.
+	Best string
+	PV   []string
+}
+
+// Player is the summary of the moves of one player.
+type Player struct {
+	Name         string
+	Moves        int
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+	// AverageLoss is the average evaluation lost per move, in centipawns.
+	AverageLoss float64
+	// Accuracy is from 0 to 100, how close the moves kept the winning chances to those of the
+	// engine's choices.
+	Accuracy float64
+}
+
+func (p Player) String() string {
+	return fmt.Sprintf("%s: accuracy %.1f%%, average loss %.0f cp, %d inaccuracies, %d mistakes, %d blunders",
+		p.Name, p.Accuracy, p.AverageLoss, p.Inaccuracies, p.Mistakes, p.Blunders)
+}
+
+// Review is the review of a game.
+type Review struct {
+	Moves []Move
+	Sente Player
+	Gote  Player
+}
+
+// Reviewer reviews games with an engine.
+type Reviewer struct {
+	engine     *engine.GUIEngine
+	options    map[string]string
+	params     engine.GoParams
+	thresholds Thresholds
+	// OnProgress is called after every position searched, with the number searched and the total.
+	OnProgress func(done, total int)
+}
+
+// WithSearch sets the search run on every position, one second per position by default.
+func WithSearch(p engine.GoParams) func(*Reviewer) {
+	return func(r *Reviewer) {
+		r.params = p
+	}
+}
+
+// WithOptions are sent to the engine with setoption before the review, in the order of their names.
+func WithOptions(options map[string]string) func(*Reviewer) {
+	return func(r *Reviewer) {
+		r.options = options
+	}
+}
+
+// WithThresholds classifies the moves with t instead of DefaultThresholds.
+func WithThresholds(t Thresholds) func(*Reviewer) {
+	return func(r *Reviewer) {
+		r.thresholds = t
+	}
+}
+
+// New returns a reviewer using e.
+func New(e *engine.GUIEngine, options ...func(*Reviewer)) *Reviewer {
+	r := &Reviewer{
+		engine:     e,
+		params:     engine.GoParams{MoveTime: time.Second},
+		thresholds: DefaultThresholds,
+	}
+	for _, f := range options {
+		f(r)
+	}
+	return r
+}
+
+// evaluation is the result of the search of a position, from the point of view of the side to move.
+type evaluation struct {
+	score int
+	best  string
+	pv    []string
+}
+
+// Review searches every position of rec and classifies its moves.
+func (r *Reviewer) Review(ctx context.Context, rec kifu.Record) (Review, error) {
+	if err := r.prepare(ctx); err != nil {
+		return Review{}, err
+	}
+	b, err := rec.Board()
+	if err != nil {
+		return Review{}, err
+	}
+
+	moves := make([]string, 0, len(rec.Moves))
+	evals := make([]evaluation, 0, len(rec.Moves)+1)
+	colors := make([]shogi.Color, 0, len(rec.Moves))
+	for i := 0; ; i++ {
+		e, err := r.evaluate(ctx, rec.StartPosition, moves, b)
+		if err != nil {
+			return Review{}, fmt.Errorf("review: ply %d: %w", i, err)
+		}
+		evals = append(evals, e)
+		if r.OnProgress != nil {
+			r.OnProgress(i+1, len(rec.Moves)+1)
+		}
+		if i == len(rec.Moves) {
+			break
+		}
+		usi := rec.Moves[i].USI
+		m, err := b.ResolveUSIMove(usi)
+		if err != nil {
+			return Review{}, fmt.Errorf("review: move %d: %w", i+1, err)
+		}
+		colors = append(colors, b.Turn)
+		if err := b.ProcessMove(&m); err != nil {
+			return Review{}, fmt.Errorf("review: move %d: %w", i+1, err)
+		}
+		moves = append(moves, usi)
+	}
+
+	rv := Review{Sente: Player{Name: rec.Sente}, Gote: Player{Name: rec.Gote}}
+	for i, usi := range moves {
+		before, after := evals[i].score, -evals[i+1].score
+		m := Move{
+			Ply:    i + 1,
+			Color:  colors[i],
+			USI:    usi,
+			Before: before,
+			After:  after,
+			Best:   evals[i].best,
+			PV:     evals[i].pv,
+		}
+		if usi != m.Best {
+			m.Loss = max(0, clamp(before)-clamp(after))
+		}
+		m.Class = r.thresholds.classify(m.Loss)
+		rv.Moves = append(rv.Moves, m)
+	}
+	rv.Sente = summarize(rv.Sente, rv.Moves, shogi.Black)
+	rv.Gote = summarize(rv.Gote, rv.Moves, shogi.White)
+	return rv, nil
+}
+
+// prepare initializes the engine before the first review and starts a new game.
+func (r *Reviewer) prepare(ctx context.Context) error {
+	if err := r.engine.Init(ctx, r.options); err != nil {
+		return err
+	}
+	return r.engine.NewGame(ctx)
+}
+
+// evaluate searches the position reached by moves from sfen, b.
+func (r *Reviewer) evaluate(ctx context.Context, sfen string, moves []string, b shogi.Board) (evaluation, error) {
+	if err := r.engine.SendPosition(sfen, moves); err != nil {
+		return evaluation{}, err
+	}
+	bm, err := r.engine.Search(ctx, r.params)
+	if err != nil {
+		return evaluation{}, err
+	}
+	score, ok := bm.Score()
+	switch {
+	case ok:
+	case bm.Resign || b.IsCheckmate():
+		score = engine.Score{IsMate: true, MateSign: -1}
+	case bm.Win:
+		score = engine.Score{IsMate: true, MateSign: 1}
+	}
+	e := evaluation{score: score.Centipawns(), best: bm.Move}
+	for i := len(bm.Infos) - 1; i >= 0; i-- {
+		if info := bm.Infos[i]; len(info.PV) > 0 && info.MultiPV <= 1 {
+			e.pv = info.PV
+			break
+		}
+	}
+	if len(e.pv) == 0 && bm.Move != "" {
+		e.pv = []string{bm.Move}
+	}
+	return e, nil
+}
+
+func clamp(cp int) int {
+	return max(-maxEval, min(maxEval, cp))
+}
+
+// winningChances converts an evaluation to the expected score of the player, from 0 to 1, with
+// the logistic model engines use.
+func winningChances(cp int) float64 {
+	return 1 / (1 + math.Exp(-float64(clamp(cp))/600))
+}
+
+// moveAccuracy scores a move from 0 to 100 by the winning chances it lost.
+func moveAccuracy(m Move) float64 {
+	if m.Loss == 0 {
+		return 100
+	}
+	lost := 100 * (winningChances(m.Before) - winningChances(m.Before-m.Loss))
+	return max(0, min(100, 103.1668*math.Exp(-0.04354*lost)-3.1669))
+}
+
+// summarize fills p with the moves of c.
+func summarize(p Player, moves []Move, c shogi.Color) Player {
+	loss, accuracy := 0, 0.0
+	for _, m := range moves {
+		if m.Color != c {
+			continue
+		}
+		p.Moves++
+		loss += m.Loss
+		accuracy += moveAccuracy(m)
+		switch m.Class {
+		case Inaccuracy:
+			p.Inaccuracies++
+		case Mistake:
+			p.Mistakes++
+		case Blunder:
+			p.Blunders++
+		}
+	}
+	if p.Moves > 0 {
+		p.AverageLoss = float64(loss) / float64(p.Moves)
+		p.Accuracy = accuracy / float64(p.Moves)
+	}
+	return p
+}
+
+// Annotate returns rec with a comment on every move that wasn't good, naming its class, the
+// evaluations and the line the engine preferred, and the summary of both players in the header.
+func (rv Review) Annotate(rec kifu.Record) (kifu.Record, error) {
+	b, err := rec.Board()
+	if err != nil {
+		return kifu.Record{}, err
+	}
+	out := rec
+	out.Moves = append([]kifu.Move{}, rec.Moves...)
+	out.Comments = append(append([]string{}, rec.Comments...), "Review "+rv.Sente.String(), "Review "+rv.Gote.String())
+	for i, m := range rv.Moves {
+		if i >= len(out.Moves) {
+			break
+		}
+		if m.Class != Good {
+			comment := fmt.Sprintf("%s (%s → %s), best %s", m.Class, formatEval(m.Before), formatEval(m.After), engine.FormatPV(b, m.PV))
+			if c := out.Moves[i].Comment; c != "" {
+				comment = c + "\n" + comment
+			}
+			out.Moves[i].Comment = comment
+		}
+		mo, err := b.ResolveUSIMove(m.USI)
+		if err != nil {
+			return kifu.Record{}, fmt.Errorf("review: move %d: %w", i+1, err)
+		}
+		if err := b.ProcessMove(&mo); err != nil {
+			return kifu.Record{}, fmt.Errorf("review: move %d: %w", i+1, err)
+		}
+	}
+	return out, nil
+}
+
+// formatEval writes an evaluation in pawns, or as a mate.
+func formatEval(cp int) string {
+	if cp > maxEval || cp < -maxEval {
+		if cp > 0 {
+			return "mate"
+		}
+		return "mated"
+	}
+	return fmt.Sprintf("%+.2f", float64(cp)/100)
+}
+
+// Flagged returns the moves that weren't good, as lines such as "12. 8h2b+ Blunder (+0.30 → -9.00), best 7g7f".
+func (rv Review) Flagged() []string {
+	lines := []string{}
+	for _, m := range rv.Moves {
+		if m.Class == Good {
+			continue
+		}
+		line := fmt.Sprintf("%d. %s %s (%s → %s)", m.Ply, m.USI, m.Class, formatEval(m.Before), formatEval(m.After))
+		if m.Best != "" {
+			line += ", best " + strings.Join(m.PV, " ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,155 @@
+package review_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/engine"
+	"github.com/juanpablocruz/shogo/clientr/internal/kifu"
+	"github.com/juanpablocruz/shogo/clientr/internal/review"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// scriptedEngine reads the commands a GUIEngine sends to api and answers every search with the
+// score and best move scripted for the number of moves of the position.
+type scriptedEngine struct {
+	api    engine.ServerLocalEngine
+	scores []int
+	best   []string
+	// options receives the setoption commands, if set.
+	options chan string
+}
+
+func (s scriptedEngine) run(ctx context.Context) {
+	moves := 0
+	for {
+		var cmd string
+		select {
+		case cmd = <-s.api.GUICh:
+		case <-ctx.Done():
+			return
+		}
+		switch {
+		case cmd == "usi":
+			s.api.EngineCh <- "id name scripted"
+			s.api.EngineCh <- "usiok"
+		case strings.HasPrefix(cmd, "setoption") && s.options != nil:
+			s.options <- cmd
+		case cmd == "isready":
+			s.api.EngineCh <- "readyok"
+		case strings.HasPrefix(cmd, "position"):
+			moves = 0
+			if _, after, ok := strings.Cut(cmd, " moves "); ok {
+				moves = len(strings.Fields(after))
+			}
+		case strings.HasPrefix(cmd, "go"):
+			s.api.EngineCh <- fmt.Sprintf("info depth 10 score cp %d pv %s", s.scores[moves], s.best[moves])
+			s.api.EngineCh <- "bestmove " + s.best[moves]
+		}
+	}
+}
+
+func TestReviewer_Review(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	api := engine.ServerLocalEngine{EngineCh: make(chan string, 8), GUICh: make(chan string, 8)}
+	options := make(chan string, 3)
+	go scriptedEngine{
+		api: api,
+		// Evaluations of the side to move after each ply.
+		scores:  []int{50, -50, 60, 900, -700},
+		best:    []string{"7g7f", "3c3d", "6g6f", "8c8d", "2f2e"},
+		options: options,
+	}.run(ctx)
+
+	rec := kifu.NewRecord("alice", "bob")
+	for _, m := range []string{"7g7f", "3c3d", "2g2f", "4a3b"} {
+		rec.Moves = append(rec.Moves, kifu.Move{USI: m})
+	}
+	r := review.New(engine.NewGUIEngine(api), review.WithSearch(engine.GoParams{Depth: 10}),
+		review.WithOptions(map[string]string{"USI_Hash": "256", "MultiPV": "1", "Threads": "4"}))
+	rv, err := r.Review(ctx, rec)
+	if err != nil {
+		t.Fatalf("Review() failed: %v", err)
+	}
+	for _, want := range []string{"MultiPV", "Threads", "USI_Hash"} {
+		if got := <-options; !strings.HasPrefix(got, "setoption name "+want+" ") {
+			t.Errorf("Review() sent %q, want the option %s", got, want)
+		}
+	}
+
+	tests := []struct {
+		name   string // description of this test case
+		ply    int
+		before int
+		after  int
+		loss   int
+		class  review.Class
+	}{
+		{name: "engine move", ply: 1, before: 50, after: 50, loss: 0, class: review.Good},
+		{name: "engine reply", ply: 2, before: -50, after: -60, loss: 0, class: review.Good},
+		{name: "blunder", ply: 3, before: 60, after: -900, loss: 960, class: review.Blunder},
+		{name: "inaccuracy", ply: 4, before: 900, after: 700, loss: 200, class: review.Inaccuracy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := rv.Moves[tt.ply-1]
+			if m.Before != tt.before || m.After != tt.after || m.Loss != tt.loss || m.Class != tt.class {
+				t.Errorf("Moves[%d] = %+v, want %d → %d, loss %d, %s", tt.ply-1, m, tt.before, tt.after, tt.loss, tt.class)
+			}
+		})
+	}
+
+	if rv.Sente.Blunders != 1 || rv.Sente.Moves != 2 || rv.Sente.AverageLoss != 480 {
+		t.Errorf("Sente = %+v, want 1 blunder in 2 moves, average loss 480", rv.Sente)
+	}
+	if rv.Gote.Inaccuracies != 1 || rv.Gote.Blunders != 0 {
+		t.Errorf("Gote = %+v, want 1 inaccuracy", rv.Gote)
+	}
+	if rv.Sente.Accuracy >= rv.Gote.Accuracy || rv.Gote.Accuracy >= 100 {
+		t.Errorf("Accuracy = %.1f and %.1f, want sente below gote below 100", rv.Sente.Accuracy, rv.Gote.Accuracy)
+	}
+
+	annotated, err := rv.Annotate(rec)
+	if err != nil {
+		t.Fatalf("Annotate() failed: %v", err)
+	}
+	if c := annotated.Moves[2].Comment; !strings.HasPrefix(c, "Blunder (+0.60 → -9.00), best ") {
+		t.Errorf("Annotate() comment = %q, want the blunder and the best line", c)
+	}
+	if annotated.Moves[0].Comment != "" || rec.Moves[2].Comment != "" {
+		t.Errorf("Annotate() commented a good move or changed the record")
+	}
+	if len(annotated.Comments) != 2 || !strings.HasPrefix(annotated.Comments[0], "Review alice: accuracy") {
+		t.Errorf("Annotate() comments = %v, want the summary of both players", annotated.Comments)
+	}
+	if got := rv.Flagged(); len(got) != 2 || got[0] != "3. 2g2f Blunder (+0.60 → -9.00), best 6g6f" {
+		t.Errorf("Flagged() = %q", got)
+	}
+}
+
+func TestReviewer_Review_builtin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	le := engine.NewLocalEngine(shogi.NewGame("sente", "gote"))
+	go le.Run(ctx)
+
+	// Gote doesn't recapture the horse after the bishop exchange.
+	rec := kifu.NewRecord("sente", "gote")
+	for _, m := range []string{"7g7f", "3c3d", "8h2b+", "4a3b"} {
+		rec.Moves = append(rec.Moves, kifu.Move{USI: m})
+	}
+	rv, err := review.New(engine.NewGUIEngine(le)).Review(ctx, rec)
+	if err != nil {
+		t.Fatalf("Review() failed: %v", err)
+	}
+	if len(rv.Moves) != 4 {
+		t.Fatalf("Review() = %d moves, want 4", len(rv.Moves))
+	}
+	if m := rv.Moves[3]; m.Class != review.Blunder {
+		t.Errorf("Moves[3] = %+v, want not recapturing the horse a blunder", m)
+	}
+}
@@ -0,0 +1,250 @@
+// Package save keeps games on disk, to resume them later.
+package save
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// ErrNotFound is returned when there is no save with the name asked for.
+var ErrNotFound = errors.New("save: not found")
+
+// Clock is the state of the clock of a saved game.
+type Clock struct {
+	// TimeControl is read by shogi.ParseTimeControl.
+	TimeControl string `json:"time_control"`
+	// Sente and Gote are the time left to each player.
+	Sente Side `json:"sente"`
+	Gote  Side `json:"gote"`
+}
+
+// Side is the time left to a player of a saved game, byoyomi included.
+type Side struct {
+	Main time.Duration `json:"main"`
+	// Period is the time left in the current byoyomi period.
+	Period time.Duration `json:"period,omitempty"`
+	// Periods is the number of byoyomi periods left, the current one included.
+	Periods int `json:"periods,omitempty"`
+	// Moves is the number of moves left to play in the current Canadian period.
+	Moves int `json:"moves,omitempty"`
+}
+
+func newSide(s shogi.ClockState) Side {
+	return Side{Main: s.Main, Period: s.Period, Periods: s.Periods, Moves: s.Moves}
+}
+
+func (s Side) state() shogi.ClockState {
+	return shogi.ClockState{Main: s.Main, Period: s.Period, Periods: s.Periods, Moves: s.Moves}
+}
+
+// Game is a saved game.
+type Game struct {
+	Name    string    `json:"name"`
+	SavedAt time.Time `json:"saved_at"`
+	Sente   string    `json:"sente"`
+	Gote    string    `json:"gote"`
+	// StartPosition is the SFEN the game started from.
+	StartPosition string `json:"start_position"`
+	// Moves are the moves played in USI notation.
+	Moves   []string      `json:"moves"`
+	Outcome shogi.Outcome `json:"outcome"`
+	Clock   *Clock        `json:"clock,omitempty"`
+	// Agent is the provider of the AI agent the game was played with, e.g. claude or openai, set and
+	// restored by the caller of New and Restore.
+	Agent string `json:"agent,omitempty"`
+	// Hint is the hint of the agent waiting to be played or dismissed.
+	Hint string `json:"hint,omitempty"`
+}
+
+// New returns the save of g named name, hint is the pending hint of the agent.
+func New(name string, g *shogi.Game, hint string) Game {
+	s := Game{
+		Name:          name,
+		SavedAt:       time.Now(),
+		Sente:         g.SentePlayer(),
+		Gote:          g.GotePlayer(),
+		StartPosition: g.StartPosition(),
+		Moves:         []string{},
+		Outcome:       g.Outcome(),
+		Hint:          hint,
+	}
+	for _, m := range g.Moves() {
+		s.Moves = append(s.Moves, m.USI())
+	}
+	if clock := g.Clock(); clock != nil {
+		s.Clock = &Clock{
+			TimeControl: clock.TimeControl().String(),
+			Sente:       newSide(clock.State(shogi.Black)),
+			Gote:        newSide(clock.State(shogi.White)),
+		}
+	}
+	return s
+}
+
+// Finished reports whether the game is over.
+func (s Game) Finished() bool {
+	return s.Outcome != "" && s.Outcome != shogi.NoOutcome
+}
+
+// Restore returns the saved game, played with ai, the agent of the provider Agent. The clock runs
+// for the side to move.
+func (s Game) Restore(ai agent.Agent) (*shogi.Game, error) {
+	options := []func(*shogi.Game){}
+	if s.Clock != nil {
+		tc, err := shogi.ParseTimeControl(s.Clock.TimeControl)
+		if err != nil {
+			return nil, fmt.Errorf("save: %s: %w", s.Name, err)
+		}
+		options = append(options, shogi.WithClock(tc))
+	}
+	g := shogi.NewGame(s.Sente, s.Gote, options...)
+	b := shogi.NewBoard()
+	position := s.StartPosition
+	if position == "" {
+		position = shogi.StartingPosition
+	}
+	if err := b.LoadSfen(position); err != nil {
+		return nil, fmt.Errorf("save: %s: %w", s.Name, err)
+	}
+	g.SetBoard(&b)
+	for i, usi := range s.Moves {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			return nil, fmt.Errorf("save: %s: move %d: %w", s.Name, i+1, err)
+		}
+		if err := g.Move(m); err != nil {
+			return nil, fmt.Errorf("save: %s: move %d: %w", s.Name, i+1, err)
+		}
+	}
+	if clock := g.Clock(); clock != nil {
+		clock.SetState(shogi.Black, s.Clock.Sente.state())
+		clock.SetState(shogi.White, s.Clock.Gote.state())
+	}
+	if s.Finished() {
+		g.End(s.Outcome)
+	}
+	g.SetAIClient(ai)
+	return g, nil
+}
+
+// Store keeps saves as JSON files in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store in dir, created on the first save.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir returns the directory saves are kept in by default, shogo/saves in the user's
+// config directory.
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".shogo", "saves")
+	}
+	return filepath.Join(dir, "shogo", "saves")
+}
+
+// Dir returns the directory of the store.
+func (st *Store) Dir() string {
+	return st.dir
+}
+
+func (st *Store) path(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("save: invalid name %q", name)
+	}
+	return filepath.Join(st.dir, name+".json"), nil
+}
+
+// Save writes g, replacing any save with the same name.
+func (st *Store) Save(g Game) error {
+	path, err := st.path(g.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(st.dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write aside and rename so a crash never leaves a truncated save.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the save named name.
+func (st *Store) Load(name string) (Game, error) {
+	path, err := st.path(name)
+	if err != nil {
+		return Game{}, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Game{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return Game{}, err
+	}
+	var g Game
+	if err := json.Unmarshal(b, &g); err != nil {
+		return Game{}, fmt.Errorf("save: %s: %w", name, err)
+	}
+	g.Name = name
+	return g, nil
+}
+
+// List returns the saves, the most recent first.
+func (st *Store) List() ([]Game, error) {
+	entries, err := os.ReadDir(st.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	games := []Game{}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		g, err := st.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].SavedAt.After(games[j].SavedAt) })
+	return games, nil
+}
+
+// Last returns the most recent save of an unfinished game, ErrNotFound if there is none.
+func (st *Store) Last() (Game, error) {
+	games, err := st.List()
+	if err != nil {
+		return Game{}, err
+	}
+	for _, g := range games {
+		if !g.Finished() {
+			return g, nil
+		}
+	}
+	return Game{}, ErrNotFound
+}
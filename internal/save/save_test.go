@@ -0,0 +1,138 @@
+package save_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/save"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// play returns a game with a clock after the moves.
+func play(t *testing.T, moves ...string) *shogi.Game {
+	t.Helper()
+	g := shogi.NewGame("habu", "fujii", shogi.WithClock(shogi.TimeControl{Kind: shogi.Byoyomi, Main: 10 * time.Minute, Byoyomi: 30 * time.Second, Periods: 1}))
+	for _, usi := range moves {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+		}
+		if err := g.Move(m); err != nil {
+			t.Fatalf("Move(%s) failed: %v", usi, err)
+		}
+	}
+	return g
+}
+
+func TestStore(t *testing.T) {
+	store := save.NewStore(t.TempDir())
+	if _, err := store.Last(); !errors.Is(err, save.ErrNotFound) {
+		t.Fatalf("Last() on an empty store = %v, want ErrNotFound", err)
+	}
+
+	g := play(t, "7g7f", "3c3d", "8h2b+")
+	g.Clock().Set(shogi.White, 7*time.Minute)
+	unfinished := save.New("club", g, "3a2b")
+	unfinished.SavedAt = time.Now().Add(-time.Hour)
+	if err := store.Save(unfinished); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	finished := save.New("lost", play(t, "7g7f"), "")
+	finished.Outcome = shogi.WhiteWon
+	if err := store.Save(finished); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	games, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(games) != 2 || games[0].Name != "lost" || games[1].Name != "club" {
+		t.Fatalf("List() = %+v, want lost then club", games)
+	}
+	last, err := store.Last()
+	if err != nil || last.Name != "club" {
+		t.Fatalf("Last() = %+v, %v, want club", last, err)
+	}
+
+	loaded, err := store.Load("club")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if loaded.Hint != "3a2b" || loaded.Clock == nil || loaded.Clock.Gote.Main > 7*time.Minute || loaded.Clock.Gote.Main < 7*time.Minute-time.Second {
+		t.Errorf("Load() = %+v", loaded)
+	}
+	r, err := loaded.Restore(nil)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if r.Board().String() != g.Board().String() || len(r.Moves()) != 3 || r.SentePlayer() != "habu" {
+		t.Errorf("Restore() = %s, want %s", r.Board().String(), g.Board().String())
+	}
+	if r.Clock().TimeControl().String() != g.Clock().TimeControl().String() || r.Clock().Remaining(shogi.White) > 7*time.Minute || r.Clock().Turn() != shogi.White {
+		t.Errorf("Restore() clock = %v %v", r.Clock().TimeControl(), r.Clock().Remaining(shogi.White))
+	}
+
+	lost, err := store.Load("lost")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if r, err := lost.Restore(nil); err != nil || r.Outcome() != shogi.WhiteWon {
+		t.Errorf("Restore() of a finished game = %v, %v", r.Outcome(), err)
+	}
+
+	if _, err := store.Load("missing"); !errors.Is(err, save.ErrNotFound) {
+		t.Errorf("Load(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_invalid_names(t *testing.T) {
+	store := save.NewStore(t.TempDir())
+	for _, name := range []string{"", "..", "../escape", `a\b`} {
+		if err := store.Save(save.New(name, play(t), "")); err == nil {
+			t.Errorf("Save(%q) succeeded unexpectedly", name)
+		}
+	}
+}
+
+func TestGame_Restore_clock(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		tc   shogi.TimeControl
+		// state is the time left to gote, who waits for sente to move.
+		state shogi.ClockState
+	}{
+		{
+			name:  "byoyomi periods",
+			tc:    shogi.TimeControl{Kind: shogi.Byoyomi, Main: 10 * time.Minute, Byoyomi: 30 * time.Second, Periods: 3},
+			state: shogi.ClockState{Period: 30 * time.Second, Periods: 2},
+		},
+		{
+			name:  "canadian moves",
+			tc:    shogi.TimeControl{Kind: shogi.Canadian, Main: 10 * time.Minute, Byoyomi: 5 * time.Minute, Moves: 20},
+			state: shogi.ClockState{Period: 3 * time.Minute, Moves: 12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := shogi.NewGame("habu", "fujii", shogi.WithClock(tt.tc))
+			g.Clock().SetState(shogi.White, tt.state)
+			store := save.NewStore(t.TempDir())
+			if err := store.Save(save.New("club", g, "")); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			loaded, err := store.Load("club")
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			r, err := loaded.Restore(nil)
+			if err != nil {
+				t.Fatalf("Restore() failed: %v", err)
+			}
+			if got := r.Clock().State(shogi.White); got != tt.state {
+				t.Errorf("Restore() clock of gote = %+v, want %+v", got, tt.state)
+			}
+		})
+	}
+}
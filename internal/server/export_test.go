@@ -0,0 +1,8 @@
+package server
+
+// Len returns the number of games and sessions the server keeps.
+func (s *Server) Len() (games, sessions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.games), len(s.sessions)
+}
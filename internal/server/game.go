@@ -0,0 +1,421 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// game is a game between two players, the server's board is the reference for both.
+type game struct {
+	mu      sync.Mutex
+	id      string
+	players [2]*player
+	// tokens let the players resume the game, away is set while a player is disconnected.
+	tokens [2]string
+	away   [2]bool
+	// forfeit fires when a player away doesn't come back in time.
+	forfeit [2]*time.Timer
+	// spectators follow the game without playing.
+	spectators []*player
+	game       *shogi.Game
+	// position is the SFEN the game started from.
+	position string
+	logger   *log.Logger
+	// drawOffer is the player who offered a draw, until the opponent moves or accepts it.
+	drawOffer *player
+	// flag fires when the side to move runs out of time.
+	flag *time.Timer
+	// done is set once the end of the game is announced, with the reason it ended.
+	done   bool
+	reason string
+}
+
+func newGame(id string, sente, gote *player, position string, tc *shogi.TimeControl, logger *log.Logger) (*game, error) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(position); err != nil {
+		return nil, err
+	}
+	options := []func(*shogi.Game){}
+	if tc != nil {
+		options = append(options, shogi.WithClock(*tc))
+	}
+	g := shogi.NewGame(sente.Name(), gote.Name(), options...)
+	g.SetBoard(&b)
+	var tokens [2]string
+	for i := range tokens {
+		var err error
+		if tokens[i], err = newToken(); err != nil {
+			return nil, err
+		}
+	}
+	return &game{
+		id:       id,
+		players:  [2]*player{sente, gote},
+		tokens:   tokens,
+		game:     g,
+		position: position,
+		logger:   logger,
+	}, nil
+}
+
+// start tells both players about the game and runs the clock.
+func (g *game) start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	tc := ""
+	if clock := g.game.Clock(); clock != nil {
+		tc = clock.TimeControl().String()
+	}
+	for color, p := range g.players {
+		p.send(protocol.Start{
+			ID:          g.id,
+			Sente:       g.game.SentePlayer(),
+			Gote:        g.game.GotePlayer(),
+			Color:       shogi.Color(color).String(),
+			Position:    g.position,
+			TimeControl: tc,
+			Token:       g.tokens[color],
+		})
+	}
+	g.logger.Printf("game %s: %s vs %s started", g.id, g.game.SentePlayer(), g.game.GotePlayer())
+	g.armFlag()
+}
+
+// over reports whether the game finished.
+func (g *game) over() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done
+}
+
+// move plays a move of p after checking it on the board.
+func (g *game) move(p *player, m protocol.Move) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		p.sendError("game is over")
+		return
+	}
+	if g.checkFlag() {
+		return
+	}
+
+	color := g.colorOf(p)
+	if g.away[color.Opponent()] {
+		p.sendError("opponent is away")
+		return
+	}
+	b := g.game.Board()
+	if b.Turn != color {
+		p.sendError("not your turn")
+		return
+	}
+	if ply := len(g.game.Moves()) + 1; m.Ply != ply {
+		p.sendError("move %s is ply %d, expecting %d", m.Move, m.Ply, ply)
+		return
+	}
+	mo, err := b.ResolveUSIMove(m.Move)
+	if err != nil || !b.IsLegal(mo) {
+		p.sendError("illegal move %s", m.Move)
+		return
+	}
+	if err := g.game.Move(mo); err != nil {
+		if !g.checkFlag() {
+			p.sendError("%v", err)
+		}
+		return
+	}
+	g.drawOffer = nil
+
+	g.players[color.Opponent()].send(m)
+	for _, s := range g.spectators {
+		s.send(m)
+	}
+	g.sendClock()
+	if g.game.Board().IsCheckmate() {
+		g.finish(shogi.Winner(color), ReasonCheckmate)
+		return
+	}
+	g.armFlag()
+}
+
+// offerDraw passes the draw offer of p to its opponent.
+func (g *game) offerDraw(p *player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+	g.drawOffer = p
+	g.players[g.colorOf(p).Opponent()].send(protocol.DrawOffer{})
+}
+
+// acceptDraw ends the game in a draw if the opponent of p offered it.
+func (g *game) acceptDraw(p *player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.drawOffer == nil || g.drawOffer == p {
+		p.sendError("no draw offered")
+		return
+	}
+	g.finish(shogi.Draw, ReasonDraw)
+}
+
+// resign finishes the game with a loss of p.
+func (g *game) resign(p *player, reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.finish(shogi.Winner(g.colorOf(p).Opponent()), reason)
+}
+
+// end finishes the game, unless it already is.
+func (g *game) end(o shogi.Outcome, reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.finish(o, reason)
+}
+
+func (g *game) finish(o shogi.Outcome, reason string) {
+	if g.done {
+		return
+	}
+	g.done = true
+	g.reason = reason
+	g.game.End(o)
+	if g.flag != nil {
+		g.flag.Stop()
+	}
+	for _, t := range g.forfeit {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	g.broadcast(protocol.GameOver{Result: o.String(), Reason: reason})
+	g.logger.Printf("game %s: %s vs %s: %s %s", g.id, g.game.SentePlayer(), g.game.GotePlayer(), o, reason)
+}
+
+// disconnect stops the clocks while p is away, the game is lost by p unless it resumes it
+// within grace.
+func (g *game) disconnect(p *player, grace time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	color := g.colorOf(p)
+	if g.done || g.players[color] != p {
+		return
+	}
+	g.away[color] = true
+	g.drawOffer = nil
+	if clock := g.game.Clock(); clock != nil {
+		clock.Stop()
+	}
+	if g.flag != nil {
+		g.flag.Stop()
+		g.flag = nil
+	}
+	g.broadcast(protocol.Presence{Name: p.Name(), Grace: grace.Milliseconds()})
+	g.logger.Printf("game %s: %s lost the connection", g.id, p.Name())
+
+	var t *time.Timer
+	t = time.AfterFunc(grace, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.forfeit[color] == t {
+			g.finish(shogi.Winner(color.Opponent()), ReasonDisconnect)
+		}
+	})
+	g.forfeit[color] = t
+}
+
+// reconnect puts p in the place of the player away with token, and sends it the game so far.
+func (g *game) reconnect(p *player, token string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return errors.New("game is over")
+	}
+	color := shogi.Black
+	if g.tokens[shogi.White] == token {
+		color = shogi.White
+	}
+	if !g.away[color] {
+		return errors.New("player is still connected")
+	}
+	g.forfeit[color].Stop()
+	g.forfeit[color] = nil
+	g.away[color] = false
+	name := g.players[color].Name()
+	p.SetName(name)
+	g.players[color] = p
+
+	h := g.history()
+	h.Color = color.String()
+	h.Token = token
+	p.send(h)
+	g.logger.Printf("game %s: %s is back", g.id, name)
+	for _, o := range append([]*player{g.players[color.Opponent()]}, g.spectators...) {
+		o.send(protocol.Presence{Name: name, Connected: true})
+	}
+
+	if g.away[color.Opponent()] {
+		return nil
+	}
+	if clock := g.game.Clock(); clock != nil {
+		clock.Start(g.game.Board().Turn)
+		g.sendClock()
+	}
+	g.armFlag()
+	return nil
+}
+
+// info describes the game for the list of games in progress.
+func (g *game) info() protocol.GameInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return protocol.GameInfo{
+		ID:    g.id,
+		Sente: g.game.SentePlayer(),
+		Gote:  g.game.GotePlayer(),
+		Ply:   len(g.game.Moves()),
+	}
+}
+
+// watch sends the game so far to p, then keeps p posted like the players.
+func (g *game) watch(p *player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p.send(g.history())
+	if clock := g.game.Clock(); clock != nil {
+		p.send(g.clock())
+	}
+	if g.done {
+		p.send(protocol.GameOver{Result: g.game.Outcome().String(), Reason: g.reason})
+		return
+	}
+	g.spectators = append(g.spectators, p)
+}
+
+// history returns the game so far.
+func (g *game) history() protocol.History {
+	h := protocol.History{
+		ID:       g.id,
+		Sente:    g.game.SentePlayer(),
+		Gote:     g.game.GotePlayer(),
+		Position: g.position,
+		Moves:    []string{},
+	}
+	if clock := g.game.Clock(); clock != nil {
+		h.TimeControl = clock.TimeControl().String()
+	}
+	for _, m := range g.game.Moves() {
+		h.Moves = append(h.Moves, m.USI())
+	}
+	return h
+}
+
+// unwatch stops sending the game to p.
+func (g *game) unwatch(p *player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, s := range g.spectators {
+		if s == p {
+			g.spectators = append(g.spectators[:i], g.spectators[i+1:]...)
+			return
+		}
+	}
+}
+
+// checkFlag finishes the game if the flag of the side to move fell, it reports whether it did.
+func (g *game) checkFlag() bool {
+	clock := g.game.Clock()
+	if clock == nil {
+		return false
+	}
+	loser, flagged := clock.Flagged()
+	if !flagged {
+		return false
+	}
+	g.finish(shogi.Winner(loser.Opponent()), ReasonTimeUp)
+	return true
+}
+
+// armFlag checks the clock again when the side to move would run out of time.
+func (g *game) armFlag() {
+	clock := g.game.Clock()
+	if clock == nil {
+		return
+	}
+	if g.flag != nil {
+		g.flag.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(clock.TimeLeft(clock.Turn()), func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.flag != t || g.done {
+			return
+		}
+		if !g.checkFlag() {
+			g.armFlag()
+		}
+	})
+	g.flag = t
+}
+
+// sendClock sends the time left of both players to both.
+func (g *game) sendClock() {
+	clock := g.game.Clock()
+	if clock == nil {
+		return
+	}
+	g.broadcast(g.clock())
+}
+
+// clock returns the time left of both players, the game must have a clock.
+func (g *game) clock() protocol.Clock {
+	clock := g.game.Clock()
+	return protocol.Clock{
+		Sente: clock.Remaining(shogi.Black).Milliseconds(),
+		Gote:  clock.Remaining(shogi.White).Milliseconds(),
+	}
+}
+
+// newToken returns a random session token.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// colorOf returns the side p plays.
+func (g *game) colorOf(p *player) shogi.Color {
+	if g.players[shogi.White] == p {
+		return shogi.White
+	}
+	return shogi.Black
+}
+
+// chat sends a chat message to both players and the spectators.
+func (g *game) chat(c protocol.Chat) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.broadcast(c)
+}
+
+// broadcast sends payload to both players and the spectators, g.mu must be held.
+func (g *game) broadcast(payload protocol.Payload) {
+	for _, p := range g.players {
+		p.send(payload)
+	}
+	for _, p := range g.spectators {
+		p.send(payload)
+	}
+}
@@ -0,0 +1,414 @@
+// Package server hosts shogo games: players join a lobby, are paired two by two and play on a
+// board kept by the server, which validates every move and runs the clocks. Spectators can
+// follow any game in progress, and players who lose the connection can resume their game.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// Reasons a game ends with, sent in protocol.GameOver.
+const (
+	ReasonResign     = "resign"
+	ReasonCheckmate  = "checkmate"
+	ReasonTimeUp     = "time_up"
+	ReasonDraw       = "draw"
+	ReasonDisconnect = "disconnect"
+)
+
+// Server pairs the players connected to it and referees their games.
+type Server struct {
+	timeControl *shogi.TimeControl
+	position    string
+	logger      *log.Logger
+	grace       time.Duration
+
+	mu sync.Mutex
+	// waiting is the player in the lobby waiting for an opponent.
+	waiting *player
+	// games are the games in progress by ID.
+	games  map[string]*game
+	lastID int
+	// sessions are the games in progress by the tokens of their players.
+	sessions map[string]*game
+}
+
+// WithTimeControl plays every game with a clock of tc, games have no clock by default.
+func WithTimeControl(tc shogi.TimeControl) func(*Server) {
+	return func(s *Server) {
+		s.timeControl = &tc
+	}
+}
+
+// WithPosition sets the SFEN games start from, the starting position by default.
+func WithPosition(sfen string) func(*Server) {
+	return func(s *Server) {
+		s.position = sfen
+	}
+}
+
+// WithLogger sets where the server logs connections and games, nowhere by default.
+func WithLogger(l *log.Logger) func(*Server) {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithReconnectGrace keeps the game of a player who lost the connection for d, with the clocks
+// stopped, so it can resume it. By default losing the connection loses the game.
+func WithReconnectGrace(d time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.grace = d
+	}
+}
+
+// New returns a server, options configure the games it hosts.
+func New(options ...func(*Server)) *Server {
+	s := &Server{
+		position: shogi.StartingPosition,
+		logger:   log.New(io.Discard, "", 0),
+		games:    map[string]*game{},
+		sessions: map[string]*game{},
+	}
+	for _, f := range options {
+		f(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the TCP address and serves the connections until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, address string) error {
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve accepts connections on l until ctx is done, l is closed when it returns.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	s.logger.Printf("listening on %s", l.Addr())
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// outboxSize is the number of messages queued for a player, a player who doesn't read them in
+// time is disconnected.
+const outboxSize = 64
+
+// player is a connection to the server.
+type player struct {
+	conn *protocol.Conn
+	// closer closes the connection of the player.
+	closer io.Closer
+	// outbox queues the messages to the player, written by writeLoop so the server never writes
+	// to a connection while holding a lock.
+	outbox chan protocol.Payload
+
+	// mu guards name, set by join and resume while games and spectators read it.
+	mu   sync.Mutex
+	name string
+	// game is the game the player is in, nil while in the lobby.
+	game *game
+	// watching is the game the connection follows as a spectator.
+	watching *game
+}
+
+func newPlayer(conn net.Conn) *player {
+	return &player{
+		conn:   protocol.NewConn(conn),
+		closer: conn,
+		outbox: make(chan protocol.Payload, outboxSize),
+	}
+}
+
+// Name returns the name the player joined with.
+func (p *player) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.name
+}
+
+// SetName sets the name of the player.
+func (p *player) SetName(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.name = name
+}
+
+// send queues payload for the player, it never blocks.
+func (p *player) send(payload protocol.Payload) {
+	select {
+	case p.outbox <- payload:
+	default:
+		// The player doesn't keep up, closing the connection ends its read loop.
+		_ = p.closer.Close()
+	}
+}
+
+func (p *player) sendError(format string, args ...interface{}) {
+	p.send(protocol.Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// writeLoop writes the messages queued for the player until done is closed, then the ones left.
+func (p *player) writeLoop(done <-chan struct{}) {
+	for {
+		select {
+		case payload := <-p.outbox:
+			// A failed send shows up as a read error on the connection of the player.
+			_ = p.conn.Send(payload)
+		case <-done:
+			for {
+				select {
+				case payload := <-p.outbox:
+					_ = p.conn.Send(payload)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	p := newPlayer(conn)
+	done, written := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(written)
+		p.writeLoop(done)
+	}()
+	s.logger.Printf("%s connected", conn.RemoteAddr())
+	for {
+		payload, err := p.conn.Receive()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logger.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+			break
+		}
+		s.handle(p, payload)
+	}
+	s.leave(p)
+	close(done)
+	<-written
+	s.logger.Printf("%s disconnected", conn.RemoteAddr())
+}
+
+// handle processes a message of p.
+func (s *Server) handle(p *player, payload protocol.Payload) {
+	switch payload := payload.(type) {
+	case protocol.Join:
+		s.join(p, payload)
+		return
+	case protocol.Watch:
+		s.watch(p, payload)
+		return
+	case protocol.Resume:
+		s.resume(p, payload)
+		return
+	}
+
+	s.mu.Lock()
+	g := p.game
+	s.mu.Unlock()
+	if g == nil {
+		p.sendError("%s outside of a game", payload.Action())
+		return
+	}
+
+	switch payload := payload.(type) {
+	case protocol.Move:
+		g.move(p, payload)
+	case protocol.Resign:
+		g.resign(p, ReasonResign)
+	case protocol.DrawOffer:
+		g.offerDraw(p)
+	case protocol.DrawAccept:
+		g.acceptDraw(p)
+	case protocol.Chat:
+		g.chat(protocol.Chat{From: p.Name(), Text: payload.Text})
+	default:
+		p.sendError("unexpected %s", payload.Action())
+	}
+}
+
+// join puts p in the lobby, or starts a game if another player is waiting.
+func (s *Server) join(p *player, j protocol.Join) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if p.game != nil && !p.game.over() {
+		p.sendError("already playing")
+		return
+	}
+	if s.waiting == p {
+		return
+	}
+	if p.watching != nil {
+		p.watching.unwatch(p)
+		p.watching = nil
+	}
+	name := j.Name
+	if name == "" {
+		name = "anonymous"
+	}
+	p.SetName(name)
+	p.game = nil
+
+	if s.waiting == nil {
+		s.waiting = p
+		s.logger.Printf("%s is waiting for an opponent", name)
+		return
+	}
+	sente, gote := s.waiting, p
+	s.waiting = nil
+
+	s.lastID++
+	id := strconv.Itoa(s.lastID)
+	g, err := newGame(id, sente, gote, s.position, s.timeControl, s.logger)
+	if err != nil {
+		s.logger.Printf("starting %s vs %s: %v", sente.Name(), gote.Name(), err)
+		sente.sendError("couldn't start the game: %v", err)
+		gote.sendError("couldn't start the game: %v", err)
+		return
+	}
+	sente.game, gote.game = g, g
+	s.games[id] = g
+	for _, token := range g.tokens {
+		s.sessions[token] = g
+	}
+	g.start()
+}
+
+// prune forgets the games that are over, s.mu must be held.
+func (s *Server) prune() {
+	for id, g := range s.games {
+		if g.over() {
+			delete(s.games, id)
+		}
+	}
+	for token, g := range s.sessions {
+		if g.over() {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// watch adds p to the spectators of a game, or lists the games in progress.
+func (s *Server) watch(p *player, w protocol.Watch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+
+	if w.GameID == "" {
+		list := protocol.Games{Games: []protocol.GameInfo{}}
+		for _, g := range s.games {
+			list.Games = append(list.Games, g.info())
+		}
+		sort.Slice(list.Games, func(i, j int) bool {
+			a, b := list.Games[i].ID, list.Games[j].ID
+			return len(a) < len(b) || len(a) == len(b) && a < b
+		})
+		p.send(list)
+		return
+	}
+
+	if p.game != nil && !p.game.over() {
+		p.sendError("already playing")
+		return
+	}
+	g, ok := s.games[w.GameID]
+	if !ok {
+		p.sendError("no game %s in progress", w.GameID)
+		return
+	}
+	if p.watching != nil {
+		p.watching.unwatch(p)
+	}
+	p.watching = g
+	g.watch(p)
+}
+
+// resume puts p in the place of the player of the game r.Token was given to.
+func (s *Server) resume(p *player, r protocol.Resume) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if p.game != nil && !p.game.over() {
+		p.sendError("already playing")
+		return
+	}
+	g, ok := s.sessions[r.Token]
+	if !ok {
+		p.sendError("no game to resume")
+		return
+	}
+	if s.waiting == p {
+		s.waiting = nil
+	}
+	if p.watching != nil {
+		p.watching.unwatch(p)
+		p.watching = nil
+	}
+	if err := g.reconnect(p, r.Token); err != nil {
+		p.sendError("%v", err)
+		return
+	}
+	p.game = g
+}
+
+// leave removes p from the lobby. A game in progress is lost by p, unless it is kept for p to
+// resume it.
+func (s *Server) leave(p *player) {
+	s.mu.Lock()
+	if s.waiting == p {
+		s.waiting = nil
+	}
+	g := p.game
+	if p.watching != nil {
+		p.watching.unwatch(p)
+		p.watching = nil
+	}
+	s.mu.Unlock()
+	switch {
+	case g == nil:
+	case s.grace > 0:
+		g.disconnect(p, s.grace)
+	default:
+		g.resign(p, ReasonDisconnect)
+	}
+}
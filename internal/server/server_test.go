@@ -0,0 +1,368 @@
+package server_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/protocol"
+	"github.com/juanpablocruz/shogo/clientr/internal/server"
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// testClient is a raw connection to the server under test.
+type testClient struct {
+	t    *testing.T
+	raw  net.Conn
+	conn *protocol.Conn
+}
+
+func (c testClient) send(p protocol.Payload) {
+	c.t.Helper()
+	if err := c.conn.Send(p); err != nil {
+		c.t.Fatalf("Send(%#v) failed: %v", p, err)
+	}
+}
+
+func (c testClient) receive() protocol.Payload {
+	c.t.Helper()
+	c.raw.SetReadDeadline(time.Now().Add(2 * time.Second))
+	p, err := c.conn.Receive()
+	if err != nil {
+		c.t.Fatalf("Receive() failed: %v", err)
+	}
+	return p
+}
+
+// expect reads the next message and checks it is want.
+func (c testClient) expect(want protocol.Payload) {
+	c.t.Helper()
+	if got := c.receive(); got != want {
+		c.t.Fatalf("Receive() = %#v, want %#v", got, want)
+	}
+}
+
+// expectError reads the next message and checks it is an error.
+func (c testClient) expectError() {
+	c.t.Helper()
+	if got := c.receive(); got.Action() != protocol.ActionError {
+		c.t.Fatalf("Receive() = %#v, want an error", got)
+	}
+}
+
+// startServer runs a server on a free port for the duration of the test.
+func startServer(t *testing.T, options ...func(*server.Server)) string {
+	t.Helper()
+	return serve(t, server.New(options...))
+}
+
+// serve runs s on a free port for the duration of the test.
+func serve(t *testing.T, s *server.Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.Serve(ctx, l); err != nil {
+			t.Errorf("Serve() failed: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, address string) testClient {
+	t.Helper()
+	raw, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	t.Cleanup(func() { raw.Close() })
+	return testClient{t: t, raw: raw, conn: protocol.NewConn(raw)}
+}
+
+// pair joins two players to the server, sente joins first.
+func pair(t *testing.T, address string) (testClient, testClient) {
+	t.Helper()
+	sente, gote, _ := pairStarts(t, address)
+	return sente, gote
+}
+
+// pairStarts is pair, also returning the start of the game received by sente and gote.
+func pairStarts(t *testing.T, address string) (testClient, testClient, [2]protocol.Start) {
+	t.Helper()
+	sente, gote := dial(t, address), dial(t, address)
+	sente.send(protocol.Join{Name: "sente"})
+	// Make sure sente is in the lobby before gote joins.
+	sente.send(protocol.Resign{})
+	sente.expectError()
+	gote.send(protocol.Join{Name: "gote"})
+
+	var starts [2]protocol.Start
+	for i, c := range []struct {
+		client testClient
+		color  string
+	}{{sente, "b"}, {gote, "w"}} {
+		start, ok := c.client.receive().(protocol.Start)
+		if !ok || start.Sente != "sente" || start.Gote != "gote" || start.Color != c.color || start.Token == "" {
+			t.Fatalf("Receive() = %#v, want start as %s", start, c.color)
+		}
+		starts[i] = start
+	}
+	return sente, gote, starts
+}
+
+func TestServer_resign(t *testing.T) {
+	address := startServer(t)
+	sente, gote := pair(t, address)
+
+	moves := []string{"7g7f", "3c3d", "8h2b+"}
+	for i, m := range moves {
+		mover, opponent := sente, gote
+		if i%2 == 1 {
+			mover, opponent = gote, sente
+		}
+		mover.send(protocol.Move{Move: m, Ply: i + 1})
+		opponent.expect(protocol.Move{Move: m, Ply: i + 1})
+	}
+
+	gote.send(protocol.Resign{})
+	want := protocol.GameOver{Result: shogi.BlackWon.String(), Reason: server.ReasonResign}
+	sente.expect(want)
+	gote.expect(want)
+}
+
+func TestServer_forgets_finished_games(t *testing.T) {
+	s := server.New()
+	address := serve(t, s)
+
+	want := protocol.GameOver{Result: shogi.BlackWon.String(), Reason: server.ReasonResign}
+	for range 10 {
+		sente, gote := pair(t, address)
+		gote.send(protocol.Resign{})
+		sente.expect(want)
+		gote.expect(want)
+	}
+
+	c := dial(t, address)
+	c.send(protocol.Join{Name: "late"})
+	c.send(protocol.Resign{})
+	c.expectError()
+	if games, sessions := s.Len(); games != 0 || sessions != 0 {
+		t.Errorf("Len() = %d games, %d sessions, want none after every game finished", games, sessions)
+	}
+}
+
+func TestServer_checkmate(t *testing.T) {
+	address := startServer(t, server.WithPosition("4k4/9/4G4/9/9/9/9/9/4K4 b G 1"))
+	sente, gote := pair(t, address)
+
+	sente.send(protocol.Move{Move: "G*5b", Ply: 1})
+	gote.expect(protocol.Move{Move: "G*5b", Ply: 1})
+	want := protocol.GameOver{Result: shogi.BlackWon.String(), Reason: server.ReasonCheckmate}
+	sente.expect(want)
+	gote.expect(want)
+}
+
+func TestServer_rejects_moves(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		gote bool
+		move protocol.Move
+	}{
+		{
+			name: "not your turn",
+			gote: true,
+			move: protocol.Move{Move: "3c3d", Ply: 1},
+		},
+		{
+			name: "illegal move",
+			move: protocol.Move{Move: "7g7e", Ply: 1},
+		},
+		{
+			name: "wrong ply",
+			move: protocol.Move{Move: "7g7f", Ply: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startServer(t)
+			sente, gote := pair(t, address)
+			mover := sente
+			if tt.gote {
+				mover = gote
+			}
+			mover.send(tt.move)
+			mover.expectError()
+
+			// The game goes on from the same position.
+			sente.send(protocol.Move{Move: "7g7f", Ply: 1})
+			gote.expect(protocol.Move{Move: "7g7f", Ply: 1})
+		})
+	}
+}
+
+func TestServer_draw(t *testing.T) {
+	address := startServer(t)
+	sente, gote := pair(t, address)
+
+	gote.send(protocol.DrawAccept{})
+	gote.expectError()
+
+	sente.send(protocol.DrawOffer{})
+	gote.expect(protocol.DrawOffer{})
+	sente.send(protocol.DrawAccept{})
+	sente.expectError()
+
+	gote.send(protocol.Chat{From: "someone else", Text: "ok"})
+	sente.expect(protocol.Chat{From: "gote", Text: "ok"})
+	gote.expect(protocol.Chat{From: "gote", Text: "ok"})
+
+	gote.send(protocol.DrawAccept{})
+	want := protocol.GameOver{Result: shogi.Draw.String(), Reason: server.ReasonDraw}
+	sente.expect(want)
+	gote.expect(want)
+}
+
+func TestServer_time_up(t *testing.T) {
+	address := startServer(t, server.WithTimeControl(shogi.TimeControl{Kind: shogi.SuddenDeath, Main: 300 * time.Millisecond}))
+	sente, gote := pair(t, address)
+
+	sente.send(protocol.Move{Move: "7g7f", Ply: 1})
+	gote.expect(protocol.Move{Move: "7g7f", Ply: 1})
+	if clock, ok := sente.receive().(protocol.Clock); !ok || clock.Sente > 300 || clock.Gote > 300 || clock.Gote < 200 {
+		t.Fatalf("Receive() = %#v, want the clocks", clock)
+	}
+	gote.receive()
+
+	want := protocol.GameOver{Result: shogi.BlackWon.String(), Reason: server.ReasonTimeUp}
+	sente.expect(want)
+	gote.expect(want)
+}
+
+func TestServer_disconnect(t *testing.T) {
+	address := startServer(t)
+	sente, gote := pair(t, address)
+
+	sente.raw.Close()
+	gote.expect(protocol.GameOver{Result: shogi.WhiteWon.String(), Reason: server.ReasonDisconnect})
+
+	// A new player can pair with the one left.
+	gote.send(protocol.Join{Name: "gote"})
+	gote.send(protocol.Resign{})
+	gote.expectError()
+	other := dial(t, address)
+	other.send(protocol.Join{Name: "other"})
+	if start, ok := gote.receive().(protocol.Start); !ok || start.Gote != "other" {
+		t.Errorf("Receive() = %#v, want a new game", start)
+	}
+}
+
+func TestServer_watch(t *testing.T) {
+	address := startServer(t, server.WithTimeControl(shogi.TimeControl{Kind: shogi.SuddenDeath, Main: time.Minute}))
+	sente, gote := pair(t, address)
+	sente.send(protocol.Move{Move: "7g7f", Ply: 1})
+	gote.expect(protocol.Move{Move: "7g7f", Ply: 1})
+	sente.receive()
+	gote.receive()
+
+	spectator := dial(t, address)
+	spectator.send(protocol.Watch{GameID: "42"})
+	spectator.expectError()
+	spectator.send(protocol.Watch{})
+	if games, ok := spectator.receive().(protocol.Games); !ok || len(games.Games) != 1 ||
+		games.Games[0] != (protocol.GameInfo{ID: "1", Sente: "sente", Gote: "gote", Ply: 1}) {
+		t.Fatalf("Receive() = %#v, want the game in progress", games)
+	}
+
+	spectator.send(protocol.Watch{GameID: "1"})
+	h, ok := spectator.receive().(protocol.History)
+	if !ok || h.ID != "1" || h.TimeControl != "1m0s" || len(h.Moves) != 1 || h.Moves[0] != "7g7f" {
+		t.Fatalf("Receive() = %#v, want the history of the game", h)
+	}
+	if _, ok := spectator.receive().(protocol.Clock); !ok {
+		t.Fatalf("Receive() want the clocks")
+	}
+
+	// Spectators can't play.
+	spectator.send(protocol.Move{Move: "3c3d", Ply: 2})
+	spectator.expectError()
+
+	gote.send(protocol.Move{Move: "3c3d", Ply: 2})
+	sente.expect(protocol.Move{Move: "3c3d", Ply: 2})
+	spectator.expect(protocol.Move{Move: "3c3d", Ply: 2})
+	if _, ok := spectator.receive().(protocol.Clock); !ok {
+		t.Fatalf("Receive() want the clocks")
+	}
+
+	gote.send(protocol.Chat{Text: "gg"})
+	spectator.expect(protocol.Chat{From: "gote", Text: "gg"})
+	gote.send(protocol.Resign{})
+	spectator.expect(protocol.GameOver{Result: shogi.BlackWon.String(), Reason: server.ReasonResign})
+
+	spectator.send(protocol.Watch{GameID: "1"})
+	spectator.expectError()
+}
+
+func TestServer_resume(t *testing.T) {
+	address := startServer(t,
+		server.WithReconnectGrace(time.Minute),
+		server.WithTimeControl(shogi.TimeControl{Kind: shogi.SuddenDeath, Main: time.Minute}))
+	sente, gote, starts := pairStarts(t, address)
+	sente.send(protocol.Move{Move: "7g7f", Ply: 1})
+	gote.expect(protocol.Move{Move: "7g7f", Ply: 1})
+	sente.receive()
+	gote.receive()
+
+	gote.raw.Close()
+	if presence, ok := sente.receive().(protocol.Presence); !ok || presence.Name != "gote" || presence.Connected || presence.Grace != 60000 {
+		t.Fatalf("Receive() = %#v, want gote away", presence)
+	}
+	sente.send(protocol.DrawAccept{})
+	sente.expectError()
+	sente.send(protocol.Chat{Text: "still there?"})
+	sente.expect(protocol.Chat{From: "sente", Text: "still there?"})
+
+	other := dial(t, address)
+	other.send(protocol.Resume{Token: "not a token"})
+	other.expectError()
+	// Sente is still connected, its token can't be used.
+	other.send(protocol.Resume{Token: starts[0].Token})
+	other.expectError()
+
+	other.send(protocol.Resume{Token: starts[1].Token})
+	h, ok := other.receive().(protocol.History)
+	if !ok || h.Color != "w" || h.Token != starts[1].Token || h.Gote != "gote" || len(h.Moves) != 1 {
+		t.Fatalf("Receive() = %#v, want the game to resume as gote", h)
+	}
+	sente.expect(protocol.Presence{Name: "gote", Connected: true})
+	for _, c := range []testClient{sente, other} {
+		if clock, ok := c.receive().(protocol.Clock); !ok || clock.Gote > 60000 || clock.Gote < 59000 {
+			t.Fatalf("Receive() = %#v, want the clocks", clock)
+		}
+	}
+
+	other.send(protocol.Move{Move: "3c3d", Ply: 2})
+	sente.expect(protocol.Move{Move: "3c3d", Ply: 2})
+}
+
+func TestServer_resume_timeout(t *testing.T) {
+	address := startServer(t, server.WithReconnectGrace(100*time.Millisecond))
+	sente, gote := pair(t, address)
+
+	sente.raw.Close()
+	if presence, ok := gote.receive().(protocol.Presence); !ok || presence.Name != "sente" || presence.Connected {
+		t.Fatalf("Receive() = %#v, want sente away", presence)
+	}
+	gote.send(protocol.Move{Move: "3c3d", Ply: 1})
+	gote.expectError()
+	gote.expect(protocol.GameOver{Result: shogi.WhiteWon.String(), Reason: server.ReasonDisconnect})
+}
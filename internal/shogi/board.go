@@ -2,8 +2,11 @@ package shogi
 
 import (
 	"fmt"
+	"hash/fnv"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 // L N S G K G S N L
@@ -25,10 +28,11 @@ type Board struct {
 	Hand        Hand
 }
 
+// getAllPiecesOfType returns the pieces on the board like p, of its color and promotion.
 func (b Board) getAllPiecesOfType(p Piece) []Piece {
 	allPieces := []Piece{}
-	for _, bp := range b.Pieces[p.Color] {
-		if bp.Type == p.Type {
+	for sq := range b.BitBoard {
+		if bp, err := b.GetPieceAtSquareWithPiece(p, Square(sq)); err == nil {
 			allPieces = append(allPieces, bp)
 		}
 	}
@@ -104,25 +108,11 @@ func (b *Board) LoadSfen(sfen string) error {
 		return fmt.Errorf("shogi: error parsing sfen, expected at maximum 4 parts, got: %d (%v)", len(parts), parts)
 	}
 
-	placements := parts[0]
-
-	rankPieces := strings.Split(placements, "/")
-	if len(rankPieces) != 9 {
-		return fmt.Errorf("shogi: error parsing sfen, expected 9 ranks, got: %d (%v)", len(rankPieces), rankPieces)
-	}
-	for rank, r := range rankPieces {
-		files := strings.Split(r, "")
-		fileIdx := 0
-		for _, p := range files {
-			ws, err := strconv.Atoi(p)
-			if err != nil {
-				b.BitBoard[(rank*numOfSquaresInRow)+fileIdx] = p
-			} else {
-				fileIdx += ws - 1
-			}
-			fileIdx++
-		}
+	placements, err := parsePlacement(parts[0])
+	if err != nil {
+		return err
 	}
+	copy(b.BitBoard, placements)
 
 	turn := parts[1]
 	switch turn {
@@ -133,16 +123,70 @@ func (b *Board) LoadSfen(sfen string) error {
 	default:
 		return fmt.Errorf("shogi: error parsing sfen, expected turn to be b or w, got: %s", turn)
 	}
-	// handPieces := parts[2]
 
-	currentMovePart := parts[3]
-	if cm, err := strconv.Atoi(currentMovePart); err == nil {
-		b.CurrentMove = cm
+	hand, err := ParseHand(parts[2])
+	if err != nil {
+		return err
+	}
+	b.Hand = hand
+
+	if len(parts) == 4 {
+		if cm, err := strconv.Atoi(parts[3]); err == nil {
+			b.CurrentMove = cm
+		}
 	}
 
 	return nil
 }
 
+// parsePlacement decodes the piece placement field of a SFEN string into the 81 squares of a BitBoard.
+// Promoted pieces are prefixed with '+' and stored as a single square, e.g. +P
+func parsePlacement(placement string) ([]string, error) {
+	rankPieces := strings.Split(placement, "/")
+	if len(rankPieces) != 9 {
+		return nil, fmt.Errorf("shogi: error parsing sfen, expected 9 ranks, got: %d (%v)", len(rankPieces), rankPieces)
+	}
+
+	bb := make([]string, numOfSquaresInBoard)
+	for rank, r := range rankPieces {
+		fileIdx := 0
+		promoted := false
+		for _, c := range r {
+			if unicode.IsDigit(c) {
+				fileIdx += int(c - '0')
+				continue
+			}
+			if c == '+' {
+				promoted = true
+				continue
+			}
+			if fileIdx >= numOfSquaresInRow {
+				return nil, fmt.Errorf("shogi: error parsing sfen, rank %d has more than 9 files: %s", rank+1, r)
+			}
+			code := string(c)
+			if promoted {
+				code = "+" + code
+				promoted = false
+			}
+			bb[(rank*numOfSquaresInRow)+fileIdx] = code
+			fileIdx++
+		}
+	}
+	return bb, nil
+}
+
+// Clone returns a deep copy of the board that can be modified without affecting b.
+func (b Board) Clone() Board {
+	c := b
+	c.BitBoard = slices.Clone(b.BitBoard)
+	c.Pieces = make(map[Color][]Piece, len(b.Pieces))
+	for color, pieces := range b.Pieces {
+		c.Pieces[color] = slices.Clone(pieces)
+	}
+	c.Hand = b.Hand.Clone()
+	return c
+}
+
 func (b Board) Drop(pt PieceType, c Color, s Square) bool {
 	return false
 }
@@ -181,6 +225,9 @@ func (b Board) String() string {
 		}
 		piecePlacement += p
 	}
+	if wSpree > 0 {
+		piecePlacement = fmt.Sprintf("%s%d", piecePlacement, wSpree)
+	}
 
 	// b for Black's turn or w for White's
 	turn := b.Turn.String()
@@ -197,6 +244,20 @@ func (b Board) String() string {
 	return fmt.Sprintf("%s %s %s %d", piecePlacement, turn, handPieces, currentMove)
 }
 
+// Position returns the SFEN of the board without the move count, which is the same for every
+// occurrence of a position.
+func (b Board) Position() string {
+	s := b.String()
+	return s[:strings.LastIndex(s, " ")]
+}
+
+// Hash returns a 64-bit FNV-1a hash of the Position.
+func (b Board) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(b.Position()))
+	return h.Sum64()
+}
+
 func (b Board) GetPieceAtSquareWithPiece(p Piece, sq Square) (Piece, error) {
 	if b.BitBoard[sq] == p.String() {
 		p.Square = sq
@@ -206,17 +267,27 @@ func (b Board) GetPieceAtSquareWithPiece(p Piece, sq Square) (Piece, error) {
 }
 
 func (b Board) GetPieceAtSquare(sq Square) (Piece, error) {
-	if b.BitBoard[sq] != "" {
-		p := NewPiece(b.BitBoard[sq], false)
+	if code := b.BitBoard[sq]; code != "" {
+		p := NewPiece(strings.TrimPrefix(code, "+"), strings.HasPrefix(code, "+"))
+		p.Square = sq
 		return p, nil
 	}
 	return Piece{}, fmt.Errorf("shogi: no piece found at square (%s,%s)", sq.File().String(), sq.Rank().String())
 }
 
 func (b *Board) ProcessMove(m *Move) error {
+	if m.Type == Drop {
+		return b.processDrop(m)
+	}
+
 	var p Piece
 	var err error
-	if m.Origin == NewSquare(0, 0) {
+	if m.Piece != (Piece{}) && b.BitBoard[m.Origin] == m.Piece.String() {
+		p, err = b.GetPieceAtSquareWithPiece(m.Piece, m.Origin)
+		if err != nil {
+			return err
+		}
+	} else if m.Origin == NewSquare(0, 0) {
 		candidates := b.GetPiecesThatCanMove(*m)
 		if len(candidates) != 1 {
 			b.Debug()
@@ -235,17 +306,37 @@ func (b *Board) ProcessMove(m *Move) error {
 		}
 	}
 
+	// Captured pieces go to the hand of the capturing player, demoted.
+	if captured, err := b.GetPieceAtSquare(m.Destination); err == nil {
+		if captured.Color == p.Color {
+			return fmt.Errorf("shogi: %s can't capture own piece at %s", p.String(), m.Destination.String())
+		}
+		b.Hand.Add(p.Color, captured.Type)
+	}
+
+	if m.IsPromoting {
+		p.IsPromoted = true
+	}
+
 	b.BitBoard[m.Origin] = ""
 	b.BitBoard[m.Destination] = p.String()
 
-	var nextTurn Color
-	if p.Color == Black {
-		nextTurn = White
-	} else {
-		nextTurn = Black
+	b.NextTurn(p.Color.Opponent())
+
+	return nil
+}
+
+func (b *Board) processDrop(m *Move) error {
+	if b.BitBoard[m.Destination] != "" {
+		return fmt.Errorf("shogi: can't drop %s on occupied square %s", m.Piece.String(), m.Destination.String())
+	}
+	if err := b.Hand.Remove(m.Piece.Color, m.Piece.Type); err != nil {
+		return err
 	}
-	b.NextTurn(nextTurn)
 
+	p := Piece{Type: m.Piece.Type, Color: m.Piece.Color}
+	b.BitBoard[m.Destination] = p.String()
+	b.NextTurn(p.Color.Opponent())
 	return nil
 }
 
@@ -64,6 +64,11 @@ func TestBoard_String(t *testing.T) {
 			sfen: shogi.StartingPosition,
 			want: shogi.StartingPosition,
 		},
+		{
+			name: "empty squares at the end of the last rank",
+			sfen: "4k4/9/4P4/9/9/9/9/9/4K4 w Gp 1",
+			want: "4k4/9/4P4/9/9/9/9/9/4K4 w Gp 1",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -116,3 +121,109 @@ func TestBoard_ProcessMove(t *testing.T) {
 		})
 	}
 }
+
+func TestBoard_LoadSfen_promoted_and_hand(t *testing.T) {
+	sfen := "lnsgkgsnl/1r5+B1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL w B2Pp 6"
+	b := shogi.NewBoard()
+	if err := b.LoadSfen(sfen); err != nil {
+		t.Fatalf("LoadSfen() failed: %v", err)
+	}
+	if b.BitBoard[16] != "+B" {
+		t.Errorf("LoadSfen() failed: expecting +B at square 16, got %q", b.BitBoard[16])
+	}
+	if b.BitBoard[17] != "" {
+		t.Errorf("LoadSfen() failed: expecting empty square 17, got %q", b.BitBoard[17])
+	}
+	if b.Hand.Count(shogi.Black, shogi.Bishop) != 1 || b.Hand.Count(shogi.Black, shogi.Pawn) != 2 || b.Hand.Count(shogi.White, shogi.Pawn) != 1 {
+		t.Errorf("LoadSfen() failed: hand not loaded, got %s", b.Hand.String())
+	}
+	if b.String() != sfen {
+		t.Errorf("String() = %v, want %v", b.String(), sfen)
+	}
+}
+
+func TestBoard_ProcessMove_capture_promotion_and_drop(t *testing.T) {
+	b := shogi.NewBoard()
+	b.LoadSfen(shogi.StartingPosition)
+
+	for _, usi := range []string{"7g7f", "3c3d", "8h2b+", "3a2b", "B*4e"} {
+		m, err := b.ResolveUSIMove(usi)
+		if err != nil {
+			t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+		}
+		if err := b.ProcessMove(&m); err != nil {
+			t.Fatalf("ProcessMove(%s) failed: %v", usi, err)
+		}
+	}
+
+	want := "lnsgkg1nl/1r5s1/pppppp1pp/6p2/5B3/2P6/PP1PPPPPP/7R1/LNSGKGSNL w b 6"
+	if b.String() != want {
+		t.Errorf("ProcessMove() board = %v, want %v", b.String(), want)
+	}
+
+	m, _ := shogi.ParseUSIMove("B*5e")
+	m.Piece.Color = shogi.Black
+	if err := b.ProcessMove(&m); err == nil {
+		t.Errorf("ProcessMove() succeeded unexpectedly dropping a piece not in hand")
+	}
+}
+
+func TestBoard_ResolveUSIMove_rejects(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		usi  string
+	}{
+		{name: "empty origin", usi: "5e5d"},
+		{name: "opponent piece", usi: "3c3d"},
+		{name: "own piece capture", usi: "9i9g"},
+		{name: "drop without hand", usi: "P*5e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := shogi.NewBoard()
+			b.LoadSfen(shogi.StartingPosition)
+			if _, err := b.ResolveUSIMove(tt.usi); err == nil {
+				t.Errorf("ResolveUSIMove(%s) succeeded unexpectedly", tt.usi)
+			}
+		})
+	}
+}
+
+func TestBoard_Clone(t *testing.T) {
+	b := shogi.NewBoard()
+	b.LoadSfen("lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b P 1")
+	c := b.Clone()
+	c.BitBoard[0] = ""
+	c.Hand.Add(shogi.Black, shogi.Pawn)
+	if b.BitBoard[0] != "l" || b.Hand.Count(shogi.Black, shogi.Pawn) != 1 {
+		t.Errorf("Clone() shares state with the original board")
+	}
+}
+
+func TestBoard_Hash(t *testing.T) {
+	play := func(moves ...string) shogi.Board {
+		t.Helper()
+		b := shogi.NewBoard()
+		b.LoadSfen(shogi.StartingPosition)
+		for _, usi := range moves {
+			m, err := b.ResolveUSIMove(usi)
+			if err != nil {
+				t.Fatalf("ResolveUSIMove(%s) failed: %v", usi, err)
+			}
+			if err := b.ProcessMove(&m); err != nil {
+				t.Fatalf("ProcessMove(%s) failed: %v", usi, err)
+			}
+		}
+		return b
+	}
+	a, b := play("7g7f", "3c3d", "2g2f"), play("2g2f", "3c3d", "7g7f")
+	if a.Hash() != b.Hash() || a.Position() != b.Position() {
+		t.Errorf("Hash() differs for the same position reached by a transposition")
+	}
+	if want := "lnsgkgsnl/1r5b1/pppppp1pp/6p2/9/2P4P1/PP1PPPP1P/1B5R1/LNSGKGSNL w -"; a.Position() != want {
+		t.Errorf("Position() = %q, want %q", a.Position(), want)
+	}
+	if start := play(); start.Hash() == a.Hash() {
+		t.Errorf("Hash() is the same for different positions")
+	}
+}
@@ -0,0 +1,443 @@
+package shogi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeControlKind is the way a clock behaves once the main time of a player runs out.
+type TimeControlKind int
+
+const (
+	// SuddenDeath loses the game when the main time runs out.
+	SuddenDeath TimeControlKind = iota
+	// Byoyomi gives Periods periods of Byoyomi each, a move played within a period doesn't use it up.
+	Byoyomi
+	// Fischer adds Increment to the main time after every move.
+	Fischer
+	// Canadian gives Byoyomi to play Moves moves, the period starts again once they are played.
+	Canadian
+)
+
+func (k TimeControlKind) String() string {
+	switch k {
+	case Byoyomi:
+		return "byoyomi"
+	case Fischer:
+		return "fischer"
+	case Canadian:
+		return "canadian"
+	}
+	return "sudden"
+}
+
+// TimeControl is the time each player has to play the game.
+type TimeControl struct {
+	Kind TimeControlKind
+	// Main is the time of each player before byoyomi starts.
+	Main time.Duration
+	// Byoyomi is the length of a byoyomi period, both in Byoyomi and Canadian.
+	Byoyomi time.Duration
+	// Periods is the number of byoyomi periods, 0 is taken as a single period.
+	Periods int
+	// Increment is the time added after every move in Fischer.
+	Increment time.Duration
+	// Moves is the number of moves to play in each Canadian period.
+	Moves int
+}
+
+// Time control notation:
+//
+//	[sudden:]<main>                  e.g. 10m
+//	[byoyomi:]<main>+<period>[x<n>]  e.g. 10m+30s, 0s+30sx3
+//	fischer:<main>+<increment>       e.g. fischer:5m+10s
+//	canadian:<main>+<period>/<moves> e.g. canadian:10m+5m/20
+//
+// Durations are written as in time.ParseDuration.
+
+// ParseTimeControl decodes a time control in the notation above.
+func ParseTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	kind, rest, hasKind := strings.Cut(s, ":")
+	if !hasKind {
+		rest = s
+	}
+	main, extra, hasExtra := strings.Cut(rest, "+")
+
+	var err error
+	if tc.Main, err = time.ParseDuration(main); err != nil {
+		return TimeControl{}, fmt.Errorf("shogi: invalid main time in %q", s)
+	}
+
+	switch {
+	case !hasKind && !hasExtra, kind == "sudden":
+		tc.Kind = SuddenDeath
+	case !hasKind, kind == "byoyomi":
+		tc.Kind = Byoyomi
+	case kind == "fischer":
+		tc.Kind = Fischer
+	case kind == "canadian":
+		tc.Kind = Canadian
+	default:
+		return TimeControl{}, fmt.Errorf("shogi: unknown time control %q", kind)
+	}
+	if tc.Kind == SuddenDeath {
+		if hasExtra {
+			return TimeControl{}, fmt.Errorf("shogi: sudden death takes no extra time in %q", s)
+		}
+		return tc, nil
+	}
+	if !hasExtra {
+		return TimeControl{}, fmt.Errorf("shogi: missing %s time in %q", tc.Kind, s)
+	}
+
+	period, count := extra, ""
+	switch tc.Kind {
+	case Byoyomi:
+		if i := strings.LastIndex(extra, "x"); i >= 0 {
+			period, count = extra[:i], extra[i+1:]
+		}
+	case Canadian:
+		var ok bool
+		if period, count, ok = strings.Cut(extra, "/"); !ok {
+			return TimeControl{}, fmt.Errorf("shogi: missing canadian moves in %q", s)
+		}
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		return TimeControl{}, fmt.Errorf("shogi: invalid %s time in %q", tc.Kind, s)
+	}
+	n := 0
+	if count != "" {
+		if n, err = strconv.Atoi(count); err != nil || n < 1 {
+			return TimeControl{}, fmt.Errorf("shogi: invalid %s count in %q", tc.Kind, s)
+		}
+	}
+
+	switch tc.Kind {
+	case Byoyomi:
+		tc.Byoyomi, tc.Periods = d, n
+	case Fischer:
+		tc.Increment = d
+	case Canadian:
+		tc.Byoyomi, tc.Moves = d, n
+	}
+	return tc, nil
+}
+
+// String encodes the time control in the notation read by ParseTimeControl.
+func (tc TimeControl) String() string {
+	switch tc.Kind {
+	case Byoyomi:
+		if tc.Periods > 1 {
+			return fmt.Sprintf("%v+%vx%d", tc.Main, tc.Byoyomi, tc.Periods)
+		}
+		return fmt.Sprintf("%v+%v", tc.Main, tc.Byoyomi)
+	case Fischer:
+		return fmt.Sprintf("fischer:%v+%v", tc.Main, tc.Increment)
+	case Canadian:
+		return fmt.Sprintf("canadian:%v+%v/%d", tc.Main, tc.Byoyomi, tc.Moves)
+	}
+	return tc.Main.String()
+}
+
+// sideClock is the time left to one player.
+type sideClock struct {
+	main time.Duration
+	// period is the time left in the current byoyomi period.
+	period time.Duration
+	// periods is the number of byoyomi periods left, the current one included.
+	periods int
+	// moves is the number of moves left to play in the current Canadian period.
+	moves int
+}
+
+func newSideClock(tc TimeControl) sideClock {
+	s := sideClock{main: tc.Main, period: tc.Byoyomi}
+	switch tc.Kind {
+	case Byoyomi:
+		s.periods = max(tc.Periods, 1)
+	case Canadian:
+		s.moves = tc.Moves
+	}
+	return s
+}
+
+// spend takes d from the clock, first from the main time and then from byoyomi. It reports
+// whether the flag fell.
+func (s sideClock) spend(tc TimeControl, d time.Duration) (sideClock, bool) {
+	if d < s.main {
+		s.main -= d
+		return s, false
+	}
+	d -= s.main
+	s.main = 0
+
+	switch tc.Kind {
+	case Byoyomi:
+		for s.periods > 0 {
+			if d < s.period {
+				s.period -= d
+				return s, false
+			}
+			d -= s.period
+			s.periods--
+			s.period = tc.Byoyomi
+		}
+	case Canadian:
+		if d < s.period {
+			s.period -= d
+			return s, false
+		}
+		s.period = 0
+	}
+	return s, true
+}
+
+// left returns the time until the flag falls if no move is played.
+func (s sideClock) left(tc TimeControl) time.Duration {
+	switch tc.Kind {
+	case Byoyomi:
+		if s.periods == 0 {
+			return s.main
+		}
+		return s.main + s.period + time.Duration(s.periods-1)*tc.Byoyomi
+	case Canadian:
+		return s.main + s.period
+	}
+	return s.main
+}
+
+// moved applies the time control to the clock of a player who just finished a move.
+func (s sideClock) moved(tc TimeControl) sideClock {
+	switch tc.Kind {
+	case Fischer:
+		s.main += tc.Increment
+	case Byoyomi:
+		if s.main == 0 {
+			s.period = tc.Byoyomi
+		}
+	case Canadian:
+		if s.main == 0 {
+			s.moves--
+			if s.moves <= 0 {
+				s.period, s.moves = tc.Byoyomi, tc.Moves
+			}
+		}
+	}
+	return s
+}
+
+// Clock is a game clock, it runs for the side to move and is pressed after every move.
+// It is safe to read it while the game is being played.
+type Clock struct {
+	mu          sync.Mutex
+	timeControl TimeControl
+	sides       [2]sideClock
+	turn        Color
+	running     bool
+	// started is when the current move started, while the clock is running.
+	started time.Time
+	flagged bool
+	now     func() time.Time
+}
+
+// WithNow sets the source of the current time of the clock, time.Now by default.
+func WithNow(now func() time.Time) func(*Clock) {
+	return func(c *Clock) {
+		c.now = now
+	}
+}
+
+// NewClock returns a stopped clock with the full time of tc for both players.
+func NewClock(tc TimeControl, options ...func(*Clock)) *Clock {
+	c := &Clock{
+		timeControl: tc,
+		sides:       [2]sideClock{newSideClock(tc), newSideClock(tc)},
+		now:         time.Now,
+	}
+	for _, f := range options {
+		f(c)
+	}
+	return c
+}
+
+// TimeControl returns the time control the clock was created with.
+func (c *Clock) TimeControl() TimeControl {
+	return c.timeControl
+}
+
+// Start runs the clock of turn.
+func (c *Clock) Start(turn Color) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		c.stop()
+	}
+	c.turn = turn
+	c.running = !c.flagged
+	c.started = c.now()
+}
+
+// Stop stops the clock of the side to move, keeping the time it used.
+func (c *Clock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop()
+}
+
+func (c *Clock) stop() {
+	if !c.running {
+		return
+	}
+	c.sides[c.turn], c.flagged = c.sides[c.turn].spend(c.timeControl, c.now().Sub(c.started))
+	c.running = false
+}
+
+// Press ends the move of the side to move and runs the clock of its opponent. It returns
+// false, leaving the clock stopped, when the flag of the side to move fell before the press.
+func (c *Clock) Press() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return !c.flagged
+	}
+	c.stop()
+	if c.flagged {
+		return false
+	}
+	c.sides[c.turn] = c.sides[c.turn].moved(c.timeControl)
+	c.turn = c.turn.Opponent()
+	c.running = true
+	c.started = c.now()
+	return true
+}
+
+// Set sets the main time left to color, e.g. to follow the clock of a server. The move of
+// the side to move is timed again from now.
+func (c *Clock) Set(color Color, main time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sides[color].main = main
+	if c.running && color == c.turn {
+		c.started = c.now()
+	}
+}
+
+// ClockState is the time left to a player, byoyomi included.
+type ClockState struct {
+	Main time.Duration
+	// Period is the time left in the current byoyomi period.
+	Period time.Duration
+	// Periods is the number of byoyomi periods left, the current one included.
+	Periods int
+	// Moves is the number of moves left to play in the current Canadian period.
+	Moves int
+}
+
+// State returns the time left to color as of now.
+func (c *Clock) State(color Color) ClockState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, _ := c.side(color)
+	return ClockState{Main: s.main, Period: s.period, Periods: s.periods, Moves: s.moves}
+}
+
+// SetState sets the time left to color, e.g. to resume a saved game. The move of the side to
+// move is timed again from now.
+func (c *Clock) SetState(color Color, state ClockState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sides[color] = sideClock{main: state.Main, period: state.Period, periods: state.Periods, moves: state.Moves}
+	if c.running && color == c.turn {
+		c.started = c.now()
+	}
+}
+
+// Running reports whether the clock is running.
+func (c *Clock) Running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+// Turn returns the side the clock runs, or last ran, for.
+func (c *Clock) Turn() Color {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.turn
+}
+
+// side returns the clock of color as of now and whether its flag fell.
+func (c *Clock) side(color Color) (sideClock, bool) {
+	if !c.running || color != c.turn {
+		return c.sides[color], c.flagged && color == c.turn
+	}
+	return c.sides[color].spend(c.timeControl, c.now().Sub(c.started))
+}
+
+// Remaining returns the main time left to color.
+func (c *Clock) Remaining(color Color) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, _ := c.side(color)
+	return s.main
+}
+
+// TimeLeft returns the time until the flag of color falls if it doesn't move, byoyomi included.
+func (c *Clock) TimeLeft(color Color) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, flagged := c.side(color)
+	if flagged {
+		return 0
+	}
+	return s.left(c.timeControl)
+}
+
+// Byoyomi returns the time left in the current byoyomi period of color, the full period
+// while it still has main time.
+func (c *Clock) Byoyomi(color Color) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, _ := c.side(color)
+	return s.period
+}
+
+// Flagged reports whether the flag of a player fell, and which one.
+func (c *Clock) Flagged() (Color, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, flagged := c.side(c.turn)
+	return c.turn, flagged
+}
+
+// Format renders the time left to color, e.g. 9:58, 0:25 (x3) in byoyomi with three
+// periods left or 4:12 (5) in Canadian byoyomi with five moves to play.
+func (c *Clock) Format(color Color) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, flagged := c.side(color)
+	switch {
+	case flagged:
+		return formatDuration(0) + " flag"
+	case s.main > 0 || c.timeControl.Kind == SuddenDeath || c.timeControl.Kind == Fischer:
+		return formatDuration(s.main)
+	case c.timeControl.Kind == Byoyomi:
+		return fmt.Sprintf("%s (x%d)", formatDuration(s.period), s.periods)
+	}
+	return fmt.Sprintf("%s (%d)", formatDuration(s.period), s.moves)
+}
+
+// formatDuration renders d as h:mm:ss, or m:ss under an hour, rounding seconds up so a
+// clock only shows 0:00 once it ran out.
+func formatDuration(d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
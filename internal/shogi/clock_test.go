@@ -0,0 +1,292 @@
+package shogi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+// fakeTime is a clock source moved by hand.
+type fakeTime struct {
+	t time.Time
+}
+
+func (f *fakeTime) now() time.Time {
+	return f.t
+}
+
+func (f *fakeTime) advance(d time.Duration) {
+	f.t = f.t.Add(d)
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		s       string
+		want    shogi.TimeControl
+		wantErr bool
+	}{
+		{
+			name: "sudden death",
+			s:    "10m",
+			want: shogi.TimeControl{Kind: shogi.SuddenDeath, Main: 10 * time.Minute},
+		},
+		{
+			name: "byoyomi",
+			s:    "10m+30s",
+			want: shogi.TimeControl{Kind: shogi.Byoyomi, Main: 10 * time.Minute, Byoyomi: 30 * time.Second},
+		},
+		{
+			name: "byoyomi with periods",
+			s:    "byoyomi:0s+30sx3",
+			want: shogi.TimeControl{Kind: shogi.Byoyomi, Byoyomi: 30 * time.Second, Periods: 3},
+		},
+		{
+			name: "fischer",
+			s:    "fischer:5m+10s",
+			want: shogi.TimeControl{Kind: shogi.Fischer, Main: 5 * time.Minute, Increment: 10 * time.Second},
+		},
+		{
+			name: "canadian",
+			s:    "canadian:10m+5m/20",
+			want: shogi.TimeControl{Kind: shogi.Canadian, Main: 10 * time.Minute, Byoyomi: 5 * time.Minute, Moves: 20},
+		},
+		{
+			name:    "canadian without moves",
+			s:       "canadian:10m+5m",
+			wantErr: true,
+		},
+		{
+			name:    "unknown kind",
+			s:       "hourglass:1m",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			s:       "ten minutes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := shogi.ParseTimeControl(tt.s)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseTimeControl() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseTimeControl() succeeded unexpectedly")
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimeControl() = %+v, want %+v", got, tt.want)
+			}
+			back, err := shogi.ParseTimeControl(got.String())
+			if err != nil || back != got {
+				t.Errorf("ParseTimeControl(%q) = %+v, %v, want %+v", got.String(), back, err, got)
+			}
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		name        string // description of this test case
+		tc          string
+		moves       []time.Duration
+		wantFlagged bool
+		wantLoser   shogi.Color
+		// want is the clock of each player after the moves.
+		want [2]string
+	}{
+		{
+			name:  "sudden death",
+			tc:    "5m",
+			moves: []time.Duration{30 * time.Second, 10 * time.Second, 1500 * time.Millisecond},
+			want:  [2]string{"4:29", "4:50"},
+		},
+		{
+			name:        "sudden death flag",
+			tc:          "1m",
+			moves:       []time.Duration{30 * time.Second, 61 * time.Second},
+			wantFlagged: true,
+			wantLoser:   shogi.White,
+			want:        [2]string{"0:30", "0:00 flag"},
+		},
+		{
+			name:  "byoyomi resets after every move",
+			tc:    "1m+30s",
+			moves: []time.Duration{70 * time.Second, time.Second, 25 * time.Second},
+			want:  [2]string{"0:30 (x1)", "0:59"},
+		},
+		{
+			name:        "byoyomi flag",
+			tc:          "1m+30s",
+			moves:       []time.Duration{90 * time.Second},
+			wantFlagged: true,
+			wantLoser:   shogi.Black,
+			want:        [2]string{"0:00 flag", "1:00"},
+		},
+		{
+			name:  "byoyomi periods are used up",
+			tc:    "0s+10sx3",
+			moves: []time.Duration{25 * time.Second, 5 * time.Second, 5 * time.Second},
+			want:  [2]string{"0:10 (x1)", "0:10 (x3)"},
+		},
+		{
+			name:        "byoyomi periods flag",
+			tc:          "0s+10sx3",
+			moves:       []time.Duration{25 * time.Second, 5 * time.Second, 10 * time.Second},
+			wantFlagged: true,
+			wantLoser:   shogi.Black,
+			want:        [2]string{"0:00 flag", "0:10 (x3)"},
+		},
+		{
+			name:  "fischer increment",
+			tc:    "fischer:1m+10s",
+			moves: []time.Duration{20 * time.Second, 5 * time.Second, 20 * time.Second},
+			want:  [2]string{"0:40", "1:05"},
+		},
+		{
+			name:  "canadian period starts again after its moves",
+			tc:    "canadian:10s+1m/2",
+			moves: []time.Duration{20 * time.Second, 0, 30 * time.Second},
+			want:  [2]string{"1:00 (2)", "0:10"},
+		},
+		{
+			name:  "canadian moves left",
+			tc:    "canadian:0s+1m/3",
+			moves: []time.Duration{20 * time.Second, 0, 30 * time.Second},
+			want:  [2]string{"0:10 (1)", "1:00 (2)"},
+		},
+		{
+			name:        "canadian flag",
+			tc:          "canadian:0s+1m/3",
+			moves:       []time.Duration{20 * time.Second, 0, 30 * time.Second, 0, 11 * time.Second},
+			wantFlagged: true,
+			wantLoser:   shogi.Black,
+			want:        [2]string{"0:00 flag", "1:00 (1)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := shogi.ParseTimeControl(tt.tc)
+			if err != nil {
+				t.Fatalf("ParseTimeControl() failed: %v", err)
+			}
+			ft := &fakeTime{t: time.Unix(0, 0)}
+			c := shogi.NewClock(tc, shogi.WithNow(ft.now))
+			c.Start(shogi.Black)
+			for i, think := range tt.moves {
+				ft.advance(think)
+				if !c.Press() {
+					if !tt.wantFlagged || i != len(tt.moves)-1 {
+						t.Fatalf("Press() flagged at move %d", i+1)
+					}
+				}
+			}
+
+			loser, flagged := c.Flagged()
+			if flagged != tt.wantFlagged || (flagged && loser != tt.wantLoser) {
+				t.Errorf("Flagged() = %v, %v, want %v, %v", loser, flagged, tt.wantLoser, tt.wantFlagged)
+			}
+			for i, color := range []shogi.Color{shogi.Black, shogi.White} {
+				if got := c.Format(color); got != tt.want[i] {
+					t.Errorf("Format(%v) = %q, want %q", color, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClock_running(t *testing.T) {
+	ft := &fakeTime{t: time.Unix(0, 0)}
+	c := shogi.NewClock(shogi.TimeControl{Kind: shogi.SuddenDeath, Main: time.Minute}, shogi.WithNow(ft.now))
+	c.Start(shogi.White)
+
+	ft.advance(15 * time.Second)
+	if got := c.Remaining(shogi.White); got != 45*time.Second {
+		t.Errorf("Remaining() of the side to move = %v, want 45s", got)
+	}
+	if got := c.Remaining(shogi.Black); got != time.Minute {
+		t.Errorf("Remaining() of the side waiting = %v, want 1m", got)
+	}
+	if got := c.TimeLeft(shogi.White); got != 45*time.Second {
+		t.Errorf("TimeLeft() of the side to move = %v, want 45s", got)
+	}
+
+	c.Stop()
+	ft.advance(time.Hour)
+	if _, flagged := c.Flagged(); flagged {
+		t.Errorf("Flagged() while stopped")
+	}
+	if got := c.Remaining(shogi.White); got != 45*time.Second {
+		t.Errorf("Remaining() after Stop() = %v, want 45s", got)
+	}
+
+	c.Start(shogi.White)
+	ft.advance(time.Minute)
+	if loser, flagged := c.Flagged(); !flagged || loser != shogi.White {
+		t.Errorf("Flagged() = %v, %v, want w, true", loser, flagged)
+	}
+}
+
+func TestClock_SetState(t *testing.T) {
+	ft := &fakeTime{t: time.Unix(0, 0)}
+	tc := shogi.TimeControl{Kind: shogi.Canadian, Main: time.Minute, Byoyomi: time.Minute, Moves: 5}
+	c := shogi.NewClock(tc, shogi.WithNow(ft.now))
+	c.Start(shogi.Black)
+	ft.advance(70 * time.Second)
+	c.Press()
+	want := shogi.ClockState{Period: 50 * time.Second, Moves: 4}
+	if got := c.State(shogi.Black); got != want {
+		t.Fatalf("State() = %+v, want %+v", got, want)
+	}
+
+	restored := shogi.NewClock(tc, shogi.WithNow(ft.now))
+	restored.SetState(shogi.Black, want)
+	if got := restored.State(shogi.Black); got != want {
+		t.Errorf("State() after SetState() = %+v, want %+v", got, want)
+	}
+	if got := restored.Format(shogi.Black); got != "0:50 (4)" {
+		t.Errorf("Format() after SetState() = %s, want 0:50 (4)", got)
+	}
+}
+
+func TestGame_flagFall(t *testing.T) {
+	ft := &fakeTime{t: time.Unix(0, 0)}
+	tc := shogi.TimeControl{Kind: shogi.Byoyomi, Main: time.Minute, Byoyomi: 10 * time.Second}
+	g := shogi.NewGame("sente", "gote", shogi.WithClock(tc, shogi.WithNow(ft.now)))
+
+	move := func(usi string) error {
+		m, err := g.Board().ResolveUSIMove(usi)
+		if err != nil {
+			t.Fatalf("ResolveUSIMove() failed: %v", err)
+		}
+		return g.Move(m)
+	}
+
+	ft.advance(30 * time.Second)
+	if err := move("7g7f"); err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if got := g.Clock().Remaining(shogi.Black); got != 30*time.Second {
+		t.Errorf("Remaining() of sente = %v, want 30s", got)
+	}
+	if g.Outcome() != shogi.NoOutcome {
+		t.Errorf("Outcome() = %v, want %v", g.Outcome(), shogi.NoOutcome)
+	}
+
+	ft.advance(70 * time.Second)
+	if got := g.Outcome(); got != shogi.BlackWon {
+		t.Errorf("Outcome() = %v, want %v", got, shogi.BlackWon)
+	}
+	if err := move("3c3d"); err == nil {
+		t.Errorf("Move() succeeded after the flag fell")
+	}
+	if len(g.Moves()) != 1 {
+		t.Errorf("Moves() = %d, want 1", len(g.Moves()))
+	}
+}
@@ -13,3 +13,10 @@ func (c Color) String() string {
 	}
 	return "w"
 }
+
+func (c Color) Opponent() Color {
+	if c == Black {
+		return White
+	}
+	return Black
+}
@@ -1,6 +1,10 @@
 package shogi
 
-import "github.com/juanpablocruz/shogo/clientr/internal/agent"
+import (
+	"fmt"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/agent"
+)
 
 type Outcome string
 
@@ -16,12 +20,23 @@ func (o Outcome) String() string {
 }
 
 type Game struct {
-	sentePlayer string
-	gotePlayer  string
-	notation    Notation
-	moves       []*Move
-	board       *Board
-	ai          agent.Agent
+	sentePlayer   string
+	gotePlayer    string
+	notation      Notation
+	startPosition string
+	moves         []*Move
+	board         *Board
+	ai            agent.Agent
+	clock         *Clock
+	outcome       Outcome
+	onMove        func(Move) error
+}
+
+// WithClock plays the game with a clock of tc, running for the side to move from the start.
+func WithClock(tc TimeControl, options ...func(*Clock)) func(*Game) {
+	return func(g *Game) {
+		g.clock = NewClock(tc, options...)
+	}
 }
 
 func NewGame(sentePlayer, GotePlayer string, options ...func(*Game)) *Game {
@@ -37,13 +52,18 @@ func NewGame(sentePlayer, GotePlayer string, options ...func(*Game)) *Game {
 			Hand:      Hand{},
 			MoveCount: int32(board.CurrentMove),
 		},
-		board: &board,
-		moves: []*Move{},
+		startPosition: StartingPosition,
+		board:         &board,
+		moves:         []*Move{},
+		outcome:       NoOutcome,
 	}
 
 	for _, f := range options {
 		f(game)
 	}
+	if game.clock != nil {
+		game.clock.Start(board.Turn)
+	}
 
 	return game
 }
@@ -56,6 +76,11 @@ func (g *Game) GetAIClient() agent.Agent {
 	return g.ai
 }
 
+// SetMoveHandler sets a function called before every move is played, an error rejects the move.
+func (g *Game) SetMoveHandler(f func(Move) error) {
+	g.onMove = f
+}
+
 func (g Game) GotePlayer() string {
 	return g.gotePlayer
 }
@@ -76,11 +101,55 @@ func (g Game) Board() *Board {
 	return g.board
 }
 
+// StartPosition returns the SFEN of the position the moves of the game were played from.
+func (g Game) StartPosition() string {
+	return g.startPosition
+}
+
 func (g *Game) SetBoard(b *Board) {
 	g.board = b
+	if len(g.moves) == 0 {
+		g.startPosition = b.String()
+		if g.clock != nil {
+			g.clock.Start(b.Turn)
+		}
+	}
 }
 
-func (g Game) MoveStr(cmd string) error {
+// Clock returns the clock of the game, nil when it is played without one.
+func (g Game) Clock() *Clock {
+	return g.clock
+}
+
+// Outcome returns the result of the game, NoOutcome while it is being played.
+// A game with a clock is lost by the player whose flag fell.
+func (g *Game) Outcome() Outcome {
+	if g.outcome == NoOutcome && g.clock != nil {
+		if loser, flagged := g.clock.Flagged(); flagged {
+			g.clock.Stop()
+			g.outcome = Winner(loser.Opponent())
+		}
+	}
+	return g.outcome
+}
+
+// End finishes the game with o, e.g. after a resignation, stopping its clock.
+func (g *Game) End(o Outcome) {
+	g.outcome = o
+	if g.clock != nil {
+		g.clock.Stop()
+	}
+}
+
+// Winner returns the outcome of a game won by c.
+func Winner(c Color) Outcome {
+	if c == Black {
+		return BlackWon
+	}
+	return WhiteWon
+}
+
+func (g *Game) MoveStr(cmd string) error {
 	m, err := g.notation.DecodeMovement(cmd)
 	if err != nil {
 		return err
@@ -89,6 +158,20 @@ func (g Game) MoveStr(cmd string) error {
 }
 
 func (g *Game) Move(m Move) error {
+	if o := g.Outcome(); o != NoOutcome {
+		return fmt.Errorf("shogi: game is over, %s", o)
+	}
+	if g.onMove != nil {
+		if err := g.onMove(m); err != nil {
+			return err
+		}
+	}
 	g.moves = append(g.moves, &m)
-	return g.board.ProcessMove(&m)
+	if err := g.board.ProcessMove(&m); err != nil {
+		return err
+	}
+	if g.clock != nil && !g.clock.Press() {
+		g.Outcome()
+	}
+	return nil
 }
@@ -1,6 +1,11 @@
 package shogi
 
-import "strconv"
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"unicode"
+)
 
 type Hand struct {
 	BlackPieces map[PieceType]int
@@ -36,5 +41,86 @@ func (h Hand) String() string {
 		}
 	}
 
+	if handStr == "" {
+		return "-"
+	}
 	return handStr
 }
+
+// ParseHand decodes the pieces in hand field of a SFEN string, e.g. R6P2p or -
+func ParseHand(s string) (Hand, error) {
+	h := Hand{}
+	if s == "-" {
+		return h, nil
+	}
+
+	count := 0
+	for _, c := range s {
+		if unicode.IsDigit(c) {
+			count = count*10 + int(c-'0')
+			continue
+		}
+		p := NewPiece(string(c), false)
+		if p.Type == NoPiece {
+			return Hand{}, fmt.Errorf("shogi: invalid piece in hand %c in %s", c, s)
+		}
+		if count == 0 {
+			count = 1
+		}
+		for range count {
+			h.Add(p.Color, p.Type)
+		}
+		count = 0
+	}
+	if count != 0 {
+		return Hand{}, fmt.Errorf("shogi: invalid pieces in hand %s, count without piece", s)
+	}
+	return h, nil
+}
+
+func (h Hand) pieces(c Color) map[PieceType]int {
+	if c == Black {
+		return h.BlackPieces
+	}
+	return h.WhitePieces
+}
+
+// Count returns how many pieces of type pt the player c holds.
+func (h Hand) Count(c Color, pt PieceType) int {
+	return h.pieces(c)[pt]
+}
+
+// Add puts a captured piece in the hand of player c.
+func (h *Hand) Add(c Color, pt PieceType) {
+	if c == Black {
+		if h.BlackPieces == nil {
+			h.BlackPieces = make(map[PieceType]int)
+		}
+		h.BlackPieces[pt]++
+		return
+	}
+	if h.WhitePieces == nil {
+		h.WhitePieces = make(map[PieceType]int)
+	}
+	h.WhitePieces[pt]++
+}
+
+// Remove takes a piece out of the hand of player c to drop it.
+func (h *Hand) Remove(c Color, pt PieceType) error {
+	pieces := h.pieces(c)
+	if pieces[pt] < 1 {
+		return fmt.Errorf("shogi: no %s in hand to drop", pt.String())
+	}
+	pieces[pt]--
+	if pieces[pt] == 0 {
+		delete(pieces, pt)
+	}
+	return nil
+}
+
+func (h Hand) Clone() Hand {
+	return Hand{
+		BlackPieces: maps.Clone(h.BlackPieces),
+		WhitePieces: maps.Clone(h.WhitePieces),
+	}
+}
@@ -66,3 +66,34 @@ func TestHand_String(t *testing.T) {
 		})
 	}
 }
+
+func TestParseHand(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		s       string
+		wantErr bool
+	}{
+		{name: "empty", s: "-"},
+		{name: "black and white", s: "R6P2p"},
+		{name: "two digit count", s: "12Pb"},
+		{name: "unknown piece", s: "X", wantErr: true},
+		{name: "trailing count", s: "P2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := shogi.ParseHand(tt.s)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseHand() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseHand() succeeded unexpectedly")
+			}
+			if got.String() != tt.s {
+				t.Errorf("ParseHand() = %v, want %v", got.String(), tt.s)
+			}
+		})
+	}
+}
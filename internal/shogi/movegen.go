@@ -0,0 +1,193 @@
+package shogi
+
+// Legal move generation.
+//
+// A move is legal when the piece can reach the destination, the player's own king is not left in
+// check, and the drop rules hold:
+//   - pawns, lances and knights can't be dropped where they would never be able to move again
+//   - nifu: a pawn can't be dropped on a file that already has an unpromoted pawn of the same player
+//   - uchifuzume: a pawn drop can't give checkmate
+//
+// Pawns and lances reaching the last rank and knights reaching the last two ranks must promote.
+
+// inPromotionZone reports whether r is one of the three ranks farthest from player c.
+func inPromotionZone(c Color, r Rank) bool {
+	if c == Black {
+		return r <= 2
+	}
+	return r >= numOfSquaresInRow-3
+}
+
+// ranksAhead returns how many ranks are left in front of a piece of player c standing on r.
+func ranksAhead(c Color, r Rank) int {
+	if c == Black {
+		return int(r)
+	}
+	return numOfSquaresInRow - 1 - int(r)
+}
+
+// canPromote reports whether p is a piece that can still promote.
+func canPromote(p Piece) bool {
+	return !p.IsPromoted && p.Type != King && p.Type != Gold
+}
+
+// mustPromote reports whether an unpromoted p would be left without moves on dest.
+func mustPromote(p Piece, dest Square) bool {
+	if p.IsPromoted {
+		return false
+	}
+	switch p.Type {
+	case Pawn, Lance:
+		return ranksAhead(p.Color, dest.Rank()) < 1
+	case Knight:
+		return ranksAhead(p.Color, dest.Rank()) < 2
+	}
+	return false
+}
+
+// PiecesOf returns the pieces of player c on the board, with their squares.
+func (b Board) PiecesOf(c Color) []Piece {
+	pieces := []Piece{}
+	for sq := range b.BitBoard {
+		p, err := b.GetPieceAtSquare(Square(sq))
+		if err != nil || p.Color != c {
+			continue
+		}
+		pieces = append(pieces, p)
+	}
+	return pieces
+}
+
+// KingSquare returns the square of the king of player c, false if c has no king on the board.
+func (b Board) KingSquare(c Color) (Square, bool) {
+	king := Piece{Type: King, Color: c}.String()
+	for sq, code := range b.BitBoard {
+		if code == king {
+			return Square(sq), true
+		}
+	}
+	return Square(-1), false
+}
+
+// IsAttacked reports whether any piece of player by can move to sq.
+func (b Board) IsAttacked(sq Square, by Color) bool {
+	for _, p := range b.PiecesOf(by) {
+		if p.CanMove(sq, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// InCheck reports whether the king of player c is attacked.
+func (b Board) InCheck(c Color) bool {
+	sq, ok := b.KingSquare(c)
+	if !ok {
+		return false
+	}
+	return b.IsAttacked(sq, c.Opponent())
+}
+
+// IsCheckmate reports whether the player on turn is in check and has no legal moves.
+func (b Board) IsCheckmate() bool {
+	return b.InCheck(b.Turn) && len(b.LegalMoves()) == 0
+}
+
+// pseudoLegalMoves returns the moves of the player on turn without checking whether they leave
+// the own king in check.
+func (b Board) pseudoLegalMoves() []Move {
+	moves := []Move{}
+	for _, p := range b.PiecesOf(b.Turn) {
+		for dest := range b.BitBoard {
+			sq := Square(dest)
+			if !p.CanMove(sq, b) {
+				continue
+			}
+			m := Move{Type: SimpleMovement, Piece: p, Origin: p.Square, Destination: sq}
+			if target, err := b.GetPieceAtSquare(sq); err == nil {
+				if target.Color == p.Color {
+					continue
+				}
+				m.Type = Capture
+			}
+
+			promotes := canPromote(p) && (inPromotionZone(p.Color, p.Square.Rank()) || inPromotionZone(p.Color, sq.Rank()))
+			if promotes {
+				pm := m
+				pm.IsPromoting = true
+				moves = append(moves, pm)
+			}
+			if !promotes || !mustPromote(p, sq) {
+				moves = append(moves, m)
+			}
+		}
+	}
+
+	for _, pt := range []PieceType{Rook, Bishop, Gold, Silver, Knight, Lance, Pawn} {
+		if b.Hand.Count(b.Turn, pt) < 1 {
+			continue
+		}
+		p := Piece{Type: pt, Color: b.Turn}
+		for dest, code := range b.BitBoard {
+			sq := Square(dest)
+			if code != "" || mustPromote(p, sq) {
+				continue
+			}
+			if pt == Pawn && b.hasPawnOnFile(b.Turn, sq.File()) {
+				continue
+			}
+			moves = append(moves, Move{Type: Drop, Piece: p, Destination: sq})
+		}
+	}
+	return moves
+}
+
+// hasPawnOnFile reports whether player c has an unpromoted pawn on file f.
+func (b Board) hasPawnOnFile(c Color, f File) bool {
+	pawn := Piece{Type: Pawn, Color: c}.String()
+	for r := Rank(0); r < numOfSquaresInRow; r++ {
+		if b.BitBoard[NewSquare(f, r)] == pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// LegalMoves returns every legal move of the player on turn.
+func (b Board) LegalMoves() []Move {
+	moves := []Move{}
+	for _, m := range b.pseudoLegalMoves() {
+		if b.isLegal(m) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// IsLegal reports whether m is a legal move for the player on turn. The move is compared in
+// USI notation so moves decoded from any notation can be checked.
+func (b Board) IsLegal(m Move) bool {
+	usi := m.USI()
+	for _, lm := range b.LegalMoves() {
+		if lm.USI() == usi {
+			return true
+		}
+	}
+	return false
+}
+
+// isLegal checks that a pseudo legal move doesn't leave the own king in check and is not a
+// pawn drop checkmate.
+func (b Board) isLegal(m Move) bool {
+	after := b.Clone()
+	if err := after.ProcessMove(&m); err != nil {
+		return false
+	}
+	if after.InCheck(b.Turn) {
+		return false
+	}
+	if m.Type == Drop && m.Piece.Type == Pawn && after.InCheck(after.Turn) && len(after.LegalMoves()) == 0 {
+		return false
+	}
+	return true
+}
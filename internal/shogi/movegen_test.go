@@ -0,0 +1,106 @@
+package shogi_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestBoard_LegalMoves(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		sfen string
+		// Moves that must and must not be legal, in USI notation.
+		legal     []string
+		illegal   []string
+		wantCount int
+		wantMate  bool
+	}{
+		{
+			name:      "starting position",
+			sfen:      shogi.StartingPosition,
+			legal:     []string{"7g7f", "2h3h", "3i4h", "5i5h"},
+			illegal:   []string{"8h2b", "2h2c", "7g7e"},
+			wantCount: 30,
+		},
+		{
+			name:    "rook slides along the file until blocked",
+			sfen:    "4k4/9/9/9/9/9/4P4/9/R3K4 b - 1",
+			legal:   []string{"9i9a", "9i9a+", "9i9b", "9i8i"},
+			illegal: []string{"9i5i", "9i8h"},
+		},
+		{
+			name:    "pawn must promote on the last rank",
+			sfen:    "k8/4P4/9/9/9/9/9/9/K8 b - 1",
+			legal:   []string{"5b5a+"},
+			illegal: []string{"5b5a"},
+		},
+		{
+			name:    "nifu and last rank pawn drops",
+			sfen:    "4k4/9/9/9/9/9/4P4/9/4K4 b P 1",
+			legal:   []string{"P*4b", "P*6h"},
+			illegal: []string{"P*5d", "P*4a"},
+		},
+		{
+			name:    "pawn drop mate is illegal but a gold drop mate is not",
+			sfen:    "7lk/7p1/8G/9/9/9/9/9/K8 b GP 1",
+			legal:   []string{"G*1b", "P*1d"},
+			illegal: []string{"P*1b"},
+		},
+		{
+			name:    "king can't move into check",
+			sfen:    "4k4/9/9/9/9/9/9/3r5/4K4 b - 1",
+			legal:   []string{"5i4i", "5i6h"},
+			illegal: []string{"5i6i", "5i5h", "5i4h"},
+		},
+		{
+			name:     "head on gold mate",
+			sfen:     "4k4/4G4/4P4/9/9/9/9/9/4K4 w - 1",
+			wantMate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := shogi.NewBoard()
+			if err := b.LoadSfen(tt.sfen); err != nil {
+				t.Fatalf("LoadSfen() failed: %v", err)
+			}
+			moves := b.LegalMoves()
+			got := make([]string, 0, len(moves))
+			for _, m := range moves {
+				got = append(got, m.USI())
+			}
+
+			for _, m := range tt.legal {
+				if !slices.Contains(got, m) {
+					t.Errorf("LegalMoves() missing %s in %v", m, got)
+				}
+			}
+			for _, m := range tt.illegal {
+				if slices.Contains(got, m) {
+					t.Errorf("LegalMoves() contains illegal %s", m)
+				}
+			}
+			if tt.wantCount > 0 && len(got) != tt.wantCount {
+				t.Errorf("LegalMoves() want %d moves got %d: %v", tt.wantCount, len(got), got)
+			}
+			if b.IsCheckmate() != tt.wantMate {
+				t.Errorf("IsCheckmate() want %v got %v", tt.wantMate, b.IsCheckmate())
+			}
+		})
+	}
+}
+
+func TestBoard_InCheck(t *testing.T) {
+	b := shogi.NewBoard()
+	if err := b.LoadSfen("4k4/9/9/9/9/9/9/9/4K3r b - 1"); err != nil {
+		t.Fatalf("LoadSfen() failed: %v", err)
+	}
+	if !b.InCheck(shogi.Black) {
+		t.Errorf("InCheck() want black in check")
+	}
+	if b.InCheck(shogi.White) {
+		t.Errorf("InCheck() want white not in check")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"slices"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
 type Notation struct {
@@ -39,23 +38,9 @@ func (n Notation) DecodeBoard(sfen string) (Board, error) {
 		CurrentMove: 0,
 	}
 
-	bParts := strings.Split(parts[0], "/")
-	if len(bParts) < 9 {
-		return Board{}, fmt.Errorf("shogi: invalid sfen string received, expecting board with 9 /, received: %s", parts[0])
-	}
-
-	bb := make([]string, 81)
-	for rank, rankStr := range bParts {
-		fileIndx := 0
-		for _, c := range rankStr {
-			if unicode.IsDigit(c) {
-				num := int(c) - 48
-				fileIndx += num
-				continue
-			}
-			bb[(rank*numOfSquaresInRow)+fileIndx] = string(c)
-			fileIndx++
-		}
+	bb, err := parsePlacement(parts[0])
+	if err != nil {
+		return Board{}, fmt.Errorf("shogi: invalid sfen string received: %w", err)
 	}
 
 	b.BitBoard = bb
@@ -71,8 +56,10 @@ func (n Notation) DecodeBoard(sfen string) (Board, error) {
 		b.Turn = White
 	}
 
-	var h Hand
-
+	h, err := ParseHand(parts[2])
+	if err != nil {
+		return Board{}, err
+	}
 	b.Hand = h
 
 	if len(parts) == 4 {
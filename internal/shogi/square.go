@@ -40,14 +40,14 @@ func (f File) String() string {
 }
 
 func (r Rank) String() string {
-	if r < 1 || int(r) > len(numAsRank) {
+	if r < 0 || int(r) >= len(numAsRank) {
 		return ""
 	}
 	return string(numAsRank[r])
 }
 
 func (r Rank) Rune() rune {
-	if r < 0 || int(r) > len(numAsRank) {
+	if r < 0 || int(r) >= len(numAsRank) {
 		return '-'
 	}
 	return numAsRank[r]
@@ -0,0 +1,128 @@
+package shogi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// USI move notation:
+//
+//	<from><to>[+]  e.g. 7g7f, 8h2b+
+//	<piece>*<to>   e.g. P*5e
+//
+// USI numbers files from right to left as seen from sente, while squares in this package
+// number them from left to right as the board is rendered, so files are mirrored when
+// converting between both.
+
+// ParseUSIMove decodes a move in USI notation. The piece of a board move is not known
+// until it is resolved against a Board, drops always carry a Black piece.
+func ParseUSIMove(s string) (Move, error) {
+	if len(s) < 4 || len(s) > 5 {
+		return Move{}, fmt.Errorf("shogi: invalid usi move %q", s)
+	}
+
+	if s[1] == '*' {
+		if len(s) != 4 {
+			return Move{}, fmt.Errorf("shogi: invalid usi drop %q", s)
+		}
+		code := s[:1]
+		if !strings.Contains("RBGSNLP", code) {
+			return Move{}, fmt.Errorf("shogi: invalid usi drop piece %q", s)
+		}
+		dest, err := ParseUSISquare(s[2:4])
+		if err != nil {
+			return Move{}, err
+		}
+		return Move{
+			Type:        Drop,
+			Piece:       NewPiece(code, false),
+			Destination: dest,
+		}, nil
+	}
+
+	origin, err := ParseUSISquare(s[:2])
+	if err != nil {
+		return Move{}, err
+	}
+	dest, err := ParseUSISquare(s[2:4])
+	if err != nil {
+		return Move{}, err
+	}
+
+	m := Move{
+		Type:        SimpleMovement,
+		Origin:      origin,
+		Destination: dest,
+	}
+	if len(s) == 5 {
+		if s[4] != '+' {
+			return Move{}, fmt.Errorf("shogi: invalid usi move suffix %q", s)
+		}
+		m.IsPromoting = true
+	}
+	return m, nil
+}
+
+// ParseUSISquare decodes a square in USI notation, e.g. 7g.
+func ParseUSISquare(s string) (Square, error) {
+	if len(s) != 2 || s[0] < '1' || s[0] > '9' || s[1] < 'a' || s[1] > 'i' {
+		return Square(-1), fmt.Errorf("shogi: invalid usi square %q", s)
+	}
+	file := File(numOfSquaresInRow - int(s[0]-'0'))
+	rank := Rank(s[1] - 'a')
+	return NewSquare(file, rank), nil
+}
+
+// USISquare encodes the square in USI notation, e.g. 7g.
+func (sq Square) USISquare() string {
+	return fmt.Sprintf("%d%c", numOfSquaresInRow-int(sq.File()), 'a'+rune(sq.Rank()))
+}
+
+// USI encodes the move in USI notation.
+func (m Move) USI() string {
+	if m.Type == Drop {
+		return fmt.Sprintf("%s*%s", m.Piece.Type.String(), m.Destination.USISquare())
+	}
+	usi := m.Origin.USISquare() + m.Destination.USISquare()
+	if m.IsPromoting {
+		usi += "+"
+	}
+	return usi
+}
+
+// ResolveUSIMove decodes a move in USI notation and completes it with the piece that moves
+// on this board, so it can be encoded in other notations and processed.
+func (b Board) ResolveUSIMove(s string) (Move, error) {
+	m, err := ParseUSIMove(s)
+	if err != nil {
+		return Move{}, err
+	}
+
+	if m.Type == Drop {
+		m.Piece.Color = b.Turn
+		if b.Hand.Count(b.Turn, m.Piece.Type) < 1 {
+			return Move{}, fmt.Errorf("shogi: no %s in hand to drop for %s", m.Piece.Type.String(), s)
+		}
+		if b.BitBoard[m.Destination] != "" {
+			return Move{}, fmt.Errorf("shogi: can't drop on occupied square for %s", s)
+		}
+		return m, nil
+	}
+
+	p, err := b.GetPieceAtSquare(m.Origin)
+	if err != nil {
+		return Move{}, fmt.Errorf("shogi: no piece to move for %s", s)
+	}
+	if p.Color != b.Turn {
+		return Move{}, fmt.Errorf("shogi: %s moves a piece of the player not on turn", s)
+	}
+	m.Piece = p
+
+	if captured, err := b.GetPieceAtSquare(m.Destination); err == nil {
+		if captured.Color == p.Color {
+			return Move{}, fmt.Errorf("shogi: %s captures own piece", s)
+		}
+		m.Type = Capture
+	}
+	return m, nil
+}
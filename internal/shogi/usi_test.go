@@ -0,0 +1,67 @@
+package shogi_test
+
+import (
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/shogi"
+)
+
+func TestParseUSIMove(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		usi     string
+		want    shogi.Move
+		wantErr bool
+	}{
+		{
+			name: "board move",
+			usi:  "7g7f",
+			want: shogi.Move{
+				Origin:      shogi.NewSquare(shogi.File(2), shogi.Rank(6)),
+				Destination: shogi.NewSquare(shogi.File(2), shogi.Rank(5)),
+			},
+		},
+		{
+			name: "promotion",
+			usi:  "8h2b+",
+			want: shogi.Move{
+				Origin:      shogi.NewSquare(shogi.File(1), shogi.Rank(7)),
+				Destination: shogi.NewSquare(shogi.File(7), shogi.Rank(1)),
+				IsPromoting: true,
+			},
+		},
+		{
+			name: "drop",
+			usi:  "P*5e",
+			want: shogi.Move{
+				Type:        shogi.Drop,
+				Piece:       shogi.Piece{Type: shogi.Pawn, Color: shogi.Black},
+				Destination: shogi.NewSquare(shogi.File(4), shogi.Rank(4)),
+			},
+		},
+		{name: "king drop fails", usi: "K*5e", wantErr: true},
+		{name: "bad rank fails", usi: "7j7f", wantErr: true},
+		{name: "bad suffix fails", usi: "7g7f=", wantErr: true},
+		{name: "too short fails", usi: "7g7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := shogi.ParseUSIMove(tt.usi)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseUSIMove() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseUSIMove() succeeded unexpectedly")
+			}
+			if got != tt.want {
+				t.Errorf("ParseUSIMove() = %+v, want %+v", got, tt.want)
+			}
+			if got.USI() != tt.usi {
+				t.Errorf("USI() = %s, want %s", got.USI(), tt.usi)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package shogi
 
-import "unicode"
+import (
+	"strings"
+	"unicode"
+)
 
 func abs(x int) int {
 	if x < 0 {
@@ -13,6 +16,9 @@ func sign(x int) int {
 	if x < 0 {
 		return -1
 	}
+	if x == 0 {
+		return 0
+	}
 	return 1
 }
 
@@ -40,7 +46,7 @@ func isPathClear(o, s Square, board Board, stepFile, stepRank int) bool {
 // forwardDirection returns the "forward" direction for a piece based on its letter color
 // Here we assume that uppercase pieces move "up" and lowercase pieces move "down"
 func forwardDirection(board Board, o Square) int {
-	pieceLetter := board.BitBoard[o]
+	pieceLetter := strings.TrimPrefix(board.BitBoard[o], "+")
 	if pieceLetter != "" && unicode.IsUpper(rune(pieceLetter[0])) {
 		return -1 // e.g. Sente: forward means upward (decreasing rank)
 	}
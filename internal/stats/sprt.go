@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// SPRTResult is the decision of a sequential probability ratio test.
+type SPRTResult int
+
+const (
+	// Continue, more games are needed to decide.
+	Continue SPRTResult = iota
+	// AcceptH0, the Elo difference is Elo0 or less.
+	AcceptH0
+	// AcceptH1, the Elo difference is Elo1 or more.
+	AcceptH1
+)
+
+func (r SPRTResult) String() string {
+	switch r {
+	case AcceptH0:
+		return "H0 accepted"
+	case AcceptH1:
+		return "H1 accepted"
+	}
+	return "continue"
+}
+
+// SPRT is a sequential probability ratio test between the hypotheses H0: the Elo difference is
+// Elo0, and H1: it is Elo1. Alpha is the probability of accepting H1 when H0 holds and Beta the
+// probability of accepting H0 when H1 holds. The test can be run after every game, or pair of games,
+// and stops the match as soon as one of the hypotheses is accepted.
+type SPRT struct {
+	Elo0  float64
+	Elo1  float64
+	Alpha float64
+	Beta  float64
+}
+
+// Validate checks the test parameters.
+func (s SPRT) Validate() error {
+	if s.Elo0 >= s.Elo1 {
+		return fmt.Errorf("stats: sprt elo0 %.1f must be lower than elo1 %.1f", s.Elo0, s.Elo1)
+	}
+	if s.Alpha <= 0 || s.Alpha >= 1 || s.Beta <= 0 || s.Beta >= 1 {
+		return fmt.Errorf("stats: sprt alpha and beta must be in (0, 1), got %v and %v", s.Alpha, s.Beta)
+	}
+	return nil
+}
+
+// Bounds returns the log likelihood ratios at which H0 and H1 are accepted.
+func (s SPRT) Bounds() (lower, upper float64) {
+	return math.Log(s.Beta / (1 - s.Alpha)), math.Log((1 - s.Beta) / s.Alpha)
+}
+
+// LLR returns the log likelihood ratio of the results, using the normal approximation of the
+// generalized SPRT with logistic Elo. It is 0 until the results show some variance, as a run of
+// identical results says nothing about how likely the other outcomes are.
+func (s SPRT) LLR(r Results) float64 {
+	mean, variance, n := r.sample()
+	if n == 0 || variance == 0 {
+		return 0
+	}
+	s0, s1 := ExpectedScore(s.Elo0), ExpectedScore(s.Elo1)
+	return float64(n) * (s1 - s0) * (2*mean - s0 - s1) / (2 * variance)
+}
+
+// Test returns the log likelihood ratio of the results and the decision it leads to.
+func (s SPRT) Test(r Results) (float64, SPRTResult) {
+	llr := s.LLR(r)
+	lower, upper := s.Bounds()
+	switch {
+	case llr >= upper:
+		return llr, AcceptH1
+	case llr <= lower:
+		return llr, AcceptH0
+	}
+	return llr, Continue
+}
+
+func (s SPRT) String() string {
+	lower, upper := s.Bounds()
+	return fmt.Sprintf("SPRT elo0 %.1f elo1 %.1f alpha %.2f beta %.2f, bounds (%.2f, %.2f)", s.Elo0, s.Elo1, s.Alpha, s.Beta, lower, upper)
+}
@@ -0,0 +1,153 @@
+// Package stats estimates the strength difference between two players from the results of a match.
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// confidence95 is the z score of a two sided 95% confidence interval.
+const confidence95 = 1.959963984540054
+
+// Results are the outcomes of a match from the point of view of one player.
+type Results interface {
+	// Games returns the number of games played.
+	Games() int
+	// sample returns the mean and variance of the score of a single observation, a game or a
+	// pair of games, scaled to [0, 1], and the number of observations.
+	sample() (mean, variance float64, n int)
+}
+
+// WLD are the wins, losses and draws of a player.
+type WLD struct {
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// Add counts a game scored 1 for a win, 0.5 for a draw and 0 for a loss.
+func (r *WLD) Add(score float64) {
+	switch {
+	case score >= 1:
+		r.Wins++
+	case score > 0:
+		r.Draws++
+	default:
+		r.Losses++
+	}
+}
+
+func (r WLD) Games() int {
+	return r.Wins + r.Losses + r.Draws
+}
+
+func (r WLD) sample() (float64, float64, int) {
+	n := r.Games()
+	if n == 0 {
+		return 0, 0, 0
+	}
+	w, l, d := float64(r.Wins)/float64(n), float64(r.Losses)/float64(n), float64(r.Draws)/float64(n)
+	mean := w + d/2
+	variance := w*math.Pow(1-mean, 2) + d*math.Pow(0.5-mean, 2) + l*math.Pow(mean, 2)
+	return mean, variance, n
+}
+
+func (r WLD) String() string {
+	return fmt.Sprintf("%d - %d - %d", r.Wins, r.Losses, r.Draws)
+}
+
+// Pentanomial counts pairs of games played from the same opening with swapped colours,
+// by the points scored in the pair: 0, 0.5, 1, 1.5 and 2.
+// Pairs remove most of the noise introduced by unbalanced openings.
+type Pentanomial [5]int
+
+// Add counts a pair of games, each scored 1 for a win, 0.5 for a draw and 0 for a loss.
+func (p *Pentanomial) Add(first, second float64) {
+	p[int(math.Round((first+second)*2))]++
+}
+
+// Pairs returns the number of pairs played.
+func (p Pentanomial) Pairs() int {
+	n := 0
+	for _, c := range p {
+		n += c
+	}
+	return n
+}
+
+func (p Pentanomial) Games() int {
+	return p.Pairs() * 2
+}
+
+func (p Pentanomial) sample() (float64, float64, int) {
+	n := p.Pairs()
+	if n == 0 {
+		return 0, 0, 0
+	}
+	mean := 0.0
+	for i, c := range p {
+		mean += float64(c) / float64(n) * float64(i) / 4
+	}
+	variance := 0.0
+	for i, c := range p {
+		variance += float64(c) / float64(n) * math.Pow(float64(i)/4-mean, 2)
+	}
+	return mean, variance, n
+}
+
+func (p Pentanomial) String() string {
+	return fmt.Sprintf("[%d, %d, %d, %d, %d]", p[0], p[1], p[2], p[3], p[4])
+}
+
+// Elo converts an expected score in [0, 1] to an Elo difference, using the logistic model.
+// A score of 0 or 1 is an infinite difference.
+func Elo(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
+
+// ExpectedScore converts an Elo difference to the expected score of the stronger player.
+func ExpectedScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// Estimate is the Elo difference measured by a match, with its 95% confidence interval.
+type Estimate struct {
+	Games int
+	Score float64
+	Elo   float64
+	// ErrorMargin is half the width of the 95% confidence interval of Elo.
+	ErrorMargin float64
+	// LOS is the likelihood of superiority, the probability the player is stronger.
+	LOS float64
+}
+
+// NewEstimate computes the Elo difference measured by the results.
+func NewEstimate(r Results) Estimate {
+	mean, variance, n := r.sample()
+	if n == 0 {
+		return Estimate{Score: 0.5, LOS: 0.5}
+	}
+	stderr := math.Sqrt(variance / float64(n))
+	e := Estimate{
+		Games: r.Games(),
+		Score: mean,
+		Elo:   Elo(mean),
+		LOS:   0.5,
+	}
+	e.ErrorMargin = (Elo(mean+confidence95*stderr) - Elo(mean-confidence95*stderr)) / 2
+	if stderr > 0 {
+		e.LOS = 0.5 * (1 + math.Erf((mean-0.5)/stderr/math.Sqrt2))
+	}
+	return e
+}
+
+// String formats the estimate, e.g. Elo: 88.7 +/- 42.9, LOS: 100.0%
+func (e Estimate) String() string {
+	return fmt.Sprintf("Elo: %.1f +/- %.1f, LOS: %.1f%%", e.Elo, e.ErrorMargin, e.LOS*100)
+}
@@ -0,0 +1,145 @@
+package stats_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/juanpablocruz/shogo/clientr/internal/stats"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestNewEstimate(t *testing.T) {
+	tests := []struct {
+		name         string // description of this test case
+		results      stats.Results
+		wantElo      float64
+		wantMargin   float64
+		wantLOS      float64
+		wantInfinite bool
+	}{
+		{
+			name:       "wins, losses and draws",
+			results:    stats.WLD{Wins: 100, Losses: 50, Draws: 50},
+			wantElo:    88.74,
+			wantMargin: 42.84,
+			wantLOS:    1,
+		},
+		{
+			name:    "even match",
+			results: stats.WLD{Wins: 30, Losses: 30, Draws: 40},
+			wantLOS: 0.5,
+		},
+		{
+			name:    "balanced pairs",
+			results: stats.Pentanomial{5, 20, 50, 20, 5},
+			wantLOS: 0.5,
+		},
+		{
+			name:         "only wins",
+			results:      stats.WLD{Wins: 10},
+			wantInfinite: true,
+			wantLOS:      0.5,
+		},
+		{
+			name:    "no games",
+			results: stats.WLD{},
+			wantLOS: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stats.NewEstimate(tt.results)
+			if tt.wantInfinite {
+				if !math.IsInf(got.Elo, 1) {
+					t.Errorf("NewEstimate() Elo = %v, want +Inf", got.Elo)
+				}
+				return
+			}
+			if !near(got.Elo, tt.wantElo, 0.01) {
+				t.Errorf("NewEstimate() Elo = %v, want %v", got.Elo, tt.wantElo)
+			}
+			if tt.wantMargin > 0 && !near(got.ErrorMargin, tt.wantMargin, 0.01) {
+				t.Errorf("NewEstimate() ErrorMargin = %v, want %v", got.ErrorMargin, tt.wantMargin)
+			}
+			if !near(got.LOS, tt.wantLOS, 0.001) {
+				t.Errorf("NewEstimate() LOS = %v, want %v", got.LOS, tt.wantLOS)
+			}
+		})
+	}
+}
+
+func TestElo_ExpectedScore(t *testing.T) {
+	for _, elo := range []float64{-300, -10, 0, 35.5, 400} {
+		if got := stats.Elo(stats.ExpectedScore(elo)); !near(got, elo, 1e-9) {
+			t.Errorf("Elo(ExpectedScore(%v)) = %v", elo, got)
+		}
+	}
+}
+
+func TestPentanomial_Add(t *testing.T) {
+	var p stats.Pentanomial
+	p.Add(1, 0)
+	p.Add(1, 0.5)
+	p.Add(0.5, 0.5)
+	p.Add(0, 0)
+	if want := (stats.Pentanomial{1, 0, 2, 1, 0}); p != want {
+		t.Errorf("Add() = %v, want %v", p, want)
+	}
+	if p.Games() != 8 {
+		t.Errorf("Games() = %d, want 8", p.Games())
+	}
+}
+
+func TestSPRT_Test(t *testing.T) {
+	sprt := stats.SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	tests := []struct {
+		name    string // description of this test case
+		results stats.Results
+		wantLLR float64
+		want    stats.SPRTResult
+	}{
+		{
+			name:    "not enough games",
+			results: stats.WLD{Wins: 100, Losses: 50, Draws: 50},
+			wantLLR: 1.97,
+			want:    stats.Continue,
+		},
+		{
+			name:    "clearly stronger",
+			results: stats.WLD{Wins: 1000, Losses: 500, Draws: 500},
+			want:    stats.AcceptH1,
+		},
+		{
+			name:    "clearly not stronger",
+			results: stats.Pentanomial{50, 200, 500, 150, 30},
+			want:    stats.AcceptH0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llr, got := sprt.Test(tt.results)
+			if got != tt.want {
+				t.Errorf("Test() = %s (llr %.2f), want %s", got, llr, tt.want)
+			}
+			if tt.wantLLR != 0 && !near(llr, tt.wantLLR, 0.01) {
+				t.Errorf("Test() llr = %v, want %v", llr, tt.wantLLR)
+			}
+		})
+	}
+}
+
+func TestSPRT_Validate(t *testing.T) {
+	if err := (stats.SPRT{Elo0: 5, Elo1: 0, Alpha: 0.05, Beta: 0.05}).Validate(); err == nil {
+		t.Errorf("Validate() succeeded unexpectedly with elo0 > elo1")
+	}
+	if err := (stats.SPRT{Elo0: 0, Elo1: 5, Alpha: 0, Beta: 0.05}).Validate(); err == nil {
+		t.Errorf("Validate() succeeded unexpectedly with alpha 0")
+	}
+	lower, upper := stats.SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}.Bounds()
+	if !near(lower, -2.94, 0.01) || !near(upper, 2.94, 0.01) {
+		t.Errorf("Bounds() = %v, %v", lower, upper)
+	}
+}